@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+type accessTokenResponse struct {
+	models.AccessToken
+	Token string `json:"token"`
+}
+
+// GetAccessTokens returns the personal [models.AccessToken]s of the user as JSON in the response body. The tokens
+// themselves are not included. It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) GetAccessTokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	tokens, err := s.AccessTokens.GetAccessTokens(user.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// CreateAccessToken creates a personal [models.AccessToken] from the [models.AccessTokenCreate] in the request body.
+// The response body contains the created access token including the token itself, which can't be retrieved again.
+// It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var create models.AccessTokenCreate
+	if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := create.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	accessToken, token, err := s.AccessTokens.CreateAccessToken(user.Id, *create.Name, create.Scopes, create.ExpiresAt)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(accessTokenResponse{AccessToken: accessToken, Token: token})
+}
+
+// DeleteAccessToken revokes the personal [models.AccessToken] with the ID specified as a request path value. It
+// expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) DeleteAccessToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	tokenId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "No access token id was given in the request path", http.StatusBadRequest)
+		return
+	}
+	if err := s.AccessTokens.DeleteAccessToken(user.Id, tokenId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
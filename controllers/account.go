@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+type profileExport struct {
+	Id       int         `json:"id"`
+	Name     string      `json:"name"`
+	Email    string      `json:"email,omitempty"`
+	Role     models.Role `json:"role"`
+	Timezone string      `json:"timezone"`
+}
+
+type accountUpdateRequest struct {
+	Timezone *string `json:"timezone"`
+}
+
+type shareExport struct {
+	TodoId int `json:"todoId"`
+	UserId int `json:"userId"`
+}
+
+type listShareExport struct {
+	ListId int `json:"listId"`
+	UserId int `json:"userId"`
+}
+
+// accountExport is everything stored about a user except secrets like password hashes and tokens.
+type accountExport struct {
+	ExportedAt time.Time     `json:"exportedAt"`
+	Profile    profileExport `json:"profile"`
+	Todos      []models.Todo `json:"todos"`
+	Tags       []models.Tag  `json:"tags"`
+	// SharesGiven are the shares of the user's todos with other users.
+	SharesGiven []shareExport `json:"sharesGiven"`
+	// SharesReceived are the todos of other users shared with the user, directly or through a list.
+	SharesReceived []models.Todo `json:"sharesReceived"`
+	Lists          []models.List `json:"lists"`
+	// ListSharesGiven are the shares of the user's lists with other users.
+	ListSharesGiven []listShareExport `json:"listSharesGiven"`
+	// Reminders are the reminders of the user's todos.
+	Reminders []models.Reminder `json:"reminders"`
+	// Dependencies are the dependencies of the user's todos on the todos they wait for.
+	Dependencies []models.Dependency `json:"dependencies"`
+	// ChecklistItems are the items of the checklists of the user's todos.
+	ChecklistItems []models.ChecklistItem `json:"checklistItems"`
+	Sessions       []models.Session       `json:"sessions"`
+	AccessTokens   []models.AccessToken   `json:"accessTokens"`
+}
+
+func newProfile(user models.User) profileExport {
+	return profileExport{Id: user.Id, Name: user.Name, Email: user.Email, Role: user.Role, Timezone: user.Timezone}
+}
+
+// exportAccount collects the data of the user with id userId from all stores.
+func (s *Server) exportAccount(userId int) (accountExport, error) {
+	export := accountExport{ExportedAt: time.Now().UTC(), SharesGiven: []shareExport{}, SharesReceived: []models.Todo{}}
+	user, err := s.Users.GetUser(userId)
+	if err != nil {
+		return export, err
+	}
+	export.Profile = newProfile(user)
+	todos, err := s.Todos.GetTodos(userId, models.TodoFilter{IncludeShared: true})
+	if err != nil {
+		return export, err
+	}
+	if export.Tags, err = s.Tags.GetTags(userId); err != nil {
+		return export, err
+	}
+	if export.Lists, err = s.Lists.GetLists(userId, false); err != nil {
+		return export, err
+	}
+	export.ListSharesGiven = []listShareExport{}
+	for _, list := range export.Lists {
+		shares, err := s.Lists.GetListShares(list.Id)
+		if err != nil {
+			return export, err
+		}
+		for _, shareUserId := range shares {
+			export.ListSharesGiven = append(export.ListSharesGiven, listShareExport{ListId: list.Id, UserId: shareUserId})
+		}
+	}
+	export.Todos = []models.Todo{}
+	export.Reminders = []models.Reminder{}
+	export.Dependencies = []models.Dependency{}
+	export.ChecklistItems = []models.ChecklistItem{}
+	for _, todo := range todos {
+		if todo.UserId != userId {
+			export.SharesReceived = append(export.SharesReceived, todo)
+			continue
+		}
+		export.Todos = append(export.Todos, todo)
+		shares, err := s.Todos.GetTodoShares(todo.Id)
+		if err != nil {
+			return export, err
+		}
+		for _, shareUserId := range shares {
+			export.SharesGiven = append(export.SharesGiven, shareExport{TodoId: todo.Id, UserId: shareUserId})
+		}
+		reminders, err := s.Reminders.GetReminders(todo.Id)
+		if err != nil {
+			return export, err
+		}
+		export.Reminders = append(export.Reminders, reminders...)
+		dependencies, err := s.Dependencies.GetDependencies(todo.Id)
+		if err != nil {
+			return export, err
+		}
+		for _, dependency := range dependencies {
+			if dependency.TodoId == todo.Id {
+				export.Dependencies = append(export.Dependencies, dependency)
+			}
+		}
+		items, err := s.Checklists.GetChecklistItems(todo.Id)
+		if err != nil {
+			return export, err
+		}
+		export.ChecklistItems = append(export.ChecklistItems, items...)
+	}
+	if export.Sessions, err = s.Sessions.GetSessions(userId); err != nil {
+		return export, err
+	}
+	export.AccessTokens, err = s.AccessTokens.GetAccessTokens(userId)
+	return export, err
+}
+
+// ExportAccount returns all data of the user as a JSON file to download: the profile, the todos, the tags, the lists
+// and their shares, the shares of the todos with other users, the todos shared with the user, the reminders, the
+// dependencies, the checklist items, the sessions and the personal access tokens. Password hashes, tokens and second factors are left out.
+// It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	export, err := s.exportAccount(user.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	filename := fmt.Sprintf("todo-export-%d-%s.json", user.Id, export.ExportedAt.Format("20060102"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(export)
+}
+
+// UpdateAccount changes the settings of the user given in the request body, which is currently only the IANA
+// timezone like "Europe/Berlin" that dates are interpreted in. The profile of the user is returned as JSON in the
+// response body. An unknown timezone responds with an HTTP status code 400 (Bad Request). It expects the request to be
+// authorized by [middlewares.AuthenticateUser].
+func (s *Server) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var req accountUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			http.Error(w, "Unknown timezone, it has to be an IANA name like Europe/Berlin.", http.StatusBadRequest)
+			return
+		}
+		if err := s.Users.SetUserTimezone(user.Id, *req.Timezone); err != nil {
+			respondUserError(w, err)
+			return
+		}
+	}
+	profile, err := s.Users.GetUser(user.Id)
+	if err != nil {
+		respondUserError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newProfile(profile))
+}
+
+// DeleteAccount revokes all sessions of the user and deletes the user together with its todos, the shares of its
+// todos, the shares with it, its tokens and its second factors. Admins have to be demoted by another admin first, so
+// that the last admin can't delete itself. It expects the request to be authorized by
+// [middlewares.AuthenticateUser].
+func (s *Server) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if user.Role == models.RoleAdmin {
+		http.Error(w, "Admins can't delete their account, another admin has to make them a member first.", http.StatusForbidden)
+		return
+	}
+	if _, err := s.Sessions.DeleteAllSessions(user.Id); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if err := s.Users.DeleteUser(user.Id); err != nil {
+		respondUserError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
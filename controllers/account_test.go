@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"todo/models"
+)
+
+func TestServer_ExportAccount(t *testing.T) {
+	s, store := newTestServer()
+	john, _ := store.CreateUser(models.User{Name: "john", Email: "john@example.com"})
+	jane, _ := store.CreateUser(models.User{Name: "jane"})
+	own, _ := store.CreateTodo(models.Todo{Title: "own", Text: "text", UserId: john.Id})
+	store.CreateTodoShare(own.Id, jane.Id)
+	shared, _ := store.CreateTodo(models.Todo{Title: "shared", Text: "text", UserId: jane.Id})
+	store.CreateTodoShare(shared.Id, john.Id)
+	store.LoginUser(john, models.Client{UserAgent: "curl"})
+
+	w := httptest.NewRecorder()
+	s.ExportAccount(w, newUserRequest(http.MethodGet, "/me/export", "", john))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;") {
+		t.Fatalf("The export returned status %d with the headers %v", w.Code, w.Header())
+	}
+	var export accountExport
+	if err := json.NewDecoder(w.Body).Decode(&export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Profile != (profileExport{Id: john.Id, Name: "john", Email: "john@example.com", Role: models.RoleMember, Timezone: "UTC"}) {
+		t.Fatalf("Unexpected profile %+v", export.Profile)
+	}
+	if len(export.Todos) != 1 || !reflect.DeepEqual(export.Todos[0], own) {
+		t.Fatalf("Unexpected todos %+v", export.Todos)
+	}
+	if len(export.SharesGiven) != 1 || export.SharesGiven[0] != (shareExport{TodoId: own.Id, UserId: jane.Id}) {
+		t.Fatalf("Unexpected given shares %+v", export.SharesGiven)
+	}
+	if len(export.SharesReceived) != 1 || !reflect.DeepEqual(export.SharesReceived[0], shared) {
+		t.Fatalf("Unexpected received shares %+v", export.SharesReceived)
+	}
+	if len(export.Sessions) != 1 || export.Sessions[0].UserAgent != "curl" {
+		t.Fatalf("Unexpected sessions %+v", export.Sessions)
+	}
+}
+
+func TestServer_DeleteAccount(t *testing.T) {
+	s, store := newTestServer()
+	john := models.User{Name: "john"}
+	john.SetPassword("doe")
+	john, _ = store.CreateUser(john)
+	jane, _ := store.CreateUser(models.User{Name: "jane"})
+	admin, _ := store.CreateUser(models.User{Name: "admin", Role: models.RoleAdmin})
+	own, _ := store.CreateTodo(models.Todo{Title: "own", Text: "text", UserId: john.Id})
+	store.CreateTodoShare(own.Id, jane.Id)
+	shared, _ := store.CreateTodo(models.Todo{Title: "shared", Text: "text", UserId: jane.Id})
+	store.CreateTodoShare(shared.Id, john.Id)
+	tokens, _ := store.LoginUser(john, models.Client{})
+
+	w := httptest.NewRecorder()
+	s.DeleteAccount(w, newUserRequest(http.MethodDelete, "/me", "", admin))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("An admin deleting the account returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.DeleteAccount(w, newUserRequest(http.MethodDelete, "/me", "", john))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Deleting the account failed with status %d", w.Code)
+	}
+	if _, _, err := store.AuthenticateUser(tokens.AccessToken); err == nil {
+		t.Fatalf("The session of the deleted user is still valid")
+	}
+	if _, err := store.GetTodo(own.Id); err == nil {
+		t.Fatalf("The todo of the deleted user still exists")
+	}
+	if shares, _ := store.GetTodoShares(shared.Id); len(shares) != 0 {
+		t.Fatalf("The share with the deleted user still exists: %v", shares)
+	}
+	if todos, _ := store.GetTodos(jane.Id, models.TodoFilter{IncludeShared: true}); len(todos) != 1 || todos[0].Id != shared.Id {
+		t.Fatalf("The todos of the other user changed: %+v", todos)
+	}
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+type setRoleRequest struct {
+	Role models.Role `json:"role"`
+}
+
+// userIdFromPath returns the user id in the request path. If it is missing or is the id of the admin making the
+// request while self is false, it responds with an HTTP status code 400 (Bad Request) and returns false.
+func userIdFromPath(w http.ResponseWriter, r *http.Request, self bool) (int, bool) {
+	userId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "No user id was given in the request path", http.StatusBadRequest)
+		return 0, false
+	}
+	if admin, ok := r.Context().Value(middlewares.ContextUserKey).(models.User); ok && !self && admin.Id == userId {
+		http.Error(w, "Admins can't apply this to themselves.", http.StatusBadRequest)
+		return 0, false
+	}
+	return userId, true
+}
+
+// respondUserError responds with an HTTP status code 404 (Not Found) if err is [sql.ErrNoRows] and with an HTTP status
+// code 500 (Internal Server Error) otherwise.
+func respondUserError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	logger.Error(err.Error())
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// AdminGetUsers returns all [models.User]s including their roles and whether they are disabled as JSON in the response
+// body. It expects the request to be authorized by [middlewares.RequireRole] with [models.RoleAdmin], like all
+// handlers in this file.
+func (s *Server) AdminGetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.Users.GetUsers()
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// DisableUser disables the user with the id in the request path and revokes all of its sessions. Disabled users can't
+// log in and their personal access tokens are rejected, but their data is kept. Admins can't disable themselves.
+func (s *Server) DisableUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := userIdFromPath(w, r, false)
+	if !ok {
+		return
+	}
+	if err := s.Users.SetUserDisabled(userId, true); err != nil {
+		respondUserError(w, err)
+		return
+	}
+	if _, err := s.Sessions.DeleteAllSessions(userId); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EnableUser re-enables the disabled user with the id in the request path.
+func (s *Server) EnableUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := userIdFromPath(w, r, true)
+	if !ok {
+		return
+	}
+	if err := s.Users.SetUserDisabled(userId, false); err != nil {
+		respondUserError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetUserRole sets the role of the user with the id in the request path to the role in the request body. The sessions
+// of the user are revoked, so that no token carries the previous role. Admins can't change their own role, which
+// ensures that there is always an admin left.
+func (s *Server) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	userId, ok := userIdFromPath(w, r, false)
+	if !ok {
+		return
+	}
+	var request setRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !slices.Contains(models.Roles, request.Role) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := s.Users.SetUserRole(userId, request.Role); err != nil {
+		respondUserError(w, err)
+		return
+	}
+	if _, err := s.Sessions.DeleteAllSessions(userId); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteUser revokes all sessions of the user with the id in the request path and deletes the user together with its
+// todos, shares, tokens and second factors. Admins can't delete themselves.
+func (s *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := userIdFromPath(w, r, false)
+	if !ok {
+		return
+	}
+	if _, err := s.Sessions.DeleteAllSessions(userId); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if err := s.Users.DeleteUser(userId); err != nil {
+		respondUserError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminGetTodo returns the [models.Todo] with the id in the request path regardless of who owns it.
+func (s *Server) AdminGetTodo(w http.ResponseWriter, r *http.Request) {
+	todo, err := s.getTodoFromPathId(r, w)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todo)
+}
+
+// UnlockUser forgets the failed logins of the user with the id in the request path, so that a locked account can log
+// in again right away. Failures from the client IP addresses are kept. If the user does not exist, it responds with an
+// HTTP status code 404 (Not Found).
+func (s *Server) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := userIdFromPath(w, r, true)
+	if !ok {
+		return
+	}
+	if err := s.LoginAttempts.UnlockUser(userId); err != nil {
+		respondUserError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"todo/models"
+)
+
+func TestServer_AdminUsers(t *testing.T) {
+	s, store := newTestServer()
+	admin, _ := store.CreateUser(models.User{Name: "admin", Role: models.RoleAdmin})
+	user := models.User{Name: "john"}
+	user.SetPassword("doe")
+	user, _ = store.CreateUser(user)
+	tokens, _ := store.LoginUser(user, models.Client{})
+
+	w := httptest.NewRecorder()
+	s.DisableUser(w, newPathRequest(http.MethodPost, "/admin/users/"+strconv.Itoa(user.Id)+"/disable", user.Id, "", admin))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Disabling the user failed with status %d", w.Code)
+	}
+	if _, _, err := store.AuthenticateUser(tokens.AccessToken); err == nil {
+		t.Fatalf("The session of the disabled user is still valid")
+	}
+	w = httptest.NewRecorder()
+	s.LoginUser(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"name": "john", "password": "doe"}`)))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("The login of a disabled user returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.EnableUser(w, newPathRequest(http.MethodPost, "/admin/users/"+strconv.Itoa(user.Id)+"/enable", user.Id, "", admin))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Enabling the user failed with status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.SetUserRole(w, newPathRequest(http.MethodPut, "/admin/users/"+strconv.Itoa(user.Id)+"/role",
+		user.Id, `{"role": "owner"}`, admin))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Setting an unknown role returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.SetUserRole(w, newPathRequest(http.MethodPut, "/admin/users/"+strconv.Itoa(user.Id)+"/role",
+		user.Id, `{"role": "admin"}`, admin))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Setting the role failed with status %d", w.Code)
+	}
+	if stored, _ := store.GetUser(user.Id); stored.Role != models.RoleAdmin || stored.Disabled {
+		t.Fatalf("Unexpected stored user %+v", stored)
+	}
+
+	for name, handler := range map[string]http.HandlerFunc{"disable": s.DisableUser, "role": s.SetUserRole, "delete": s.DeleteUser} {
+		w = httptest.NewRecorder()
+		handler(w, newPathRequest(http.MethodPost, "/admin/users/"+strconv.Itoa(admin.Id),
+			admin.Id, `{"role": "member"}`, admin))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s applied to the admin itself returned status %d", name, w.Code)
+		}
+	}
+
+	todo, _ := store.CreateTodo(models.Todo{Title: "title", Text: "text", UserId: user.Id})
+	w = httptest.NewRecorder()
+	s.AdminGetTodo(w, newTodoRequest(http.MethodGet, todo.Id, "", admin))
+	var fetched models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&fetched); err != nil || fetched.Id != todo.Id {
+		t.Fatalf("The admin could not view the todo of another user: %d, %+v (%v)", w.Code, fetched, err)
+	}
+
+	w = httptest.NewRecorder()
+	s.DeleteUser(w, newPathRequest(http.MethodDelete, "/admin/users/"+strconv.Itoa(user.Id), user.Id, "", admin))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Deleting the user failed with status %d", w.Code)
+	}
+	if _, err := store.GetTodo(todo.Id); err == nil {
+		t.Fatalf("The todo of the deleted user still exists")
+	}
+	w = httptest.NewRecorder()
+	s.DeleteUser(w, newPathRequest(http.MethodDelete, "/admin/users/"+strconv.Itoa(user.Id), user.Id, "", admin))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Deleting an unknown user returned status %d", w.Code)
+	}
+}
@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+	"todo/db"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+// getChecklistItemFromPathId returns the checklist item with the id in the request path and its todo if the todo
+// belongs to user. Otherwise it responds with an HTTP status code 404 (Not Found) or 403 (Forbidden) and returns false.
+func (s *Server) getChecklistItemFromPathId(w http.ResponseWriter, r *http.Request, user models.User) (models.ChecklistItem, models.Todo, bool) {
+	var todo models.Todo
+	itemId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "No checklist item id was given in the request path", http.StatusBadRequest)
+		return models.ChecklistItem{}, todo, false
+	}
+	item, err := s.Checklists.GetChecklistItem(itemId)
+	if err == nil {
+		todo, err = s.Todos.GetTodo(item.TodoId)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return item, todo, false
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return item, todo, false
+	}
+	if todo.UserId != user.Id {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return item, todo, false
+	}
+	return item, todo, true
+}
+
+// autoCompleteTodo completes the todo with id todoId if it has [models.Todo.ChecklistAutoComplete] set and all items
+// of its checklist are checked. The todo is saved like by [Server.UpdateTodo], so its subtasks, recurrence and
+// reminders are handled the same way. It stays open if its workflow, open blockers or open subtasks don't allow it to
+// be completed, as the change of the checklist is valid on its own, and the reason is logged as a warning.
+func (s *Server) autoCompleteTodo(todoId int) error {
+	todo, err := s.Todos.GetTodo(todoId)
+	if err != nil {
+		return err
+	}
+	if !todo.ChecklistAutoComplete || todo.IsDone || !todo.Checklist.Complete() {
+		return nil
+	}
+	before := todo
+	todo.SetStatus(models.StatusDone, time.Now())
+	err = s.saveTodo(todo, before, s.SubtaskPolicy, false)
+	if errors.Is(err, models.ErrStatusTransition) || errors.Is(err, errOpenBlockers) || errors.Is(err, errOpenSubtasks) {
+		logger.Warning(fmt.Sprintf("todo %d with a checked checklist is not completed: %v", todoId, err))
+		return nil
+	}
+	return err
+}
+
+// GetChecklist returns the [models.ChecklistItem]s of the todo with the id in the request path in their order as JSON
+// in the response body. The checklist can be read by everyone the todo is shared with, but only the owner can change
+// it. It expects the request to be authorized by [middlewares.AuthenticateUser], like all handlers in this file.
+func (s *Server) GetChecklist(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	todo, err := s.getTodoFromPathId(r, w)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	canRead, err := s.canReadTodo(user, todo)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !canRead {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	items, err := s.Checklists.GetChecklistItems(todo.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// CreateChecklistItem appends a [models.ChecklistItem] with the "text" and optionally "checked" in the request body to
+// the checklist of the todo with the id in the request path. The item is returned as JSON in the response body with
+// the status code 201 (Created).
+func (s *Server) CreateChecklistItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	todo, ok := s.getOwnTodoFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	var update models.ChecklistItemUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	item := models.ChecklistItem{TodoId: todo.Id}
+	if err := item.Update(update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item, err := s.Checklists.CreateChecklistItem(item)
+	if err == nil {
+		err = s.autoCompleteTodo(todo.Id)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+// UpdateChecklistItem changes the "text" or checks and unchecks the [models.ChecklistItem] with the id in the request
+// path as given in the request body. Checking the last open item completes a todo with
+// [models.Todo.ChecklistAutoComplete] set. The changed item is returned as JSON in the response body.
+func (s *Server) UpdateChecklistItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	item, todo, ok := s.getChecklistItemFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	var update models.ChecklistItemUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := item.Update(update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err := s.Checklists.UpdateChecklistItem(item)
+	if err == nil {
+		err = s.autoCompleteTodo(todo.Id)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// MoveChecklistItem moves the [models.ChecklistItem] with the id in the request path to right before or right after
+// another item of the same checklist given by its id in the field "before" or "after" of the request body, like
+// [Server.MoveTodo] does for todos, renumbering the items if there is no position left between the neighbours. The
+// moved item is returned as JSON in the response body.
+func (s *Server) MoveChecklistItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	item, _, ok := s.getChecklistItemFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	var move models.Move
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := move.Validate(item.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	items, err := s.Checklists.GetChecklistItems(item.TodoId)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	items = slices.DeleteFunc(items, func(other models.ChecklistItem) bool { return other.Id == item.Id })
+	i := slices.IndexFunc(items, func(other models.ChecklistItem) bool { return other.Id == move.TargetId() })
+	if i < 0 {
+		http.Error(w, "The checklist item to move next to has to be another item of the same checklist", http.StatusBadRequest)
+		return
+	}
+	if move.After != nil {
+		i++
+	}
+	positions := make([]string, len(items))
+	for j, other := range items {
+		positions[j] = other.Position
+	}
+	var renumbered []string
+	item.Position, renumbered = place(positions, i, item.Position)
+	changed := []models.ChecklistItem{item}
+	for j, position := range renumbered {
+		items[j].Position = position
+		changed = append(changed, items[j])
+	}
+	err = s.Checklists.UpdateChecklistItems(changed)
+	if errors.Is(err, db.ErrPositionTaken) {
+		http.Error(w, "Another checklist item was moved to this position at the same time, try again",
+			http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// DeleteChecklistItem removes the [models.ChecklistItem] with the id in the request path from its checklist. Removing
+// the last open item completes a todo with [models.Todo.ChecklistAutoComplete] set, like checking it.
+func (s *Server) DeleteChecklistItem(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	item, todo, ok := s.getChecklistItemFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	err := s.Checklists.DeleteChecklistItem(item.Id)
+	if err == nil {
+		err = s.autoCompleteTodo(todo.Id)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"todo/models"
+)
+
+func TestServer_Checklist(t *testing.T) {
+	s, store := newTestServer()
+	owner, _ := store.CreateUser(models.User{Name: "john"})
+	other, _ := store.CreateUser(models.User{Name: "jane"})
+	todo, _ := store.CreateTodo(models.Todo{Title: "Pack for the trip", UserId: owner.Id})
+	var items []models.ChecklistItem
+	for _, text := range []string{" passport ", "charger", "tickets"} {
+		w := httptest.NewRecorder()
+		s.CreateChecklistItem(w, newTodoRequest(http.MethodPost, todo.Id, `{"text": "`+text+`"}`, owner))
+		var item models.ChecklistItem
+		if err := json.NewDecoder(w.Body).Decode(&item); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("Adding a checklist item returned status %d (%v)", w.Code, err)
+		}
+		items = append(items, item)
+	}
+	passport, charger, tickets := items[0], items[1], items[2]
+	if passport.Text != "passport" || passport.TodoId != todo.Id {
+		t.Fatalf("Unexpected checklist item %+v", passport)
+	}
+	for body, status := range map[string]int{`{"text": " "}`: http.StatusBadRequest, `{`: http.StatusBadRequest} {
+		w := httptest.NewRecorder()
+		s.CreateChecklistItem(w, newTodoRequest(http.MethodPost, todo.Id, body, owner))
+		if w.Code != status {
+			t.Errorf("Adding the item %s returned status %d, want %d", body, w.Code, status)
+		}
+	}
+	w := httptest.NewRecorder()
+	s.CreateChecklistItem(w, newTodoRequest(http.MethodPost, todo.Id, `{"text": "theirs"}`, other))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Adding an item to the todo of another user returned status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.MoveChecklistItem(w, newPathRequest(http.MethodPost, "/checklist-items/"+strconv.Itoa(tickets.Id),
+		tickets.Id, `{"before": `+strconv.Itoa(passport.Id)+`}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Moving a checklist item returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.UpdateChecklistItem(w, newPathRequest(http.MethodPatch, "/checklist-items/"+strconv.Itoa(charger.Id),
+		charger.Id, `{"text": "cable", "checked": true}`, owner))
+	if err := json.NewDecoder(w.Body).Decode(&charger); err != nil || !charger.Checked || charger.Text != "cable" {
+		t.Fatalf("Checking a checklist item returned %+v with status %d (%v)", charger, w.Code, err)
+	}
+	w = httptest.NewRecorder()
+	s.UpdateChecklistItem(w, newPathRequest(http.MethodPatch, "/checklist-items/"+strconv.Itoa(charger.Id),
+		charger.Id, `{"checked": true}`, other))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Checking an item of another user returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.GetChecklist(w, newTodoRequest(http.MethodGet, todo.Id, "", owner))
+	var checklist []models.ChecklistItem
+	json.NewDecoder(w.Body).Decode(&checklist)
+	var ids []int
+	for _, item := range checklist {
+		ids = append(ids, item.Id)
+	}
+	if !slices.Equal(ids, []int{tickets.Id, passport.Id, charger.Id}) {
+		t.Fatalf("The checklist items are in the order %v", ids)
+	}
+	w = httptest.NewRecorder()
+	s.GetTodo(w, newTodoRequest(http.MethodGet, todo.Id, "", owner))
+	json.NewDecoder(w.Body).Decode(&todo)
+	if todo.Checklist != (models.ChecklistProgress{Checked: 1, Total: 3}) {
+		t.Fatalf("The todo has the checklist progress %+v", todo.Checklist)
+	}
+
+	w = httptest.NewRecorder()
+	s.DeleteChecklistItem(w, newPathRequest(http.MethodDelete, "/checklist-items/"+strconv.Itoa(passport.Id),
+		passport.Id, "", owner))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Removing a checklist item returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.DeleteChecklistItem(w, newPathRequest(http.MethodDelete, "/checklist-items/"+strconv.Itoa(passport.Id),
+		passport.Id, "", owner))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Removing a removed checklist item returned status %d", w.Code)
+	}
+	if stored, _ := store.GetTodo(todo.Id); stored.Checklist != (models.ChecklistProgress{Checked: 1, Total: 2}) {
+		t.Fatalf("The todo has the checklist progress %+v after removing an item", stored.Checklist)
+	}
+}
+
+func TestServer_ChecklistAutoComplete(t *testing.T) {
+	s, store := newTestServer()
+	owner, _ := store.CreateUser(models.User{Name: "john"})
+	todo, _ := store.CreateTodo(models.Todo{Title: "Pack for the trip", UserId: owner.Id})
+	blocker, _ := store.CreateTodo(models.Todo{Title: "Book the hotel", UserId: owner.Id})
+	first, _ := store.CreateChecklistItem(models.ChecklistItem{TodoId: todo.Id, Text: "passport"})
+	second, _ := store.CreateChecklistItem(models.ChecklistItem{TodoId: todo.Id, Text: "tickets"})
+	check := func(item models.ChecklistItem) models.Todo {
+		w := httptest.NewRecorder()
+		s.UpdateChecklistItem(w, newPathRequest(http.MethodPatch, "/checklist-items/"+strconv.Itoa(item.Id),
+			item.Id, `{"checked": true}`, owner))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Checking a checklist item returned status %d", w.Code)
+		}
+		stored, _ := store.GetTodo(todo.Id)
+		return stored
+	}
+	if stored := check(first); stored.IsDone {
+		t.Fatalf("A todo without auto-completion was completed with its checklist")
+	}
+	w := httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, `{"checklistAutoComplete": true}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Enabling auto-completion returned status %d", w.Code)
+	}
+	store.CreateDependency(models.Dependency{TodoId: todo.Id, BlockerId: blocker.Id})
+	if stored := check(second); stored.IsDone {
+		t.Fatalf("A todo with an open blocker was completed with its checklist")
+	}
+	store.DeleteDependency(models.Dependency{TodoId: todo.Id, BlockerId: blocker.Id})
+	if stored := check(second); !stored.IsDone || stored.Status != models.StatusDone || stored.CompletedAt == nil {
+		t.Fatalf("The todo was not completed with its checklist: %+v", stored)
+	}
+
+	checked, _ := store.CreateTodo(models.Todo{Title: "Pack the bag", UserId: owner.Id})
+	store.CreateChecklistItem(models.ChecklistItem{TodoId: checked.Id, Text: "passport", Checked: true})
+	w = httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, checked.Id, `{"checklistAutoComplete": true}`, owner))
+	var updated models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil || !updated.IsDone || updated.Status != models.StatusDone {
+		t.Fatalf("Enabling auto-completion for a checked checklist returned %+v (status %d, %v)", updated, w.Code, err)
+	}
+}
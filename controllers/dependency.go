@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"todo/db"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+var (
+	errUnknownBlocker  = errors.New("the todo of a dependency has to be another todo you can access")
+	errForeignBlocked  = errors.New("only your own todos can be blocked")
+	errDependencyCycle = errors.New("the todo would wait for itself")
+	errDependencyTaken = errors.New("the dependency exists already")
+	errOpenBlockers    = errors.New("the todo is blocked by open todos, complete them first or force it")
+)
+
+// respondDependencyError responds with the HTTP status code matching err.
+func respondDependencyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrDependencyChange), errors.Is(err, errUnknownBlocker), errors.Is(err, errDependencyCycle):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errForeignBlocked):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, errDependencyTaken), errors.Is(err, errOpenBlockers):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// dependencyFromRequest returns the [models.DependencyChange] in the request body of the todo with the id in the
+// request path, which has to belong to user, and the [models.Dependency] it gives. Otherwise it responds with an error
+// status code and returns false.
+func (s *Server) dependencyFromRequest(w http.ResponseWriter, r *http.Request, user models.User) (models.DependencyChange, models.Dependency, bool) {
+	todo, ok := s.getOwnTodoFromPathId(w, r, user)
+	if !ok {
+		return models.DependencyChange{}, models.Dependency{}, false
+	}
+	var change models.DependencyChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return change, models.Dependency{}, false
+	}
+	if err := change.Validate(todo.Id); err != nil {
+		respondDependencyError(w, err)
+		return change, models.Dependency{}, false
+	}
+	return change, change.Dependency(todo.Id), true
+}
+
+// checkOtherTodo returns [errUnknownBlocker] unless the other todo of change is readable by user and
+// [errForeignBlocked] if it is the one that is blocked and does not belong to user.
+func (s *Server) checkOtherTodo(user models.User, change models.DependencyChange) error {
+	other, err := s.Todos.GetTodo(change.OtherId())
+	canRead := false
+	if err == nil {
+		canRead, err = s.canReadTodo(user, other)
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !canRead) {
+		return errUnknownBlocker
+	}
+	if err == nil && change.Blocks != nil && other.UserId != user.Id {
+		return errForeignBlocked
+	}
+	return err
+}
+
+// checkBlockers returns [errOpenBlockers] if todo waits for todos that are not done.
+func (s *Server) checkBlockers(todo models.Todo) error {
+	dependencies, err := s.Dependencies.GetDependencies(todo.Id)
+	if err != nil {
+		return err
+	}
+	for _, dependency := range dependencies {
+		if dependency.TodoId != todo.Id {
+			continue
+		}
+		blocker, err := s.Todos.GetTodo(dependency.BlockerId)
+		if err != nil {
+			return err
+		}
+		if !blocker.IsDone {
+			return errOpenBlockers
+		}
+	}
+	return nil
+}
+
+// GetTodoDependencies returns the todos the todo with the id in the request path is blocked by and the todos it blocks
+// as [models.Dependencies] in JSON in the response body. Only the todos the user can read are included. It expects the
+// request to be authorized by [middlewares.AuthenticateUser], like all handlers in this file.
+func (s *Server) GetTodoDependencies(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	todo, err := s.getTodoFromPathId(r, w)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	canRead, err := s.canReadTodo(user, todo)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !canRead {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	dependencies, err := s.Dependencies.GetDependencies(todo.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	result := models.Dependencies{BlockedBy: []models.Todo{}, Blocks: []models.Todo{}}
+	for _, dependency := range dependencies {
+		list, otherId := &result.BlockedBy, dependency.BlockerId
+		if dependency.BlockerId == todo.Id {
+			list, otherId = &result.Blocks, dependency.TodoId
+		}
+		other, err := s.Todos.GetTodo(otherId)
+		if err == nil {
+			canRead, err = s.canReadTodo(user, other)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if canRead {
+			*list = append(*list, other)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// CreateTodoDependency makes the todo with the id in the request path wait for the todo "blockedBy" in the request body
+// or makes the todo "blocks" wait for it. The todo in the path has to belong to the user and the other todo has to be
+// readable by the user, a todo "blocks" has to belong to the user as well. Dependencies that would make a todo wait for
+// itself are rejected. The dependency is returned as JSON in the response body with the status code 201 (Created).
+func (s *Server) CreateTodoDependency(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	change, dependency, ok := s.dependencyFromRequest(w, r, user)
+	if !ok {
+		return
+	}
+	if err := s.checkOtherTodo(user, change); err != nil {
+		respondDependencyError(w, err)
+		return
+	}
+	err := s.Dependencies.CreateDependency(dependency)
+	switch {
+	case errors.Is(err, db.ErrDependencyExists):
+		err = errDependencyTaken
+	case errors.Is(err, db.ErrDependencyCycle):
+		err = errDependencyCycle
+	}
+	if err != nil {
+		respondDependencyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dependency)
+}
+
+// DeleteTodoDependency removes the dependency given like for [Server.CreateTodoDependency] in the request body. Only
+// the todo in the path has to belong to the user, so that dependencies on todos that are no longer shared with the
+// user can still be removed.
+func (s *Server) DeleteTodoDependency(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	_, dependency, ok := s.dependencyFromRequest(w, r, user)
+	if !ok {
+		return
+	}
+	err := s.Dependencies.DeleteDependency(dependency)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondDependencyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"todo/models"
+)
+
+func TestServer_TodoDependencies(t *testing.T) {
+	s, store := newTestServer()
+	owner, _ := store.CreateUser(models.User{Name: "john"})
+	other, _ := store.CreateUser(models.User{Name: "jane"})
+	design, _ := store.CreateTodo(models.Todo{Title: "design", UserId: owner.Id})
+	build, _ := store.CreateTodo(models.Todo{Title: "build", UserId: owner.Id})
+	release, _ := store.CreateTodo(models.Todo{Title: "release", UserId: owner.Id})
+	review, _ := store.CreateTodo(models.Todo{Title: "review", UserId: other.Id})
+	secret, _ := store.CreateTodo(models.Todo{Title: "secret", UserId: other.Id})
+	store.CreateTodoShare(review.Id, owner.Id)
+
+	change := func(method string, todoId int, body string, user models.User) int {
+		w := httptest.NewRecorder()
+		r := newTodoRequest(method, todoId, body, user)
+		if method == http.MethodPost {
+			s.CreateTodoDependency(w, r)
+		} else {
+			s.DeleteTodoDependency(w, r)
+		}
+		return w.Code
+	}
+	id := strconv.Itoa
+	for _, dependency := range []struct {
+		todoId int
+		body   string
+	}{
+		{build.Id, `{"blockedBy": ` + id(design.Id) + `}`},
+		{build.Id, `{"blocks": ` + id(release.Id) + `}`},
+		{release.Id, `{"blockedBy": ` + id(review.Id) + `}`},
+	} {
+		if status := change(http.MethodPost, dependency.todoId, dependency.body, owner); status != http.StatusCreated {
+			t.Fatalf("Creating the dependency %s returned status %d", dependency.body, status)
+		}
+	}
+	for body, status := range map[string]int{
+		`{}`:                                    http.StatusBadRequest,
+		`{"blockedBy": ` + id(release.Id) + `}`: http.StatusBadRequest,
+		`{"blocks": ` + id(release.Id) + `, "blockedBy": ` + id(build.Id) + `}`: http.StatusBadRequest,
+		`{"blockedBy": ` + id(secret.Id) + `}`:                                  http.StatusBadRequest,
+		`{"blockedBy": 1000}`:                                                   http.StatusBadRequest,
+		`{"blocks": ` + id(design.Id) + `}`:                                     http.StatusBadRequest,
+		`{"blockedBy": ` + id(build.Id) + `}`:                                   http.StatusConflict,
+	} {
+		if code := change(http.MethodPost, release.Id, body, owner); code != status {
+			t.Errorf("Creating the dependency %s returned status %d, want %d", body, code, status)
+		}
+	}
+	if status := change(http.MethodPost, review.Id, `{"blockedBy": `+id(design.Id)+`}`, owner); status != http.StatusForbidden {
+		t.Fatalf("A dependency was added to the todo of another user (status %d)", status)
+	}
+	// john can read the shared todo of jane, but cannot make it wait for his todo
+	if status := change(http.MethodPost, design.Id, `{"blocks": `+id(review.Id)+`}`, owner); status != http.StatusForbidden {
+		t.Fatalf("The recipient of a share blocked the shared todo (status %d)", status)
+	}
+
+	w := httptest.NewRecorder()
+	s.GetTodoDependencies(w, newTodoRequest(http.MethodGet, build.Id, "", owner))
+	var dependencies models.Dependencies
+	if err := json.NewDecoder(w.Body).Decode(&dependencies); err != nil || len(dependencies.BlockedBy) != 1 ||
+		dependencies.BlockedBy[0].Id != design.Id || len(dependencies.Blocks) != 1 || dependencies.Blocks[0].Id != release.Id {
+		t.Fatalf("Unexpected dependencies %+v (status %d, %v)", dependencies, w.Code, err)
+	}
+	// jane only sees the dependencies on todos she can read
+	w = httptest.NewRecorder()
+	s.GetTodoDependencies(w, newTodoRequest(http.MethodGet, review.Id, "", other))
+	if err := json.NewDecoder(w.Body).Decode(&dependencies); err != nil || len(dependencies.BlockedBy) != 0 || len(dependencies.Blocks) != 0 {
+		t.Fatalf("Unexpected dependencies of the shared todo %+v (status %d, %v)", dependencies, w.Code, err)
+	}
+
+	ids := func() []int {
+		w := httptest.NewRecorder()
+		s.GetTodos(w, newUserRequest(http.MethodGet, "/todos?actionable", "", owner))
+		var todos []models.Todo
+		json.NewDecoder(w.Body).Decode(&todos)
+		var ids []int
+		for _, todo := range todos {
+			ids = append(ids, todo.Id)
+		}
+		return ids
+	}
+	if got := ids(); !slices.Equal(got, []int{design.Id}) {
+		t.Fatalf("Unexpected actionable todos %v", got)
+	}
+	update := func(todoId int, query string) int {
+		w := httptest.NewRecorder()
+		r := newTodoRequest(http.MethodPatch, todoId, `{"isDone": true}`, owner)
+		r.URL.RawQuery = query
+		s.UpdateTodo(w, r)
+		return w.Code
+	}
+	if status := update(build.Id, ""); status != http.StatusConflict {
+		t.Fatalf("Completing a blocked todo returned status %d", status)
+	}
+	if status := update(design.Id, ""); status != http.StatusOK {
+		t.Fatalf("Completing the blocker returned status %d", status)
+	}
+	if got := ids(); !slices.Equal(got, []int{design.Id, build.Id}) {
+		t.Fatalf("Unexpected actionable todos after completing the blocker %v", got)
+	}
+	if status := update(build.Id, ""); status != http.StatusOK {
+		t.Fatalf("Completing the todo after its blocker returned status %d", status)
+	}
+	if status := update(release.Id, ""); status != http.StatusConflict {
+		t.Fatalf("Completing a todo blocked by a shared todo returned status %d", status)
+	}
+	if status := update(release.Id, "force"); status != http.StatusOK {
+		t.Fatalf("Forcing the completion of a blocked todo returned status %d", status)
+	}
+
+	// the dependency on the shared todo can still be removed after the share was revoked
+	store.DeleteTodoShare(review.Id, owner.Id)
+	if status := change(http.MethodDelete, release.Id, `{"blockedBy": `+id(review.Id)+`}`, owner); status != http.StatusNoContent {
+		t.Fatalf("Deleting the dependency returned status %d", status)
+	}
+	if status := change(http.MethodDelete, release.Id, `{"blockedBy": `+id(review.Id)+`}`, owner); status != http.StatusNotFound {
+		t.Fatalf("Deleting a missing dependency returned status %d", status)
+	}
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+type ListShare struct {
+	Id     int  `json:"id"`
+	ListId *int `json:"listId"`
+	UserId *int `json:"userId"`
+}
+
+// errUnknownList is returned by [Server.ownList] for lists that don't exist or belong to another user.
+var errUnknownList = errors.New("unknown list")
+
+// ownList returns [errUnknownList] if the list with id listId is not a list of the user with id userId.
+func (s *Server) ownList(userId int, listId int) error {
+	list, err := s.Lists.GetList(listId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && list.UserId != userId) {
+		return fmt.Errorf("%w %d", errUnknownList, listId)
+	}
+	return err
+}
+
+// respondListError responds with an HTTP status code 400 (Bad Request) for unknown lists and 500 (Internal Server
+// Error) otherwise.
+func respondListError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnknownList) {
+		http.Error(w, "The list does not exist.", http.StatusBadRequest)
+		return
+	}
+	logger.Error(err.Error())
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// getListFromPathId returns the list with the id in the request path if the user owns it or, unless write is set, if
+// it is shared with the user. Otherwise it responds with an HTTP status code 404 (Not Found) or 403 (Forbidden) and
+// returns false.
+func (s *Server) getListFromPathId(w http.ResponseWriter, r *http.Request, user models.User, write bool) (models.List, bool) {
+	listId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "No list id was given in the request path", http.StatusBadRequest)
+		return models.List{}, false
+	}
+	list, err := s.Lists.GetList(listId)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return list, false
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return list, false
+	}
+	if list.UserId == user.Id {
+		return list, true
+	}
+	if !write {
+		shares, err := s.Lists.GetListShares(list.Id)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return list, false
+		}
+		if slices.Contains(shares, user.Id) {
+			return list, true
+		}
+	}
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	return list, false
+}
+
+// GetLists returns the [models.List]s of the user as JSON in the response body. The query parameter "shared" includes
+// the lists shared with the user. It expects the request to be authorized by [middlewares.AuthenticateUser], like all
+// handlers in this file.
+func (s *Server) GetLists(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	lists, err := s.Lists.GetLists(user.Id, r.URL.Query().Has("shared"))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lists)
+}
+
+// GetList returns the [models.List] with the id in the request path as JSON in the response body if the user owns it
+// or it is shared with the user.
+func (s *Server) GetList(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	list, ok := s.getListFromPathId(w, r, user, false)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetListTodos returns the [models.Todo]s in the list with the id in the request path as JSON in the response body if
+// the user owns the list or it is shared with the user. The todos are filtered by the query parameters of
+// [Server.GetTodos] except "shared".
+func (s *Server) GetListTodos(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	list, ok := s.getListFromPathId(w, r, user, false)
+	if !ok {
+		return
+	}
+	filter := models.TodoFilter{ListId: &list.Id}
+	if !s.parseTodoFilter(w, r.URL.Query(), user, &filter) {
+		return
+	}
+	todos, err := s.Todos.GetTodos(list.UserId, filter)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todos)
+}
+
+// CreateList creates a [models.List] with the name in the request body and returns it as JSON in the response body.
+func (s *Server) CreateList(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var update models.ListUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	list := models.List{UserId: user.Id}
+	if err := list.Update(update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	list, err := s.Lists.CreateList(list)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(list)
+}
+
+// UpdateList renames the [models.List] with the id in the request path or changes its [models.Workflow] and returns it
+// as JSON in the response body. Only the owner of the list can change it.
+func (s *Server) UpdateList(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	list, ok := s.getListFromPathId(w, r, user, true)
+	if !ok {
+		return
+	}
+	var update models.ListUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := list.Update(update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Lists.UpdateList(list); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// DeleteList deletes the [models.List] with the id in the request path and its shares. Its todos are kept without a
+// list. Only the owner of the list can delete it.
+func (s *Server) DeleteList(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	list, ok := s.getListFromPathId(w, r, user, true)
+	if !ok {
+		return
+	}
+	if err := s.Lists.DeleteList(list.Id); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ShareList shares a [models.List] and with it all of its todos with another [models.User] than its owner, like
+// [Server.ShareTodo] does for a single todo.
+func (s *Server) ShareList(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	list, ok := s.getListFromPathId(w, r, user, true)
+	if !ok {
+		return
+	}
+	var listShare ListShare
+	if err := json.NewDecoder(r.Body).Decode(&listShare); err != nil || listShare.UserId == nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	listShare.ListId = &list.Id
+	if list.UserId == *listShare.UserId {
+		http.Error(w, "You are trying to share your own list with yourself.", http.StatusBadRequest)
+		return
+	}
+	shareId, err := s.Lists.CreateListShare(list.Id, *listShare.UserId)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	listShare.Id = shareId
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listShare)
+}
+
+// UnshareList removes a [models.User] from the users a [models.List] is shared with.
+func (s *Server) UnshareList(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	list, ok := s.getListFromPathId(w, r, user, true)
+	if !ok {
+		return
+	}
+	var listShare ListShare
+	if err := json.NewDecoder(r.Body).Decode(&listShare); err != nil || listShare.UserId == nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	listShare.ListId = &list.Id
+	shareId, err := s.Lists.DeleteListShare(list.Id, *listShare.UserId)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	listShare.Id = shareId
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listShare)
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"todo/models"
+)
+
+func TestServer_CreateList(t *testing.T) {
+	s, store := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	w := httptest.NewRecorder()
+	s.CreateList(w, newUserRequest(http.MethodPost, "/lists", `{"name": " work "}`, owner))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Creating a list failed with status %d", w.Code)
+	}
+	var list models.List
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, models.List{Id: list.Id, UserId: owner.Id, Name: "work"}) {
+		t.Fatalf("The created list does not match the request: %+v", list)
+	}
+	w = httptest.NewRecorder()
+	s.CreateList(w, newUserRequest(http.MethodPost, "/lists", `{"name": ""}`, owner))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Creating a list without name returned status %d", w.Code)
+	}
+
+	foreign, _ := store.CreateList(models.List{UserId: 2, Name: "secret"})
+	w = httptest.NewRecorder()
+	s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", `{"title": "a", "listId": `+strconv.Itoa(foreign.Id)+`}`, owner))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Creating a todo in the list of another user returned status %d", w.Code)
+	}
+	todo, _ := store.CreateTodo(models.Todo{Title: "a", UserId: owner.Id})
+	w = httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, `{"listId": `+strconv.Itoa(list.Id)+`}`, owner))
+	if stored, _ := store.GetTodo(todo.Id); w.Code != http.StatusOK || stored.ListId == nil || *stored.ListId != list.Id {
+		t.Fatalf("Moving the todo to the list returned status %d: %+v", w.Code, stored)
+	}
+	w = httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, `{"listId": null}`, owner))
+	if stored, _ := store.GetTodo(todo.Id); w.Code != http.StatusOK || stored.ListId != nil {
+		t.Fatalf("Taking the todo out of the list returned status %d: %+v", w.Code, stored)
+	}
+}
+
+func TestServer_ShareList(t *testing.T) {
+	s, store := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	other := models.User{Id: 2, Name: "jane"}
+	list, _ := store.CreateList(models.List{UserId: owner.Id, Name: "work"})
+	todo, _ := store.CreateTodo(models.Todo{Title: "a", UserId: owner.Id, ListId: &list.Id})
+	store.CreateTodo(models.Todo{Title: "b", UserId: owner.Id})
+
+	w := httptest.NewRecorder()
+	s.GetListTodos(w, newPathRequest(http.MethodGet, "/lists/1/todos", list.Id, "", other))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("The todos of a list that is not shared were accessible (status %d)", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.ShareList(w, newPathRequest(http.MethodPost, "/lists/1/share", list.Id, `{"userId": 2}`, other))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Another user was able to share the list (status %d)", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.ShareList(w, newPathRequest(http.MethodPost, "/lists/1/share", list.Id, `{"userId": 2}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Sharing the list failed with status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.GetListTodos(w, newPathRequest(http.MethodGet, "/lists/1/todos", list.Id, "", other))
+	var todos []models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todos); err != nil || len(todos) != 1 || todos[0].Id != todo.Id {
+		t.Fatalf("Unexpected todos of the shared list %+v (status %d, %v)", todos, w.Code, err)
+	}
+	w = httptest.NewRecorder()
+	s.GetTodo(w, newTodoRequest(http.MethodGet, todo.Id, "", other))
+	if w.Code != http.StatusOK {
+		t.Fatalf("A todo in a shared list was not accessible (status %d)", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.UpdateList(w, newPathRequest(http.MethodPatch, "/lists/1", list.Id, `{"name": "mine"}`, other))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Another user was able to rename the shared list (status %d)", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.UnshareList(w, newPathRequest(http.MethodDelete, "/lists/1/share", list.Id, `{"userId": 2}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Revoking the list share failed with status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.GetTodo(w, newTodoRequest(http.MethodGet, todo.Id, "", other))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("A todo was still accessible after revoking the share of its list (status %d)", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.DeleteList(w, newPathRequest(http.MethodDelete, "/lists/1", list.Id, "", owner))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Deleting the list failed with status %d", w.Code)
+	}
+	if stored, err := store.GetTodo(todo.Id); err != nil || stored.ListId != nil {
+		t.Fatalf("The todo of the deleted list was not kept without a list: %+v (%v)", stored, err)
+	}
+}
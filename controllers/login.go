@@ -2,19 +2,97 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 	"todo/db"
 	"todo/logger"
 	"todo/models"
 )
 
 type loginResponse struct {
-	Token string `json:"token"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	RefreshToken     string    `json:"refreshToken"`
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+}
+
+func newLoginResponse(tokens models.SessionTokens) loginResponse {
+	return loginResponse{
+		Token:            tokens.AccessToken,
+		ExpiresAt:        tokens.ExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	}
+}
+
+// challengeResponse is returned instead of a [loginResponse] if the login has to be completed with a second factor.
+type challengeResponse struct {
+	Challenge string    `json:"challenge"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// LoginThrottle configures how failed logins slow down guessing passwords. Failures are counted per user name and per
+// client IP address, also for names no user exists with.
+type LoginThrottle struct {
+	// BaseDelay is how long logins for a user name are blocked after the first failure. The delay doubles with every
+	// further failure.
+	BaseDelay time.Duration
+	// MaxFailures is the number of failures after which a user name is locked for LockoutDuration.
+	MaxFailures int
+	// MaxClientFailures is the number of failures after which a client IP address is blocked for LockoutDuration.
+	// There is no delay before, as several users may share an address.
+	MaxClientFailures int
+	// LockoutDuration is the longest time logins are blocked. Failures are forgotten once it passed without another
+	// failure.
+	LockoutDuration time.Duration
+}
+
+// DefaultLoginThrottle is the [LoginThrottle] of a [Server] unless it is configured otherwise.
+var DefaultLoginThrottle = LoginThrottle{
+	BaseDelay:         time.Second,
+	MaxFailures:       5,
+	MaxClientFailures: 50,
+	LockoutDuration:   15 * time.Minute,
+}
+
+// blockedUntil returns the time until which logins with the failures of a user name and a client IP address are
+// blocked.
+func (t LoginThrottle) blockedUntil(account models.LoginFailures, client models.LoginFailures) time.Time {
+	var until time.Time
+	if account.Count >= t.MaxFailures {
+		until = account.LastFailureAt.Add(t.LockoutDuration)
+	} else if account.Count > 0 {
+		delay := t.BaseDelay
+		for i := 1; i < account.Count && delay < t.LockoutDuration; i++ {
+			delay *= 2
+		}
+		until = account.LastFailureAt.Add(min(delay, t.LockoutDuration))
+	}
+	if client.Count >= t.MaxClientFailures {
+		if clientUntil := client.LastFailureAt.Add(t.LockoutDuration); clientUntil.After(until) {
+			until = clientUntil
+		}
+	}
+	return until
+}
+
+type refreshRequest struct {
+	RefreshToken *string `json:"refreshToken"`
 }
 
 // LoginUser uses a username and a password provided in the request body to authenticate a user.
-// It returns a JSON object that contains a token in the response body.
-func LoginUser(w http.ResponseWriter, r *http.Request) {
+// It returns a JSON object that contains an access token, a refresh token and their expiry times in the response body.
+// If the user enabled a second factor, the JSON object only contains a challenge and its expiry time instead, which
+// has to be completed with [Server.CompleteLogin]; the failed logins of the name are only reset once it is.
+// Failed logins block further logins for the same user name and from the same client IP address as configured by
+// [Server.LoginThrottle]. While they are blocked, it responds with an HTTP status code 429 (Too Many Requests) and a
+// Retry-After header. Unknown users and wrong passwords both respond with an HTTP status code 401 (Unauthorized).
+// Disabled users respond with an HTTP status code 403 (Forbidden).
+func (s *Server) LoginUser(w http.ResponseWriter, r *http.Request) {
 	var userLogin models.UserLogin
 	if err := json.NewDecoder(r.Body).Decode(&userLogin); err != nil {
 		logger.Error(err.Error())
@@ -27,11 +105,76 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
-	token, err := db.LoginUser(user)
+	client := clientFromRequest(r)
+	// the attempt is counted as failed before the password is verified, so that concurrent guesses are throttled like
+	// consecutive ones, and taken back unless the password turns out to be wrong
+	account, clientFailures, err := s.LoginAttempts.ReserveLoginAttempt(user.Name, client.IP, s.LoginThrottle.LockoutDuration)
 	if err != nil {
 		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	release := func() {
+		if err := s.LoginAttempts.ReleaseLoginAttempt(user.Name, client.IP); err != nil {
+			logger.Error(err.Error())
+		}
+	}
+	if until := s.LoginThrottle.blockedUntil(account, clientFailures); time.Now().Before(until) {
+		release()
+		retryAfter := math.Ceil(time.Until(until).Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+		http.Error(w, "Too many failed logins, try again later.", http.StatusTooManyRequests)
+		return
+	}
+	tokens, err := s.Users.LoginUser(user, client)
+	if errors.Is(err, db.ErrInvalidCredentials) {
+		logger.Warning(fmt.Sprintf("failed login for %q from %s (%d failures for the name, %d for the address)",
+			user.Name, client.IP, account.Count+1, clientFailures.Count+1))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	release()
+	if errors.Is(err, db.ErrUserDisabled) {
+		http.Error(w, "The account is disabled.", http.StatusForbidden)
+		return
+	}
+	if err != nil && !errors.Is(err, db.ErrSecondFactorRequired) {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if tokens.Challenge != "" {
+		// the failures of the user name are only forgotten once the second factor was entered as well
+		json.NewEncoder(w).Encode(challengeResponse{Challenge: tokens.Challenge, ExpiresAt: tokens.ChallengeExpiresAt})
+		return
+	}
+	// the password was correct, so the failures of the user name are forgotten
+	if err := s.LoginAttempts.ResetLoginFailures(user.Name); err != nil {
+		logger.Error(err.Error())
+	}
+	json.NewEncoder(w).Encode(newLoginResponse(tokens))
+}
+
+// RefreshToken exchanges the refresh token provided in the request body for a new access token and a new refresh
+// token of the same session. The response body has the same format as the one of [Server.LoginUser]. Every refresh
+// token can only be used once, using it again revokes the session.
+func (s *Server) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var refresh refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&refresh); err != nil || refresh.RefreshToken == nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	tokens, err := s.Sessions.RefreshSession(*refresh.RefreshToken)
+	if err != nil {
+		if errors.Is(err, db.ErrRefreshTokenReused) {
+			logger.Warning(err.Error())
+		} else {
+			logger.Error(err.Error())
+		}
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
-	json.NewEncoder(w).Encode(loginResponse{Token: token})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newLoginResponse(tokens))
 }
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"todo/db"
+	"todo/models"
+)
+
+func TestLoginThrottle_blockedUntil(t *testing.T) {
+	throttle := LoginThrottle{BaseDelay: time.Second, MaxFailures: 5, MaxClientFailures: 10, LockoutDuration: time.Minute}
+	last := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		account int
+		client  int
+		want    time.Duration
+	}{
+		{0, 0, -1},
+		{1, 1, time.Second},
+		{3, 3, 4 * time.Second},
+		{4, 9, 8 * time.Second},
+		{5, 0, time.Minute},
+		{0, 9, -1},
+		{0, 10, time.Minute},
+	}
+	for _, test := range tests {
+		account := models.LoginFailures{Count: test.account, LastFailureAt: last}
+		client := models.LoginFailures{Count: test.client, LastFailureAt: last}
+		want := last.Add(test.want)
+		if test.want < 0 {
+			want = time.Time{}
+		}
+		if got := throttle.blockedUntil(account, client); !got.Equal(want) {
+			t.Errorf("%d failures for the name and %d for the address block until %v, want %v", test.account, test.client, got, want)
+		}
+	}
+	throttle.MaxFailures = 100
+	account := models.LoginFailures{Count: 80, LastFailureAt: last}
+	if got := throttle.blockedUntil(account, models.LoginFailures{}); !got.Equal(last.Add(time.Minute)) {
+		t.Errorf("The delay is not capped at the lockout duration: %v", got)
+	}
+}
+
+func TestServer_LoginUserThrottled(t *testing.T) {
+	s, store := newTestServer()
+	s.LoginThrottle = LoginThrottle{BaseDelay: time.Hour, MaxFailures: 3, MaxClientFailures: 100, LockoutDuration: time.Hour}
+	user := models.User{Name: "john"}
+	user.SetPassword("doe")
+	user, _ = store.CreateUser(user)
+	login := func(name string, password string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := `{"name": "` + name + `", "password": "` + password + `"}`
+		s.LoginUser(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body)))
+		return w
+	}
+
+	for _, name := range []string{"john", "unknown"} {
+		if w := login(name, "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("A failed login for %s returned status %d", name, w.Code)
+		}
+		w := login(name, "doe")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("A login for %s right after a failure returned status %d", name, w.Code)
+		}
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != "3600" {
+			t.Fatalf("Unexpected Retry-After %q for %s", retryAfter, name)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/admin/users/1/unlock", nil)
+	r.SetPathValue("id", "1")
+	s.UnlockUser(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Unlocking the user failed with status %d", w.Code)
+	}
+	if w := login("john", "doe"); w.Code != http.StatusOK {
+		t.Fatalf("The login after unlocking returned status %d", w.Code)
+	}
+	if account, _, _ := store.GetLoginFailures("john", ""); account.Count != 0 {
+		t.Fatalf("The failures were not reset after a successful login: %+v", account)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/admin/users/99/unlock", nil)
+	r.SetPathValue("id", "99")
+	s.UnlockUser(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Unlocking an unknown user returned status %d", w.Code)
+	}
+}
+
+// slowLogins verifies passwords slowly, so that parallel logins overlap.
+type slowLogins struct {
+	db.UserStore
+}
+
+func (s slowLogins) LoginUser(user models.User, client models.Client) (models.SessionTokens, error) {
+	time.Sleep(20 * time.Millisecond)
+	return s.UserStore.LoginUser(user, client)
+}
+
+func TestServer_LoginUserParallel(t *testing.T) {
+	s, store := newTestServer()
+	s.Users = slowLogins{store}
+	s.LoginThrottle = LoginThrottle{BaseDelay: time.Hour, MaxFailures: 3, MaxClientFailures: 100, LockoutDuration: time.Hour}
+	user := models.User{Name: "john"}
+	user.SetPassword("doe")
+	store.CreateUser(user)
+	codes := make([]int, 10)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			s.LoginUser(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"name": "john", "password": "wrong"}`)))
+			codes[i] = w.Code
+		}()
+	}
+	wg.Wait()
+	verified := 0
+	for _, code := range codes {
+		if code == http.StatusUnauthorized {
+			verified++
+		} else if code != http.StatusTooManyRequests {
+			t.Fatalf("A parallel login returned status %d", code)
+		}
+	}
+	if verified != 1 {
+		t.Fatalf("%d of the parallel guesses were verified instead of one", verified)
+	}
+	if account, _, _ := store.GetLoginFailures("john", ""); account.Count != 1 {
+		t.Fatalf("The blocked guesses were counted as failures: %+v", account)
+	}
+}
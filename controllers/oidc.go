@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"todo/db"
+	"todo/logger"
+	"todo/oidc"
+)
+
+// oidcCookieName is the cookie the [oidc.AuthRequest] of a login attempt is kept in until the provider redirects back.
+const oidcCookieName = "oidc_login"
+
+// oidcLoginTimeout is how long a user has to log in at the provider, in seconds.
+const oidcLoginTimeout = 10 * 60
+
+func encodeAuthRequest(req oidc.AuthRequest) string {
+	return req.State + "." + req.Nonce + "." + req.Verifier
+}
+
+func decodeAuthRequest(value string) (oidc.AuthRequest, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return oidc.AuthRequest{}, false
+	}
+	return oidc.AuthRequest{State: parts[0], Nonce: parts[1], Verifier: parts[2]}, true
+}
+
+// OIDCLogin starts a login at the identity provider. It keeps the state, nonce and PKCE verifier of the login attempt
+// in a cookie and redirects to the authorization endpoint of the provider, which redirects back to
+// [Server.OIDCCallback].
+func (s *Server) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if s.OIDC == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    encodeAuthRequest(req),
+		Path:     "/oidc",
+		MaxAge:   oidcLoginTimeout,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, s.OIDC.AuthCodeURL(req), http.StatusFound)
+}
+
+// OIDCCallback completes a login started by [Server.OIDCLogin]. It checks the state against the cookie, exchanges the
+// authorization code for an ID token and logs in the user linked to the subject of the token, creating the user on the
+// first login. The response body has the same format as the one of [Server.LoginUser].
+// If the state does not match or the provider reports an error, it responds with an HTTP status code 400
+// (Bad Request). If the code can't be exchanged or the ID token is invalid, it responds with 401 (Unauthorized).
+func (s *Server) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if s.OIDC == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	cookie, err := r.Cookie(oidcCookieName)
+	if err != nil {
+		http.Error(w, "The login was not started or timed out.", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcCookieName, Path: "/oidc", MaxAge: -1, HttpOnly: true})
+	req, ok := decodeAuthRequest(cookie.Value)
+	query := r.URL.Query()
+	if !ok || subtle.ConstantTimeCompare([]byte(req.State), []byte(query.Get("state"))) != 1 {
+		http.Error(w, "The state does not match the login.", http.StatusBadRequest)
+		return
+	}
+	if providerError := query.Get("error"); providerError != "" {
+		logger.Warning("OIDC login failed: " + providerError + " " + query.Get("error_description"))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	token, err := s.OIDC.Exchange(r.Context(), query.Get("code"), req)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	tokens, err := s.Users.LoginExternalUser(token.Identity(), clientFromRequest(r))
+	if errors.Is(err, db.ErrUserDisabled) {
+		http.Error(w, "The account is disabled.", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newLoginResponse(tokens))
+}
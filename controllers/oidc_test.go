@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"todo/oidc"
+	"todo/oidc/oidctest"
+)
+
+func newOIDCTestServer(t *testing.T) (*Server, *oidctest.Provider) {
+	mock := oidctest.NewProvider("todo", "secret")
+	t.Cleanup(mock.Close)
+	config := oidc.Config{
+		Issuer:       mock.Issuer(),
+		ClientId:     "todo",
+		ClientSecret: "secret",
+		RedirectURL:  "http://example.com/oidc/callback",
+	}
+	provider, err := oidc.NewProvider(context.Background(), config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer()
+	s.OIDC = provider
+	return s, mock
+}
+
+// startOIDCLogin starts a login and follows the redirect to the mock provider. It returns the login cookie and the
+// callback URL the provider redirected back to.
+func startOIDCLogin(t *testing.T, s *Server) (*http.Cookie, string) {
+	w := httptest.NewRecorder()
+	s.OIDCLogin(w, httptest.NewRequest(http.MethodGet, "/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect to the provider but got status %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcCookieName || !cookies[0].HttpOnly {
+		t.Fatalf("Unexpected cookies %v", cookies)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return cookies[0], resp.Header.Get("Location")
+}
+
+func oidcCallback(s *Server, cookie *http.Cookie, callback string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, callback, nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	s.OIDCCallback(w, r)
+	return w
+}
+
+func TestServer_OIDCCallback(t *testing.T) {
+	s, mock := newOIDCTestServer(t)
+	mock.SetUser("1234", "alice")
+	login := func() int {
+		cookie, callback := startOIDCLogin(t, s)
+		w := oidcCallback(s, cookie, callback)
+		if w.Code != http.StatusOK {
+			t.Fatalf("The callback failed with status %d: %s", w.Code, w.Body)
+		}
+		var response loginResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		user, _, err := s.Users.AuthenticateUser(response.Token)
+		if err != nil || user.Name != "alice" {
+			t.Fatalf("The token does not authenticate alice: %+v (%v)", user, err)
+		}
+		return user.Id
+	}
+	first := login()
+	if second := login(); second != first {
+		t.Fatalf("The second login created another user %d instead of reusing %d", second, first)
+	}
+}
+
+func TestServer_OIDCCallback_invalid(t *testing.T) {
+	s, _ := newOIDCTestServer(t)
+	cookie, callback := startOIDCLogin(t, s)
+	if w := oidcCallback(s, nil, callback); w.Code != http.StatusBadRequest {
+		t.Fatalf("A callback without cookie returned status %d", w.Code)
+	}
+	otherCookie, _ := startOIDCLogin(t, s)
+	if w := oidcCallback(s, otherCookie, callback); w.Code != http.StatusBadRequest {
+		t.Fatalf("A callback with the state of another login returned status %d", w.Code)
+	}
+	if w := oidcCallback(s, cookie, callback+"&error=access_denied"); w.Code != http.StatusBadRequest {
+		t.Fatalf("A callback with an error returned status %d", w.Code)
+	}
+	if w := oidcCallback(s, cookie, "/oidc/callback?code=unknown&state="+callbackState(callback)); w.Code != http.StatusUnauthorized {
+		t.Fatalf("A callback with an unknown code returned status %d", w.Code)
+	}
+}
+
+func callbackState(callback string) string {
+	r := httptest.NewRequest(http.MethodGet, callback, nil)
+	return r.URL.Query().Get("state")
+}
+
+func TestServer_OIDCLogin_disabled(t *testing.T) {
+	s, _ := newTestServer()
+	w := httptest.NewRecorder()
+	s.OIDCLogin(w, httptest.NewRequest(http.MethodGet, "/oidc/login", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d without a provider but got %d", http.StatusNotFound, w.Code)
+	}
+}
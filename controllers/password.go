@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"todo/db"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+	"todo/notify"
+)
+
+type passwordChangeRequest struct {
+	CurrentPassword *string `json:"currentPassword"`
+	NewPassword     *string `json:"newPassword"`
+}
+
+type passwordResetRequest struct {
+	Name *string `json:"name"`
+}
+
+type passwordResetConfirmRequest struct {
+	Token    *string `json:"token"`
+	Password *string `json:"password"`
+}
+
+// ChangePassword sets the password of the user to the new password in the request body if the current password in
+// it is correct. All other sessions of the user are revoked, their number is returned as JSON in the response body.
+// If the current password is wrong, it responds with an HTTP status code 403 (Forbidden), if the new password violates
+// [Server.PasswordPolicy] with an HTTP status code 400 (Bad Request) and the violated rule.
+// It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	current, ok := r.Context().Value(middlewares.ContextSessionKey).(models.Session)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var req passwordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CurrentPassword == nil || req.NewPassword == nil || *req.NewPassword == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := s.PasswordPolicy.Check(user.Name, *req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Passwords.ChangePassword(user.Id, *req.CurrentPassword, *req.NewPassword); err != nil {
+		if errors.Is(err, db.ErrWrongPassword) {
+			http.Error(w, "The current password is wrong.", http.StatusForbidden)
+			return
+		}
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	count, err := s.Sessions.DeleteOtherSessions(user.Id, current.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deleteSessionsResponse{Revoked: count})
+}
+
+// RequestPasswordReset sends a single-use token to reset the password to the email address of the user named in the
+// request body. It always responds with an HTTP status code 202 (Accepted) before the user is looked up, and the
+// token is created and sent in the background, so that neither the response nor its timing tell which users exist or
+// have an email address.
+func (s *Server) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	// the request context ends with the response, but sending the message must not be cancelled by that
+	ctx := context.WithoutCancel(r.Context())
+	s.passwordResets.Add(1)
+	go func() {
+		defer s.passwordResets.Done()
+		s.sendPasswordReset(ctx, *req.Name)
+	}()
+}
+
+// sendPasswordReset creates a password reset token for the user called name and sends it to the email address of the
+// user. Unknown users and users without an email address are only logged.
+func (s *Server) sendPasswordReset(ctx context.Context, name string) {
+	user, token, err := s.Passwords.CreatePasswordReset(name)
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Warning(fmt.Sprintf("password reset requested for unknown user %q", name))
+		return
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if user.Email == "" {
+		logger.Warning(fmt.Sprintf("password reset requested for user %d without email address", user.Id))
+		return
+	}
+	if err := s.Notifier.Notify(ctx, s.passwordResetMessage(user, token)); err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+func (s *Server) passwordResetMessage(user models.User, token string) notify.Message {
+	minutes := int(db.PasswordResetLifetime.Minutes())
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hello %s,\n\nsomeone asked to reset the password of your account. ", user.Name)
+	if s.PasswordResetURL != "" {
+		separator := "?"
+		if strings.Contains(s.PasswordResetURL, "?") {
+			separator = "&"
+		}
+		fmt.Fprintf(&body, "Open the following link within %d minutes to choose a new password:\n\n%s%stoken=%s\n",
+			minutes, s.PasswordResetURL, separator, url.QueryEscape(token))
+	} else {
+		fmt.Fprintf(&body, "Use the following token within %d minutes to choose a new password:\n\n%s\n",
+			minutes, token)
+	}
+	body.WriteString("\nIf you did not ask for it, you can ignore this message.\n")
+	return notify.Message{To: user.Email, Subject: "Reset your password", Body: body.String()}
+}
+
+// ResetPassword sets the password of the user a reset token was sent to. The request body contains the token and the
+// new password. All sessions of the user are revoked. If the token is invalid, expired or already used or the new
+// password violates [Server.PasswordPolicy] for the user the token was sent to, it responds with an HTTP status code
+// 400 (Bad Request). A token is only used up by a successful reset.
+func (s *Server) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == nil || req.Password == nil || *req.Password == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	// the user is looked up without using the token, so that it stays valid if the password is rejected
+	user, err := s.Passwords.GetPasswordReset(*req.Token)
+	if err == nil {
+		if err := s.PasswordPolicy.Check(user.Name, *req.Password); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, err = s.Passwords.ResetPassword(*req.Token, *req.Password)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "The reset token is invalid or expired.", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.Sessions.DeleteAllSessions(user.Id); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"todo/middlewares"
+	"todo/models"
+	"todo/notify"
+	"todo/notify/smtptest"
+	"todo/password"
+)
+
+func TestServer_ChangePassword(t *testing.T) {
+	s, store := newTestServer()
+	user := models.User{Name: "john"}
+	user.SetPassword("doe")
+	user, _ = store.CreateUser(user)
+	current, _ := store.LoginUser(user, models.Client{})
+	other, _ := store.LoginUser(user, models.Client{})
+	_, session, err := store.AuthenticateUser(current.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func(body string) *http.Request {
+		r := newUserRequest(http.MethodPost, "/me/password", body, user)
+		return r.WithContext(context.WithValue(r.Context(), middlewares.ContextSessionKey, session))
+	}
+
+	w := httptest.NewRecorder()
+	s.ChangePassword(w, newRequest(`{"currentPassword": "wrong", "newPassword": "correct horse"}`))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Changing the password with a wrong current password returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.ChangePassword(w, newRequest(`{"currentPassword": "doe", "newPassword": "john's password"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Changing the password to one violating the policy returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.ChangePassword(w, newRequest(`{"currentPassword": "doe", "newPassword": "correct horse"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Changing the password failed with status %d", w.Code)
+	}
+	var response deleteSessionsResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil || response.Revoked != 1 {
+		t.Fatalf("Expected one revoked session but got %+v (%v)", response, err)
+	}
+	if _, _, err := store.AuthenticateUser(other.AccessToken); err == nil {
+		t.Fatalf("The other session is still valid after changing the password")
+	}
+	if _, _, err := store.AuthenticateUser(current.AccessToken); err != nil {
+		t.Fatalf("The current session was revoked: %v", err)
+	}
+}
+
+func TestServer_ResetPassword(t *testing.T) {
+	mail := smtptest.NewServer()
+	defer mail.Close()
+	s, store := newTestServer()
+	s.Notifier = notify.NewSMTPNotifier(mail.Addr, "todo@example.com", "", "")
+	s.PasswordResetURL = "https://todo.example.com/reset"
+
+	w := httptest.NewRecorder()
+	body := `{"name": "john", "password": "battery staple", "email": "john@example.com"}`
+	s.CreateUser(w, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Creating the user failed with status %d", w.Code)
+	}
+	login := models.User{Name: "john"}
+	login.SetPassword("battery staple")
+	tokens, err := store.LoginUser(login, models.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requestReset := func(name string) int {
+		w := httptest.NewRecorder()
+		s.RequestPasswordReset(w, httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"name": "`+name+`"}`)))
+		s.passwordResets.Wait()
+		return w.Code
+	}
+	if code := requestReset("unknown"); code != http.StatusAccepted {
+		t.Fatalf("Requesting a reset for an unknown user returned status %d", code)
+	}
+	if code := requestReset("john"); code != http.StatusAccepted {
+		t.Fatalf("Requesting a reset returned status %d", code)
+	}
+	emails := mail.Emails()
+	if len(emails) != 1 || emails[0].To[0] != "john@example.com" {
+		t.Fatalf("Expected one email to john but got %+v", emails)
+	}
+	link := regexp.MustCompile(`https://todo\.example\.com/reset\?token=\S+`).FindString(emails[0].Data)
+	if link == "" {
+		t.Fatalf("The email does not contain a reset link: %q", emails[0].Data)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := parsed.Query().Get("token")
+
+	resetPassword := func(token string) int {
+		w := httptest.NewRecorder()
+		body := `{"token": "` + token + `", "password": "correct horse"}`
+		s.ResetPassword(w, httptest.NewRequest(http.MethodPost, "/password/reset/confirm", strings.NewReader(body)))
+		return w.Code
+	}
+	w = httptest.NewRecorder()
+	body = `{"token": "` + token + `", "password": "horse of John"}`
+	s.ResetPassword(w, httptest.NewRequest(http.MethodPost, "/password/reset/confirm", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), password.ErrContainsName.Error()) {
+		t.Fatalf("Resetting to a password containing the user name returned status %d: %s", w.Code, w.Body)
+	}
+	if code := resetPassword("invalid"); code != http.StatusBadRequest {
+		t.Fatalf("Resetting with an invalid token returned status %d", code)
+	}
+	if code := resetPassword(token); code != http.StatusNoContent {
+		t.Fatalf("Resetting the password failed with status %d", code)
+	}
+	if code := resetPassword(token); code != http.StatusBadRequest {
+		t.Fatalf("Resetting twice with the same token returned status %d", code)
+	}
+	if _, _, err := store.AuthenticateUser(tokens.AccessToken); err == nil {
+		t.Fatalf("The session is still valid after resetting the password")
+	}
+	login.SetPassword("correct horse")
+	if _, err := store.LoginUser(login, models.Client{}); err != nil {
+		t.Fatalf("Login with the new password failed: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"todo/db"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+// MoveTodo moves the todo with the id in the request path in the manual order of the todos of its owner, to right
+// before or right after another todo of the owner given by its id in the field "before" or "after" of the request body.
+// Only the moved todo gets a new [models.Todo.Position], unless there is no position left between the neighbours and
+// all todos of the owner are renumbered. The moved todo is returned as JSON in the response body. If another todo took
+// the position in the meantime, the status code is 409 (Conflict) and the move can be repeated.
+func (s *Server) MoveTodo(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	todo, ok := s.getOwnTodoFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	var move models.Move
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := move.Validate(todo.Id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	target, err := s.Todos.GetTodo(move.TargetId())
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && target.UserId != user.Id) {
+		http.Error(w, "The todo to move next to has to be another todo of yours", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	todos, err := s.Todos.GetTodos(user.Id, models.TodoFilter{Sort: models.DefaultTodoSort})
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	todos = slices.DeleteFunc(todos, func(other models.Todo) bool { return other.Id == todo.Id })
+	i := slices.IndexFunc(todos, func(other models.Todo) bool { return other.Id == target.Id })
+	// the neighbours to move between are the todo before the target and the target, or the target and the todo after it
+	if move.After != nil {
+		i++
+	}
+	positions := make([]string, len(todos))
+	for j, other := range todos {
+		positions[j] = other.Position
+	}
+	var renumbered []string
+	todo.Position, renumbered = place(positions, i, todo.Position)
+	changed := []models.Todo{todo}
+	for j, position := range renumbered {
+		todos[j].Position = position
+		changed = append(changed, todos[j])
+	}
+	err = s.Todos.UpdateTodos(changed)
+	if errors.Is(err, db.ErrPositionTaken) {
+		http.Error(w, "Another todo was moved to this position at the same time, try again", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todo)
+}
+
+// place returns the position for an entry inserted at index i of the ordered positions of the others, which has the
+// position current so far. If there is no position left between its neighbours, for example because they have the
+// same position, all of them are renumbered with [models.Positions] and their new positions are returned in the same
+// order. The new positions differ from all previous ones, so they can be stored one by one.
+func place(positions []string, i int, current string) (string, []string) {
+	var after, before string
+	if i > 0 {
+		after = positions[i-1]
+	}
+	if i < len(positions) {
+		before = positions[i]
+	}
+	if position, err := models.PositionBetween(after, before); err == nil {
+		return position, nil
+	}
+	renumbered := models.Positions(len(positions)+1, append(slices.Clip(positions), current))
+	position := renumbered[i]
+	return position, slices.Delete(renumbered, i, i+1)
+}
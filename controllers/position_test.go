@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"todo/models"
+)
+
+func TestServer_MoveTodo(t *testing.T) {
+	s, store := newTestServer()
+	owner, _ := store.CreateUser(models.User{Name: "john"})
+	other, _ := store.CreateUser(models.User{Name: "jane"})
+	var todos []models.Todo
+	for _, body := range []string{
+		`{"title": "a", "position": "0"}`, `{"title": "b", "priority": "urgent"}`, `{"title": "c", "priority": "low"}`,
+	} {
+		w := httptest.NewRecorder()
+		s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", body, owner))
+		var todo models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+			t.Fatalf("Creating a todo returned status %d (%v)", w.Code, err)
+		}
+		todos = append(todos, todo)
+	}
+	a, b, c := todos[0], todos[1], todos[2]
+	if a.Position == "0" || a.Position >= b.Position || b.Priority != models.PriorityUrgent {
+		t.Fatalf("Unexpected todos %+v", todos)
+	}
+	theirs, _ := store.CreateTodo(models.Todo{Title: "theirs", UserId: other.Id})
+
+	ids := func(query string) []int {
+		w := httptest.NewRecorder()
+		s.GetTodos(w, newUserRequest(http.MethodGet, "/todos"+query, "", owner))
+		var todos []models.Todo
+		json.NewDecoder(w.Body).Decode(&todos)
+		var ids []int
+		for _, todo := range todos {
+			ids = append(ids, todo.Id)
+		}
+		return ids
+	}
+	move := func(todoId int, body string, user models.User) int {
+		w := httptest.NewRecorder()
+		s.MoveTodo(w, newTodoRequest(http.MethodPost, todoId, body, user))
+		return w.Code
+	}
+	if status := move(c.Id, `{"before": `+strconv.Itoa(a.Id)+`}`, owner); status != http.StatusOK {
+		t.Fatalf("Moving a todo returned status %d", status)
+	}
+	if status := move(a.Id, `{"after": `+strconv.Itoa(b.Id)+`}`, owner); status != http.StatusOK {
+		t.Fatalf("Moving a todo returned status %d", status)
+	}
+	if got := ids(""); !slices.Equal(got, []int{c.Id, b.Id, a.Id}) {
+		t.Fatalf("The todos are in the order %v after moving them", got)
+	}
+	if stored, _ := store.GetTodo(b.Id); stored.Position != b.Position {
+		t.Fatalf("A todo that was not moved got the position %q", stored.Position)
+	}
+	for body, status := range map[string]int{
+		`{}`: http.StatusBadRequest,
+		`{"before": ` + strconv.Itoa(a.Id) + `, "after": ` + strconv.Itoa(b.Id) + `}`: http.StatusBadRequest,
+		`{"before": ` + strconv.Itoa(a.Id) + `}`:                                      http.StatusBadRequest,
+		`{"before": ` + strconv.Itoa(theirs.Id) + `}`:                                 http.StatusBadRequest,
+		`{"after": 1000}`: http.StatusBadRequest,
+	} {
+		if code := move(a.Id, body, owner); code != status {
+			t.Errorf("Moving with %s returned status %d, want %d", body, code, status)
+		}
+	}
+	if status := move(theirs.Id, `{"before": `+strconv.Itoa(a.Id)+`}`, owner); status != http.StatusForbidden {
+		t.Fatalf("Moving the todo of another user returned status %d", status)
+	}
+
+	// there is no position between "a" and "a0", so moving between them renumbers the todos
+	for id, position := range map[int]string{c.Id: "a", b.Id: "a0"} {
+		stored, _ := store.GetTodo(id)
+		stored.Position = position
+		store.UpdateTodo(stored)
+	}
+	if status := move(a.Id, `{"before": `+strconv.Itoa(b.Id)+`}`, owner); status != http.StatusOK {
+		t.Fatalf("Moving a todo between neighbours without room returned status %d", status)
+	}
+	if got := ids(""); !slices.Equal(got, []int{c.Id, a.Id, b.Id}) {
+		t.Fatalf("The todos are in the order %v after renumbering them", got)
+	}
+	if stored, _ := store.GetTodo(b.Id); stored.Position == "a0" {
+		t.Fatalf("The todos were not renumbered")
+	}
+
+	if got := ids("?sort=-priority,position"); !slices.Equal(got, []int{b.Id, c.Id, a.Id}) {
+		t.Fatalf("The todos sorted by priority are in the order %v", got)
+	}
+	w := httptest.NewRecorder()
+	s.GetTodos(w, newUserRequest(http.MethodGet, "/todos?sort=title", "", owner))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Sorting by an unknown key returned status %d", w.Code)
+	}
+}
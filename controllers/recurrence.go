@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+const (
+	// defaultOccurrences and maxOccurrences are the default and the largest number of occurrences returned by
+	// [Server.GetTodoOccurrences].
+	defaultOccurrences = 10
+	maxOccurrences     = 100
+)
+
+// GetTodoOccurrences returns the next occurrences of the recurring [models.Todo] with the id in the request path as a
+// JSON list of [models.Occurrence] in the response body, which is empty if the todo does not repeat. The query
+// parameter "count" sets how many occurrences are returned, up to 100. Occurrences are computed in the timezone of the
+// owner of the todo. It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) GetTodoOccurrences(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	count := defaultOccurrences
+	if value := r.URL.Query().Get("count"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxOccurrences {
+			http.Error(w, "count has to be a number from 1 to 100", http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+	todo, err := s.getTodoFromPathId(r, w)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	canRead, err := s.canReadTodo(user, todo)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !canRead {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	owner, err := s.Users.GetUser(todo.UserId)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(todo.Occurrences(owner.Location(), count))
+}
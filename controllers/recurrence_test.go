@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"todo/models"
+)
+
+func TestServer_RecurringTodos(t *testing.T) {
+	s, store := newTestServer()
+	owner, _ := store.CreateUser(models.User{Name: "john"})
+	store.SetUserTimezone(owner.Id, "America/New_York")
+
+	for body, status := range map[string]int{
+		`{"title": "no due date", "recurrence": "FREQ=DAILY"}`:                            http.StatusBadRequest,
+		`{"title": "invalid", "dueAt": "2024-03-08T14:00:00Z", "recurrence": "FREQ=NOW"}`: http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", body, owner))
+		if w.Code != status {
+			t.Errorf("Creating %s returned status %d, want %d", body, w.Code, status)
+		}
+	}
+	// 9:00 in New York on the Friday before daylight saving time starts
+	w := httptest.NewRecorder()
+	s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos",
+		`{"title": "report", "dueAt": "2024-03-08T14:00:00Z", "recurrence": "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=3"}`, owner))
+	var todo models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+		t.Fatalf("Creating a recurring todo returned status %d: %v", w.Code, err)
+	}
+
+	w = httptest.NewRecorder()
+	r := newTodoRequest(http.MethodGet, todo.Id, "", owner)
+	r.URL.RawQuery = "count=5"
+	s.GetTodoOccurrences(w, r)
+	var occurrences []models.Occurrence
+	if err := json.NewDecoder(w.Body).Decode(&occurrences); err != nil {
+		t.Fatalf("Getting the occurrences returned status %d: %v", w.Code, err)
+	}
+	if len(occurrences) != 2 || !occurrences[0].DueAt.Equal(time.Date(2024, 3, 11, 13, 0, 0, 0, time.UTC)) ||
+		!occurrences[1].DueAt.Equal(time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected occurrences %+v", occurrences)
+	}
+	for _, query := range []string{"count=0", "count=101", "count=many"} {
+		w = httptest.NewRecorder()
+		r = newTodoRequest(http.MethodGet, todo.Id, "", owner)
+		r.URL.RawQuery = query
+		s.GetTodoOccurrences(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Getting the occurrences with %s returned status %d", query, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	s.GetTodoOccurrences(w, newTodoRequest(http.MethodGet, todo.Id, "", models.User{Id: owner.Id + 1}))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Another user got the occurrences (status %d)", w.Code)
+	}
+
+	complete := func() models.Todo {
+		w := httptest.NewRecorder()
+		s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, `{"isDone": true}`, owner))
+		var updated models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+			t.Fatalf("Completing the todo returned status %d: %v", w.Code, err)
+		}
+		return updated
+	}
+	if updated := complete(); updated.IsDone || !updated.DueAt.Equal(occurrences[0].DueAt) || updated.Recurrence != "FREQ=WEEKLY;BYDAY=MO,FR;COUNT=2" {
+		t.Fatalf("The todo did not advance to its next occurrence: %+v", updated)
+	}
+	complete()
+	if updated := complete(); !updated.IsDone || !updated.DueAt.Equal(occurrences[1].DueAt) {
+		t.Fatalf("The todo did not stay done after its last occurrence: %+v", updated)
+	}
+}
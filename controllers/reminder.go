@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+// getOwnTodoFromPathId returns the todo with the id in the request path if it belongs to user. Otherwise it responds
+// with an error status code and returns false.
+func (s *Server) getOwnTodoFromPathId(w http.ResponseWriter, r *http.Request, user models.User) (models.Todo, bool) {
+	todo, err := s.getTodoFromPathId(r, w)
+	if err != nil {
+		logger.Error(err.Error())
+		return todo, false
+	}
+	if todo.UserId != user.Id {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return todo, false
+	}
+	return todo, true
+}
+
+// getReminderFromPathId returns the reminder with the id in the request path and its todo if the todo belongs to user.
+// Otherwise it responds with an HTTP status code 404 (Not Found) or 403 (Forbidden) and returns false.
+func (s *Server) getReminderFromPathId(w http.ResponseWriter, r *http.Request, user models.User) (models.Reminder, models.Todo, bool) {
+	var todo models.Todo
+	reminderId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "No reminder id was given in the request path", http.StatusBadRequest)
+		return models.Reminder{}, todo, false
+	}
+	reminder, err := s.Reminders.GetReminder(reminderId)
+	if err == nil {
+		todo, err = s.Todos.GetTodo(reminder.TodoId)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return reminder, todo, false
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return reminder, todo, false
+	}
+	if todo.UserId != user.Id {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return reminder, todo, false
+	}
+	return reminder, todo, true
+}
+
+// rescheduleReminders moves the reminders relative to the due date of todo after it changed and makes them pending
+// again. They are deleted if the todo is no longer due.
+func (s *Server) rescheduleReminders(todo models.Todo) error {
+	reminders, err := s.Reminders.GetReminders(todo.Id)
+	if err != nil {
+		return err
+	}
+	owner, err := s.Users.GetUser(todo.UserId)
+	if err != nil {
+		return err
+	}
+	for _, reminder := range reminders {
+		if reminder.BeforeMinutes == nil {
+			continue
+		}
+		if todo.DueAt == nil {
+			err = s.Reminders.DeleteReminder(reminder.Id)
+		} else if err = reminder.Schedule(todo, owner.Location()); err == nil {
+			err = s.Reminders.UpdateReminder(reminder)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetReminders returns the [models.Reminder]s of the todo with the id in the request path as JSON in the response body.
+// Reminders are sent to the owner of the todo, so only the owner can see and change them. It expects the request to
+// be authorized by [middlewares.AuthenticateUser], like all handlers in this file.
+func (s *Server) GetReminders(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	todo, ok := s.getOwnTodoFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	reminders, err := s.Reminders.GetReminders(todo.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reminders)
+}
+
+// CreateReminder adds a [models.Reminder] to the todo with the id in the request path. The request body gives either
+// the time "at" or the "beforeMinutes" before the due date of the todo, which is interpreted in the timezone of the
+// user for all-day todos. The reminder is returned as JSON in the response body with the status code 201 (Created).
+func (s *Server) CreateReminder(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	todo, ok := s.getOwnTodoFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	var reminder models.Reminder
+	if err := json.NewDecoder(r.Body).Decode(&reminder); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	profile, err := s.Users.GetUser(user.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if err := reminder.Schedule(todo, profile.Location()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reminder, err = s.Reminders.CreateReminder(reminder)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reminder)
+}
+
+// DeleteReminder deletes the [models.Reminder] with the id in the request path.
+func (s *Server) DeleteReminder(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	reminder, _, ok := s.getReminderFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	if err := s.Reminders.DeleteReminder(reminder.Id); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SnoozeReminder sends the [models.Reminder] with the id in the request path again later, whether it was already sent
+// or not. The request body gives either the "minutes" to snooze for or the time "until" which to snooze. The snoozed
+// reminder is returned as JSON in the response body.
+func (s *Server) SnoozeReminder(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	reminder, _, ok := s.getReminderFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	var snooze models.ReminderSnooze
+	if err := json.NewDecoder(r.Body).Decode(&snooze); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	until, err := snooze.Time(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reminder.Snooze(until)
+	if err := s.Reminders.UpdateReminder(reminder); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reminder)
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"todo/models"
+)
+
+func TestServer_Reminders(t *testing.T) {
+	s, store := newTestServer()
+	owner, _ := store.CreateUser(models.User{Name: "john"})
+	other := models.User{Id: owner.Id + 1}
+	due := time.Date(2024, 6, 1, 16, 0, 0, 0, time.UTC)
+	todo, _ := store.CreateTodo(models.Todo{Title: "Pay the rent", UserId: owner.Id, DueAt: &due})
+	undated, _ := store.CreateTodo(models.Todo{Title: "Someday", UserId: owner.Id})
+
+	createReminder := func(todoId int, body string, user models.User) (models.Reminder, int) {
+		w := httptest.NewRecorder()
+		s.CreateReminder(w, newTodoRequest(http.MethodPost, todoId, body, user))
+		var reminder models.Reminder
+		if w.Code == http.StatusCreated {
+			json.NewDecoder(w.Body).Decode(&reminder)
+		}
+		return reminder, w.Code
+	}
+	relative, status := createReminder(todo.Id, `{"beforeMinutes": 30}`, owner)
+	if status != http.StatusCreated || !relative.RemindAt.Equal(due.Add(-30*time.Minute)) || relative.TodoId != todo.Id {
+		t.Fatalf("Creating a relative reminder returned status %d and %+v", status, relative)
+	}
+	absolute, status := createReminder(todo.Id, `{"at": "2024-05-31T08:00:00+02:00"}`, owner)
+	if status != http.StatusCreated || !absolute.RemindAt.Equal(time.Date(2024, 5, 31, 6, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Creating a reminder at a time returned status %d and %+v", status, absolute)
+	}
+	for body, status := range map[string]int{
+		`{}`: http.StatusBadRequest,
+		`{"at": "2024-05-31T08:00:00Z", "beforeMinutes": 5}`: http.StatusBadRequest,
+		`{"beforeMinutes": -5}`:                              http.StatusBadRequest,
+	} {
+		if _, code := createReminder(todo.Id, body, owner); code != status {
+			t.Errorf("Creating a reminder with %s returned status %d, want %d", body, code, status)
+		}
+	}
+	if _, code := createReminder(undated.Id, `{"beforeMinutes": 5}`, owner); code != http.StatusBadRequest {
+		t.Fatalf("Creating a relative reminder for a todo without due date returned status %d", code)
+	}
+	if _, code := createReminder(todo.Id, `{"beforeMinutes": 5}`, other); code != http.StatusForbidden {
+		t.Fatalf("Another user created a reminder (status %d)", code)
+	}
+
+	w := httptest.NewRecorder()
+	s.GetReminders(w, newTodoRequest(http.MethodGet, todo.Id, "", owner))
+	var reminders []models.Reminder
+	if err := json.NewDecoder(w.Body).Decode(&reminders); err != nil || len(reminders) != 2 {
+		t.Fatalf("Unexpected reminders %+v (status %d, %v)", reminders, w.Code, err)
+	}
+
+	// the reminder was sent and is snoozed for ten minutes
+	store.CompleteReminder(relative, relative.RemindAt)
+	w = httptest.NewRecorder()
+	s.SnoozeReminder(w, newPathRequest(http.MethodPost, "/reminders/"+strconv.Itoa(relative.Id),
+		relative.Id, `{"minutes": 10}`, owner))
+	var snoozed models.Reminder
+	if err := json.NewDecoder(w.Body).Decode(&snoozed); err != nil || snoozed.SentAt != nil ||
+		snoozed.RemindAt.Sub(time.Now()) > 10*time.Minute || snoozed.RemindAt.Sub(time.Now()) < 9*time.Minute {
+		t.Fatalf("Unexpected snoozed reminder %+v (status %d, %v)", snoozed, w.Code, err)
+	}
+	for body, status := range map[string]int{
+		`{"minutes": 0}`:                                  http.StatusBadRequest,
+		`{"until": "2000-01-01T00:00:00Z"}`:               http.StatusBadRequest,
+		`{"minutes": 5, "until": "2999-01-01T00:00:00Z"}`: http.StatusBadRequest,
+	} {
+		w = httptest.NewRecorder()
+		s.SnoozeReminder(w, newPathRequest(http.MethodPost, "/reminders/"+strconv.Itoa(relative.Id),
+			relative.Id, body, owner))
+		if w.Code != status {
+			t.Errorf("Snoozing with %s returned status %d, want %d", body, w.Code, status)
+		}
+	}
+	w = httptest.NewRecorder()
+	s.SnoozeReminder(w, newPathRequest(http.MethodPost, "/reminders/"+strconv.Itoa(relative.Id),
+		relative.Id, `{"minutes": 10}`, other))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Another user snoozed the reminder (status %d)", w.Code)
+	}
+
+	// relative reminders follow the due date and are deleted with it
+	w = httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, `{"dueAt": "2024-06-02T16:00:00Z"}`, owner))
+	if stored, _ := store.GetReminder(relative.Id); w.Code != http.StatusOK || !stored.RemindAt.Equal(due.Add(24*time.Hour-30*time.Minute)) {
+		t.Fatalf("The relative reminder did not follow the due date: %+v (status %d)", stored, w.Code)
+	}
+	if stored, _ := store.GetReminder(absolute.Id); !stored.RemindAt.Equal(absolute.RemindAt) {
+		t.Fatalf("The reminder at a fixed time was moved: %+v", stored)
+	}
+	w = httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, `{"dueAt": null}`, owner))
+	if _, err := store.GetReminder(relative.Id); w.Code != http.StatusOK || err == nil {
+		t.Fatalf("The relative reminder of a todo without due date was kept (status %d)", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.DeleteReminder(w, newPathRequest(http.MethodDelete, "/reminders/"+strconv.Itoa(absolute.Id), absolute.Id, "", owner))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Deleting the reminder returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.DeleteReminder(w, newPathRequest(http.MethodDelete, "/reminders/"+strconv.Itoa(absolute.Id), absolute.Id, "", owner))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Deleting a missing reminder returned status %d", w.Code)
+	}
+}
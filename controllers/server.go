@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"sync"
+	"todo/db"
+	"todo/models"
+	"todo/notify"
+	"todo/oidc"
+	"todo/password"
+)
+
+// Server holds the stores the HTTP handlers read from and write to. All handlers are methods of Server so that the
+// backend can be swapped, for example with a [db.MemoryStore] in tests.
+type Server struct {
+	Todos        db.TodoStore
+	Tags         db.TagStore
+	Lists        db.ListStore
+	Reminders    db.ReminderStore
+	Dependencies db.DependencyStore
+	Checklists   db.ChecklistStore
+	Users        db.UserStore
+	Sessions     db.SessionStore
+	AccessTokens db.AccessTokenStore
+	TwoFactor    db.TwoFactorStore
+	Passwords    db.PasswordStore
+	// PasswordPolicy is checked for new passwords when users are created or change or reset their password.
+	PasswordPolicy password.Policy
+	// LoginAttempts counts failed logins, which are slowed down as configured by LoginThrottle.
+	LoginAttempts db.LoginAttemptStore
+	LoginThrottle LoginThrottle
+	// OIDC is the identity provider users can log in with. It is nil if logging in with a provider is disabled.
+	OIDC *oidc.Provider
+	// Notifier delivers password reset tokens to users.
+	Notifier notify.Notifier
+	// SubtaskPolicy decides what happens to the subtasks of completed and deleted todos unless a request asks for
+	// another policy.
+	SubtaskPolicy models.SubtaskPolicy
+	// PasswordResetURL is the page users reset their password on. The reset token is appended to it as the query
+	// parameter "token". If it is empty, the message only contains the token.
+	PasswordResetURL string
+	// passwordResets tracks the password reset requests that are still handled in the background.
+	passwordResets sync.WaitGroup
+}
+
+// NewServer returns a [Server] using store for todos, tags, lists, reminders, dependencies, checklists, users,
+// sessions, access tokens, second factors, passwords and failed logins. Messages are written to the log until another
+// [notify.Notifier] is set. Todos with subtasks can't be deleted and todos with open subtasks can't be completed until
+// another policy is set.
+func NewServer(store db.Store) *Server {
+	return &Server{
+		Todos:          store,
+		Tags:           store,
+		Lists:          store,
+		Reminders:      store,
+		Dependencies:   store,
+		Checklists:     store,
+		Users:          store,
+		Sessions:       store,
+		AccessTokens:   store,
+		TwoFactor:      store,
+		Passwords:      store,
+		PasswordPolicy: password.DefaultPolicy,
+		LoginAttempts:  store,
+		LoginThrottle:  DefaultLoginThrottle,
+		Notifier:       notify.LogNotifier{},
+		SubtaskPolicy:  models.SubtasksBlock,
+	}
+}
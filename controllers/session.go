@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+// clientFromRequest returns the user agent and the IP address of the client that sent the request.
+func clientFromRequest(r *http.Request) models.Client {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return models.Client{UserAgent: r.UserAgent(), IP: ip}
+}
+
+// GetSessions returns the active [models.Session]s of the user as JSON in the response body. The session used for the
+// request is marked as current. It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) GetSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	current, _ := r.Context().Value(middlewares.ContextSessionKey).(models.Session)
+	sessions, err := s.Sessions.GetSessions(user.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == current.Id
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// DeleteSession revokes the [models.Session] with the ID specified as a request path value. Only sessions of the user
+// can be revoked. It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	sessionId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "No session id was given in the request path", http.StatusBadRequest)
+		return
+	}
+	if err := s.Sessions.DeleteSession(user.Id, sessionId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type deleteSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// DeleteOtherSessions revokes all [models.Session]s of the user except the one used for the request. The number of
+// revoked sessions is returned as JSON in the response body. It expects the request to be authorized by
+// [middlewares.AuthenticateUser].
+func (s *Server) DeleteOtherSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	current, ok := r.Context().Value(middlewares.ContextSessionKey).(models.Session)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	count, err := s.Sessions.DeleteOtherSessions(user.Id, current.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deleteSessionsResponse{Revoked: count})
+}
+
+// Logout revokes the [models.Session] whose token was used for the request, so that neither its access token nor its
+// refresh token can be used anymore. It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	current, ok := r.Context().Value(middlewares.ContextSessionKey).(models.Session)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if err := s.Sessions.DeleteSession(user.Id, current.Id); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll revokes every [models.Session] of the user including the one used for the request. The number of revoked
+// sessions is returned as JSON in the response body. It expects the request to be authorized by
+// [middlewares.AuthenticateUser].
+func (s *Server) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	count, err := s.Sessions.DeleteAllSessions(user.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deleteSessionsResponse{Revoked: count})
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"todo/middlewares"
+	"todo/models"
+)
+
+func TestServer_Sessions(t *testing.T) {
+	s, store := newTestServer()
+	user := models.User{Name: "john"}
+	user.SetPassword("doe")
+	user, _ = store.CreateUser(user)
+	tokens, _ := store.LoginUser(user, models.Client{UserAgent: "laptop"})
+	store.LoginUser(user, models.Client{UserAgent: "phone"})
+	_, current, err := store.AuthenticateUser(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func(method string) *http.Request {
+		r := newUserRequest(method, "/sessions", "", user)
+		return r.WithContext(context.WithValue(r.Context(), middlewares.ContextSessionKey, current))
+	}
+
+	w := httptest.NewRecorder()
+	s.GetSessions(w, newRequest(http.MethodGet))
+	var sessions []models.Session
+	if err := json.NewDecoder(w.Body).Decode(&sessions); err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected two sessions but got %d", len(sessions))
+	}
+	for _, session := range sessions {
+		if session.Current != (session.UserAgent == "laptop") {
+			t.Fatalf("The session %+v is marked wrongly as current", session)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.DeleteOtherSessions(w, newRequest(http.MethodDelete))
+	var response deleteSessionsResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Revoked != 1 {
+		t.Fatalf("Expected one revoked session but got %d", response.Revoked)
+	}
+
+	w = httptest.NewRecorder()
+	r := newRequest(http.MethodDelete)
+	r.SetPathValue("id", "42")
+	s.DeleteSession(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Deleting an unknown session returned status %d", w.Code)
+	}
+}
+
+func TestServer_Logout(t *testing.T) {
+	s, store := newTestServer()
+	user := models.User{Name: "john"}
+	user.SetPassword("doe")
+	user, _ = store.CreateUser(user)
+	tokens, _ := store.LoginUser(user, models.Client{})
+	otherTokens, _ := store.LoginUser(user, models.Client{})
+	newRequest := func(token string) *http.Request {
+		_, session, err := store.AuthenticateUser(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := newUserRequest(http.MethodPost, "/logout", "", user)
+		return r.WithContext(context.WithValue(r.Context(), middlewares.ContextSessionKey, session))
+	}
+
+	w := httptest.NewRecorder()
+	s.Logout(w, newRequest(tokens.AccessToken))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Logout failed with status %d", w.Code)
+	}
+	if _, _, err := store.AuthenticateUser(tokens.AccessToken); err == nil {
+		t.Fatalf("The token is still valid after logging out")
+	}
+	if _, err := store.RefreshSession(tokens.RefreshToken); err == nil {
+		t.Fatalf("The session can still be refreshed after logging out")
+	}
+	if _, _, err := store.AuthenticateUser(otherTokens.AccessToken); err != nil {
+		t.Fatalf("Logging out revoked another session: %v", err)
+	}
+
+	store.LoginUser(user, models.Client{})
+	w = httptest.NewRecorder()
+	s.LogoutAll(w, newRequest(otherTokens.AccessToken))
+	var response deleteSessionsResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Revoked != 2 {
+		t.Fatalf("Expected two revoked sessions but got %d", response.Revoked)
+	}
+	if sessions, _ := store.GetSessions(user.Id); len(sessions) != 0 {
+		t.Fatalf("There are still %d sessions after logging out everywhere", len(sessions))
+	}
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"todo/logger"
+	"todo/models"
+)
+
+// checkTransition returns an error wrapping [models.ErrStatusTransition] if the workflow of the list of todo does not
+// allow it to change from the status from to its current status.
+func (s *Server) checkTransition(todo models.Todo, from models.Status) error {
+	if todo.ListId == nil || todo.Status == from {
+		return nil
+	}
+	list, err := s.Lists.GetList(*todo.ListId)
+	if err != nil {
+		return err
+	}
+	return list.Workflow.CheckTransition(from, todo.Status)
+}
+
+// respondStatusError responds with the HTTP status code 409 (Conflict) for an illegal status transition and with
+// 500 (Internal Server Error) for any other error.
+func respondStatusError(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrStatusTransition) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	logger.Error(err.Error())
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"todo/models"
+)
+
+func TestServer_UpdateTodo_Status(t *testing.T) {
+	s, store := newTestServer()
+	owner, _ := store.CreateUser(models.User{Name: "john"})
+	w := httptest.NewRecorder()
+	s.CreateList(w, newUserRequest(http.MethodPost, "/lists",
+		`{"name": "work", "workflow": {"open": ["inProgress"], "inProgress": ["blocked", "done"], "blocked": ["inProgress"]}}`, owner))
+	var list models.List
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil || len(list.Workflow) != 3 {
+		t.Fatalf("Creating a list with a workflow returned status %d and %+v (%v)", w.Code, list, err)
+	}
+	w = httptest.NewRecorder()
+	s.CreateList(w, newUserRequest(http.MethodPost, "/lists", `{"name": "invalid", "workflow": {}}`, owner))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Creating a list with an empty workflow returned status %d", w.Code)
+	}
+
+	for body, status := range map[string]int{
+		`{"title": "skipped", "status": "done", "listId": ` + strconv.Itoa(list.Id) + `}`:       http.StatusConflict,
+		`{"title": "started", "status": "inProgress", "listId": ` + strconv.Itoa(list.Id) + `}`: http.StatusOK,
+		`{"title": "unlisted", "status": "done"}`:                                               http.StatusOK,
+	} {
+		w = httptest.NewRecorder()
+		s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", body, owner))
+		if w.Code != status {
+			t.Errorf("Creating a todo with %s returned status %d, want %d", body, w.Code, status)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", `{"title": "report", "listId": `+strconv.Itoa(list.Id)+`}`, owner))
+	var todo models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil || todo.Status != models.StatusOpen || todo.IsDone {
+		t.Fatalf("Unexpected new todo %+v (status %d, %v)", todo, w.Code, err)
+	}
+	update := func(body string) (models.Todo, int) {
+		w := httptest.NewRecorder()
+		s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, body, owner))
+		stored, _ := store.GetTodo(todo.Id)
+		return stored, w.Code
+	}
+	if stored, status := update(`{"status": "done"}`); status != http.StatusConflict || stored.Status != models.StatusOpen {
+		t.Fatalf("Skipping the workflow returned status %d: %+v", status, stored)
+	}
+	if _, status := update(`{"isDone": true}`); status != http.StatusConflict {
+		t.Fatalf("Completing the todo with isDone skipped the workflow (status %d)", status)
+	}
+	if _, status := update(`{"status": "finished"}`); status != http.StatusBadRequest {
+		t.Fatalf("Setting an unknown status returned status %d", status)
+	}
+	for _, status := range []models.Status{models.StatusInProgress, models.StatusBlocked, models.StatusInProgress} {
+		if stored, code := update(`{"status": "` + string(status) + `"}`); code != http.StatusOK || stored.Status != status {
+			t.Fatalf("Changing to %s returned status %d: %+v", status, code, stored)
+		}
+	}
+	stored, status := update(`{"status": "done"}`)
+	if status != http.StatusOK || !stored.IsDone || stored.CompletedAt == nil {
+		t.Fatalf("Completing the todo returned status %d: %+v", status, stored)
+	}
+	if _, status := update(`{"isDone": false}`); status != http.StatusConflict {
+		t.Fatalf("The todo was reopened although the workflow has no transition from done (status %d)", status)
+	}
+
+	// without a list, and for clients that only know isDone, every change is allowed
+	if stored, status := update(`{"listId": null, "isDone": false}`); status != http.StatusOK || stored.Status != models.StatusOpen || stored.CompletedAt != nil {
+		t.Fatalf("Reopening the todo outside of the list returned status %d: %+v", status, stored)
+	}
+	if stored, status := update(`{"status": "cancelled"}`); status != http.StatusOK || !stored.IsDone || stored.CompletedAt == nil {
+		t.Fatalf("Cancelling the todo returned status %d: %+v", status, stored)
+	}
+	w = httptest.NewRecorder()
+	s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", `{"title": "old client", "isDone": true}`, owner))
+	if err := json.NewDecoder(w.Body).Decode(&todo); err != nil || todo.Status != models.StatusDone || todo.CompletedAt == nil {
+		t.Fatalf("A todo created as done has the status %s and completion %v (%v)", todo.Status, todo.CompletedAt, err)
+	}
+}
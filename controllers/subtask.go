@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+var (
+	// errUnknownParent is returned by [Server.checkParent] for parents that don't exist or belong to another user.
+	errUnknownParent = errors.New("the parent todo does not exist")
+	// errSubtaskCycle is returned by [Server.checkParent] if a todo would become a subtask of itself.
+	errSubtaskCycle = errors.New("a todo can't be a subtask of itself or of its subtasks")
+	// errOpenSubtasks is returned for todos with open subtasks that can't be completed with [models.SubtasksBlock].
+	errOpenSubtasks = errors.New("the todo has open subtasks")
+	// errHasSubtasks is returned for todos with subtasks that can't be deleted with [models.SubtasksBlock].
+	errHasSubtasks = errors.New("the todo has subtasks")
+)
+
+// respondSubtaskError responds with an HTTP status code 400 (Bad Request) for invalid parents, 409 (Conflict) for
+// todos whose subtasks block the request and 500 (Internal Server Error) otherwise.
+func respondSubtaskError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUnknownParent), errors.Is(err, errSubtaskCycle):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, errOpenSubtasks), errors.Is(err, errHasSubtasks):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// checkParent returns [errUnknownParent] if the todo parentId does not belong to the owner of todo and
+// [errSubtaskCycle] if it is todo itself or one of its subtasks.
+func (s *Server) checkParent(todo models.Todo, parentId int) error {
+	for ancestorId := &parentId; ancestorId != nil; {
+		if *ancestorId == todo.Id {
+			return errSubtaskCycle
+		}
+		ancestor, err := s.Todos.GetTodo(*ancestorId)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && ancestor.UserId != todo.UserId) {
+			return errUnknownParent
+		}
+		if err != nil {
+			return err
+		}
+		ancestorId = ancestor.ParentId
+	}
+	return nil
+}
+
+// subtasks returns the subtasks of todo and their subtasks, parents before their children.
+func (s *Server) subtasks(todo models.Todo) ([]models.Todo, error) {
+	var subtasks []models.Todo
+	seen := map[int]bool{todo.Id: true}
+	for parents := []models.Todo{todo}; len(parents) > 0; {
+		parent := parents[0]
+		parents = parents[1:]
+		children, err := s.Todos.GetTodos(todo.UserId, models.TodoFilter{ParentId: &parent.Id})
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if !seen[child.Id] {
+				seen[child.Id] = true
+				subtasks = append(subtasks, child)
+				parents = append(parents, child)
+			}
+		}
+	}
+	return subtasks, nil
+}
+
+// subtaskPolicy returns the [models.SubtaskPolicy] given by the query parameter "subtasks" or [Server.SubtaskPolicy]
+// if there is none.
+func (s *Server) subtaskPolicy(r *http.Request) (models.SubtaskPolicy, error) {
+	if !r.URL.Query().Has("subtasks") {
+		return s.SubtaskPolicy, nil
+	}
+	return models.ParseSubtaskPolicy(r.URL.Query().Get("subtasks"))
+}
+
+// completeSubtasks applies policy to the open subtasks of todo, which is completed or cancelled, and returns the changed
+// subtasks to be stored together with todo. With [models.SubtasksCascade] they get the status of todo regardless of
+// the workflows of their lists, with [models.SubtasksBlock] it returns [errOpenSubtasks] if there are any.
+func (s *Server) completeSubtasks(todo models.Todo, policy models.SubtaskPolicy) ([]models.Todo, error) {
+	subtasks, err := s.subtasks(todo)
+	if err != nil {
+		return nil, err
+	}
+	var changed []models.Todo
+	for _, subtask := range subtasks {
+		if subtask.IsDone {
+			continue
+		}
+		switch policy {
+		case models.SubtasksBlock:
+			return nil, errOpenSubtasks
+		case models.SubtasksCascade:
+			subtask.SetStatus(todo.Status, time.Now())
+		case models.SubtasksOrphan:
+			if *subtask.ParentId != todo.Id {
+				continue
+			}
+			subtask.ParentId = nil
+		}
+		changed = append(changed, subtask)
+	}
+	return changed, nil
+}
+
+// deleteTodo deletes todo and applies policy to its subtasks. With [models.SubtasksBlock] it returns [errHasSubtasks]
+// if there are any. With [models.SubtasksCascade] the todo and its subtasks are deleted by the store at once, with
+// [models.SubtasksOrphan] the store keeps the subtasks of the deleted todo as top-level todos.
+func (s *Server) deleteTodo(todo models.Todo, policy models.SubtaskPolicy) (models.Todo, error) {
+	switch policy {
+	case models.SubtasksCascade:
+		return s.Todos.DeleteTodoWithSubtasks(todo.Id)
+	case models.SubtasksBlock:
+		children, err := s.Todos.GetTodos(todo.UserId, models.TodoFilter{ParentId: &todo.Id})
+		if err != nil {
+			return todo, err
+		}
+		if len(children) > 0 {
+			return todo, errHasSubtasks
+		}
+	}
+	return s.Todos.DeleteTodo(todo.Id)
+}
+
+// GetTodoChildren returns the direct subtasks of the [models.Todo] with the id in the request path as JSON in the
+// response body, filtered by the query parameters of [Server.GetTodos] except "shared". Users the todo is shared with
+// only get the subtasks they can read themselves. It expects the request to be authorized by
+// [middlewares.AuthenticateUser].
+func (s *Server) GetTodoChildren(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	todo, err := s.getTodoFromPathId(r, w)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	canRead, err := s.canReadTodo(user, todo)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !canRead {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	filter := models.TodoFilter{ParentId: &todo.Id}
+	if !s.parseTodoFilter(w, r.URL.Query(), user, &filter) {
+		return
+	}
+	children, err := s.Todos.GetTodos(todo.UserId, filter)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	readable := []models.Todo{}
+	for _, child := range children {
+		canRead, err := s.canReadTodo(user, child)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if canRead {
+			readable = append(readable, child)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(readable)
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"todo/db"
+	"todo/models"
+)
+
+func TestServer_UpdateTodo_Parent(t *testing.T) {
+	s, store := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	parent, _ := store.CreateTodo(models.Todo{Title: "parent", UserId: owner.Id})
+	child, _ := store.CreateTodo(models.Todo{Title: "child", UserId: owner.Id, ParentId: &parent.Id})
+	foreign, _ := store.CreateTodo(models.Todo{Title: "foreign", UserId: 2})
+
+	for body, status := range map[string]int{
+		`{"parentId": ` + strconv.Itoa(parent.Id) + `}`:  http.StatusBadRequest,
+		`{"parentId": ` + strconv.Itoa(child.Id) + `}`:   http.StatusBadRequest,
+		`{"parentId": ` + strconv.Itoa(foreign.Id) + `}`: http.StatusBadRequest,
+		`{"parentId": null}`:                             http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		s.UpdateTodo(w, newTodoRequest(http.MethodPatch, parent.Id, body, owner))
+		if w.Code != status {
+			t.Errorf("Updating the parent with %s returned status %d, want %d", body, w.Code, status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.GetTodos(w, newUserRequest(http.MethodGet, "/todos?tree", "", owner))
+	var trees []models.TodoTree
+	if err := json.NewDecoder(w.Body).Decode(&trees); err != nil {
+		t.Fatal(err)
+	}
+	if len(trees) != 1 || len(trees[0].Children) != 1 || trees[0].Children[0].Id != child.Id {
+		t.Fatalf("Unexpected tree %+v", trees)
+	}
+	w = httptest.NewRecorder()
+	s.GetTodoChildren(w, newTodoRequest(http.MethodGet, parent.Id, "", owner))
+	var children []models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&children); err != nil || len(children) != 1 || children[0].Id != child.Id {
+		t.Fatalf("Unexpected children %+v (status %d, %v)", children, w.Code, err)
+	}
+	w = httptest.NewRecorder()
+	s.GetTodoChildren(w, newTodoRequest(http.MethodGet, parent.Id, "", models.User{Id: 2}))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Another user got the children of the todo (status %d)", w.Code)
+	}
+}
+
+func TestServer_SubtaskPolicies(t *testing.T) {
+	s, store := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	parent, _ := store.CreateTodo(models.Todo{Title: "parent", UserId: owner.Id})
+	child, _ := store.CreateTodo(models.Todo{Title: "child", UserId: owner.Id, ParentId: &parent.Id})
+	grandchild, _ := store.CreateTodo(models.Todo{Title: "grandchild", UserId: owner.Id, ParentId: &child.Id})
+
+	w := httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, parent.Id, `{"isDone": true}`, owner))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Completing a todo with open subtasks returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.DeleteTodo(w, newTodoRequest(http.MethodDelete, parent.Id, "", owner))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Deleting a todo with subtasks returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r := newTodoRequest(http.MethodPatch, child.Id, `{"isDone": true}`, owner)
+	r.URL.RawQuery = "subtasks=cascade"
+	s.UpdateTodo(w, r)
+	if stored, _ := store.GetTodo(grandchild.Id); w.Code != http.StatusOK || !stored.IsDone {
+		t.Fatalf("Completing with cascade returned status %d and left the subtask %+v", w.Code, stored)
+	}
+
+	s.SubtaskPolicy = models.SubtasksOrphan
+	w = httptest.NewRecorder()
+	s.DeleteTodo(w, newTodoRequest(http.MethodDelete, child.Id, "", owner))
+	if stored, err := store.GetTodo(grandchild.Id); w.Code != http.StatusOK || err != nil || stored.ParentId != nil {
+		t.Fatalf("Deleting with orphan returned status %d and left the subtask %+v (%v)", w.Code, stored, err)
+	}
+	store.UpdateTodo(models.Todo{Id: grandchild.Id, Title: "grandchild", UserId: owner.Id, ParentId: &parent.Id})
+	w = httptest.NewRecorder()
+	r = newTodoRequest(http.MethodDelete, parent.Id, "", owner)
+	r.URL.RawQuery = "subtasks=cascade"
+	s.DeleteTodo(w, r)
+	if _, err := store.GetTodo(grandchild.Id); w.Code != http.StatusOK || err == nil {
+		t.Fatalf("Deleting with cascade returned status %d and kept the subtask", w.Code)
+	}
+	other, _ := store.CreateTodo(models.Todo{Title: "other", UserId: owner.Id})
+	w = httptest.NewRecorder()
+	r = newTodoRequest(http.MethodDelete, other.Id, "", owner)
+	r.URL.RawQuery = "subtasks=later"
+	s.DeleteTodo(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("An unknown policy returned status %d", w.Code)
+	}
+}
+
+// failingUpdates fails every update of todos.
+type failingUpdates struct {
+	db.TodoStore
+}
+
+func (failingUpdates) UpdateTodos([]models.Todo) error {
+	return errors.New("update failed")
+}
+
+func TestServer_SubtaskCascadeFailure(t *testing.T) {
+	s, store := newTestServer()
+	s.Todos = failingUpdates{store}
+	owner := models.User{Id: 1, Name: "john"}
+	parent, _ := store.CreateTodo(models.Todo{Title: "parent", UserId: owner.Id})
+	child, _ := store.CreateTodo(models.Todo{Title: "child", UserId: owner.Id, ParentId: &parent.Id})
+
+	w := httptest.NewRecorder()
+	r := newTodoRequest(http.MethodPatch, parent.Id, `{"isDone": true}`, owner)
+	r.URL.RawQuery = "subtasks=cascade"
+	s.UpdateTodo(w, r)
+	if stored, _ := store.GetTodo(child.Id); w.Code != http.StatusInternalServerError || stored.IsDone {
+		t.Fatalf("A failed completion returned status %d and left the subtask %+v", w.Code, stored)
+	}
+}
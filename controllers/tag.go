@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo/db"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+)
+
+type tagMergeRequest struct {
+	// Into is the id of the tag the tag in the request path is merged into.
+	Into *int `json:"into"`
+}
+
+// errUnknownTag is returned by [Server.ownTags] for tags that don't exist or belong to another user.
+var errUnknownTag = errors.New("unknown tag")
+
+// ownTags looks up the tags with the ids of tags and returns [errUnknownTag] if one of them is not a tag of the user
+// with id userId.
+func (s *Server) ownTags(userId int, tags []models.Tag) ([]models.Tag, error) {
+	owned := make([]models.Tag, 0, len(tags))
+	for _, ref := range tags {
+		tag, err := s.Tags.GetTag(ref.Id)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && tag.UserId != userId) {
+			return nil, fmt.Errorf("%w %d", errUnknownTag, ref.Id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, tag)
+	}
+	return owned, nil
+}
+
+// respondTagError responds with an HTTP status code 400 (Bad Request) for unknown tags, 409 (Conflict) for taken tag
+// names and 500 (Internal Server Error) otherwise.
+func respondTagError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUnknownTag):
+		http.Error(w, "The tag does not exist.", http.StatusBadRequest)
+	case errors.Is(err, db.ErrTagExists):
+		http.Error(w, "A tag with this name already exists.", http.StatusConflict)
+	default:
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// getTagFromPathId returns the tag with the id in the request path. If it does not exist or belongs to another user,
+// it responds with an HTTP status code 404 (Not Found) and returns false.
+func (s *Server) getTagFromPathId(w http.ResponseWriter, r *http.Request, user models.User) (models.Tag, bool) {
+	tagId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "No tag id was given in the request path", http.StatusBadRequest)
+		return models.Tag{}, false
+	}
+	tag, err := s.Tags.GetTag(tagId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && tag.UserId != user.Id) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return tag, false
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return tag, false
+	}
+	return tag, true
+}
+
+// GetTags returns the [models.Tag]s of the user ordered by name as JSON in the response body. It expects the request
+// to be authorized by [middlewares.AuthenticateUser], like all handlers in this file.
+func (s *Server) GetTags(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	tags, err := s.Tags.GetTags(user.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// CreateTag creates a [models.Tag] with the name and color in the request body and returns it as JSON in the response
+// body. If the user already has a tag with the name, it responds with an HTTP status code 409 (Conflict).
+func (s *Server) CreateTag(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var update models.TagUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	tag := models.Tag{UserId: user.Id}
+	if err := tag.Update(update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tag, err := s.Tags.CreateTag(tag)
+	if err != nil {
+		respondTagError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tag)
+}
+
+// UpdateTag renames or recolors the [models.Tag] with the id in the request path with the fields given in the
+// request body and returns it as JSON in the response body.
+func (s *Server) UpdateTag(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	tag, ok := s.getTagFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	var update models.TagUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if err := tag.Update(update); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Tags.UpdateTag(tag); err != nil {
+		respondTagError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tag)
+}
+
+// MergeTags merges the [models.Tag] with the id in the request path into the tag given in the request body: the other
+// tag is attached to all todos of the tag and the tag is deleted. The remaining tag is returned as JSON in the
+// response body.
+func (s *Server) MergeTags(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	source, ok := s.getTagFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	var req tagMergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Into == nil || *req.Into == source.Id {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	targets, err := s.ownTags(user.Id, []models.Tag{{Id: *req.Into}})
+	if err != nil {
+		respondTagError(w, err)
+		return
+	}
+	if err := s.Tags.MergeTags(source.Id, targets[0].Id); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(targets[0])
+}
+
+// DeleteTag deletes the [models.Tag] with the id in the request path and detaches it from all todos.
+func (s *Server) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	tag, ok := s.getTagFromPathId(w, r, user)
+	if !ok {
+		return
+	}
+	if err := s.Tags.DeleteTag(tag.Id); err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"todo/models"
+)
+
+func TestServer_CreateTag(t *testing.T) {
+	s, _ := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	w := httptest.NewRecorder()
+	s.CreateTag(w, newUserRequest(http.MethodPost, "/tags", `{"name": " Work ", "color": "#FF0000"}`, owner))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Creating a tag failed with status %d", w.Code)
+	}
+	var tag models.Tag
+	if err := json.NewDecoder(w.Body).Decode(&tag); err != nil {
+		t.Fatal(err)
+	}
+	if tag != (models.Tag{Id: tag.Id, UserId: owner.Id, Name: "Work", Color: "#ff0000"}) {
+		t.Fatalf("The created tag does not match the request: %+v", tag)
+	}
+	for body, status := range map[string]int{
+		`{"name": "Work"}`:                    http.StatusConflict,
+		`{"name": " "}`:                       http.StatusBadRequest,
+		`{"name": "home", "color": "orange"}`: http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		s.CreateTag(w, newUserRequest(http.MethodPost, "/tags", body, owner))
+		if w.Code != status {
+			t.Errorf("Creating the tag %s returned status %d, want %d", body, w.Code, status)
+		}
+	}
+}
+
+func TestServer_Tags(t *testing.T) {
+	s, store := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	other := models.User{Id: 2, Name: "jane"}
+	work, _ := store.CreateTag(models.Tag{UserId: owner.Id, Name: "work"})
+	urgent, _ := store.CreateTag(models.Tag{UserId: owner.Id, Name: "urgent"})
+	foreign, _ := store.CreateTag(models.Tag{UserId: other.Id, Name: "secret"})
+
+	w := httptest.NewRecorder()
+	s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", `{"title": "a", "tags": [{"id": `+strconv.Itoa(foreign.Id)+`}]}`, owner))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Tagging a todo with the tag of another user returned status %d", w.Code)
+	}
+	todo, _ := store.CreateTodo(models.Todo{Title: "a", UserId: owner.Id, Tags: []models.Tag{work}})
+	w = httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, `{"addTags": [`+strconv.Itoa(urgent.Id)+`], "removeTags": [`+strconv.Itoa(work.Id)+`]}`, owner))
+	var updated models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatalf("Updating the tags returned status %d: %v", w.Code, err)
+	}
+	if len(updated.Tags) != 1 || updated.Tags[0] != urgent {
+		t.Fatalf("Unexpected tags after the update %+v", updated.Tags)
+	}
+
+	getTodos := func(query string) (int, []models.Todo) {
+		w := httptest.NewRecorder()
+		s.GetTodos(w, newUserRequest(http.MethodGet, "/todos?"+query, "", owner))
+		var todos []models.Todo
+		json.NewDecoder(w.Body).Decode(&todos)
+		return w.Code, todos
+	}
+	if _, todos := getTodos("tag=urgent&tag=work"); len(todos) != 1 {
+		t.Fatalf("Unexpected todos with any tag %+v", todos)
+	}
+	if _, todos := getTodos("tag=urgent&tag=work&tagMode=all"); len(todos) != 0 {
+		t.Fatalf("Unexpected todos with all tags %+v", todos)
+	}
+	if code, _ := getTodos("tag=urgent&tagMode=some"); code != http.StatusBadRequest {
+		t.Fatalf("An invalid tag mode returned status %d", code)
+	}
+
+	w = httptest.NewRecorder()
+	s.UpdateTag(w, newPathRequest(http.MethodPatch, "/tags/"+strconv.Itoa(foreign.Id),
+		foreign.Id, `{"name": "mine"}`, owner))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Renaming the tag of another user returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.MergeTags(w, newPathRequest(http.MethodPost, "/tags/"+strconv.Itoa(urgent.Id),
+		urgent.Id, `{"into": `+strconv.Itoa(foreign.Id)+`}`, owner))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Merging into the tag of another user returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.MergeTags(w, newPathRequest(http.MethodPost, "/tags/"+strconv.Itoa(urgent.Id),
+		urgent.Id, `{"into": `+strconv.Itoa(work.Id)+`}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Merging the tags failed with status %d", w.Code)
+	}
+	if stored, _ := store.GetTodo(todo.Id); len(stored.Tags) != 1 || stored.Tags[0] != work {
+		t.Fatalf("The todo was not moved to the merged tag: %+v", stored.Tags)
+	}
+	w = httptest.NewRecorder()
+	s.DeleteTag(w, newPathRequest(http.MethodDelete, "/tags/"+strconv.Itoa(work.Id), work.Id, "", owner))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Deleting the tag failed with status %d", w.Code)
+	}
+	if tags, _ := store.GetTags(owner.Id); len(tags) != 0 {
+		t.Fatalf("Unexpected tags after deleting %+v", tags)
+	}
+}
@@ -3,23 +3,25 @@ package controllers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
-	"todo/db"
+	"time"
 	"todo/logger"
 	"todo/middlewares"
 	"todo/models"
 )
 
-func getTodoFromPathId(r *http.Request, w http.ResponseWriter) (models.Todo, error) {
+func (s *Server) getTodoFromPathId(r *http.Request, w http.ResponseWriter) (models.Todo, error) {
 	var todo models.Todo
 	todoId, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "No todo id was given in the request path", http.StatusBadRequest)
 		return todo, errors.New("No proper id was given for a todo in the request path")
 	}
-	todo, err = db.GetTodo(todoId)
+	todo, err = s.Todos.GetTodo(todoId)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return todo, err
@@ -30,56 +32,173 @@ func getTodoFromPathId(r *http.Request, w http.ResponseWriter) (models.Todo, err
 // GetTodo returns a [models.Todo] with an ID specified as a request path value. It also requires that the user is
 // authorized by [middlewares.AuthenticateUser] as it expects the request's context to have a user object.
 // If the query for the [models.Todo] succeeds it is returned as JSON in the response body.
-func GetTodo(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetTodo(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
 	if !ok {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
-	todo, err := getTodoFromPathId(r, w)
+	todo, err := s.getTodoFromPathId(r, w)
 	if err != nil {
 		logger.Error(err.Error())
 		return
 	}
-	if todo.UserId != user.Id {
-		//check if user is todoUser or has share on todo, then return
-		userShares, err := db.GetTodoShares(todo.Id)
+	canRead, err := s.canReadTodo(user, todo)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !canRead {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	json.NewEncoder(w).Encode(todo)
+}
+
+// canReadTodo reports whether user owns todo or it is shared with the user, directly or through its list.
+func (s *Server) canReadTodo(user models.User, todo models.Todo) (bool, error) {
+	if todo.UserId == user.Id {
+		return true, nil
+	}
+	userShares, err := s.Todos.GetTodoShares(todo.Id)
+	if err != nil || slices.Contains(userShares, user.Id) {
+		return err == nil, err
+	}
+	if todo.ListId == nil {
+		return false, nil
+	}
+	listShares, err := s.Lists.GetListShares(*todo.ListId)
+	return slices.Contains(listShares, user.Id), err
+}
+
+// parseFilterTime parses a time given as RFC 3339 timestamp or as date, which stands for the beginning of the date in
+// loc.
+func parseFilterTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, loc)
+}
+
+// parseDueFilter sets the due date bounds of filter from the query parameters "overdue", "today", "dueBefore" and
+// "dueAfter". Dates are interpreted in loc and the tightest bounds win if several parameters are given.
+func parseDueFilter(query url.Values, loc *time.Location, now time.Time, filter *models.TodoFilter) error {
+	filter.Location = loc
+	after := func(t time.Time) {
+		if filter.DueAfter == nil || t.After(*filter.DueAfter) {
+			filter.DueAfter = &t
+		}
+	}
+	before := func(t time.Time) {
+		if filter.DueBefore == nil || t.Before(*filter.DueBefore) {
+			filter.DueBefore = &t
+		}
+	}
+	if query.Has("overdue") {
+		notDone := false
+		filter.IsDone = &notDone
+		before(now)
+	}
+	if query.Has("today") {
+		local := now.In(loc)
+		today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		after(today)
+		before(today.AddDate(0, 0, 1))
+	}
+	for name, bound := range map[string]func(time.Time){"dueAfter": after, "dueBefore": before} {
+		if !query.Has(name) {
+			continue
+		}
+		t, err := parseFilterTime(query.Get(name), loc)
+		if err != nil {
+			return fmt.Errorf("%s has to be a RFC 3339 timestamp or a date like 2006-01-02", name)
+		}
+		bound(t)
+	}
+	return nil
+}
+
+// parseTodoFilter sets the due date, tag and dependency conditions and the order of filter from the query parameters.
+// Due dates are interpreted in the timezone of user. If the parameters are invalid, it responds with an HTTP status
+// code 400 (Bad Request) and returns false.
+func (s *Server) parseTodoFilter(w http.ResponseWriter, query url.Values, user models.User, filter *models.TodoFilter) bool {
+	if query.Has("overdue") || query.Has("today") || query.Has("dueBefore") || query.Has("dueAfter") {
+		profile, err := s.Users.GetUser(user.Id)
 		if err != nil {
+			logger.Error(err.Error())
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-			return
+			return false
 		}
-		if !slices.Contains(userShares, user.Id) {
-			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-			return
+		if err := parseDueFilter(query, profile.Location(), time.Now(), filter); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
 		}
 	}
-	json.NewEncoder(w).Encode(todo)
+	filter.Tags = slices.Clone(query["tag"])
+	slices.Sort(filter.Tags)
+	filter.Tags = slices.Compact(filter.Tags)
+	switch query.Get("tagMode") {
+	case "", "any":
+	case "all":
+		filter.AllTags = true
+	default:
+		http.Error(w, "tagMode has to be all or any", http.StatusBadRequest)
+		return false
+	}
+	filter.Actionable = query.Has("actionable")
+	if query.Has("sort") {
+		sorts, err := models.ParseTodoSort(query.Get("sort"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+		filter.Sort = sorts
+	}
+	return true
 }
 
-// GetTodos returns a list of [models.Todo] with an ID specified as a request path value. It also requires that the user is
-// authorized by [middlewares.AuthenticateUser] as it expects the request's context to have a user object.
+// GetTodos returns a list of [models.Todo] with an ID specified as a request path value. It also requires that the user
+// is authorized by [middlewares.AuthenticateUser] as it expects the request's context to have a user object.
+// The query parameter "shared" includes the todos shared with the user, directly or through a shared list. The query
+// parameters "overdue" for todos that are not done and due already, "today" for todos due today, "dueBefore" and
+// "dueAfter" only return todos with a due date. Their dates and all-day todos are interpreted in the timezone of the
+// user. The query parameter "actionable" only returns todos whose blockers are all done. With the query parameter
+// "tree" the todos are nested below their parents in the field "children". The todos are in their manual order unless
+// the query parameter "sort" gives a comma-separated list of keys like "priority,-dueAt,position", where a leading "-"
+// sorts in descending order, see [models.ParseTodoSort].
 // If the query for the list succeeds it is returned as JSON in the response body.
-func GetTodos(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetTodos(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
 	if !ok {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
+	query := r.URL.Query()
 	//check if shared flag is set
-	includeShared := r.URL.Query().Has("shared")
-	todos, err := db.GetTodos(user.Id, includeShared)
+	filter := models.TodoFilter{IncludeShared: query.Has("shared")}
+	if !s.parseTodoFilter(w, query, user, &filter) {
+		return
+	}
+	todos, err := s.Todos.GetTodos(user.Id, filter)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	if query.Has("tree") {
+		json.NewEncoder(w).Encode(models.NewTodoTrees(todos))
+		return
+	}
 	json.NewEncoder(w).Encode(todos)
 }
 
 // CreateTodo creates a [models.Todo] based on the corresponding fields in the request body. It expects the request
 // to be authorized by [middlewares.AuthenticateUser] as it expects the request's context to have a user object.
-// If the the object is created and persisted successfully it is returned as JSON in the response body.
-func CreateTodo(w http.ResponseWriter, r *http.Request) {
+// If the the object is created and persisted successfully it is returned as JSON in the response body. New todos start
+// as open, so a todo created with another status in a list whose workflow does not allow changing to it from open is
+// rejected with the status code 409 (Conflict) like such an update.
+func (s *Server) CreateTodo(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
 	if !ok {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
@@ -91,7 +210,43 @@ func CreateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	todo.UserId = user.Id
-	todo, err := db.CreateTodo(todo.Title, todo.Text, todo.UserId, todo.IsDone)
+	// new todos are placed last, they are only placed elsewhere by moving them
+	todo.Position = ""
+	// the status wins over isDone, and a todo that is created completed was completed now
+	if todo.Status != "" {
+		todo.IsDone = todo.Status.IsTerminal()
+	}
+	todo.NormalizeStatus()
+	todo.CompletedAt = nil
+	todo.SetStatus(todo.Status, time.Now())
+	todo.NormalizeDates()
+	if err := todo.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tags, err := s.ownTags(user.Id, todo.Tags)
+	if err != nil {
+		respondTagError(w, err)
+		return
+	}
+	todo.Tags = tags
+	if todo.ListId != nil {
+		if err := s.ownList(user.Id, *todo.ListId); err != nil {
+			respondListError(w, err)
+			return
+		}
+		if err := s.checkTransition(todo, models.StatusOpen); err != nil {
+			respondStatusError(w, err)
+			return
+		}
+	}
+	if todo.ParentId != nil {
+		if err := s.checkParent(todo, *todo.ParentId); err != nil {
+			respondSubtaskError(w, err)
+			return
+		}
+	}
+	todo, err = s.Todos.CreateTodo(todo)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -102,14 +257,15 @@ func CreateTodo(w http.ResponseWriter, r *http.Request) {
 
 // DeleteTodo deletes a [models.Todo] based on the request's path value. It expects the request
 // to be authorized by [middlewares.AuthenticateUser] as it expects the request's context to have a user object.
-// If the the object is deleted successfully it is returned as JSON in the response body.
-func DeleteTodo(w http.ResponseWriter, r *http.Request) {
+// If the the object is deleted successfully it is returned as JSON in the response body. The query parameter "subtasks"
+// overrides [Server.SubtaskPolicy] for the subtasks of the todo.
+func (s *Server) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
 	if !ok {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
-	todo, err := getTodoFromPathId(r, w)
+	todo, err := s.getTodoFromPathId(r, w)
 	if err != nil {
 		logger.Error(err.Error())
 		return
@@ -118,10 +274,14 @@ func DeleteTodo(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 		return
 	}
-	deletedTodo, err := db.DeleteTodo(todo.Id)
+	policy, err := s.subtaskPolicy(r)
 	if err != nil {
-		logger.Error(err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	deletedTodo, err := s.deleteTodo(todo, policy)
+	if err != nil {
+		respondSubtaskError(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(deletedTodo)
@@ -131,14 +291,20 @@ func DeleteTodo(w http.ResponseWriter, r *http.Request) {
 // specified as a path value. The request body with the fields to update must not provide all fields of a [models.Todo]
 // but only the fields that should be updated. It expects the request to be authorized by [middlewares.AuthenticateUser]
 // as it expects the request's context to have a user object. If the the object is updated successfully the updated
-// object is returned as JSON in the response body.
-func UpdateTodo(w http.ResponseWriter, r *http.Request) {
+// object is returned as JSON in the response body. A status change that the workflow of the list of the todo does not
+// allow is rejected with the status code 409 (Conflict), like completing a todo that is blocked by open todos unless
+// the query parameter "force" is given. When the todo is completed or cancelled, the query parameter "subtasks"
+// overrides [Server.SubtaskPolicy] for its open subtasks. Completed recurring todos are reopened for their
+// next occurrence in the timezone of the user with [models.Todo.Advance], unless their series has ended. Reminders
+// relative to the due date follow it when it changes. Enabling [models.Todo.ChecklistAutoComplete] completes a todo
+// whose checklist is already checked like [Server.UpdateChecklistItem] does.
+func (s *Server) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
 	if !ok {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
-	todo, err := getTodoFromPathId(r, w)
+	todo, err := s.getTodoFromPathId(r, w)
 	if err != nil {
 		logger.Error(err.Error())
 		return
@@ -153,8 +319,53 @@ func UpdateTodo(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
+	added := make([]models.Tag, len(todoUpdate.AddTags))
+	for i, tagId := range todoUpdate.AddTags {
+		added[i].Id = tagId
+	}
+	if _, err := s.ownTags(user.Id, added); err != nil {
+		respondTagError(w, err)
+		return
+	}
+	if todoUpdate.ListId.Int != nil {
+		if err := s.ownList(user.Id, *todoUpdate.ListId.Int); err != nil {
+			respondListError(w, err)
+			return
+		}
+	}
+	if todoUpdate.ParentId.Int != nil {
+		if err := s.checkParent(todo, *todoUpdate.ParentId.Int); err != nil {
+			respondSubtaskError(w, err)
+			return
+		}
+	}
+	before := todo
 	todo.Update(todoUpdate)
-	if err := db.UpdateTodo(todo); err != nil {
+	todo.NormalizeDates()
+	if err := todo.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	policy := s.SubtaskPolicy
+	if todo.IsDone && !before.IsDone {
+		if policy, err = s.subtaskPolicy(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.saveTodo(todo, before, policy, r.URL.Query().Has("force")); err != nil {
+		respondSaveTodoError(w, err)
+		return
+	}
+	if todo.ChecklistAutoComplete && !before.ChecklistAutoComplete {
+		if err := s.autoCompleteTodo(todo.Id); err != nil {
+			logger.Error(err.Error())
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	// fetch the todo again for the names and colors of the added tags and a completion by its checklist
+	if todo, err = s.Todos.GetTodo(todo.Id); err != nil {
 		logger.Error(err.Error())
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -166,6 +377,57 @@ func UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// saveTodo stores todo after it was changed from before. A new status has to be allowed by the workflow of the list of
+// the todo, and unless force is set a completed todo must not be blocked by open todos. When the todo is completed or
+// cancelled, policy is applied to its open subtasks, which are stored in the same transaction as todo, and completed
+// recurring todos are reopened for their next occurrence in the timezone of the owner with [models.Todo.Advance].
+// Reminders relative to the due date follow it when it changes.
+func (s *Server) saveTodo(todo models.Todo, before models.Todo, policy models.SubtaskPolicy, force bool) error {
+	if err := s.checkTransition(todo, before.Status); err != nil {
+		return err
+	}
+	if todo.Status == models.StatusDone && before.Status != models.StatusDone && !force {
+		if err := s.checkBlockers(todo); err != nil {
+			return err
+		}
+	}
+	var changed []models.Todo
+	if todo.IsDone && !before.IsDone {
+		var err error
+		if changed, err = s.completeSubtasks(todo, policy); err != nil {
+			return err
+		}
+		// a cancelled recurring todo is not repeated
+		if todo.Recurrence != "" && todo.Status == models.StatusDone {
+			owner, err := s.Users.GetUser(todo.UserId)
+			if err != nil {
+				return err
+			}
+			todo.Advance(owner.Location())
+		}
+	}
+	if err := s.Todos.UpdateTodos(append(changed, todo)); err != nil {
+		return err
+	}
+	due, allDay := before.DueAt, before.AllDay
+	if todo.AllDay != allDay || (todo.DueAt == nil) != (due == nil) || (due != nil && !todo.DueAt.Equal(*due)) {
+		return s.rescheduleReminders(todo)
+	}
+	return nil
+}
+
+// respondSaveTodoError responds with the HTTP status code matching an error of [Server.saveTodo].
+func respondSaveTodoError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errOpenBlockers):
+		respondDependencyError(w, err)
+	case errors.Is(err, errOpenSubtasks):
+		respondSubtaskError(w, err)
+	default:
+		respondStatusError(w, err)
+	}
+}
+
 type TodoShare struct {
 	Id     int  `json:"id"`
 	TodoId *int `json:"todoId"`
@@ -173,13 +435,13 @@ type TodoShare struct {
 }
 
 // ShareTodo shares a [model.Todo] with another [models.User] that is not the creator of the [models.Todo].
-func ShareTodo(w http.ResponseWriter, r *http.Request) {
+func (s *Server) ShareTodo(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
 	if !ok {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
-	todo, err := getTodoFromPathId(r, w)
+	todo, err := s.getTodoFromPathId(r, w)
 	if err != nil {
 		logger.Error(err.Error())
 		return
@@ -202,7 +464,7 @@ func ShareTodo(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "You are trying to share your own Todo with yourself.", http.StatusBadRequest)
 		return
 	}
-	shareId, err := db.CreateTodoShare(*todoShare.TodoId, *todoShare.UserId)
+	shareId, err := s.Todos.CreateTodoShare(*todoShare.TodoId, *todoShare.UserId)
 	if err != nil {
 		logger.Error(err.Error())
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -217,13 +479,13 @@ func ShareTodo(w http.ResponseWriter, r *http.Request) {
 }
 
 // UnshareTodo removes a [models.User] from the users a [model.Todo] is shared with.
-func UnshareTodo(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UnshareTodo(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
 	if !ok {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
-	todo, err := getTodoFromPathId(r, w)
+	todo, err := s.getTodoFromPathId(r, w)
 	if err != nil {
 		logger.Error(err.Error())
 		return
@@ -242,7 +504,7 @@ func UnshareTodo(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 		return
 	}
-	shareId, err := db.DeleteTodoShare(*todoShare.TodoId, *todoShare.UserId)
+	shareId, err := s.Todos.DeleteTodoShare(*todoShare.TodoId, *todoShare.UserId)
 	todoShare.Id = shareId
 	if err := json.NewEncoder(w).Encode(todoShare); err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"todo/db"
+	"todo/middlewares"
+	"todo/models"
+	"todo/password"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestServer() (*Server, *db.MemoryStore) {
+	store := db.NewMemoryStore()
+	// the lowest bcrypt cost keeps the tests fast
+	store.Hasher = password.Hasher{Algorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost}
+	return NewServer(store), store
+}
+
+func newUserRequest(method string, target string, body string, user models.User) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	return r.WithContext(context.WithValue(r.Context(), middlewares.ContextUserKey, user))
+}
+
+// newPathRequest returns a request of user to target, which addresses the resource with the path value "id".
+func newPathRequest(method string, target string, id int, body string, user models.User) *http.Request {
+	r := newUserRequest(method, target, body, user)
+	r.SetPathValue("id", strconv.Itoa(id))
+	return r
+}
+
+func newTodoRequest(method string, todoId int, body string, user models.User) *http.Request {
+	return newPathRequest(method, "/todos/"+strconv.Itoa(todoId), todoId, body, user)
+}
+
+func TestServer_CreateTodo(t *testing.T) {
+	s, store := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	w := httptest.NewRecorder()
+	s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", `{"title": "cool title", "text": "cool text"}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Creating a todo failed with status %d", w.Code)
+	}
+	var created models.Todo
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.UserId != owner.Id || created.Title != "cool title" {
+		t.Fatalf("The created todo does not match the request: %+v", created)
+	}
+	if _, err := store.GetTodo(created.Id); err != nil {
+		t.Fatalf("The created todo was not persisted: %v", err)
+	}
+}
+
+func TestServer_CreateTodo_Unauthorized(t *testing.T) {
+	s, _ := newTestServer()
+	w := httptest.NewRecorder()
+	s.CreateTodo(w, httptest.NewRequest(http.MethodPost, "/todos", strings.NewReader(`{"title": "a"}`)))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status %d but got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestServer_GetTodo_Shares(t *testing.T) {
+	s, store := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	other := models.User{Id: 2, Name: "jane"}
+	todo, _ := store.CreateTodo(models.Todo{Title: "title", Text: "text", UserId: owner.Id})
+
+	w := httptest.NewRecorder()
+	s.GetTodo(w, newTodoRequest(http.MethodGet, todo.Id, "", other))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("A todo that is not shared was accessible by another user (status %d)", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.ShareTodo(w, newTodoRequest(http.MethodPost, todo.Id, `{"userId": 2}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Sharing the todo failed with status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.GetTodo(w, newTodoRequest(http.MethodGet, todo.Id, "", other))
+	if w.Code != http.StatusOK {
+		t.Fatalf("A shared todo was not accessible (status %d)", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.UnshareTodo(w, newTodoRequest(http.MethodDelete, todo.Id, `{"userId": 2}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Revoking the share failed with status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.GetTodo(w, newTodoRequest(http.MethodGet, todo.Id, "", other))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("A todo was still accessible after revoking its share (status %d)", w.Code)
+	}
+}
+
+func TestServer_UpdateTodo(t *testing.T) {
+	s, store := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	todo, _ := store.CreateTodo(models.Todo{Title: "title", Text: "text", UserId: owner.Id})
+
+	w := httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, `{"isDone": true}`, models.User{Id: 2}))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Another user was able to update the todo (status %d)", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.UpdateTodo(w, newTodoRequest(http.MethodPatch, todo.Id, `{"isDone": true}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Updating the todo failed with status %d", w.Code)
+	}
+	updated, _ := store.GetTodo(todo.Id)
+	if !updated.IsDone || updated.Title != todo.Title {
+		t.Fatalf("The todo was not updated correctly: %+v", updated)
+	}
+}
+
+func TestServer_DeleteTodo(t *testing.T) {
+	s, store := newTestServer()
+	owner := models.User{Id: 1, Name: "john"}
+	todo, _ := store.CreateTodo(models.Todo{Title: "title", Text: "text", UserId: owner.Id})
+
+	w := httptest.NewRecorder()
+	s.DeleteTodo(w, newTodoRequest(http.MethodDelete, todo.Id, "", owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Deleting the todo failed with status %d", w.Code)
+	}
+	if _, err := store.GetTodo(todo.Id); err == nil {
+		t.Fatalf("The todo still exists after deleting it")
+	}
+}
+
+func TestServer_GetTodos_Due(t *testing.T) {
+	s, store := newTestServer()
+	owner, _ := store.CreateUser(models.User{Name: "john"})
+	w := httptest.NewRecorder()
+	s.UpdateAccount(w, newUserRequest(http.MethodPatch, "/me", `{"timezone": "Mars/Olympus"}`, owner))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Setting an unknown timezone returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.UpdateAccount(w, newUserRequest(http.MethodPatch, "/me", `{"timezone": "Pacific/Kiritimati"}`, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Setting the timezone failed with status %d", w.Code)
+	}
+
+	createTodo := func(body string) models.Todo {
+		w := httptest.NewRecorder()
+		s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", body, owner))
+		var todo models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todo); err != nil {
+			t.Fatalf("Creating %s returned status %d: %v", body, w.Code, err)
+		}
+		return todo
+	}
+	// it is already tomorrow in Kiritimati (UTC+14) for most of the day in UTC
+	loc, _ := time.LoadLocation("Pacific/Kiritimati")
+	today := time.Now().In(loc).Format(time.DateOnly)
+	yesterday := time.Now().In(loc).AddDate(0, 0, -1).Format(time.DateOnly)
+	dueToday := createTodo(`{"title": "today", "dueAt": "` + today + `T00:00:00Z", "allDay": true}`)
+	overdue := createTodo(`{"title": "yesterday", "dueAt": "` + yesterday + `T00:00:00Z", "allDay": true}`)
+	createTodo(`{"title": "done", "dueAt": "` + yesterday + `T00:00:00Z", "allDay": true, "isDone": true}`)
+	createTodo(`{"title": "undated"}`)
+
+	getTodos := func(query string) []models.Todo {
+		w := httptest.NewRecorder()
+		s.GetTodos(w, newUserRequest(http.MethodGet, "/todos?"+query, "", owner))
+		var todos []models.Todo
+		if err := json.NewDecoder(w.Body).Decode(&todos); err != nil {
+			t.Fatalf("Getting the todos with %q returned status %d: %v", query, w.Code, err)
+		}
+		return todos
+	}
+	if todos := getTodos("today"); len(todos) != 1 || todos[0].Id != dueToday.Id {
+		t.Fatalf("Unexpected todos due today %+v", todos)
+	}
+	if todos := getTodos("overdue"); len(todos) != 1 || todos[0].Id != overdue.Id {
+		t.Fatalf("Unexpected overdue todos %+v", todos)
+	}
+	if todos := getTodos("dueAfter=" + yesterday + "&dueBefore=" + today); len(todos) != 2 {
+		t.Fatalf("Unexpected todos due yesterday %+v", todos)
+	}
+	w = httptest.NewRecorder()
+	s.GetTodos(w, newUserRequest(http.MethodGet, "/todos?dueBefore=tomorrow", "", owner))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("An invalid date returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.CreateTodo(w, newUserRequest(http.MethodPost, "/todos", `{"title": "a", "startAt": "2024-06-02T00:00:00Z", "dueAt": "2024-06-01T00:00:00Z"}`, owner))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("A todo starting after it is due returned status %d", w.Code)
+	}
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"todo/db"
+	"todo/logger"
+	"todo/middlewares"
+	"todo/models"
+	"todo/totp"
+)
+
+// totpIssuer is the name authenticator apps show next to the account.
+const totpIssuer = "todo"
+
+type codeRequest struct {
+	Code *string `json:"code"`
+}
+
+type completeLoginRequest struct {
+	Challenge *string `json:"challenge"`
+	Code      *string `json:"code"`
+}
+
+// EnrollTOTP creates a new secret for time-based one-time passwords and new recovery codes for the user. The response
+// body contains the secret, its otpauth URI and the recovery codes as a [models.TOTPEnrollment], none of them can be
+// retrieved again. The second factor is only required after it was confirmed with [Server.ConfirmTOTP]. If the user
+// already confirmed a secret, it responds with an HTTP status code 409 (Conflict).
+// It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	enrollment, err := s.TwoFactor.EnrollTOTP(user.Id)
+	if errors.Is(err, db.ErrTOTPEnabled) {
+		http.Error(w, "Two-factor authentication is already enabled.", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	enrollment.URI = totp.URI(totpIssuer, user.Name, enrollment.Secret)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// ConfirmTOTP enables the secret created by [Server.EnrollTOTP] if the code in the request body is a valid one-time
+// password for it. If the code is wrong, it responds with an HTTP status code 400 (Bad Request) and if nothing is
+// enrolled with 404 (Not Found). It expects the request to be authorized by [middlewares.AuthenticateUser].
+func (s *Server) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var req codeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	writeCodeResult(w, s.TwoFactor.ConfirmTOTP(user.Id, *req.Code))
+}
+
+// DisableTOTP removes the second factor of the user if the code in the request body is a valid one-time password or
+// recovery code. If the code is wrong, it responds with an HTTP status code 400 (Bad Request) and if two-factor
+// authentication is not enabled with 404 (Not Found). It expects the request to be authorized by
+// [middlewares.AuthenticateUser].
+func (s *Server) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var req codeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	writeCodeResult(w, s.TwoFactor.DisableTOTP(user.Id, *req.Code))
+}
+
+// writeCodeResult responds to a request that checked a code with 204 (No Content) if err is nil.
+func writeCodeResult(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, db.ErrInvalidCode):
+		http.Error(w, "The code is invalid.", http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	default:
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// CompleteLogin completes a login that returned a challenge with the challenge and a one-time password or recovery
+// code in the request body. The response body has the same format as the one of [Server.LoginUser]. If the challenge
+// expired, the code is wrong or too many wrong codes were entered, it responds with an HTTP status code 401
+// (Unauthorized). Wrong codes count as failed logins of the user name, which are forgotten once the login is completed.
+func (s *Server) CompleteLogin(w http.ResponseWriter, r *http.Request) {
+	var req completeLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Challenge == nil || req.Code == nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	tokens, err := s.TwoFactor.CompleteLogin(*req.Challenge, *req.Code, clientFromRequest(r))
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if err := s.LoginAttempts.ResetLoginFailures(tokens.User.Name); err != nil {
+		logger.Error(err.Error())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newLoginResponse(tokens))
+}
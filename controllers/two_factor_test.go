@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"todo/models"
+	"todo/totp"
+)
+
+func TestServer_TwoFactorLogin(t *testing.T) {
+	s, store := newTestServer()
+	user := models.User{Name: "john"}
+	user.SetPassword("doe")
+	user, _ = store.CreateUser(user)
+
+	w := httptest.NewRecorder()
+	s.EnrollTOTP(w, newUserRequest(http.MethodPost, "/2fa/totp", "", user))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Enrolling failed with status %d", w.Code)
+	}
+	var enrollment models.TOTPEnrollment
+	if err := json.NewDecoder(w.Body).Decode(&enrollment); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/todo:john?") || len(enrollment.RecoveryCodes) == 0 {
+		t.Fatalf("Unexpected enrollment %+v", enrollment)
+	}
+	w = httptest.NewRecorder()
+	s.ConfirmTOTP(w, newUserRequest(http.MethodPost, "/2fa/totp/confirm", `{"code": "abcdef"}`, user))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Confirming with a wrong code returned status %d", w.Code)
+	}
+	code, _ := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	w = httptest.NewRecorder()
+	s.ConfirmTOTP(w, newUserRequest(http.MethodPost, "/2fa/totp/confirm", `{"code": "`+code+`"}`, user))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Confirming failed with status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.LoginUser(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"name": "john", "password": "doe"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("The login failed with status %d", w.Code)
+	}
+	var challenge challengeResponse
+	if err := json.NewDecoder(w.Body).Decode(&challenge); err != nil || challenge.Challenge == "" {
+		t.Fatalf("The login did not return a challenge: %+v (%v)", challenge, err)
+	}
+	completeLogin := func(code string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := `{"challenge": "` + challenge.Challenge + `", "code": "` + code + `"}`
+		s.CompleteLogin(w, httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(body)))
+		return w
+	}
+	if w := completeLogin("abcdef"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Completing the login with a wrong code returned status %d", w.Code)
+	}
+	if account, _, err := store.GetLoginFailures("john", ""); err != nil || account.Count != 1 {
+		t.Fatalf("Expected the wrong code to be counted as a failed login but got %+v (%v)", account, err)
+	}
+	w = completeLogin(enrollment.RecoveryCodes[0])
+	if w.Code != http.StatusOK {
+		t.Fatalf("Completing the login failed with status %d", w.Code)
+	}
+	if account, _, err := store.GetLoginFailures("john", ""); err != nil || account.Count != 0 {
+		t.Fatalf("The failed logins were not forgotten after completing the login: %+v (%v)", account, err)
+	}
+	var response loginResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if authenticated, _, err := store.AuthenticateUser(response.Token); err != nil || authenticated.Id != user.Id {
+		t.Fatalf("The returned token is not valid: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	s.DisableTOTP(w, newUserRequest(http.MethodDelete, "/2fa/totp", `{"code": "`+enrollment.RecoveryCodes[1]+`"}`, user))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Disabling failed with status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.DisableTOTP(w, newUserRequest(http.MethodDelete, "/2fa/totp", `{"code": "`+enrollment.RecoveryCodes[2]+`"}`, user))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Disabling twice returned status %d", w.Code)
+	}
+}
@@ -2,24 +2,45 @@ package controllers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
-	"todo/db"
+	"todo/logger"
+	"todo/middlewares"
 	"todo/models"
 )
 
-func GetUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := db.GetUsers()
+// GetUsers returns the [models.User]s as JSON in the response body. It expects the request to be authorized by
+// [middlewares.AuthenticateUser]. Admins get all users with their roles and whether they are disabled, members only
+// get the ids and names of the users that are not disabled, which is what they need to share todos.
+func (s *Server) GetUsers(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middlewares.ContextUserKey).(models.User)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if user.Role == models.RoleAdmin {
+		s.AdminGetUsers(w, r)
+		return
+	}
+	users, err := s.Users.GetUsers()
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	visible := []models.User{}
+	for _, u := range users {
+		if !u.Disabled {
+			visible = append(visible, models.User{Id: u.Id, Name: u.Name})
+		}
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(visible)
 }
 
-// CreateUser creates a user from the username and password specified in the request body. If it succeeds it returns a
-// token in the response body.
-func CreateUser(w http.ResponseWriter, r *http.Request) {
+// CreateUser creates a user from the username and password specified in the request body. If it succeeds it returns
+// the tokens of a new session in the response body like [Server.LoginUser]. If the password violates
+// [Server.PasswordPolicy], it responds with an HTTP status code 400 (Bad Request) and the violated rule.
+func (s *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var userCreate models.UserLogin
 	if err := json.NewDecoder(r.Body).Decode(&userCreate); err != nil {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
@@ -30,16 +51,20 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
-	newUser, err := db.CreateUser(user)
+	if err := user.CheckPasswordPolicy(s.PasswordPolicy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	newUser, err := s.Users.CreateUser(user)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
-	token, err := db.LoginUser(newUser)
+	tokens, err := s.Users.LoginUser(newUser, clientFromRequest(r))
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(loginResponse{Token: token})
+	json.NewEncoder(w).Encode(newLoginResponse(tokens))
 }
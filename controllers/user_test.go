@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"todo/models"
+)
+
+func TestServer_CreateUser(t *testing.T) {
+	s, store := newTestServer()
+	w := httptest.NewRecorder()
+	s.CreateUser(w, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "john", "password": "battery staple"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Creating a user failed with status %d", w.Code)
+	}
+	var response loginResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := store.AuthenticateUser(response.Token)
+	if err != nil {
+		t.Fatalf("The returned token is not valid: %v", err)
+	}
+	if user.Name != "john" {
+		t.Fatalf("The token belongs to user %s instead of john", user.Name)
+	}
+
+	w = httptest.NewRecorder()
+	s.CreateUser(w, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "john"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Creating a user without password returned status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.CreateUser(w, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "jane", "password": "password1"}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "too common") {
+		t.Fatalf("Creating a user with a common password returned status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestServer_RefreshToken(t *testing.T) {
+	s, store := newTestServer()
+	user := models.User{Name: "john"}
+	user.SetPassword("doe")
+	user, _ = store.CreateUser(user)
+	tokens, _ := store.LoginUser(user, models.Client{})
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		body := `{"refreshToken": "` + refreshToken + `"}`
+		s.RefreshToken(w, httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(body)))
+		return w
+	}
+	w := refresh(tokens.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Refreshing the token failed with status %d", w.Code)
+	}
+	var response loginResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Token == "" || response.RefreshToken == "" || !response.ExpiresAt.Before(response.RefreshExpiresAt) {
+		t.Fatalf("The refresh response is incomplete: %+v", response)
+	}
+	if w := refresh(tokens.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Reusing a refresh token returned status %d", w.Code)
+	}
+	if w := refresh(response.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("The session was not revoked after a refresh token was reused (status %d)", w.Code)
+	}
+}
+
+func TestServer_GetUsers(t *testing.T) {
+	s, store := newTestServer()
+	admin, _ := store.CreateUser(models.User{Name: "admin", Role: models.RoleAdmin})
+	member, _ := store.CreateUser(models.User{Name: "john"})
+	disabled, _ := store.CreateUser(models.User{Name: "jane"})
+	store.SetUserDisabled(disabled.Id, true)
+
+	getUsers := func(user models.User) []models.User {
+		w := httptest.NewRecorder()
+		s.GetUsers(w, newUserRequest(http.MethodGet, "/users", "", user))
+		var users []models.User
+		if err := json.NewDecoder(w.Body).Decode(&users); err != nil {
+			t.Fatalf("Listing the users returned status %d: %v", w.Code, err)
+		}
+		return users
+	}
+	if users := getUsers(admin); len(users) != 3 || users[0].Role != models.RoleAdmin || !users[2].Disabled {
+		t.Fatalf("Admins don't get all users with their roles: %+v", users)
+	}
+	users := getUsers(member)
+	if len(users) != 2 || users[0] != (models.User{Id: admin.Id, Name: "admin"}) || users[1].Id != member.Id {
+		t.Fatalf("Members get other users than the enabled ones without roles: %+v", users)
+	}
+}
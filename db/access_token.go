@@ -0,0 +1,114 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"todo/logger"
+	"todo/models"
+)
+
+const accessTokenColumns = `a.id, a.user_id, a.name, a.scopes, a.created_at, a.last_used_at, a.expires_at`
+
+// scanAccessToken scans a row with the columns of accessTokenColumns preceded by dest into token.
+func scanAccessToken(row interface{ Scan(...any) error }, token *models.AccessToken, dest ...any) error {
+	var scopes string
+	var lastUsedAt, expiresAt sql.NullTime
+	dest = append(dest, &token.Id, &token.UserId, &token.Name, &scopes, &token.CreatedAt, &lastUsedAt, &expiresAt)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	token.Scopes = parseScopes(scopes)
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	return nil
+}
+
+// CreateAccessToken inserts a new personal access token for the user with id userId. Only the hash of the returned
+// token is stored. A nil expiresAt creates a token that does not expire.
+func (s *SQLStore) CreateAccessToken(userId int, name string, scopes []models.Scope, expiresAt *time.Time) (models.AccessToken, string, error) {
+	accessToken := models.AccessToken{
+		UserId:    userId,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	var expiration sql.NullTime
+	if expiresAt != nil {
+		expiration = sql.NullTime{Time: expiresAt.UTC(), Valid: true}
+	}
+	stmt := `INSERT INTO access_tokens (user_id, name, token_hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id`
+	for {
+		token, err := newAccessToken()
+		if err != nil {
+			return accessToken, "", err
+		}
+		err = s.queryRow(stmt, userId, name, hashToken(token), formatScopes(scopes), accessToken.CreatedAt,
+			expiration).Scan(&accessToken.Id)
+		if err == nil {
+			return accessToken, token, nil
+		}
+		if !s.dialect.isUniqueViolation(err) {
+			return accessToken, "", err
+		}
+		newErr := fmt.Errorf("CreateAccessToken: Token generation collision: %w", err)
+		logger.Warning(newErr.Error())
+	}
+}
+
+// GetAccessTokens returns all personal access tokens of the user with id userId including expired ones.
+func (s *SQLStore) GetAccessTokens(userId int) ([]models.AccessToken, error) {
+	var tokens []models.AccessToken
+	stmt := `SELECT ` + accessTokenColumns + ` FROM access_tokens AS a WHERE a.user_id = ? ORDER BY a.id`
+	rows, err := s.query(stmt, userId)
+	if err != nil {
+		return tokens, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var token models.AccessToken
+		if err := scanAccessToken(rows, &token); err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAccessToken deletes the access token tokenId if it belongs to the user with id userId. If there is no such
+// token [sql.ErrNoRows] is returned.
+func (s *SQLStore) DeleteAccessToken(userId int, tokenId int) error {
+	stmt := `DELETE FROM access_tokens WHERE id = ? AND user_id = ?`
+	result, err := s.exec(stmt, tokenId, userId)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AuthenticateAccessToken looks up the personal access token by its hash and returns it together with its user if it
+// has not expired and its user is not disabled. The last use of the token is updated.
+func (s *SQLStore) AuthenticateAccessToken(token string) (models.User, models.AccessToken, error) {
+	var user models.User
+	var accessToken models.AccessToken
+	now := time.Now().UTC()
+	stmt := `SELECT u.id, u.name, u.role, ` + accessTokenColumns + ` FROM access_tokens AS a JOIN users AS u ON (u.id = a.user_id) WHERE a.token_hash = ? AND (a.expires_at IS NULL OR a.expires_at > ?) AND NOT u.disabled`
+	if err := scanAccessToken(s.queryRow(stmt, hashToken(token), now), &accessToken, &user.Id, &user.Name, &user.Role); err != nil {
+		return user, accessToken, err
+	}
+	if _, err := s.exec(`UPDATE access_tokens SET last_used_at = ? WHERE id = ?`, now, accessToken.Id); err != nil {
+		return user, accessToken, err
+	}
+	accessToken.LastUsedAt = &now
+	return user, accessToken, nil
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"todo/models"
+)
+
+const checklistItemColumns = `id, todo_id, text, checked, position`
+
+// scanChecklistItem scans a row with the columns of checklistItemColumns into item.
+func scanChecklistItem(row interface{ Scan(...any) error }, item *models.ChecklistItem) error {
+	return row.Scan(&item.Id, &item.TodoId, &item.Text, &item.Checked, &item.Position)
+}
+
+// CreateChecklistItem inserts item into 'checklist_items' and returns it with its id set. An item without a position
+// is placed after the other items of its todo.
+func (s *SQLStore) CreateChecklistItem(item models.ChecklistItem) (models.ChecklistItem, error) {
+	for {
+		created, err := s.createChecklistItem(item)
+		// another item created at the same time took the last position, which the unique index rejects
+		if item.Position != "" || !errors.Is(err, ErrPositionTaken) {
+			return created, err
+		}
+	}
+}
+
+// createChecklistItem inserts item like [SQLStore.CreateChecklistItem] in one attempt. It returns [ErrPositionTaken]
+// if the position violates the unique index on the positions of the items of a todo.
+func (s *SQLStore) createChecklistItem(item models.ChecklistItem) (models.ChecklistItem, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return item, err
+	}
+	defer tx.Rollback()
+	if item.Position == "" {
+		var last string
+		stmt := `SELECT COALESCE(MAX(position), '') FROM checklist_items WHERE todo_id = ?`
+		if err := tx.QueryRow(s.dialect.rebind(stmt), item.TodoId).Scan(&last); err != nil {
+			return item, err
+		}
+		if item.Position, err = models.PositionBetween(last, ""); err != nil {
+			return item, err
+		}
+	}
+	stmt := `INSERT INTO checklist_items (todo_id, text, checked, position) VALUES (?, ?, ?, ?) RETURNING id`
+	err = tx.QueryRow(s.dialect.rebind(stmt), item.TodoId, item.Text, item.Checked, item.Position).Scan(&item.Id)
+	if s.dialect.isUniqueViolation(err) {
+		return item, ErrPositionTaken
+	}
+	if err != nil {
+		return item, err
+	}
+	return item, tx.Commit()
+}
+
+// GetChecklistItems selects the items of the todo todoId from 'checklist_items' ordered by position.
+func (s *SQLStore) GetChecklistItems(todoId int) ([]models.ChecklistItem, error) {
+	items := []models.ChecklistItem{}
+	rows, err := s.query(`SELECT `+checklistItemColumns+` FROM checklist_items WHERE todo_id = ? ORDER BY position, id`, todoId)
+	if err != nil {
+		return items, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item models.ChecklistItem
+		if err := scanChecklistItem(rows, &item); err != nil {
+			return items, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetChecklistItem selects the item with id itemId from 'checklist_items'.
+func (s *SQLStore) GetChecklistItem(itemId int) (models.ChecklistItem, error) {
+	var item models.ChecklistItem
+	err := scanChecklistItem(s.queryRow(`SELECT `+checklistItemColumns+` FROM checklist_items WHERE id = ?`, itemId), &item)
+	return item, err
+}
+
+// UpdateChecklistItem sets the text, checked state and position of the row in 'checklist_items' with the id of item.
+func (s *SQLStore) UpdateChecklistItem(item models.ChecklistItem) error {
+	return s.UpdateChecklistItems([]models.ChecklistItem{item})
+}
+
+// UpdateChecklistItems updates each of items like [SQLStore.UpdateChecklistItem] in a single transaction. If one of
+// them does not exist, sql.ErrNoRows is returned and none of them are changed.
+func (s *SQLStore) UpdateChecklistItems(items []models.ChecklistItem) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt := s.dialect.rebind(`UPDATE checklist_items SET text = ?, checked = ?, position = ? WHERE id = ?`)
+	for _, item := range items {
+		result, err := tx.Exec(stmt, item.Text, item.Checked, item.Position, item.Id)
+		if s.dialect.isUniqueViolation(err) {
+			return ErrPositionTaken
+		}
+		if err != nil {
+			return err
+		}
+		if count, err := result.RowsAffected(); err != nil {
+			return err
+		} else if count == 0 {
+			return sql.ErrNoRows
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteChecklistItem deletes the item with id itemId from 'checklist_items'.
+func (s *SQLStore) DeleteChecklistItem(itemId int) error {
+	return s.updateOne(`DELETE FROM checklist_items WHERE id = ?`, itemId)
+}
+
+// loadChecklists sets the checklist progress of todos, which is counted with a single query.
+func (s *SQLStore) loadChecklists(todos []models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	indexes := make(map[int]int, len(todos))
+	placeholders := make([]string, len(todos))
+	args := make([]any, len(todos))
+	for i := range todos {
+		indexes[todos[i].Id] = i
+		placeholders[i] = "?"
+		args[i] = todos[i].Id
+		todos[i].Checklist = models.ChecklistProgress{}
+	}
+	stmt := `SELECT todo_id, SUM(CASE WHEN checked THEN 1 ELSE 0 END), COUNT(*) FROM checklist_items
+		WHERE todo_id IN (` + strings.Join(placeholders, ", ") + `) GROUP BY todo_id`
+	rows, err := s.query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var todoId int
+		var progress models.ChecklistProgress
+		if err := rows.Scan(&todoId, &progress.Checked, &progress.Total); err != nil {
+			return err
+		}
+		todos[indexes[todoId]].Checklist = progress
+	}
+	return rows.Err()
+}
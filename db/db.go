@@ -1,19 +1,431 @@
 package db
 
 import (
-	"database/sql"
-	"log"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"todo/models"
+	"todo/password"
 )
 
-var db *sql.DB = nil
+// TokenLifetimes configures how long the tokens issued for a session stay valid. Every refresh of a session extends
+// it by the refresh lifetime, so sessions that are used regularly do not expire.
+type TokenLifetimes struct {
+	Access  time.Duration
+	Refresh time.Duration
+}
+
+// DefaultTokenLifetimes are the lifetimes used by a store unless they are configured otherwise.
+var DefaultTokenLifetimes = TokenLifetimes{
+	Access:  time.Minute * time.Duration(15),
+	Refresh: time.Hour * time.Duration(24*30),
+}
+
+// ErrInvalidCredentials is returned by [UserStore.LoginUser] if the user does not exist or the password is wrong. Both
+// cases take about the same time, so that neither the error nor the response time tell which users exist.
+var ErrInvalidCredentials = errors.New("the user name or password is wrong")
+
+// ErrUserDisabled is returned if a disabled user logs in with correct credentials.
+var ErrUserDisabled = errors.New("the user is disabled")
+
+// ErrRefreshTokenReused is returned when a refresh token is presented that was already exchanged before. As this
+// indicates that the token was stolen, the whole session is revoked.
+var ErrRefreshTokenReused = errors.New("refresh token was already used, the session is revoked")
+
+// ErrSecondFactorRequired is returned by [UserStore.LoginUser] if the password is correct but the user enabled a second
+// factor. The returned tokens only carry a challenge that has to be completed with [TwoFactorStore.CompleteLogin].
+var ErrSecondFactorRequired = errors.New("the login has to be completed with a second factor")
+
+// ErrInvalidCode is returned if a one-time password or recovery code is wrong or was already used.
+var ErrInvalidCode = errors.New("invalid code")
+
+// ErrTOTPEnabled is returned when enrolling a user that already confirmed a time-based one-time password.
+var ErrTOTPEnabled = errors.New("time-based one-time passwords are already enabled")
+
+// ErrTagExists is returned if a user already has a tag with the same name.
+var ErrTagExists = errors.New("a tag with this name already exists")
+
+// ErrWrongPassword is returned if the current password given to change it is wrong.
+var ErrWrongPassword = errors.New("the password is wrong")
+
+// ErrDependencyExists is returned when creating a dependency that already exists.
+var ErrDependencyExists = errors.New("the dependency already exists")
+
+// ErrDependencyCycle is returned when creating a dependency whose blocker already waits for its todo, directly or
+// through other todos.
+var ErrDependencyCycle = errors.New("the dependency would make a todo wait for itself")
+
+// ErrPositionTaken is returned if another todo of the same user or another item of the same checklist already has the
+// position of a todo or checklist item.
+var ErrPositionTaken = errors.New("the position is already taken")
+
+// TodoStore is the persistence layer for [models.Todo]s and the shares of a [models.Todo] with other users.
+type TodoStore interface {
+	// CreateTodo persists todo and attaches the tags with the ids of its tags. A todo without a position is placed
+	// after all other todos of its user, also if other todos are created at the same time. On success the todo is
+	// returned with its id and position set. If another todo of its user has its position, [ErrPositionTaken] is
+	// returned.
+	CreateTodo(todo models.Todo) (models.Todo, error)
+	// GetTodos fetches the [models.Todo]s created by the user with id userId that match filter, in the order of
+	// [models.TodoFilter.Sort]. If [models.TodoFilter.IncludeShared] is set, the todos shared with that user and the
+	// todos in lists shared with that user are also included.
+	GetTodos(userId int, filter models.TodoFilter) ([]models.Todo, error)
+	// GetTodo fetches the [models.Todo] with the [models.Todo.Id] equal to todoId.
+	GetTodo(todoId int) (models.Todo, error)
+	// UpdateTodo sets all editable fields of the stored [models.Todo] with the same id to the values of todo. The tags
+	// with the ids of its tags are attached to it instead of the previous ones. If another todo of its user has its
+	// position, [ErrPositionTaken] is returned.
+	UpdateTodo(todo models.Todo) error
+	// UpdateTodos updates todos like UpdateTodo in a single transaction, so that either all of them or none of them
+	// are changed.
+	UpdateTodos(todos []models.Todo) error
+	// DeleteTodo deletes the [models.Todo] with the [models.Todo.Id] equal to todoId and returns it. Its subtasks are
+	// kept as top-level todos.
+	DeleteTodo(todoId int) (models.Todo, error)
+	// DeleteTodoWithSubtasks deletes the [models.Todo] with the [models.Todo.Id] equal to todoId together with its
+	// subtasks, their subtasks and so on, and returns it. Either all of them are deleted or none.
+	DeleteTodoWithSubtasks(todoId int) (models.Todo, error)
+	// GetTodoShares fetches all the user ids the [models.Todo] is shared with.
+	GetTodoShares(todoId int) ([]int, error)
+	// CreateTodoShare shares a [models.Todo] with the user userId and returns the id of the share.
+	CreateTodoShare(todoId int, userId int) (int, error)
+	// DeleteTodoShare removes the share of a [models.Todo] with the user userId and returns the id of the share.
+	DeleteTodoShare(todoId int, userId int) (int, error)
+}
+
+// TagStore is the persistence layer for the [models.Tag]s users attach to their todos.
+type TagStore interface {
+	// CreateTag persists tag and returns it with its id set. If its user already has a tag with the same name,
+	// [ErrTagExists] is returned.
+	CreateTag(tag models.Tag) (models.Tag, error)
+	// GetTags fetches the tags of the user with id userId ordered by name.
+	GetTags(userId int) ([]models.Tag, error)
+	// GetTag fetches the tag with id tagId.
+	GetTag(tagId int) (models.Tag, error)
+	// UpdateTag sets the name and color of the stored tag with the same id to the ones of tag. If its user already has
+	// another tag with the new name, [ErrTagExists] is returned.
+	UpdateTag(tag models.Tag) error
+	// MergeTags attaches the tag targetId to every todo the tag sourceId is attached to and deletes the source tag.
+	MergeTags(sourceId int, targetId int) error
+	// DeleteTag deletes the tag with id tagId and detaches it from all todos.
+	DeleteTag(tagId int) error
+}
+
+// ListStore is the persistence layer for the [models.List]s that group the todos of a user and their shares with
+// other users.
+type ListStore interface {
+	// CreateList persists list and returns it with its id set.
+	CreateList(list models.List) (models.List, error)
+	// GetLists fetches the lists of the user with id userId ordered by id. If includeShared is set, the lists shared
+	// with that user are also included.
+	GetLists(userId int, includeShared bool) ([]models.List, error)
+	// GetList fetches the list with id listId.
+	GetList(listId int) (models.List, error)
+	// UpdateList sets the name and workflow of the stored list with the same id to the ones of list.
+	UpdateList(list models.List) error
+	// DeleteList deletes the list with id listId and its shares. Its todos are kept without a list.
+	DeleteList(listId int) error
+	// GetListShares fetches all the user ids the list is shared with.
+	GetListShares(listId int) ([]int, error)
+	// CreateListShare shares the list with the user userId and returns the id of the share.
+	CreateListShare(listId int, userId int) (int, error)
+	// DeleteListShare removes the share of the list with the user userId and returns the id of the share.
+	DeleteListShare(listId int, userId int) (int, error)
+}
+
+// ReminderStore is the persistence layer for the [models.Reminder]s of todos. Reminders are deleted with their todo.
+type ReminderStore interface {
+	// CreateReminder persists reminder and returns it with its id set.
+	CreateReminder(reminder models.Reminder) (models.Reminder, error)
+	// GetReminders fetches the reminders of the todo with id todoId ordered by id.
+	GetReminders(todoId int) ([]models.Reminder, error)
+	// GetReminder fetches the reminder with id reminderId.
+	GetReminder(reminderId int) (models.Reminder, error)
+	// UpdateReminder sets the times of the stored reminder with the same id to the ones of reminder and ends a claim on
+	// it.
+	UpdateReminder(reminder models.Reminder) error
+	// DeleteReminder deletes the reminder with id reminderId.
+	DeleteReminder(reminderId int) error
+	// GetDueReminders fetches the pending reminders that are to be sent at or before now and are not claimed beyond
+	// now, the earliest first.
+	GetDueReminders(now time.Time) ([]models.Reminder, error)
+	// ClaimReminder reserves the pending reminder until the time until if it is still due at its RemindAt and not
+	// claimed beyond now. It returns false if the reminder was sent, rescheduled or claimed by another process in the
+	// meantime, so that only one process at a time sends it. A claim that is neither completed nor released, for
+	// example because the process stopped, can be taken over once it expired.
+	ClaimReminder(reminder models.Reminder, now time.Time, until time.Time) (bool, error)
+	// CompleteReminder marks the claimed reminder as sent at sentAt and ends the claim. A reminder that was rescheduled
+	// in the meantime stays pending.
+	CompleteReminder(reminder models.Reminder, sentAt time.Time) error
+	// ReleaseReminder ends the claim on the reminder, so that it is sent by the next run.
+	ReleaseReminder(reminder models.Reminder) error
+}
+
+// ChecklistStore is the persistence layer for the [models.ChecklistItem]s of todos. Items are deleted with their todo.
+type ChecklistStore interface {
+	// CreateChecklistItem persists item and returns it with its id set. An item without a position is placed after
+	// the other items of its todo, also if other items are created at the same time. If another item of its todo has
+	// its position, [ErrPositionTaken] is returned.
+	CreateChecklistItem(item models.ChecklistItem) (models.ChecklistItem, error)
+	// GetChecklistItems fetches the items of the todo with id todoId ordered by position.
+	GetChecklistItems(todoId int) ([]models.ChecklistItem, error)
+	// GetChecklistItem fetches the item with id itemId.
+	GetChecklistItem(itemId int) (models.ChecklistItem, error)
+	// UpdateChecklistItem sets the text, checked state and position of the stored item with the same id to the ones
+	// of item. If another item of its todo has its position, [ErrPositionTaken] is returned.
+	UpdateChecklistItem(item models.ChecklistItem) error
+	// UpdateChecklistItems updates items like UpdateChecklistItem in a single transaction, so that either all of them
+	// or none of them are changed.
+	UpdateChecklistItems(items []models.ChecklistItem) error
+	// DeleteChecklistItem deletes the item with id itemId.
+	DeleteChecklistItem(itemId int) error
+}
+
+// DependencyStore is the persistence layer for the [models.Dependency]s between todos. Dependencies are deleted with
+// either of their todos.
+type DependencyStore interface {
+	// CreateDependency persists dependency. If it exists already, [ErrDependencyExists] is returned, and if its blocker
+	// already waits for its todo, directly or through other todos, [ErrDependencyCycle] is returned. The check and the
+	// insert are atomic, so that dependencies created at the same time can't form a cycle either.
+	CreateDependency(dependency models.Dependency) error
+	// DeleteDependency deletes dependency. It returns [database/sql.ErrNoRows] if it does not exist.
+	DeleteDependency(dependency models.Dependency) error
+	// GetDependencies fetches the dependencies in which the todo todoId waits for another todo or another todo waits
+	// for it, in the order they were created.
+	GetDependencies(todoId int) ([]models.Dependency, error)
+}
+
+// UserStore is the persistence layer for [models.User]s and their authentication tokens.
+type UserStore interface {
+	// CreateUser persists a [models.User]. On success the user with its id set is returned.
+	CreateUser(user models.User) (models.User, error)
+	// GetUsers retrieves all [models.User]s including their roles and whether they are disabled.
+	GetUsers() ([]models.User, error)
+	// GetUser retrieves the [models.User] with id userId including its role and whether it is disabled.
+	GetUser(userId int) (models.User, error)
+	// SetUserRole changes the role of the user with id userId.
+	SetUserRole(userId int, role models.Role) error
+	// SetUserTimezone changes the IANA timezone of the user with id userId.
+	SetUserTimezone(userId int, timezone string) error
+	// SetUserDisabled disables or re-enables the user with id userId. Disabled users can't log in and their sessions
+	// and access tokens are rejected.
+	SetUserDisabled(userId int, disabled bool) error
+	// DeleteUser deletes the user with id userId together with everything that belongs to it, like its todos, the
+	// shares of its todos and with it, its sessions and its access tokens.
+	DeleteUser(userId int) error
+	// LoginUser verifies the credentials of user and creates a new session for client. On success the tokens of the
+	// session are returned. If the user enabled a second factor, only a challenge is returned together with
+	// [ErrSecondFactorRequired]. Unknown users and wrong passwords return [ErrInvalidCredentials], disabled users with a
+	// correct password [ErrUserDisabled].
+	LoginUser(user models.User, client models.Client) (models.SessionTokens, error)
+	// LoginExternalUser creates a new session for client for the user linked to identity. On the first login of an
+	// identity a user without a password is created and linked to it. A second factor is left to the identity provider.
+	LoginExternalUser(identity models.ExternalIdentity, client models.Client) (models.SessionTokens, error)
+	// AuthenticateUser returns the [models.User] and the [models.Session] a valid, not expired token of a user that is
+	// not disabled belongs to. The last use of the session is updated.
+	AuthenticateUser(token string) (models.User, models.Session, error)
+}
+
+// SessionStore manages the [models.Session]s created on login.
+type SessionStore interface {
+	// GetSessions returns the sessions of the user with id userId that have not expired yet.
+	GetSessions(userId int) ([]models.Session, error)
+	// DeleteSession revokes the session sessionId of the user with id userId.
+	DeleteSession(userId int, sessionId int) error
+	// DeleteOtherSessions revokes all sessions of the user with id userId except keepSessionId and returns how many
+	// sessions were revoked.
+	DeleteOtherSessions(userId int, keepSessionId int) (int, error)
+	// DeleteAllSessions revokes every session of the user with id userId and returns how many sessions were revoked.
+	DeleteAllSessions(userId int) (int, error)
+	// RefreshSession exchanges a refresh token for a new access and refresh token of the same session. Presenting a
+	// refresh token a second time revokes the session and returns [ErrRefreshTokenReused] together with tokens that
+	// only carry the id of the revoked session.
+	RefreshSession(refreshToken string) (models.SessionTokens, error)
+}
+
+// RevocationStore keeps the sessions whose signed access tokens must be rejected before they expire. Signed tokens are
+// validated without looking up their session, so deleting the session alone does not invalidate them.
+type RevocationStore interface {
+	// RevokeSessions adds the sessions to the revocation list until expiresAt, the time the last access token issued
+	// for them expires.
+	RevokeSessions(sessionIds []int, expiresAt time.Time) error
+	// GetRevokedSessions returns the ids of all sessions on the revocation list that did not expire yet.
+	GetRevokedSessions() ([]int, error)
+}
+
+// AccessTokenPrefix starts every personal access token to tell it apart from the token of a session.
+const AccessTokenPrefix = "todo_pat_"
+
+// AccessTokenStore manages the personal [models.AccessToken]s of users.
+type AccessTokenStore interface {
+	// CreateAccessToken creates an access token for the user with id userId. The token itself is only returned here.
+	CreateAccessToken(userId int, name string, scopes []models.Scope, expiresAt *time.Time) (models.AccessToken, string, error)
+	// GetAccessTokens returns all access tokens of the user with id userId.
+	GetAccessTokens(userId int) ([]models.AccessToken, error)
+	// DeleteAccessToken revokes the access token tokenId of the user with id userId.
+	DeleteAccessToken(userId int, tokenId int) error
+	// AuthenticateAccessToken returns the [models.User] and the [models.AccessToken] a valid, not expired token
+	// belongs to. The last use of the access token is updated.
+	AuthenticateAccessToken(token string) (models.User, models.AccessToken, error)
+}
+
+// TwoFactorStore manages the time-based one-time passwords (TOTP) and recovery codes of users and the logins that
+// wait for them.
+type TwoFactorStore interface {
+	// EnrollTOTP creates a new TOTP secret and new recovery codes for the user with id userId. They replace an earlier
+	// enrollment that was not confirmed yet, but return [ErrTOTPEnabled] if the user already confirmed one. The second
+	// factor is only required after [TwoFactorStore.ConfirmTOTP].
+	EnrollTOTP(userId int) (models.TOTPEnrollment, error)
+	// ConfirmTOTP enables the enrolled TOTP secret of the user with id userId if code is valid for it.
+	ConfirmTOTP(userId int, code string) error
+	// DisableTOTP removes the TOTP secret and the recovery codes of the user with id userId if code is a valid one-time
+	// password or recovery code.
+	DisableTOTP(userId int, code string) error
+	// CompleteLogin creates a new session for client for the login the challenge was issued for if code is a valid
+	// one-time password or recovery code. A used recovery code is deleted. After too many wrong codes the challenge
+	// is deleted.
+	CompleteLogin(challenge string, code string, client models.Client) (models.SessionTokens, error)
+}
+
+// PasswordStore changes and resets the passwords of users.
+type PasswordStore interface {
+	// ChangePassword sets the password of the user with id userId to newPassword if currentPassword is correct and
+	// returns [ErrWrongPassword] otherwise.
+	ChangePassword(userId int, currentPassword string, newPassword string) error
+	// CreatePasswordReset creates a single-use token to reset the password of the user called name and returns it
+	// together with the user including its email address. Earlier tokens of the user are invalidated.
+	CreatePasswordReset(name string) (models.User, string, error)
+	// GetPasswordReset fetches the user the valid, not expired token was created for without using the token, for
+	// example to check the new password against the name of the user.
+	GetPasswordReset(token string) (models.User, error)
+	// ResetPassword sets the password of the user the valid, not expired token was created for to newPassword and
+	// returns the id of the user. The token can't be used again.
+	ResetPassword(token string, newPassword string) (int, error)
+}
+
+// LoginAttemptStore counts failed logins per user name and per client IP address, so that guessing passwords can be
+// slowed down. Names are counted whether a user with that name exists or not.
+type LoginAttemptStore interface {
+	// GetLoginFailures returns the failed logins counted for the user name and for the client IP address ip.
+	GetLoginFailures(name string, ip string) (account models.LoginFailures, client models.LoginFailures, err error)
+	// ReserveLoginAttempt counts a login for the user name from the client IP address ip as failed before its password
+	// is verified and returns the failures counted before it. Concurrent attempts are counted one after another, so
+	// that each of them sees the ones before and guessing in parallel is slowed down like guessing one after another.
+	// A count whose last failure is older than window starts again at one.
+	ReserveLoginAttempt(name string, ip string, window time.Duration) (account models.LoginFailures, client models.LoginFailures, err error)
+	// ReleaseLoginAttempt takes back an attempt counted by ReserveLoginAttempt that did not fail because of a wrong
+	// password.
+	ReleaseLoginAttempt(name string, ip string) error
+	// ResetLoginFailures forgets the failed logins counted for the user name, for example after a successful login.
+	ResetLoginFailures(name string) error
+	// UnlockUser forgets the failed logins counted for the name of the user with id userId.
+	UnlockUser(userId int) error
+}
+
+// Store combines all persistence interfaces a backend has to implement.
+type Store interface {
+	TodoStore
+	TagStore
+	ListStore
+	ReminderStore
+	DependencyStore
+	ChecklistStore
+	UserStore
+	SessionStore
+	AccessTokenStore
+	RevocationStore
+	TwoFactorStore
+	PasswordStore
+	LoginAttemptStore
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Open returns the [Store] described by dataSource issuing tokens with the given lifetimes and hashing passwords with
+// hasher. A "postgres://" or "postgresql://" URL connects to PostgreSQL,
+// "memory" creates a [MemoryStore] and everything else is used as the path of a SQLite database file, optionally
+// prefixed with "sqlite://".
+func Open(dataSource string, lifetimes TokenLifetimes, hasher password.Hasher) (Store, error) {
+	if err := hasher.Validate(); err != nil {
+		return nil, err
+	}
+	var store *SQLStore
+	var err error
+	switch {
+	case dataSource == "memory":
+		memory := NewMemoryStore()
+		memory.TokenLifetimes = lifetimes
+		memory.Hasher = hasher
+		return memory, nil
+	case strings.HasPrefix(dataSource, "postgres://"), strings.HasPrefix(dataSource, "postgresql://"):
+		store, err = NewPostgresStore(dataSource)
+	default:
+		store, err = NewSQLiteStore(strings.TrimPrefix(dataSource, "sqlite://"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	store.TokenLifetimes = lifetimes
+	store.Hasher = hasher
+	return store, nil
+}
+
+// hashToken returns the hash of a session token that is persisted instead of the token itself.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// newSessionTokens creates a random access and refresh token that expire after the given lifetimes starting at now.
+func newSessionTokens(now time.Time, lifetimes TokenLifetimes) (models.SessionTokens, error) {
+	tokenLength := 32
+	accessToken, err := createUserToken(tokenLength)
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	refreshToken, err := createUserToken(tokenLength)
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	return models.SessionTokens{
+		AccessToken:      accessToken,
+		ExpiresAt:        now.Add(lifetimes.Access),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: now.Add(lifetimes.Refresh),
+	}, nil
+}
+
+// newAccessToken creates a random personal access token.
+func newAccessToken() (string, error) {
+	token, err := createUserToken(32)
+	return AccessTokenPrefix + token, err
+}
+
+// formatScopes joins scopes to the space-separated form they are persisted in.
+func formatScopes(scopes []models.Scope) string {
+	parts := make([]string, len(scopes))
+	for i, scope := range scopes {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseScopes splits persisted scopes created by formatScopes.
+func parseScopes(scopes string) []models.Scope {
+	var parsed []models.Scope
+	for _, scope := range strings.Fields(scopes) {
+		parsed = append(parsed, models.Scope(scope))
+	}
+	return parsed
+}
 
-func getDb() *sql.DB {
-	if db == nil {
-		conn, err := sql.Open("sqlite3", "todo.db")
-		if err != nil {
-			log.Fatal(err)
-		}
-		db = conn
+func createUserToken(length int) (string, error) {
+	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
-	return db
+	return hex.EncodeToString(b), nil
 }
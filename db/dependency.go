@@ -0,0 +1,64 @@
+package db
+
+import (
+	"todo/models"
+)
+
+// CreateDependency inserts dependency into 'todo_dependencies' unless its blocker already waits for its todo. The
+// check and the insert run in one transaction that keeps other transactions from writing to the table in between.
+func (s *SQLStore) CreateDependency(dependency models.Dependency) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if stmt := s.dialect.lockTable("todo_dependencies"); stmt != "" {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	var cycle int
+	stmt := `WITH RECURSIVE blockers(id) AS (
+			SELECT CAST(? AS INTEGER)
+			UNION SELECT d.blocker_id FROM todo_dependencies AS d JOIN blockers AS b ON (d.todo_id = b.id)
+		) SELECT COUNT(*) FROM blockers WHERE id = ?`
+	if err := tx.QueryRow(s.dialect.rebind(stmt), dependency.BlockerId, dependency.TodoId).Scan(&cycle); err != nil {
+		return err
+	}
+	if cycle > 0 {
+		return ErrDependencyCycle
+	}
+	stmt = `INSERT INTO todo_dependencies (todo_id, blocker_id) VALUES (?, ?)`
+	if _, err := tx.Exec(s.dialect.rebind(stmt), dependency.TodoId, dependency.BlockerId); err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return ErrDependencyExists
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteDependency deletes dependency from 'todo_dependencies'.
+func (s *SQLStore) DeleteDependency(dependency models.Dependency) error {
+	return s.updateOne(`DELETE FROM todo_dependencies WHERE todo_id = ? AND blocker_id = ?`, dependency.TodoId, dependency.BlockerId)
+}
+
+// GetDependencies selects the rows of 'todo_dependencies' in which the todo todoId waits or is waited for, ordered by
+// id.
+func (s *SQLStore) GetDependencies(todoId int) ([]models.Dependency, error) {
+	dependencies := []models.Dependency{}
+	rows, err := s.query(`SELECT todo_id, blocker_id FROM todo_dependencies WHERE todo_id = ? OR blocker_id = ? ORDER BY id`,
+		todoId, todoId)
+	if err != nil {
+		return dependencies, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dependency models.Dependency
+		if err := rows.Scan(&dependency.TodoId, &dependency.BlockerId); err != nil {
+			return dependencies, err
+		}
+		dependencies = append(dependencies, dependency)
+	}
+	return dependencies, rows.Err()
+}
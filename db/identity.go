@@ -0,0 +1,71 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"todo/models"
+)
+
+// maxNameSuffix limits how many numbered variants of a suggested user name are tried for a new external user.
+const maxNameSuffix = 100
+
+// LoginExternalUser looks up the user linked to identity in 'user_identities' and creates a session for it. If the
+// identity is not linked yet, a user with an empty password, which never matches a password login, is created.
+// Disabled users get [ErrUserDisabled].
+func (s *SQLStore) LoginExternalUser(identity models.ExternalIdentity, client models.Client) (models.SessionTokens, error) {
+	user, err := s.externalUser(identity)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = s.createExternalUser(identity)
+	}
+	if err != nil {
+		return models.SessionTokens{}, fmt.Errorf("LoginExternalUser: %w", err)
+	}
+	if user.Disabled {
+		return models.SessionTokens{}, ErrUserDisabled
+	}
+	return s.createSession(user, client)
+}
+
+func (s *SQLStore) externalUser(identity models.ExternalIdentity) (models.User, error) {
+	var user models.User
+	stmt := `SELECT u.id, u.name, u.role, u.disabled FROM user_identities AS i JOIN users AS u ON (u.id = i.user_id) WHERE i.issuer = ? AND i.subject = ?`
+	err := s.queryRow(stmt, identity.Issuer, identity.Subject).Scan(&user.Id, &user.Name, &user.Role, &user.Disabled)
+	return user, err
+}
+
+// createExternalUser creates a user for identity and links them. If the suggested name is taken, a number is appended
+// to it. The identity is never linked to an existing user, as that would hand the account to whoever controls the
+// name at the identity provider.
+func (s *SQLStore) createExternalUser(identity models.ExternalIdentity) (models.User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+	user := models.User{Name: identity.Name, Role: models.RoleMember}
+	for suffix := 2; ; suffix++ {
+		var taken int
+		err := tx.QueryRow(s.dialect.rebind(`SELECT COUNT(*) FROM users WHERE name = ?`), user.Name).Scan(&taken)
+		if err != nil {
+			return user, err
+		}
+		if taken == 0 {
+			break
+		}
+		if suffix > maxNameSuffix {
+			return user, fmt.Errorf("no free user name for %s", identity.Name)
+		}
+		user.Name = fmt.Sprintf("%s-%d", identity.Name, suffix)
+	}
+	stmt := `INSERT INTO users (name, password) VALUES (?, '') RETURNING id`
+	if err := tx.QueryRow(s.dialect.rebind(stmt), user.Name).Scan(&user.Id); err != nil {
+		return user, err
+	}
+	stmt = `INSERT INTO user_identities (issuer, subject, user_id, created_at) VALUES (?, ?, ?, ?)`
+	if _, err := tx.Exec(s.dialect.rebind(stmt), identity.Issuer, identity.Subject, user.Id, time.Now().UTC()); err != nil {
+		return user, err
+	}
+	return user, tx.Commit()
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"encoding/json"
+	"todo/models"
+)
+
+const listColumns = `l.id, l.user_id, l.name, l.workflow`
+
+// scanList scans a row with the columns of listColumns into list. The workflow is stored as JSON, or as an empty string
+// if the list has none.
+func scanList(row interface{ Scan(...any) error }, list *models.List) error {
+	var workflow string
+	if err := row.Scan(&list.Id, &list.UserId, &list.Name, &workflow); err != nil {
+		return err
+	}
+	list.Workflow = nil
+	if workflow == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(workflow), &list.Workflow)
+}
+
+// workflowColumn returns the value of the column 'workflow' for workflow.
+func workflowColumn(workflow models.Workflow) (string, error) {
+	if workflow == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(workflow)
+	return string(data), err
+}
+
+// CreateList inserts list into 'lists' and returns it with its id set.
+func (s *SQLStore) CreateList(list models.List) (models.List, error) {
+	workflow, err := workflowColumn(list.Workflow)
+	if err != nil {
+		return list, err
+	}
+	err = s.queryRow(`INSERT INTO lists (user_id, name, workflow) VALUES (?, ?, ?) RETURNING id`, list.UserId, list.Name,
+		workflow).Scan(&list.Id)
+	return list, err
+}
+
+// GetLists selects the lists of the user with id userId from 'lists' ordered by id. If includeShared is set, the lists
+// shared with the user in 'users_lists' are included.
+func (s *SQLStore) GetLists(userId int, includeShared bool) ([]models.List, error) {
+	lists := []models.List{}
+	stmt := `SELECT ` + listColumns + ` FROM lists AS l WHERE l.user_id = ?`
+	args := []any{userId}
+	if includeShared {
+		stmt += ` OR l.id IN (SELECT list_id FROM users_lists WHERE user_id = ?)`
+		args = append(args, userId)
+	}
+	rows, err := s.query(stmt+` ORDER BY l.id`, args...)
+	if err != nil {
+		return lists, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var list models.List
+		if err := scanList(rows, &list); err != nil {
+			return lists, err
+		}
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
+
+// GetList selects the list with id listId from 'lists'.
+func (s *SQLStore) GetList(listId int) (models.List, error) {
+	var list models.List
+	err := scanList(s.queryRow(`SELECT `+listColumns+` FROM lists AS l WHERE l.id = ?`, listId), &list)
+	return list, err
+}
+
+// UpdateList sets the name and workflow of the row in 'lists' with the id of list.
+func (s *SQLStore) UpdateList(list models.List) error {
+	workflow, err := workflowColumn(list.Workflow)
+	if err != nil {
+		return err
+	}
+	return s.updateOne(`UPDATE lists SET name = ?, workflow = ? WHERE id = ?`, list.Name, workflow, list.Id)
+}
+
+// DeleteList deletes the list with id listId from 'lists'. The foreign keys delete its shares and take its todos out
+// of the list.
+func (s *SQLStore) DeleteList(listId int) error {
+	return s.updateOne(`DELETE FROM lists WHERE id = ?`, listId)
+}
+
+// GetListShares fetches all the user ids the list is shared with from 'users_lists'.
+func (s *SQLStore) GetListShares(listId int) ([]int, error) {
+	var shares []int
+	rows, err := s.query(`SELECT user_id FROM users_lists WHERE list_id = ? ORDER BY id`, listId)
+	if err != nil {
+		return shares, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var share int
+		if err := rows.Scan(&share); err != nil {
+			return shares, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// CreateListShare inserts a share of the list with the user userId into 'users_lists'.
+func (s *SQLStore) CreateListShare(listId int, userId int) (int, error) {
+	var shareId int
+	err := s.queryRow(`INSERT INTO users_lists (list_id, user_id) VALUES (?, ?) RETURNING id`, listId, userId).Scan(&shareId)
+	return shareId, err
+}
+
+// DeleteListShare deletes the share of the list with the user userId from 'users_lists'.
+func (s *SQLStore) DeleteListShare(listId int, userId int) (int, error) {
+	var shareId int
+	err := s.queryRow(`DELETE FROM users_lists WHERE list_id = ? AND user_id = ? RETURNING id`, listId, userId).Scan(&shareId)
+	return shareId, err
+}
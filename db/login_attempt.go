@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+	"todo/models"
+)
+
+// The kinds of counters in 'login_failures'.
+const (
+	loginFailureAccount = "account"
+	loginFailureClient  = "ip"
+)
+
+// GetLoginFailures selects the counters of name and ip from 'login_failures'. Missing counters are returned as zero.
+func (s *SQLStore) GetLoginFailures(name string, ip string) (models.LoginFailures, models.LoginFailures, error) {
+	account, err := s.getLoginFailures(loginFailureAccount, name)
+	if err != nil {
+		return account, models.LoginFailures{}, err
+	}
+	client, err := s.getLoginFailures(loginFailureClient, ip)
+	return account, client, err
+}
+
+func (s *SQLStore) getLoginFailures(kind string, identifier string) (models.LoginFailures, error) {
+	var failures models.LoginFailures
+	stmt := `SELECT failures, last_failure_at FROM login_failures WHERE kind = ? AND identifier = ?`
+	err := s.queryRow(stmt, kind, identifier).Scan(&failures.Count, &failures.LastFailureAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return failures, nil
+	}
+	return failures, err
+}
+
+// ReserveLoginAttempt increments the counters of name and ip in 'login_failures' and returns them as they were
+// before. Each counter is incremented by a single statement, so concurrent attempts are counted one after another.
+// The time of the failure before is kept in 'previous_failure_at' for [SQLStore.ReleaseLoginAttempt]. Counters that
+// were not incremented within window are deleted before.
+func (s *SQLStore) ReserveLoginAttempt(name string, ip string, window time.Duration) (models.LoginFailures, models.LoginFailures, error) {
+	now := time.Now().UTC()
+	if _, err := s.exec(`DELETE FROM login_failures WHERE last_failure_at <= ?`, now.Add(-window)); err != nil {
+		return models.LoginFailures{}, models.LoginFailures{}, err
+	}
+	account, err := s.reserveLoginAttempt(loginFailureAccount, name, now)
+	if err != nil {
+		return account, models.LoginFailures{}, err
+	}
+	client, err := s.reserveLoginAttempt(loginFailureClient, ip, now)
+	return account, client, err
+}
+
+func (s *SQLStore) reserveLoginAttempt(kind string, identifier string, now time.Time) (models.LoginFailures, error) {
+	var failures models.LoginFailures
+	var previous sql.NullTime
+	stmt := `INSERT INTO login_failures (kind, identifier, failures, last_failure_at) VALUES (?, ?, 1, ?)
+		ON CONFLICT (kind, identifier) DO UPDATE SET failures = login_failures.failures + 1,
+			previous_failure_at = login_failures.last_failure_at, last_failure_at = excluded.last_failure_at
+		RETURNING failures, previous_failure_at`
+	if err := s.queryRow(stmt, kind, identifier, now).Scan(&failures.Count, &previous); err != nil {
+		return failures, err
+	}
+	failures.Count--
+	if failures.Count > 0 && previous.Valid {
+		failures.LastFailureAt = previous.Time
+	}
+	return failures, nil
+}
+
+// ReleaseLoginAttempt decrements the counters of name and ip in 'login_failures' and restores the time of their last
+// failure from 'previous_failure_at'. Counters that drop to zero are deleted.
+func (s *SQLStore) ReleaseLoginAttempt(name string, ip string) error {
+	for _, counter := range [][2]string{{loginFailureAccount, name}, {loginFailureClient, ip}} {
+		stmt := `UPDATE login_failures SET failures = failures - 1,
+			last_failure_at = COALESCE(previous_failure_at, last_failure_at) WHERE kind = ? AND identifier = ?`
+		if _, err := s.exec(stmt, counter[0], counter[1]); err != nil {
+			return err
+		}
+		stmt = `DELETE FROM login_failures WHERE kind = ? AND identifier = ? AND failures <= 0`
+		if _, err := s.exec(stmt, counter[0], counter[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResetLoginFailures deletes the counter of name from 'login_failures'.
+func (s *SQLStore) ResetLoginFailures(name string) error {
+	_, err := s.exec(`DELETE FROM login_failures WHERE kind = ? AND identifier = ?`, loginFailureAccount, name)
+	return err
+}
+
+// UnlockUser deletes the counter of the name of the user with id userId from 'login_failures'. If the user does not
+// exist, it returns [sql.ErrNoRows].
+func (s *SQLStore) UnlockUser(userId int) error {
+	var name string
+	if err := s.queryRow(`SELECT name FROM users WHERE id = ?`, userId).Scan(&name); err != nil {
+		return err
+	}
+	return s.ResetLoginFailures(name)
+}
@@ -0,0 +1,1665 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"todo/models"
+	"todo/password"
+	"todo/totp"
+)
+
+type memoryUser struct {
+	user     models.User
+	password []byte
+}
+
+// public returns the user without its email address.
+func (u memoryUser) public() models.User {
+	return models.User{Id: u.user.Id, Name: u.user.Name, Role: u.user.Role, Disabled: u.user.Disabled}
+}
+
+type memorySession struct {
+	session           models.Session
+	tokenHash         string
+	refreshTokenHash  string
+	usedRefreshHashes []string
+}
+
+type memoryAccessToken struct {
+	accessToken models.AccessToken
+	tokenHash   string
+}
+
+type memoryIdentity struct {
+	issuer  string
+	subject string
+	userId  int
+}
+
+type memoryTOTP struct {
+	secret         string
+	lastStep       int64
+	confirmed      bool
+	recoveryHashes []string
+}
+
+type memoryChallenge struct {
+	tokenHash string
+	userId    int
+	expiresAt time.Time
+	attempts  int
+}
+
+type memoryPasswordReset struct {
+	tokenHash string
+	userId    int
+	expiresAt time.Time
+}
+
+type memoryLoginFailureKey struct {
+	kind       string
+	identifier string
+}
+
+type memoryLoginFailures struct {
+	models.LoginFailures
+	previousFailureAt time.Time
+}
+
+type memoryShare struct {
+	id     int
+	todoId int
+	userId int
+}
+
+type memoryListShare struct {
+	id     int
+	listId int
+	userId int
+}
+
+// MemoryStore is a [Store] that keeps everything in memory. It is meant for tests and local development, all data is
+// lost when the process exits. Lookups of missing rows return [sql.ErrNoRows] to behave like the SQL backends.
+type MemoryStore struct {
+	mu                  sync.Mutex
+	users               []memoryUser
+	todos               []models.Todo
+	tags                []models.Tag
+	shares              []memoryShare
+	lists               []models.List
+	listShares          []memoryListShare
+	reminders           []models.Reminder
+	reminderClaims      map[int]time.Time
+	dependencies        []models.Dependency
+	checklistItems      []models.ChecklistItem
+	sessions            []memorySession
+	accessTokens        []memoryAccessToken
+	identities          []memoryIdentity
+	totp                map[int]*memoryTOTP
+	challenges          []memoryChallenge
+	resets              []memoryPasswordReset
+	loginFailures       map[memoryLoginFailureKey]memoryLoginFailures
+	revoked             map[int]time.Time
+	nextUserId          int
+	nextTodoId          int
+	nextTagId           int
+	nextShareId         int
+	nextListId          int
+	nextReminderId      int
+	nextChecklistItemId int
+	nextSessionId       int
+	nextTokenId         int
+	// TokenLifetimes are the lifetimes of the tokens issued for new and refreshed sessions.
+	TokenLifetimes TokenLifetimes
+	// Hasher hashes new passwords. Outdated hashes are replaced on login.
+	Hasher password.Hasher
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty [MemoryStore].
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		revoked:             map[int]time.Time{},
+		reminderClaims:      map[int]time.Time{},
+		totp:                map[int]*memoryTOTP{},
+		loginFailures:       map[memoryLoginFailureKey]memoryLoginFailures{},
+		nextUserId:          1,
+		nextTodoId:          1,
+		nextTagId:           1,
+		nextShareId:         1,
+		nextListId:          1,
+		nextReminderId:      1,
+		nextChecklistItemId: 1,
+		nextSessionId:       1,
+		nextTokenId:         1,
+		TokenLifetimes:      DefaultTokenLifetimes,
+		Hasher:              password.DefaultHasher,
+	}
+}
+
+// Close does nothing, it only exists to implement [Store].
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// CreateTodo stores todo with a new id, after all other todos of its user unless it has a position. A todo without a
+// status gets it from [models.Todo.IsDone].
+func (s *MemoryStore) CreateTodo(todo models.Todo) (models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	todo.NormalizeStatus()
+	if todo.Position != "" && !todoPositionsUnique(append(slices.Clip(s.todos), todo)) {
+		return todo, ErrPositionTaken
+	}
+	if todo.Position == "" {
+		var last string
+		for _, other := range s.todos {
+			if other.UserId == todo.UserId {
+				last = max(last, other.Position)
+			}
+		}
+		var err error
+		if todo.Position, err = models.PositionBetween(last, ""); err != nil {
+			return todo, err
+		}
+	}
+	todo.Id = s.nextTodoId
+	s.nextTodoId++
+	if todo.Tags == nil {
+		todo.Tags = []models.Tag{}
+	}
+	stored := todo
+	stored.Tags = tagRefs(todo.Tags)
+	s.todos = append(s.todos, stored)
+	return todo, nil
+}
+
+// tagRefs returns tags with only their ids, the stored todos refer to the tags like this so that they are always
+// returned with the current names and colors.
+func tagRefs(tags []models.Tag) []models.Tag {
+	refs := make([]models.Tag, len(tags))
+	for i, tag := range tags {
+		refs[i] = models.Tag{Id: tag.Id}
+	}
+	return refs
+}
+
+// withTags returns a copy of the stored todo with its tags looked up, ordered by name, and its checklist progress.
+func (s *MemoryStore) withTags(todo models.Todo) models.Todo {
+	refs := todo.Tags
+	todo.Tags = []models.Tag{}
+	for _, ref := range refs {
+		if i := slices.IndexFunc(s.tags, func(tag models.Tag) bool { return tag.Id == ref.Id }); i >= 0 {
+			todo.Tags = append(todo.Tags, s.tags[i])
+		}
+	}
+	slices.SortFunc(todo.Tags, func(a, b models.Tag) int { return strings.Compare(a.Name, b.Name) })
+	todo.Checklist = models.ChecklistProgress{}
+	for _, item := range s.checklistItems {
+		if item.TodoId == todo.Id {
+			todo.Checklist.Total++
+			if item.Checked {
+				todo.Checklist.Checked++
+			}
+		}
+	}
+	return todo
+}
+
+// GetTodos returns the [models.Todo]s created by the user with id userId that match filter, optionally including the
+// shared ones, in the order of [models.TodoFilter.Sort].
+func (s *MemoryStore) GetTodos(userId int, filter models.TodoFilter) ([]models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var todos []models.Todo
+	for _, todo := range s.todos {
+		if todo.UserId != userId && !(filter.IncludeShared && s.canRead(todo, userId)) {
+			continue
+		}
+		if filter.Actionable && !s.isActionable(todo.Id) {
+			continue
+		}
+		if todo = s.withTags(todo); filter.Matches(todo, userId) {
+			todos = append(todos, todo)
+		}
+	}
+	sorts := filter.Sort
+	if len(sorts) == 0 {
+		sorts = models.DefaultTodoSort
+	}
+	slices.SortFunc(todos, func(a, b models.Todo) int { return models.CompareTodos(a, b, sorts) })
+	return todos, nil
+}
+
+func (s *MemoryStore) isSharedWith(todoId int, userId int) bool {
+	return slices.ContainsFunc(s.shares, func(share memoryShare) bool {
+		return share.todoId == todoId && share.userId == userId
+	})
+}
+
+// canRead reports whether todo is shared with the user directly or through its list.
+func (s *MemoryStore) canRead(todo models.Todo, userId int) bool {
+	return s.isSharedWith(todo.Id, userId) || (todo.ListId != nil && s.isListSharedWith(*todo.ListId, userId))
+}
+
+func (s *MemoryStore) todoIndex(todoId int) int {
+	return slices.IndexFunc(s.todos, func(todo models.Todo) bool {
+		return todo.Id == todoId
+	})
+}
+
+// GetTodo returns the [models.Todo] with the [models.Todo.Id] equal to todoId.
+func (s *MemoryStore) GetTodo(todoId int) (models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.todoIndex(todoId)
+	if i < 0 {
+		return models.Todo{}, sql.ErrNoRows
+	}
+	return s.withTags(s.todos[i]), nil
+}
+
+// UpdateTodo replaces the stored [models.Todo] having the same id as todo. Updating a missing todo is not an error.
+func (s *MemoryStore) UpdateTodo(todo models.Todo) error {
+	return s.UpdateTodos([]models.Todo{todo})
+}
+
+// UpdateTodos replaces each of the stored todos having the same id as one of todos at once. If two todos of a user
+// would share a position afterwards, [ErrPositionTaken] is returned and none of them are replaced.
+func (s *MemoryStore) UpdateTodos(todos []models.Todo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated := slices.Clone(s.todos)
+	for _, todo := range todos {
+		if i := s.todoIndex(todo.Id); i >= 0 {
+			todo.NormalizeStatus()
+			todo.Tags = tagRefs(todo.Tags)
+			updated[i] = todo
+		}
+	}
+	if !todoPositionsUnique(updated) {
+		return ErrPositionTaken
+	}
+	s.todos = updated
+	return nil
+}
+
+// todoPositionsUnique reports whether no two of todos of the same user share a position, like the unique index of
+// the SQL stores demands.
+func todoPositionsUnique(todos []models.Todo) bool {
+	type key struct {
+		userId   int
+		position string
+	}
+	positions := make(map[key]bool, len(todos))
+	for _, todo := range todos {
+		if positions[key{todo.UserId, todo.Position}] {
+			return false
+		}
+		positions[key{todo.UserId, todo.Position}] = true
+	}
+	return true
+}
+
+// DeleteTodo deletes the [models.Todo] with the [models.Todo.Id] equal to todoId together with its shares. Its
+// subtasks become top-level todos.
+func (s *MemoryStore) DeleteTodo(todoId int) (models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.todoIndex(todoId)
+	if i < 0 {
+		return models.Todo{}, sql.ErrNoRows
+	}
+	todo := s.withTags(s.todos[i])
+	s.deleteTodo(todoId)
+	return todo, nil
+}
+
+// DeleteTodoWithSubtasks deletes the [models.Todo] with the [models.Todo.Id] equal to todoId together with its
+// subtasks, their subtasks and so on.
+func (s *MemoryStore) DeleteTodoWithSubtasks(todoId int) (models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.todoIndex(todoId)
+	if i < 0 {
+		return models.Todo{}, sql.ErrNoRows
+	}
+	todo := s.withTags(s.todos[i])
+	todoIds := []int{todoId}
+	for j := 0; j < len(todoIds); j++ {
+		for _, child := range s.todos {
+			if child.ParentId != nil && *child.ParentId == todoIds[j] {
+				todoIds = append(todoIds, child.Id)
+			}
+		}
+	}
+	for _, id := range todoIds {
+		s.deleteTodo(id)
+	}
+	return todo, nil
+}
+
+// deleteTodo deletes the todo todoId with everything that belongs to it. Its subtasks become top-level todos.
+func (s *MemoryStore) deleteTodo(todoId int) {
+	s.todos = slices.DeleteFunc(s.todos, func(todo models.Todo) bool { return todo.Id == todoId })
+	for i, child := range s.todos {
+		if child.ParentId != nil && *child.ParentId == todoId {
+			s.todos[i].ParentId = nil
+		}
+	}
+	s.shares = slices.DeleteFunc(s.shares, func(share memoryShare) bool {
+		return share.todoId == todoId
+	})
+	s.reminders = slices.DeleteFunc(s.reminders, func(reminder models.Reminder) bool {
+		return reminder.TodoId == todoId
+	})
+	s.dependencies = slices.DeleteFunc(s.dependencies, func(dependency models.Dependency) bool {
+		return dependency.TodoId == todoId || dependency.BlockerId == todoId
+	})
+	s.checklistItems = slices.DeleteFunc(s.checklistItems, func(item models.ChecklistItem) bool {
+		return item.TodoId == todoId
+	})
+}
+
+// CreateTag stores tag with a new id. The names of the tags of a user have to be unique.
+func (s *MemoryStore) CreateTag(tag models.Tag) (models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tagNameTaken(tag) {
+		return tag, ErrTagExists
+	}
+	tag.Id = s.nextTagId
+	s.nextTagId++
+	s.tags = append(s.tags, tag)
+	return tag, nil
+}
+
+func (s *MemoryStore) tagNameTaken(tag models.Tag) bool {
+	return slices.ContainsFunc(s.tags, func(other models.Tag) bool {
+		return other.UserId == tag.UserId && other.Name == tag.Name && other.Id != tag.Id
+	})
+}
+
+func (s *MemoryStore) tagIndex(tagId int) int {
+	return slices.IndexFunc(s.tags, func(tag models.Tag) bool {
+		return tag.Id == tagId
+	})
+}
+
+// GetTags returns the tags of the user with id userId ordered by name.
+func (s *MemoryStore) GetTags(userId int) ([]models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags := []models.Tag{}
+	for _, tag := range s.tags {
+		if tag.UserId == userId {
+			tags = append(tags, tag)
+		}
+	}
+	slices.SortFunc(tags, func(a, b models.Tag) int { return strings.Compare(a.Name, b.Name) })
+	return tags, nil
+}
+
+// GetTag returns the tag with id tagId.
+func (s *MemoryStore) GetTag(tagId int) (models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.tagIndex(tagId)
+	if i < 0 {
+		return models.Tag{}, sql.ErrNoRows
+	}
+	return s.tags[i], nil
+}
+
+// UpdateTag replaces the name and color of the stored tag having the same id as tag.
+func (s *MemoryStore) UpdateTag(tag models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.tagIndex(tag.Id)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	tag.UserId = s.tags[i].UserId
+	if s.tagNameTaken(tag) {
+		return ErrTagExists
+	}
+	s.tags[i].Name, s.tags[i].Color = tag.Name, tag.Color
+	return nil
+}
+
+// MergeTags attaches the tag targetId to all todos of the tag sourceId and deletes the source tag.
+func (s *MemoryStore) MergeTags(sourceId int, targetId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tagIndex(sourceId) < 0 {
+		return sql.ErrNoRows
+	}
+	for i, todo := range s.todos {
+		ids := models.TagIds(todo.Tags)
+		if slices.Contains(ids, sourceId) && !slices.Contains(ids, targetId) {
+			s.todos[i].Tags = append(todo.Tags, models.Tag{Id: targetId})
+		}
+	}
+	s.deleteTag(sourceId)
+	return nil
+}
+
+// DeleteTag deletes the tag with id tagId and detaches it from all todos.
+func (s *MemoryStore) DeleteTag(tagId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tagIndex(tagId) < 0 {
+		return sql.ErrNoRows
+	}
+	s.deleteTag(tagId)
+	return nil
+}
+
+func (s *MemoryStore) deleteTag(tagId int) {
+	s.tags = slices.DeleteFunc(s.tags, func(tag models.Tag) bool { return tag.Id == tagId })
+	for i := range s.todos {
+		s.todos[i].Tags = slices.DeleteFunc(s.todos[i].Tags, func(tag models.Tag) bool { return tag.Id == tagId })
+	}
+}
+
+// GetTodoShares returns all the user ids the [models.Todo] is shared with.
+func (s *MemoryStore) GetTodoShares(todoId int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var shares []int
+	for _, share := range s.shares {
+		if share.todoId == todoId {
+			shares = append(shares, share.userId)
+		}
+	}
+	return shares, nil
+}
+
+// CreateTodoShare shares a [models.Todo] with the user userId. Sharing it twice with the same user is an error.
+func (s *MemoryStore) CreateTodoShare(todoId int, userId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isSharedWith(todoId, userId) {
+		return 0, fmt.Errorf("CreateTodoShare: todo %d is already shared with user %d", todoId, userId)
+	}
+	share := memoryShare{id: s.nextShareId, todoId: todoId, userId: userId}
+	s.nextShareId++
+	s.shares = append(s.shares, share)
+	return share.id, nil
+}
+
+// DeleteTodoShare removes the share of a [models.Todo] with the user userId.
+func (s *MemoryStore) DeleteTodoShare(todoId int, userId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.shares, func(share memoryShare) bool {
+		return share.todoId == todoId && share.userId == userId
+	})
+	if i < 0 {
+		return 0, sql.ErrNoRows
+	}
+	shareId := s.shares[i].id
+	s.shares = slices.Delete(s.shares, i, i+1)
+	return shareId, nil
+}
+
+// CreateList stores list with a new id.
+func (s *MemoryStore) CreateList(list models.List) (models.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list.Id = s.nextListId
+	s.nextListId++
+	s.lists = append(s.lists, list)
+	return list, nil
+}
+
+func (s *MemoryStore) listIndex(listId int) int {
+	return slices.IndexFunc(s.lists, func(list models.List) bool {
+		return list.Id == listId
+	})
+}
+
+func (s *MemoryStore) isListSharedWith(listId int, userId int) bool {
+	return slices.ContainsFunc(s.listShares, func(share memoryListShare) bool {
+		return share.listId == listId && share.userId == userId
+	})
+}
+
+// GetLists returns the lists of the user with id userId ordered by id, optionally including the shared ones.
+func (s *MemoryStore) GetLists(userId int, includeShared bool) ([]models.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lists := []models.List{}
+	for _, list := range s.lists {
+		if list.UserId == userId || (includeShared && s.isListSharedWith(list.Id, userId)) {
+			lists = append(lists, list)
+		}
+	}
+	return lists, nil
+}
+
+// GetList returns the list with id listId.
+func (s *MemoryStore) GetList(listId int) (models.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.listIndex(listId)
+	if i < 0 {
+		return models.List{}, sql.ErrNoRows
+	}
+	return s.lists[i], nil
+}
+
+// UpdateList replaces the name and workflow of the stored list having the same id as list.
+func (s *MemoryStore) UpdateList(list models.List) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.listIndex(list.Id)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.lists[i].Name, s.lists[i].Workflow = list.Name, list.Workflow
+	return nil
+}
+
+// DeleteList deletes the list with id listId together with its shares and takes its todos out of the list.
+func (s *MemoryStore) DeleteList(listId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listIndex(listId) < 0 {
+		return sql.ErrNoRows
+	}
+	s.deleteLists([]int{listId})
+	return nil
+}
+
+func (s *MemoryStore) deleteLists(listIds []int) {
+	s.lists = slices.DeleteFunc(s.lists, func(list models.List) bool { return slices.Contains(listIds, list.Id) })
+	s.listShares = slices.DeleteFunc(s.listShares, func(share memoryListShare) bool {
+		return slices.Contains(listIds, share.listId)
+	})
+	for i, todo := range s.todos {
+		if todo.ListId != nil && slices.Contains(listIds, *todo.ListId) {
+			s.todos[i].ListId = nil
+		}
+	}
+}
+
+// GetListShares returns all the user ids the list is shared with.
+func (s *MemoryStore) GetListShares(listId int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var shares []int
+	for _, share := range s.listShares {
+		if share.listId == listId {
+			shares = append(shares, share.userId)
+		}
+	}
+	return shares, nil
+}
+
+// CreateListShare shares the list with the user userId. Sharing it twice with the same user is an error.
+func (s *MemoryStore) CreateListShare(listId int, userId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isListSharedWith(listId, userId) {
+		return 0, fmt.Errorf("CreateListShare: list %d is already shared with user %d", listId, userId)
+	}
+	share := memoryListShare{id: s.nextShareId, listId: listId, userId: userId}
+	s.nextShareId++
+	s.listShares = append(s.listShares, share)
+	return share.id, nil
+}
+
+// DeleteListShare removes the share of the list with the user userId.
+func (s *MemoryStore) DeleteListShare(listId int, userId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.listShares, func(share memoryListShare) bool {
+		return share.listId == listId && share.userId == userId
+	})
+	if i < 0 {
+		return 0, sql.ErrNoRows
+	}
+	shareId := s.listShares[i].id
+	s.listShares = slices.Delete(s.listShares, i, i+1)
+	return shareId, nil
+}
+
+// CreateDependency stores dependency unless it exists already or its blocker already waits for its todo.
+func (s *MemoryStore) CreateDependency(dependency models.Dependency) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Contains(s.dependencies, dependency) {
+		return ErrDependencyExists
+	}
+	visited := map[int]bool{}
+	for pending := []int{dependency.BlockerId}; len(pending) > 0; {
+		todoId := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if todoId == dependency.TodoId {
+			return ErrDependencyCycle
+		}
+		if visited[todoId] {
+			continue
+		}
+		visited[todoId] = true
+		for _, next := range s.dependencies {
+			if next.TodoId == todoId {
+				pending = append(pending, next.BlockerId)
+			}
+		}
+	}
+	s.dependencies = append(s.dependencies, dependency)
+	return nil
+}
+
+// DeleteDependency deletes dependency.
+func (s *MemoryStore) DeleteDependency(dependency models.Dependency) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.Index(s.dependencies, dependency)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.dependencies = slices.Delete(s.dependencies, i, i+1)
+	return nil
+}
+
+// GetDependencies returns the dependencies in which the todo todoId waits or is waited for in the order they were
+// stored.
+func (s *MemoryStore) GetDependencies(todoId int) ([]models.Dependency, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dependencies := []models.Dependency{}
+	for _, dependency := range s.dependencies {
+		if dependency.TodoId == todoId || dependency.BlockerId == todoId {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	return dependencies, nil
+}
+
+// isActionable reports whether all todos the todo todoId waits for are done.
+func (s *MemoryStore) isActionable(todoId int) bool {
+	for _, dependency := range s.dependencies {
+		if i := s.todoIndex(dependency.BlockerId); dependency.TodoId == todoId && i >= 0 && !s.todos[i].IsDone {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateChecklistItem stores item with a new id, after the other items of its todo unless it has a position.
+func (s *MemoryStore) CreateChecklistItem(item models.ChecklistItem) (models.ChecklistItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item.Position != "" && !checklistPositionsUnique(append(slices.Clip(s.checklistItems), item)) {
+		return item, ErrPositionTaken
+	}
+	if item.Position == "" {
+		var last string
+		for _, other := range s.checklistItems {
+			if other.TodoId == item.TodoId {
+				last = max(last, other.Position)
+			}
+		}
+		var err error
+		if item.Position, err = models.PositionBetween(last, ""); err != nil {
+			return item, err
+		}
+	}
+	item.Id = s.nextChecklistItemId
+	s.nextChecklistItemId++
+	s.checklistItems = append(s.checklistItems, item)
+	return item, nil
+}
+
+func (s *MemoryStore) checklistItemIndex(itemId int) int {
+	return slices.IndexFunc(s.checklistItems, func(item models.ChecklistItem) bool {
+		return item.Id == itemId
+	})
+}
+
+// GetChecklistItems returns the items of the todo with id todoId ordered by position.
+func (s *MemoryStore) GetChecklistItems(todoId int) ([]models.ChecklistItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := []models.ChecklistItem{}
+	for _, item := range s.checklistItems {
+		if item.TodoId == todoId {
+			items = append(items, item)
+		}
+	}
+	slices.SortStableFunc(items, func(a, b models.ChecklistItem) int { return strings.Compare(a.Position, b.Position) })
+	return items, nil
+}
+
+// GetChecklistItem returns the item with id itemId.
+func (s *MemoryStore) GetChecklistItem(itemId int) (models.ChecklistItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.checklistItemIndex(itemId)
+	if i < 0 {
+		return models.ChecklistItem{}, sql.ErrNoRows
+	}
+	return s.checklistItems[i], nil
+}
+
+// UpdateChecklistItem replaces the text, checked state and position of the stored item having the same id as item.
+func (s *MemoryStore) UpdateChecklistItem(item models.ChecklistItem) error {
+	return s.UpdateChecklistItems([]models.ChecklistItem{item})
+}
+
+// UpdateChecklistItems replaces each of the stored items having the same id as one of items at once. If one of them
+// does not exist, sql.ErrNoRows is returned, and if two items of a todo would share a position afterwards,
+// [ErrPositionTaken], and none of them are replaced.
+func (s *MemoryStore) UpdateChecklistItems(items []models.ChecklistItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	updated := slices.Clone(s.checklistItems)
+	for _, item := range items {
+		i := s.checklistItemIndex(item.Id)
+		if i < 0 {
+			return sql.ErrNoRows
+		}
+		item.TodoId = s.checklistItems[i].TodoId
+		updated[i] = item
+	}
+	if !checklistPositionsUnique(updated) {
+		return ErrPositionTaken
+	}
+	s.checklistItems = updated
+	return nil
+}
+
+// checklistPositionsUnique reports whether no two of items of the same todo share a position, like the unique index
+// of the SQL stores demands.
+func checklistPositionsUnique(items []models.ChecklistItem) bool {
+	type key struct {
+		todoId   int
+		position string
+	}
+	positions := make(map[key]bool, len(items))
+	for _, item := range items {
+		if positions[key{item.TodoId, item.Position}] {
+			return false
+		}
+		positions[key{item.TodoId, item.Position}] = true
+	}
+	return true
+}
+
+// DeleteChecklistItem deletes the item with id itemId.
+func (s *MemoryStore) DeleteChecklistItem(itemId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.checklistItemIndex(itemId)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.checklistItems = slices.Delete(s.checklistItems, i, i+1)
+	return nil
+}
+
+// CreateReminder stores reminder with a new id.
+func (s *MemoryStore) CreateReminder(reminder models.Reminder) (models.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reminder.Id = s.nextReminderId
+	s.nextReminderId++
+	s.reminders = append(s.reminders, reminder)
+	return reminder, nil
+}
+
+func (s *MemoryStore) reminderIndex(reminderId int) int {
+	return slices.IndexFunc(s.reminders, func(reminder models.Reminder) bool {
+		return reminder.Id == reminderId
+	})
+}
+
+// GetReminders returns the reminders of the todo with id todoId ordered by id.
+func (s *MemoryStore) GetReminders(todoId int) ([]models.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reminders := []models.Reminder{}
+	for _, reminder := range s.reminders {
+		if reminder.TodoId == todoId {
+			reminders = append(reminders, reminder)
+		}
+	}
+	return reminders, nil
+}
+
+// GetReminder returns the reminder with id reminderId.
+func (s *MemoryStore) GetReminder(reminderId int) (models.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.reminderIndex(reminderId)
+	if i < 0 {
+		return models.Reminder{}, sql.ErrNoRows
+	}
+	return s.reminders[i], nil
+}
+
+// UpdateReminder replaces the times of the stored reminder having the same id as reminder.
+func (s *MemoryStore) UpdateReminder(reminder models.Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.reminderIndex(reminder.Id)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	reminder.TodoId = s.reminders[i].TodoId
+	s.reminders[i] = reminder
+	delete(s.reminderClaims, reminder.Id)
+	return nil
+}
+
+// DeleteReminder deletes the reminder with id reminderId.
+func (s *MemoryStore) DeleteReminder(reminderId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.reminderIndex(reminderId)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.reminders = slices.Delete(s.reminders, i, i+1)
+	delete(s.reminderClaims, reminderId)
+	return nil
+}
+
+// GetDueReminders returns the pending reminders that are to be sent at or before now and are not claimed beyond now,
+// the earliest first.
+func (s *MemoryStore) GetDueReminders(now time.Time) ([]models.Reminder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reminders := []models.Reminder{}
+	for _, reminder := range s.reminders {
+		if reminder.SentAt == nil && !reminder.RemindAt.After(now) && !s.reminderClaims[reminder.Id].After(now) {
+			reminders = append(reminders, reminder)
+		}
+	}
+	slices.SortStableFunc(reminders, func(a, b models.Reminder) int { return a.RemindAt.Compare(b.RemindAt) })
+	return reminders, nil
+}
+
+// pendingReminderIndex returns the index of the reminder if it is still pending at the same time, or -1.
+func (s *MemoryStore) pendingReminderIndex(reminder models.Reminder) int {
+	i := s.reminderIndex(reminder.Id)
+	if i < 0 || s.reminders[i].SentAt != nil || !s.reminders[i].RemindAt.Equal(reminder.RemindAt) {
+		return -1
+	}
+	return i
+}
+
+// ClaimReminder reserves the reminder until the time until if it is still pending at the same time and not claimed
+// beyond now.
+func (s *MemoryStore) ClaimReminder(reminder models.Reminder, now time.Time, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pendingReminderIndex(reminder) < 0 || s.reminderClaims[reminder.Id].After(now) {
+		return false, nil
+	}
+	s.reminderClaims[reminder.Id] = until.UTC()
+	return true, nil
+}
+
+// CompleteReminder marks the reminder as sent at sentAt and ends its claim if it is still pending at the same time.
+func (s *MemoryStore) CompleteReminder(reminder models.Reminder, sentAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.pendingReminderIndex(reminder); i >= 0 {
+		sentAt = sentAt.UTC()
+		s.reminders[i].SentAt = &sentAt
+		delete(s.reminderClaims, reminder.Id)
+	}
+	return nil
+}
+
+// ReleaseReminder ends the claim on the reminder if it is still pending at the same time.
+func (s *MemoryStore) ReleaseReminder(reminder models.Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pendingReminderIndex(reminder) >= 0 {
+		delete(s.reminderClaims, reminder.Id)
+	}
+	return nil
+}
+
+// CreateUser stores a [models.User] with its hashed password. User names have to be unique. Users without a role
+// become a [models.RoleMember], users without a timezone use UTC.
+func (s *MemoryStore) CreateUser(user models.User) (models.User, error) {
+	pw, err := user.GetPasswordHash(s.Hasher)
+	if err != nil {
+		return user, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userIndexByName(user.Name) >= 0 {
+		return user, fmt.Errorf("CreateUser: user %s already exists", user.Name)
+	}
+	user.Id = s.nextUserId
+	s.nextUserId++
+	if user.Role == "" {
+		user.Role = models.RoleMember
+	}
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+	s.users = append(s.users, memoryUser{user: user, password: pw})
+	return user, nil
+}
+
+func (s *MemoryStore) userIndexByName(name string) int {
+	return slices.IndexFunc(s.users, func(u memoryUser) bool {
+		return u.user.Name == name
+	})
+}
+
+// GetUsers returns all stored [models.User]s.
+func (s *MemoryStore) GetUsers() ([]models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var users []models.User
+	for _, u := range s.users {
+		users = append(users, u.public())
+	}
+	return users, nil
+}
+
+// LoginUser checks the password of user and creates a new session for client. Users with a confirmed TOTP secret get
+// a challenge and [ErrSecondFactorRequired] instead. Unknown users and wrong passwords return [ErrInvalidCredentials].
+// Disabled users get [ErrUserDisabled]. An outdated password hash is replaced by a hash created by [MemoryStore.Hasher].
+func (s *MemoryStore) LoginUser(user models.User, client models.Client) (models.SessionTokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndexByName(user.Name)
+	if i < 0 {
+		_, err := checkLoginPassword(s.Hasher, user, nil)
+		return models.SessionTokens{}, err
+	}
+	rehash, err := checkLoginPassword(s.Hasher, user, s.users[i].password)
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	if s.users[i].user.Disabled {
+		return models.SessionTokens{}, ErrUserDisabled
+	}
+	if rehash {
+		if hash, err := user.GetPasswordHash(s.Hasher); err == nil {
+			s.users[i].password = hash
+		}
+	}
+	if t, ok := s.totp[s.users[i].user.Id]; ok && t.confirmed {
+		tokens, err := s.createChallenge(s.users[i].user.Id)
+		if err != nil {
+			return tokens, err
+		}
+		return tokens, ErrSecondFactorRequired
+	}
+	return s.createSession(s.users[i].public(), client)
+}
+
+// LoginExternalUser creates a new session for client for the user linked to identity. On the first login of the
+// identity a user without a password is created, a number is appended to the suggested name if it is taken.
+func (s *MemoryStore) LoginExternalUser(identity models.ExternalIdentity, client models.Client) (models.SessionTokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.identities, func(mi memoryIdentity) bool {
+		return mi.issuer == identity.Issuer && mi.subject == identity.Subject
+	})
+	if i >= 0 {
+		for _, u := range s.users {
+			if u.user.Id == s.identities[i].userId {
+				if u.user.Disabled {
+					return models.SessionTokens{}, ErrUserDisabled
+				}
+				return s.createSession(u.public(), client)
+			}
+		}
+		return models.SessionTokens{}, fmt.Errorf("LoginExternalUser: %w", sql.ErrNoRows)
+	}
+	user := models.User{Id: s.nextUserId, Name: identity.Name, Role: models.RoleMember, Timezone: "UTC"}
+	for suffix := 2; s.userIndexByName(user.Name) >= 0; suffix++ {
+		if suffix > maxNameSuffix {
+			return models.SessionTokens{}, fmt.Errorf("LoginExternalUser: no free user name for %s", identity.Name)
+		}
+		user.Name = fmt.Sprintf("%s-%d", identity.Name, suffix)
+	}
+	s.nextUserId++
+	s.users = append(s.users, memoryUser{user: user})
+	s.identities = append(s.identities, memoryIdentity{issuer: identity.Issuer, subject: identity.Subject, userId: user.Id})
+	return s.createSession(user, client)
+}
+
+func (s *MemoryStore) createSession(user models.User, client models.Client) (models.SessionTokens, error) {
+	now := time.Now().UTC()
+	tokens, err := newSessionTokens(now, s.TokenLifetimes)
+	if err != nil {
+		return tokens, err
+	}
+	tokens.SessionId = s.nextSessionId
+	tokens.User = user
+	session := models.Session{
+		Id:               s.nextSessionId,
+		UserId:           user.Id,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        tokens.ExpiresAt,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+		UserAgent:        client.UserAgent,
+		IP:               client.IP,
+	}
+	s.nextSessionId++
+	s.sessions = append(s.sessions, memorySession{
+		session:          session,
+		tokenHash:        hashToken(tokens.AccessToken),
+		refreshTokenHash: hashToken(tokens.RefreshToken),
+	})
+	return tokens, nil
+}
+
+// RefreshSession rotates the tokens of the session the refresh token belongs to and extends the session. Reusing an
+// exchanged refresh token deletes the session and returns [ErrRefreshTokenReused].
+func (s *MemoryStore) RefreshSession(refreshToken string) (models.SessionTokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	refreshHash := hashToken(refreshToken)
+	for i, ms := range s.sessions {
+		if slices.Contains(ms.usedRefreshHashes, refreshHash) {
+			s.sessions = slices.Delete(s.sessions, i, i+1)
+			err := fmt.Errorf("RefreshSession: session %d: %w", ms.session.Id, ErrRefreshTokenReused)
+			return models.SessionTokens{SessionId: ms.session.Id}, err
+		}
+		if ms.refreshTokenHash != refreshHash || !ms.session.RefreshExpiresAt.After(now) {
+			continue
+		}
+		tokens, err := newSessionTokens(now, s.TokenLifetimes)
+		if err != nil {
+			return tokens, err
+		}
+		tokens.SessionId = ms.session.Id
+		for _, u := range s.users {
+			if u.user.Id == ms.session.UserId {
+				tokens.User = u.public()
+			}
+		}
+		s.sessions[i].usedRefreshHashes = append(ms.usedRefreshHashes, refreshHash)
+		s.sessions[i].tokenHash = hashToken(tokens.AccessToken)
+		s.sessions[i].refreshTokenHash = hashToken(tokens.RefreshToken)
+		s.sessions[i].session.LastUsedAt = now
+		s.sessions[i].session.ExpiresAt = tokens.ExpiresAt
+		s.sessions[i].session.RefreshExpiresAt = tokens.RefreshExpiresAt
+		return tokens, nil
+	}
+	return models.SessionTokens{}, fmt.Errorf("RefreshSession: unknown or expired refresh token: %w", sql.ErrNoRows)
+}
+
+// AuthenticateUser returns the [models.User] and [models.Session] the token belongs to if it has not expired yet and
+// the user is not disabled.
+func (s *MemoryStore) AuthenticateUser(token string) (models.User, models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	tokenHash := hashToken(token)
+	for i, ms := range s.sessions {
+		if ms.tokenHash != tokenHash || !ms.session.ExpiresAt.After(now) {
+			continue
+		}
+		for _, u := range s.users {
+			if u.user.Id == ms.session.UserId && !u.user.Disabled {
+				s.sessions[i].session.LastUsedAt = now
+				return u.public(), s.sessions[i].session, nil
+			}
+		}
+	}
+	return models.User{}, models.Session{}, sql.ErrNoRows
+}
+
+// GetSessions returns the sessions of the user with id userId that can still be refreshed, the most recently used
+// first.
+func (s *MemoryStore) GetSessions(userId int) ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	var sessions []models.Session
+	for _, ms := range s.sessions {
+		if ms.session.UserId == userId && ms.session.RefreshExpiresAt.After(now) {
+			sessions = append(sessions, ms.session)
+		}
+	}
+	slices.SortStableFunc(sessions, func(a, b models.Session) int {
+		return b.LastUsedAt.Compare(a.LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// DeleteSession deletes the session sessionId if it belongs to the user with id userId.
+func (s *MemoryStore) DeleteSession(userId int, sessionId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.sessions, func(ms memorySession) bool {
+		return ms.session.Id == sessionId && ms.session.UserId == userId
+	})
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.sessions = slices.Delete(s.sessions, i, i+1)
+	return nil
+}
+
+// DeleteAllSessions deletes every session of the user with id userId.
+func (s *MemoryStore) DeleteAllSessions(userId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := len(s.sessions)
+	s.sessions = slices.DeleteFunc(s.sessions, func(ms memorySession) bool {
+		return ms.session.UserId == userId
+	})
+	return count - len(s.sessions), nil
+}
+
+// DeleteOtherSessions deletes all sessions of the user with id userId except keepSessionId.
+func (s *MemoryStore) DeleteOtherSessions(userId int, keepSessionId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := len(s.sessions)
+	s.sessions = slices.DeleteFunc(s.sessions, func(ms memorySession) bool {
+		return ms.session.UserId == userId && ms.session.Id != keepSessionId
+	})
+	return count - len(s.sessions), nil
+}
+
+// CreateAccessToken stores a new personal access token for the user with id userId.
+func (s *MemoryStore) CreateAccessToken(userId int, name string, scopes []models.Scope, expiresAt *time.Time) (models.AccessToken, string, error) {
+	token, err := newAccessToken()
+	if err != nil {
+		return models.AccessToken{}, "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	accessToken := models.AccessToken{
+		Id:        s.nextTokenId,
+		UserId:    userId,
+		Name:      name,
+		Scopes:    slices.Clone(scopes),
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+	s.nextTokenId++
+	s.accessTokens = append(s.accessTokens, memoryAccessToken{accessToken: accessToken, tokenHash: hashToken(token)})
+	return accessToken, token, nil
+}
+
+// GetAccessTokens returns all personal access tokens of the user with id userId including expired ones.
+func (s *MemoryStore) GetAccessTokens(userId int) ([]models.AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tokens []models.AccessToken
+	for _, mt := range s.accessTokens {
+		if mt.accessToken.UserId == userId {
+			tokens = append(tokens, mt.accessToken)
+		}
+	}
+	return tokens, nil
+}
+
+// DeleteAccessToken deletes the access token tokenId if it belongs to the user with id userId.
+func (s *MemoryStore) DeleteAccessToken(userId int, tokenId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.accessTokens, func(mt memoryAccessToken) bool {
+		return mt.accessToken.Id == tokenId && mt.accessToken.UserId == userId
+	})
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.accessTokens = slices.Delete(s.accessTokens, i, i+1)
+	return nil
+}
+
+// AuthenticateAccessToken returns the [models.User] and [models.AccessToken] the token belongs to if it has not
+// expired.
+func (s *MemoryStore) AuthenticateAccessToken(token string) (models.User, models.AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	tokenHash := hashToken(token)
+	for i, mt := range s.accessTokens {
+		expiresAt := mt.accessToken.ExpiresAt
+		if mt.tokenHash != tokenHash || (expiresAt != nil && !expiresAt.After(now)) {
+			continue
+		}
+		for _, u := range s.users {
+			if u.user.Id == mt.accessToken.UserId && !u.user.Disabled {
+				s.accessTokens[i].accessToken.LastUsedAt = &now
+				return u.public(), s.accessTokens[i].accessToken, nil
+			}
+		}
+	}
+	return models.User{}, models.AccessToken{}, sql.ErrNoRows
+}
+
+// RevokeSessions adds the sessions to the revocation list until expiresAt.
+func (s *MemoryStore) RevokeSessions(sessionIds []int, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sessionId := range sessionIds {
+		s.revoked[sessionId] = expiresAt
+	}
+	return nil
+}
+
+// GetRevokedSessions returns the ids of the revoked sessions that did not expire yet and forgets the expired ones.
+func (s *MemoryStore) GetRevokedSessions() ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var sessionIds []int
+	for sessionId, expiresAt := range s.revoked {
+		if !expiresAt.After(now) {
+			delete(s.revoked, sessionId)
+			continue
+		}
+		sessionIds = append(sessionIds, sessionId)
+	}
+	slices.Sort(sessionIds)
+	return sessionIds, nil
+}
+
+// EnrollTOTP replaces an unconfirmed TOTP secret and the recovery codes of the user with new ones.
+func (s *MemoryStore) EnrollTOTP(userId int) (models.TOTPEnrollment, error) {
+	enrollment, err := newEnrollment()
+	if err != nil {
+		return enrollment, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.totp[userId]; ok && t.confirmed {
+		return enrollment, ErrTOTPEnabled
+	}
+	t := &memoryTOTP{secret: enrollment.Secret}
+	for _, code := range enrollment.RecoveryCodes {
+		t.recoveryHashes = append(t.recoveryHashes, hashRecoveryCode(code))
+	}
+	s.totp[userId] = t
+	return enrollment, nil
+}
+
+// ConfirmTOTP confirms the unconfirmed TOTP secret of the user if code is valid.
+func (s *MemoryStore) ConfirmTOTP(userId int, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.totp[userId]
+	if !ok || t.confirmed {
+		return sql.ErrNoRows
+	}
+	step, ok := totp.Validate(t.secret, code, time.Now(), t.lastStep)
+	if !ok {
+		return ErrInvalidCode
+	}
+	t.confirmed = true
+	t.lastStep = step
+	return nil
+}
+
+// DisableTOTP removes the TOTP secret and the recovery codes of the user if code is valid.
+func (s *MemoryStore) DisableTOTP(userId int, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.verifySecondFactor(userId, code); err != nil {
+		return err
+	}
+	delete(s.totp, userId)
+	return nil
+}
+
+// verifySecondFactor checks code against the confirmed TOTP secret and the recovery codes of the user. A used recovery
+// code is removed.
+func (s *MemoryStore) verifySecondFactor(userId int, code string) error {
+	t, ok := s.totp[userId]
+	if !ok || !t.confirmed {
+		return sql.ErrNoRows
+	}
+	if step, ok := totp.Validate(t.secret, code, time.Now(), t.lastStep); ok {
+		t.lastStep = step
+		return nil
+	}
+	i := slices.Index(t.recoveryHashes, hashRecoveryCode(code))
+	if i < 0 {
+		return ErrInvalidCode
+	}
+	t.recoveryHashes = slices.Delete(t.recoveryHashes, i, i+1)
+	return nil
+}
+
+func (s *MemoryStore) createChallenge(userId int) (models.SessionTokens, error) {
+	challenge, err := createUserToken(32)
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	now := time.Now()
+	s.challenges = slices.DeleteFunc(s.challenges, func(c memoryChallenge) bool {
+		return !c.expiresAt.After(now) || c.userId == userId
+	})
+	tokens := models.SessionTokens{Challenge: challenge, ChallengeExpiresAt: now.Add(challengeLifetime).UTC()}
+	s.challenges = append(s.challenges, memoryChallenge{
+		tokenHash: hashToken(challenge),
+		userId:    userId,
+		expiresAt: tokens.ChallengeExpiresAt,
+	})
+	return tokens, nil
+}
+
+// CompleteLogin creates a session for the login the challenge belongs to if code is a valid second factor. The
+// challenge is removed after it was completed or after too many wrong codes. Wrong codes are counted as failed logins
+// of the user name.
+func (s *MemoryStore) CompleteLogin(challenge string, code string, client models.Client) (models.SessionTokens, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash := hashToken(challenge)
+	i := slices.IndexFunc(s.challenges, func(c memoryChallenge) bool {
+		return c.tokenHash == hash && c.expiresAt.After(time.Now())
+	})
+	if i < 0 {
+		return models.SessionTokens{}, fmt.Errorf("CompleteLogin: %w", sql.ErrNoRows)
+	}
+	userId := s.challenges[i].userId
+	j := slices.IndexFunc(s.users, func(u memoryUser) bool { return u.user.Id == userId })
+	if j < 0 {
+		return models.SessionTokens{}, fmt.Errorf("CompleteLogin: %w", sql.ErrNoRows)
+	}
+	err := s.verifySecondFactor(userId, code)
+	if errors.Is(err, ErrInvalidCode) {
+		key := memoryLoginFailureKey{loginFailureAccount, s.users[j].user.Name}
+		failures := s.loginFailures[key]
+		failures.Count++
+		failures.previousFailureAt, failures.LastFailureAt = failures.LastFailureAt, time.Now().UTC()
+		s.loginFailures[key] = failures
+		if s.challenges[i].attempts+1 < maxChallengeAttempts {
+			s.challenges[i].attempts++
+			return models.SessionTokens{}, fmt.Errorf("CompleteLogin: %w", err)
+		}
+	}
+	s.challenges = slices.Delete(s.challenges, i, i+1)
+	if err != nil {
+		return models.SessionTokens{}, fmt.Errorf("CompleteLogin: %w", err)
+	}
+	return s.createSession(s.users[j].public(), client)
+}
+
+// GetUser returns the user with id userId.
+func (s *MemoryStore) GetUser(userId int) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndexById(userId)
+	if i < 0 {
+		return models.User{}, sql.ErrNoRows
+	}
+	user := s.users[i].public()
+	user.Email = s.users[i].user.Email
+	user.Timezone = s.users[i].user.Timezone
+	return user, nil
+}
+
+// SetUserRole changes the role of the user with id userId.
+func (s *MemoryStore) SetUserRole(userId int, role models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndexById(userId)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.users[i].user.Role = role
+	return nil
+}
+
+// SetUserTimezone changes the timezone of the user with id userId.
+func (s *MemoryStore) SetUserTimezone(userId int, timezone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndexById(userId)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.users[i].user.Timezone = timezone
+	return nil
+}
+
+// SetUserDisabled disables or re-enables the user with id userId. Pending second factor logins of a disabled user are
+// deleted.
+func (s *MemoryStore) SetUserDisabled(userId int, disabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndexById(userId)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.users[i].user.Disabled = disabled
+	if disabled {
+		s.challenges = slices.DeleteFunc(s.challenges, func(c memoryChallenge) bool { return c.userId == userId })
+	}
+	return nil
+}
+
+// DeleteUser deletes the user with id userId and everything that belongs to it.
+func (s *MemoryStore) DeleteUser(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndexById(userId)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	s.users = slices.Delete(s.users, i, i+1)
+	var todoIds []int
+	s.todos = slices.DeleteFunc(s.todos, func(todo models.Todo) bool {
+		if todo.UserId == userId {
+			todoIds = append(todoIds, todo.Id)
+			return true
+		}
+		return false
+	})
+	s.shares = slices.DeleteFunc(s.shares, func(share memoryShare) bool {
+		return share.userId == userId || slices.Contains(todoIds, share.todoId)
+	})
+	s.reminders = slices.DeleteFunc(s.reminders, func(reminder models.Reminder) bool {
+		return slices.Contains(todoIds, reminder.TodoId)
+	})
+	s.dependencies = slices.DeleteFunc(s.dependencies, func(dependency models.Dependency) bool {
+		return slices.Contains(todoIds, dependency.TodoId) || slices.Contains(todoIds, dependency.BlockerId)
+	})
+	s.checklistItems = slices.DeleteFunc(s.checklistItems, func(item models.ChecklistItem) bool {
+		return slices.Contains(todoIds, item.TodoId)
+	})
+	s.tags = slices.DeleteFunc(s.tags, func(tag models.Tag) bool { return tag.UserId == userId })
+	var listIds []int
+	for _, list := range s.lists {
+		if list.UserId == userId {
+			listIds = append(listIds, list.Id)
+		}
+	}
+	s.deleteLists(listIds)
+	s.listShares = slices.DeleteFunc(s.listShares, func(share memoryListShare) bool { return share.userId == userId })
+	s.sessions = slices.DeleteFunc(s.sessions, func(ms memorySession) bool { return ms.session.UserId == userId })
+	s.accessTokens = slices.DeleteFunc(s.accessTokens, func(mt memoryAccessToken) bool { return mt.accessToken.UserId == userId })
+	s.identities = slices.DeleteFunc(s.identities, func(mi memoryIdentity) bool { return mi.userId == userId })
+	delete(s.totp, userId)
+	s.challenges = slices.DeleteFunc(s.challenges, func(c memoryChallenge) bool { return c.userId == userId })
+	s.resets = slices.DeleteFunc(s.resets, func(r memoryPasswordReset) bool { return r.userId == userId })
+	return nil
+}
+
+func (s *MemoryStore) userIndexById(userId int) int {
+	return slices.IndexFunc(s.users, func(u memoryUser) bool {
+		return u.user.Id == userId
+	})
+}
+
+// ChangePassword replaces the password of the user with newPassword if currentPassword is correct.
+func (s *MemoryStore) ChangePassword(userId int, currentPassword string, newPassword string) error {
+	hash, err := s.Hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndexById(userId)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	current := models.User{}
+	current.SetPassword(currentPassword)
+	if _, err := current.CheckPassword(s.Hasher, s.users[i].password); err != nil {
+		return ErrWrongPassword
+	}
+	s.users[i].password = []byte(hash)
+	return nil
+}
+
+// CreatePasswordReset replaces the reset tokens of the user called name with a new one.
+func (s *MemoryStore) CreatePasswordReset(name string) (models.User, string, error) {
+	token, err := createUserToken(32)
+	if err != nil {
+		return models.User{}, "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndexByName(name)
+	if i < 0 {
+		return models.User{}, "", sql.ErrNoRows
+	}
+	user := models.User{Id: s.users[i].user.Id, Name: s.users[i].user.Name, Email: s.users[i].user.Email}
+	now := time.Now()
+	s.resets = slices.DeleteFunc(s.resets, func(r memoryPasswordReset) bool {
+		return r.userId == user.Id || !r.expiresAt.After(now)
+	})
+	s.resets = append(s.resets, memoryPasswordReset{
+		tokenHash: hashToken(token),
+		userId:    user.Id,
+		expiresAt: now.Add(PasswordResetLifetime),
+	})
+	return user, token, nil
+}
+
+// GetPasswordReset returns the user of the token.
+func (s *MemoryStore) GetPasswordReset(token string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokenHash := hashToken(token)
+	r := slices.IndexFunc(s.resets, func(r memoryPasswordReset) bool {
+		return r.tokenHash == tokenHash && r.expiresAt.After(time.Now())
+	})
+	if r < 0 {
+		return models.User{}, sql.ErrNoRows
+	}
+	i := s.userIndexById(s.resets[r].userId)
+	if i < 0 {
+		return models.User{}, sql.ErrNoRows
+	}
+	return models.User{Id: s.users[i].user.Id, Name: s.users[i].user.Name, Email: s.users[i].user.Email}, nil
+}
+
+// ResetPassword removes the token and sets the password of its user.
+func (s *MemoryStore) ResetPassword(token string, newPassword string) (int, error) {
+	hash, err := s.Hasher.Hash(newPassword)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokenHash := hashToken(token)
+	r := slices.IndexFunc(s.resets, func(r memoryPasswordReset) bool {
+		return r.tokenHash == tokenHash && r.expiresAt.After(time.Now())
+	})
+	if r < 0 {
+		return 0, sql.ErrNoRows
+	}
+	userId := s.resets[r].userId
+	s.resets = slices.Delete(s.resets, r, r+1)
+	i := s.userIndexById(userId)
+	if i < 0 {
+		return 0, sql.ErrNoRows
+	}
+	s.users[i].password = []byte(hash)
+	return userId, nil
+}
+
+// GetLoginFailures returns the counters of name and ip. Missing counters are returned as zero.
+func (s *MemoryStore) GetLoginFailures(name string, ip string) (models.LoginFailures, models.LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loginFailures[memoryLoginFailureKey{loginFailureAccount, name}].LoginFailures,
+		s.loginFailures[memoryLoginFailureKey{loginFailureClient, ip}].LoginFailures, nil
+}
+
+// ReserveLoginAttempt increments the counters of name and ip and returns them as they were before. Counters that
+// were not incremented within window are deleted before.
+func (s *MemoryStore) ReserveLoginAttempt(name string, ip string, window time.Duration) (models.LoginFailures, models.LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	maps.DeleteFunc(s.loginFailures, func(_ memoryLoginFailureKey, failures memoryLoginFailures) bool {
+		return !failures.LastFailureAt.After(now.Add(-window))
+	})
+	reserve := func(key memoryLoginFailureKey) models.LoginFailures {
+		failures := s.loginFailures[key]
+		before := failures.LoginFailures
+		failures.Count++
+		failures.previousFailureAt, failures.LastFailureAt = failures.LastFailureAt, now
+		s.loginFailures[key] = failures
+		return before
+	}
+	return reserve(memoryLoginFailureKey{loginFailureAccount, name}), reserve(memoryLoginFailureKey{loginFailureClient, ip}), nil
+}
+
+// ReleaseLoginAttempt decrements the counters of name and ip and restores the time of their failure before. Counters
+// that drop to zero are deleted.
+func (s *MemoryStore) ReleaseLoginAttempt(name string, ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range []memoryLoginFailureKey{{loginFailureAccount, name}, {loginFailureClient, ip}} {
+		failures, ok := s.loginFailures[key]
+		if !ok {
+			continue
+		}
+		failures.Count--
+		if failures.Count <= 0 {
+			delete(s.loginFailures, key)
+			continue
+		}
+		if !failures.previousFailureAt.IsZero() {
+			failures.LastFailureAt = failures.previousFailureAt
+		}
+		s.loginFailures[key] = failures
+	}
+	return nil
+}
+
+// ResetLoginFailures deletes the counter of name.
+func (s *MemoryStore) ResetLoginFailures(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.loginFailures, memoryLoginFailureKey{loginFailureAccount, name})
+	return nil
+}
+
+// UnlockUser deletes the counter of the name of the user with id userId. If the user does not exist, it returns
+// [sql.ErrNoRows].
+func (s *MemoryStore) UnlockUser(userId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndexById(userId)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	delete(s.loginFailures, memoryLoginFailureKey{loginFailureAccount, s.users[i].user.Name})
+	return nil
+}
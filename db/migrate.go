@@ -0,0 +1,203 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migration is a numbered schema change. The up statements apply it and the down statements revert it.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus describes a migration and whether it has been applied to the database.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator is implemented by the stores whose schema is managed by migrations.
+type Migrator interface {
+	// MigrateUp applies all pending migrations in order and returns how many were applied.
+	MigrateUp() (int, error)
+	// MigrateDown reverts the last steps applied migrations and returns how many were reverted.
+	MigrateDown(steps int) (int, error)
+	// MigrationStatus lists all known migrations and whether they are applied.
+	MigrationStatus() ([]MigrationStatus, error)
+}
+
+var _ Migrator = (*SQLStore)(nil)
+
+// loadMigrations reads the migrations of a dialect from the embedded files. Every migration consists of a file
+// named "<version>_<name>.up.sql" and an optional "<version>_<name>.down.sql".
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, entry := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("loadMigrations: unexpected migration file %s", entry.Name())
+		}
+		versionStr, description, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("loadMigrations: migration file %s has no version: %w", entry.Name(), err)
+		}
+		content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: description}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+	var migrations []migration
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("loadMigrations: migration %d has no up file", m.version)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b migration) int {
+		return a.version - b.version
+	})
+	return migrations, nil
+}
+
+func (s *SQLStore) ensureMigrationTable() error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)`
+	_, err := s.db.Exec(stmt)
+	return err
+}
+
+// appliedMigrations returns the time every applied migration version was applied at.
+func (s *SQLStore) appliedMigrations() (map[int]time.Time, error) {
+	if err := s.ensureMigrationTable(); err != nil {
+		return nil, err
+	}
+	rows, err := s.query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrateUp applies all migrations of the store's dialect that are not recorded in the schema_migrations table yet.
+// Every migration runs in its own transaction together with the insert of its version.
+func (s *SQLStore) MigrateUp() (int, error) {
+	migrations, err := loadMigrations(s.dialect.migrations())
+	if err != nil {
+		return 0, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		stmt := `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`
+		if err := s.runMigration(m.up, stmt, m.version, m.name, time.Now().UTC()); err != nil {
+			return count, fmt.Errorf("MigrateUp: migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown reverts the last steps applied migrations in reverse order.
+func (s *SQLStore) MigrateDown(steps int) (int, error) {
+	migrations, err := loadMigrations(s.dialect.migrations())
+	if err != nil {
+		return 0, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+		if m.down == "" {
+			return count, fmt.Errorf("MigrateDown: migration %d (%s) cannot be reverted", m.version, m.name)
+		}
+		stmt := `DELETE FROM schema_migrations WHERE version = ?`
+		if err := s.runMigration(m.down, stmt, m.version); err != nil {
+			return count, fmt.Errorf("MigrateDown: migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *SQLStore) runMigration(statements string, record string, args ...any) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(statements); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.dialect.rebind(record), args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus lists all migrations of the store's dialect and whether and when they were applied.
+func (s *SQLStore) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(s.dialect.migrations())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.version]
+		statuses = append(statuses, MigrationStatus{Version: m.version, Name: m.name, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMigrations_DialectsMatch(t *testing.T) {
+	sqlite, err := loadMigrations(sqliteDialect{}.migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	postgres, err := loadMigrations(postgresDialect{}.migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("SQLite has %d migrations but PostgreSQL has %d", len(sqlite), len(postgres))
+	}
+	for i := range sqlite {
+		if sqlite[i].version != postgres[i].version || sqlite[i].name != postgres[i].name {
+			t.Fatalf("Migration %d (%s) has no PostgreSQL counterpart", sqlite[i].version, sqlite[i].name)
+		}
+	}
+}
+
+func TestSQLStore_Migrate(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	migrations, err := loadMigrations(store.dialect.migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := store.MigrateUp()
+	if err != nil || count != len(migrations) {
+		t.Fatalf("Expected %d applied migrations but got %d (%v)", len(migrations), count, err)
+	}
+	if count, err := store.MigrateUp(); err != nil || count != 0 {
+		t.Fatalf("Migrations were applied twice: %d (%v)", count, err)
+	}
+	statuses, err := store.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() {
+			t.Fatalf("Migration %d is not reported as applied", status.Version)
+		}
+	}
+
+	if count, err := store.MigrateDown(len(migrations)); err != nil || count != len(migrations) {
+		t.Fatalf("Expected %d reverted migrations but got %d (%v)", len(migrations), count, err)
+	}
+	if _, err := store.GetUsers(); err == nil {
+		t.Fatalf("The users table still exists after reverting all migrations")
+	}
+	if count, err := store.MigrateUp(); err != nil || count != len(migrations) {
+		t.Fatalf("Reapplying the migrations failed: %d (%v)", count, err)
+	}
+}
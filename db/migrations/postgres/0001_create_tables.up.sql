@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    password TEXT NOT NULL,
+    token TEXT UNIQUE,
+    expiration TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS todos(
+    id SERIAL PRIMARY KEY,
+    title TEXT,
+    text TEXT,
+    is_done BOOLEAN,
+    user_id INTEGER NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS users_todos(
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER,
+    todo_id INTEGER,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+    UNIQUE (user_id, todo_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN token TEXT UNIQUE, ADD COLUMN expiration TIMESTAMPTZ;
+
+DROP TABLE IF EXISTS sessions;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS used_refresh_tokens;
+
+ALTER TABLE sessions DROP COLUMN refresh_expires_at, DROP COLUMN refresh_token_hash;
@@ -0,0 +1,11 @@
+ALTER TABLE sessions ADD COLUMN refresh_token_hash TEXT UNIQUE, ADD COLUMN refresh_expires_at TIMESTAMPTZ;
+
+UPDATE sessions SET refresh_expires_at = expires_at;
+
+ALTER TABLE sessions ALTER COLUMN refresh_expires_at SET NOT NULL;
+
+CREATE TABLE used_refresh_tokens(
+    token_hash TEXT PRIMARY KEY,
+    session_id INTEGER NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
@@ -0,0 +1,7 @@
+CREATE TABLE login_failures(
+    kind TEXT NOT NULL,
+    identifier TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (kind, identifier)
+);
@@ -0,0 +1,4 @@
+DROP INDEX todos_list_id;
+ALTER TABLE todos DROP COLUMN list_id;
+DROP TABLE IF EXISTS users_lists;
+DROP TABLE IF EXISTS lists;
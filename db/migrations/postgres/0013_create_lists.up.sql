@@ -0,0 +1,18 @@
+CREATE TABLE lists(
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE users_lists(
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    list_id INTEGER NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+    UNIQUE (user_id, list_id)
+);
+
+ALTER TABLE todos ADD COLUMN list_id INTEGER REFERENCES lists(id) ON DELETE SET NULL;
+CREATE INDEX todos_list_id ON todos(list_id);
@@ -0,0 +1,12 @@
+CREATE TABLE reminders(
+    id SERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,
+    at TIMESTAMPTZ,
+    before_minutes INTEGER,
+    remind_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ,
+    FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX reminders_todo_id ON reminders(todo_id);
+CREATE INDEX reminders_pending ON reminders(remind_at) WHERE sent_at IS NULL;
@@ -0,0 +1,3 @@
+DROP INDEX todos_user_id_position;
+ALTER TABLE todos DROP COLUMN position;
+ALTER TABLE todos DROP COLUMN priority;
@@ -0,0 +1,3 @@
+ALTER TABLE lists DROP COLUMN workflow;
+ALTER TABLE todos DROP COLUMN completed_at;
+ALTER TABLE todos DROP COLUMN status;
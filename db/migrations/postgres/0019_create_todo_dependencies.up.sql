@@ -0,0 +1,10 @@
+CREATE TABLE todo_dependencies(
+    id SERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,
+    blocker_id INTEGER NOT NULL,
+    UNIQUE (todo_id, blocker_id),
+    FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocker_id) REFERENCES todos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX todo_dependencies_blocker_id ON todo_dependencies(blocker_id);
@@ -0,0 +1,12 @@
+ALTER TABLE todos ADD COLUMN checklist_auto_complete BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE checklist_items(
+    id SERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    checked BOOLEAN NOT NULL DEFAULT FALSE,
+    position TEXT COLLATE "C" NOT NULL,
+    FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX checklist_items_todo_id_position ON checklist_items(todo_id, position);
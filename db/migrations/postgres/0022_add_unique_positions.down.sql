@@ -0,0 +1,4 @@
+DROP INDEX checklist_items_todo_id_position;
+CREATE INDEX checklist_items_todo_id_position ON checklist_items(todo_id, position);
+DROP INDEX todos_user_id_position;
+CREATE INDEX todos_user_id_position ON todos(user_id, position);
@@ -0,0 +1 @@
+ALTER SEQUENCE sessions_id_seq NO CYCLE;
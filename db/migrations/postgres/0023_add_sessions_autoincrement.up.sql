@@ -0,0 +1,2 @@
+-- SERIAL ids are never handed out again, this only makes sure the sequence of the sessions never starts over
+ALTER SEQUENCE sessions_id_seq NO CYCLE;
@@ -0,0 +1,2 @@
+-- a claimed reminder is reserved for the run that claimed it until claimed_until, afterwards another run may send it
+ALTER TABLE reminders ADD COLUMN claimed_until TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS users_todos;
+DROP TABLE IF EXISTS todos;
+DROP TABLE IF EXISTS users;
@@ -1,8 +1,4 @@
-DROP TABLE IF EXISTS users;
-DROP TABLE IF EXISTS todos;
-DROP TABLE IF EXISTS users_todos;
-
-CREATE TABLE users (
+CREATE TABLE IF NOT EXISTS users (
     id INTEGER PRIMARY KEY,
     name TEXT UNIQUE NOT NULL,
     password TEXT NOT NULL,
@@ -10,7 +6,7 @@ CREATE TABLE users (
     expiration TEXT
 );
 
-CREATE TABLE todos(
+CREATE TABLE IF NOT EXISTS todos(
     id INTEGER PRIMARY KEY,
     title TEXT,
     text TEXT,
@@ -19,11 +15,11 @@ CREATE TABLE todos(
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
-CREATE TABLE users_todos(
+CREATE TABLE IF NOT EXISTS users_todos(
     id INTEGER PRIMARY KEY,
     user_id INTEGER,
     todo_id INTEGER,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
     UNIQUE (user_id, todo_id)
-)
\ No newline at end of file
+);
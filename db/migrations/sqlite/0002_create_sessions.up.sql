@@ -0,0 +1,16 @@
+CREATE TABLE sessions(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX sessions_user_id ON sessions(user_id);
+
+-- SQLite cannot drop UNIQUE columns, so the tokens stored on the users are only invalidated
+UPDATE users SET token = NULL, expiration = NULL;
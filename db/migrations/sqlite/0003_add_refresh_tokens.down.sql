@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS used_refresh_tokens;
+
+DROP INDEX IF EXISTS sessions_refresh_token_hash;
+
+ALTER TABLE sessions DROP COLUMN refresh_expires_at;
+ALTER TABLE sessions DROP COLUMN refresh_token_hash;
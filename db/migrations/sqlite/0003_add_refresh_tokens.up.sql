@@ -0,0 +1,12 @@
+ALTER TABLE sessions ADD COLUMN refresh_token_hash TEXT;
+ALTER TABLE sessions ADD COLUMN refresh_expires_at TIMESTAMP;
+
+UPDATE sessions SET refresh_expires_at = expires_at;
+
+CREATE UNIQUE INDEX sessions_refresh_token_hash ON sessions(refresh_token_hash);
+
+CREATE TABLE used_refresh_tokens(
+    token_hash TEXT PRIMARY KEY,
+    session_id INTEGER NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
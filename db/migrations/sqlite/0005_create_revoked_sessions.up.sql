@@ -0,0 +1,4 @@
+CREATE TABLE revoked_sessions(
+    session_id INTEGER PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN email TEXT;
+
+CREATE TABLE password_resets(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
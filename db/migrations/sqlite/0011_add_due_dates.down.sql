@@ -0,0 +1,5 @@
+DROP INDEX todos_due_at;
+ALTER TABLE users DROP COLUMN timezone;
+ALTER TABLE todos DROP COLUMN all_day;
+ALTER TABLE todos DROP COLUMN due_at;
+ALTER TABLE todos DROP COLUMN start_at;
@@ -0,0 +1,5 @@
+ALTER TABLE todos ADD COLUMN start_at TIMESTAMP;
+ALTER TABLE todos ADD COLUMN due_at TIMESTAMP;
+ALTER TABLE todos ADD COLUMN all_day BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+CREATE INDEX todos_due_at ON todos(due_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS todos_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,2 @@
+ALTER TABLE todos ADD COLUMN parent_id INTEGER REFERENCES todos(id) ON DELETE SET NULL;
+CREATE INDEX todos_parent_id ON todos(parent_id);
@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE todos ADD COLUMN position TEXT NOT NULL DEFAULT '';
+UPDATE todos SET position = printf('%010d', id) || 'i';
+CREATE INDEX todos_user_id_position ON todos(user_id, position);
@@ -0,0 +1,4 @@
+ALTER TABLE todos ADD COLUMN status TEXT NOT NULL DEFAULT 'open';
+ALTER TABLE todos ADD COLUMN completed_at TIMESTAMP;
+UPDATE todos SET status = 'done' WHERE is_done;
+ALTER TABLE lists ADD COLUMN workflow TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS checklist_items;
+ALTER TABLE todos DROP COLUMN checklist_auto_complete;
@@ -0,0 +1 @@
+ALTER TABLE login_failures DROP COLUMN previous_failure_at;
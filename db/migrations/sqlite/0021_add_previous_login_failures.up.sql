@@ -0,0 +1 @@
+ALTER TABLE login_failures ADD COLUMN previous_failure_at TIMESTAMP;
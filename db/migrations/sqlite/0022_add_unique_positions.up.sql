@@ -0,0 +1,9 @@
+UPDATE todos SET position = position || printf('%010d', id) || 'i'
+WHERE EXISTS (SELECT 1 FROM todos AS t WHERE t.user_id = todos.user_id AND t.position = todos.position AND t.id < todos.id);
+DROP INDEX todos_user_id_position;
+CREATE UNIQUE INDEX todos_user_id_position ON todos(user_id, position);
+
+UPDATE checklist_items SET position = position || printf('%010d', id) || 'i'
+WHERE EXISTS (SELECT 1 FROM checklist_items AS c WHERE c.todo_id = checklist_items.todo_id AND c.position = checklist_items.position AND c.id < checklist_items.id);
+DROP INDEX checklist_items_todo_id_position;
+CREATE UNIQUE INDEX checklist_items_todo_id_position ON checklist_items(todo_id, position);
@@ -0,0 +1,37 @@
+CREATE TEMPORARY TABLE sessions_backup AS SELECT * FROM sessions;
+CREATE TEMPORARY TABLE used_refresh_tokens_backup AS SELECT * FROM used_refresh_tokens;
+
+DROP TABLE used_refresh_tokens;
+DROP TABLE sessions;
+
+CREATE TABLE sessions(
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    refresh_token_hash TEXT,
+    refresh_expires_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX sessions_user_id ON sessions(user_id);
+CREATE UNIQUE INDEX sessions_refresh_token_hash ON sessions(refresh_token_hash);
+
+CREATE TABLE used_refresh_tokens(
+    token_hash TEXT PRIMARY KEY,
+    session_id INTEGER NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+INSERT INTO sessions(id, user_id, token_hash, created_at, last_used_at, expires_at, user_agent, ip, refresh_token_hash,
+    refresh_expires_at)
+SELECT id, user_id, token_hash, created_at, last_used_at, expires_at, user_agent, ip, refresh_token_hash,
+    refresh_expires_at FROM sessions_backup;
+INSERT INTO used_refresh_tokens(token_hash, session_id) SELECT token_hash, session_id FROM used_refresh_tokens_backup;
+
+DROP TABLE sessions_backup;
+DROP TABLE used_refresh_tokens_backup;
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+	"todo/models"
+)
+
+// PasswordResetLifetime is how long a password reset token can be used.
+const PasswordResetLifetime = 30 * time.Minute
+
+// ChangePassword checks currentPassword against the hash in 'users' and replaces it with the hash of newPassword.
+func (s *SQLStore) ChangePassword(userId int, currentPassword string, newPassword string) error {
+	var hash string
+	if err := s.queryRow(`SELECT password FROM users WHERE id = ?`, userId).Scan(&hash); err != nil {
+		return err
+	}
+	current := models.User{}
+	current.SetPassword(currentPassword)
+	if _, err := current.CheckPassword(s.Hasher, []byte(hash)); err != nil {
+		return ErrWrongPassword
+	}
+	newHash, err := s.Hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(`UPDATE users SET password = ? WHERE id = ?`, newHash, userId)
+	return err
+}
+
+// CreatePasswordReset replaces the reset tokens of the user called name in 'password_resets' with a new one.
+func (s *SQLStore) CreatePasswordReset(name string) (models.User, string, error) {
+	var user models.User
+	var email sql.NullString
+	if err := s.queryRow(`SELECT id, name, email FROM users WHERE name = ?`, name).Scan(&user.Id, &user.Name, &email); err != nil {
+		return user, "", err
+	}
+	user.Email = email.String
+	token, err := createUserToken(32)
+	if err != nil {
+		return user, "", err
+	}
+	now := time.Now().UTC()
+	if _, err := s.exec(`DELETE FROM password_resets WHERE user_id = ? OR expires_at <= ?`, user.Id, now); err != nil {
+		return user, "", err
+	}
+	stmt := `INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES (?, ?, ?)`
+	if _, err := s.exec(stmt, user.Id, hashToken(token), now.Add(PasswordResetLifetime)); err != nil {
+		return user, "", err
+	}
+	return user, token, nil
+}
+
+// GetPasswordReset selects the user of the token from 'password_resets' and 'users'.
+func (s *SQLStore) GetPasswordReset(token string) (models.User, error) {
+	var user models.User
+	var email sql.NullString
+	stmt := `SELECT u.id, u.name, u.email FROM password_resets AS r JOIN users AS u ON u.id = r.user_id
+		WHERE r.token_hash = ? AND r.expires_at > ?`
+	if err := s.queryRow(stmt, hashToken(token), time.Now().UTC()).Scan(&user.Id, &user.Name, &email); err != nil {
+		return user, err
+	}
+	user.Email = email.String
+	return user, nil
+}
+
+// ResetPassword deletes the token from 'password_resets' and sets the password of its user.
+func (s *SQLStore) ResetPassword(token string, newPassword string) (int, error) {
+	newHash, err := s.Hasher.Hash(newPassword)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var userId int
+	stmt := `DELETE FROM password_resets WHERE token_hash = ? AND expires_at > ? RETURNING user_id`
+	if err := tx.QueryRow(s.dialect.rebind(stmt), hashToken(token), time.Now().UTC()).Scan(&userId); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(s.dialect.rebind(`UPDATE users SET password = ? WHERE id = ?`), newHash, userId); err != nil {
+		return 0, err
+	}
+	return userId, tx.Commit()
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+	"todo/models"
+)
+
+const reminderColumns = `id, todo_id, at, before_minutes, remind_at, sent_at`
+
+// scanReminder scans a row with the columns of reminderColumns into reminder.
+func scanReminder(row interface{ Scan(...any) error }, reminder *models.Reminder) error {
+	var at, sentAt sql.NullTime
+	var beforeMinutes sql.NullInt64
+	if err := row.Scan(&reminder.Id, &reminder.TodoId, &at, &beforeMinutes, &reminder.RemindAt, &sentAt); err != nil {
+		return err
+	}
+	reminder.At, reminder.SentAt = timePtr(at), timePtr(sentAt)
+	reminder.BeforeMinutes = intPtr(beforeMinutes)
+	reminder.RemindAt = reminder.RemindAt.UTC()
+	return nil
+}
+
+// timePtr converts a nullable column value to an optional time in UTC.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+// queryReminders runs a query selecting reminderColumns from 'reminders'.
+func (s *SQLStore) queryReminders(stmt string, args ...any) ([]models.Reminder, error) {
+	reminders := []models.Reminder{}
+	rows, err := s.query(stmt, args...)
+	if err != nil {
+		return reminders, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var reminder models.Reminder
+		if err := scanReminder(rows, &reminder); err != nil {
+			return reminders, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+// CreateReminder inserts reminder into 'reminders' and returns it with its id set.
+func (s *SQLStore) CreateReminder(reminder models.Reminder) (models.Reminder, error) {
+	stmt := `INSERT INTO reminders (todo_id, at, before_minutes, remind_at, sent_at) VALUES (?, ?, ?, ?, ?) RETURNING id`
+	err := s.queryRow(stmt, reminder.TodoId, nullTime(reminder.At), nullInt(reminder.BeforeMinutes),
+		reminder.RemindAt.UTC(), nullTime(reminder.SentAt)).Scan(&reminder.Id)
+	return reminder, err
+}
+
+// GetReminders selects the reminders of the todo with id todoId from 'reminders' ordered by id.
+func (s *SQLStore) GetReminders(todoId int) ([]models.Reminder, error) {
+	return s.queryReminders(`SELECT `+reminderColumns+` FROM reminders WHERE todo_id = ? ORDER BY id`, todoId)
+}
+
+// GetReminder selects the reminder with id reminderId from 'reminders'.
+func (s *SQLStore) GetReminder(reminderId int) (models.Reminder, error) {
+	var reminder models.Reminder
+	err := scanReminder(s.queryRow(`SELECT `+reminderColumns+` FROM reminders WHERE id = ?`, reminderId), &reminder)
+	return reminder, err
+}
+
+// UpdateReminder sets the times of the row in 'reminders' with the id of reminder and clears its claimed_until.
+func (s *SQLStore) UpdateReminder(reminder models.Reminder) error {
+	stmt := `UPDATE reminders SET at = ?, before_minutes = ?, remind_at = ?, sent_at = ?, claimed_until = NULL WHERE id = ?`
+	return s.updateOne(stmt, nullTime(reminder.At), nullInt(reminder.BeforeMinutes), reminder.RemindAt.UTC(),
+		nullTime(reminder.SentAt), reminder.Id)
+}
+
+// DeleteReminder deletes the reminder with id reminderId from 'reminders'.
+func (s *SQLStore) DeleteReminder(reminderId int) error {
+	return s.updateOne(`DELETE FROM reminders WHERE id = ?`, reminderId)
+}
+
+// GetDueReminders selects the rows of 'reminders' that are not sent, due at or before now and not claimed beyond now.
+func (s *SQLStore) GetDueReminders(now time.Time) ([]models.Reminder, error) {
+	stmt := `SELECT ` + reminderColumns + ` FROM reminders
+		WHERE sent_at IS NULL AND remind_at <= ? AND (claimed_until IS NULL OR claimed_until <= ?) ORDER BY remind_at, id`
+	return s.queryReminders(stmt, now.UTC(), now.UTC())
+}
+
+// ClaimReminder sets claimed_until of the row in 'reminders' with the id of reminder to until if it is still pending
+// at the same remind_at and not claimed beyond now. The single conditional update makes concurrent claims of the same
+// reminder fail.
+func (s *SQLStore) ClaimReminder(reminder models.Reminder, now time.Time, until time.Time) (bool, error) {
+	stmt := `UPDATE reminders SET claimed_until = ?
+		WHERE id = ? AND sent_at IS NULL AND remind_at = ? AND (claimed_until IS NULL OR claimed_until <= ?)`
+	err := s.updateOne(stmt, until.UTC(), reminder.Id, reminder.RemindAt.UTC(), now.UTC())
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CompleteReminder sets sent_at and clears claimed_until of the row in 'reminders' with the id of reminder if it is
+// still pending at the same remind_at.
+func (s *SQLStore) CompleteReminder(reminder models.Reminder, sentAt time.Time) error {
+	stmt := `UPDATE reminders SET sent_at = ?, claimed_until = NULL WHERE id = ? AND sent_at IS NULL AND remind_at = ?`
+	_, err := s.exec(stmt, sentAt.UTC(), reminder.Id, reminder.RemindAt.UTC())
+	return err
+}
+
+// ReleaseReminder clears claimed_until of the row in 'reminders' with the id of reminder if it is still pending at the
+// same remind_at.
+func (s *SQLStore) ReleaseReminder(reminder models.Reminder) error {
+	stmt := `UPDATE reminders SET claimed_until = NULL WHERE id = ? AND sent_at IS NULL AND remind_at = ?`
+	_, err := s.exec(stmt, reminder.Id, reminder.RemindAt.UTC())
+	return err
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"time"
+)
+
+// RevokeSessions inserts the sessions into 'revoked_sessions' or extends their existing entries until expiresAt.
+func (s *SQLStore) RevokeSessions(sessionIds []int, expiresAt time.Time) error {
+	stmt := `INSERT INTO revoked_sessions (session_id, expires_at) VALUES (?, ?) ON CONFLICT (session_id) DO UPDATE SET expires_at = excluded.expires_at`
+	for _, sessionId := range sessionIds {
+		if _, err := s.exec(stmt, sessionId, expiresAt.UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRevokedSessions returns the ids of the revoked sessions that did not expire yet and deletes the expired ones.
+func (s *SQLStore) GetRevokedSessions() ([]int, error) {
+	var sessionIds []int
+	now := time.Now().UTC()
+	if _, err := s.exec(`DELETE FROM revoked_sessions WHERE expires_at <= ?`, now); err != nil {
+		return sessionIds, err
+	}
+	rows, err := s.query(`SELECT session_id FROM revoked_sessions`)
+	if err != nil {
+		return sessionIds, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sessionId int
+		if err := rows.Scan(&sessionId); err != nil {
+			return sessionIds, err
+		}
+		sessionIds = append(sessionIds, sessionId)
+	}
+	return sessionIds, rows.Err()
+}
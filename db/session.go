@@ -0,0 +1,165 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"todo/logger"
+	"todo/models"
+)
+
+const sessionColumns = `s.id, s.user_id, s.created_at, s.last_used_at, s.expires_at, s.refresh_expires_at, s.user_agent, s.ip`
+
+// sessionFields returns the scan destinations matching sessionColumns.
+func sessionFields(session *models.Session) []any {
+	return []any{&session.Id, &session.UserId, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt,
+		&session.RefreshExpiresAt, &session.UserAgent, &session.IP}
+}
+
+// createSession inserts a new session for user and returns its tokens. New tokens are generated if the hash of a random
+// token collides with an existing one.
+func (s *SQLStore) createSession(user models.User, client models.Client) (models.SessionTokens, error) {
+	now := time.Now().UTC()
+	stmt := `INSERT INTO sessions (user_id, token_hash, refresh_token_hash, created_at, last_used_at, expires_at, refresh_expires_at, user_agent, ip) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	for {
+		tokens, err := newSessionTokens(now, s.TokenLifetimes)
+		if err != nil {
+			return tokens, err
+		}
+		tokens.User = user
+		err = s.queryRow(stmt, user.Id, hashToken(tokens.AccessToken), hashToken(tokens.RefreshToken), now, now,
+			tokens.ExpiresAt, tokens.RefreshExpiresAt, client.UserAgent, client.IP).Scan(&tokens.SessionId)
+		if err == nil {
+			return tokens, nil
+		}
+		if !s.dialect.isUniqueViolation(err) {
+			return models.SessionTokens{}, err
+		}
+		newErr := fmt.Errorf("createSession: Token generation collision: %w", err)
+		logger.Warning(newErr.Error())
+	}
+}
+
+// RefreshSession rotates the tokens of the session the refresh token belongs to and extends the session by the
+// refresh lifetime. The hash of the exchanged refresh token is kept in 'used_refresh_tokens' to detect its reuse, in
+// which case the session is deleted and [ErrRefreshTokenReused] is returned.
+func (s *SQLStore) RefreshSession(refreshToken string) (models.SessionTokens, error) {
+	refreshHash := hashToken(refreshToken)
+	now := time.Now().UTC()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	defer tx.Rollback()
+	var sessionId int
+	var user models.User
+	stmt := `SELECT s.id, u.id, u.name, u.role FROM sessions AS s JOIN users AS u ON (u.id = s.user_id) WHERE s.refresh_token_hash = ? AND s.refresh_expires_at > ?`
+	err = tx.QueryRow(s.dialect.rebind(stmt), refreshHash, now).Scan(&sessionId, &user.Id, &user.Name, &user.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		revokedId, err := s.revokeReusedSession(tx, refreshHash)
+		return models.SessionTokens{SessionId: revokedId}, err
+	}
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	tokens, err := newSessionTokens(now, s.TokenLifetimes)
+	if err != nil {
+		return tokens, err
+	}
+	tokens.SessionId = sessionId
+	tokens.User = user
+	stmt = `INSERT INTO used_refresh_tokens (token_hash, session_id) VALUES (?, ?)`
+	if _, err := tx.Exec(s.dialect.rebind(stmt), refreshHash, sessionId); err != nil {
+		return models.SessionTokens{}, err
+	}
+	stmt = `UPDATE sessions SET token_hash = ?, refresh_token_hash = ?, last_used_at = ?, expires_at = ?, refresh_expires_at = ? WHERE id = ?`
+	_, err = tx.Exec(s.dialect.rebind(stmt), hashToken(tokens.AccessToken), hashToken(tokens.RefreshToken), now,
+		tokens.ExpiresAt, tokens.RefreshExpiresAt, sessionId)
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	return tokens, tx.Commit()
+}
+
+// revokeReusedSession deletes the session a used refresh token belonged to and returns its id together with
+// [ErrRefreshTokenReused]. If the token is unknown or expired [sql.ErrNoRows] is returned.
+func (s *SQLStore) revokeReusedSession(tx *sql.Tx, refreshHash string) (int, error) {
+	var sessionId int
+	stmt := `SELECT session_id FROM used_refresh_tokens WHERE token_hash = ?`
+	err := tx.QueryRow(s.dialect.rebind(stmt), refreshHash).Scan(&sessionId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("RefreshSession: unknown or expired refresh token: %w", err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(s.dialect.rebind(`DELETE FROM used_refresh_tokens WHERE session_id = ?`), sessionId); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(s.dialect.rebind(`DELETE FROM sessions WHERE id = ?`), sessionId); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return sessionId, fmt.Errorf("RefreshSession: session %d: %w", sessionId, ErrRefreshTokenReused)
+}
+
+// GetSessions returns the sessions of the user with id userId that can still be refreshed, the most recently used
+// first.
+func (s *SQLStore) GetSessions(userId int) ([]models.Session, error) {
+	var sessions []models.Session
+	stmt := `SELECT ` + sessionColumns + ` FROM sessions AS s WHERE s.user_id = ? AND s.refresh_expires_at > ? ORDER BY s.last_used_at DESC`
+	rows, err := s.query(stmt, userId, time.Now().UTC())
+	if err != nil {
+		return sessions, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(sessionFields(&session)...); err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession deletes the session sessionId if it belongs to the user with id userId. If there is no such session
+// [sql.ErrNoRows] is returned.
+func (s *SQLStore) DeleteSession(userId int, sessionId int) error {
+	stmt := `DELETE FROM sessions WHERE id = ? AND user_id = ?`
+	result, err := s.exec(stmt, sessionId, userId)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteOtherSessions deletes all sessions of the user with id userId except keepSessionId.
+func (s *SQLStore) DeleteOtherSessions(userId int, keepSessionId int) (int, error) {
+	stmt := `DELETE FROM sessions WHERE user_id = ? AND id != ?`
+	result, err := s.exec(stmt, userId, keepSessionId)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// DeleteAllSessions deletes every session of the user with id userId.
+func (s *SQLStore) DeleteAllSessions(userId int) (int, error) {
+	stmt := `DELETE FROM sessions WHERE user_id = ?`
+	result, err := s.exec(stmt, userId)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
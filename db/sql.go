@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"todo/password"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// dialect contains the differences between the SQL databases supported by [SQLStore].
+type dialect interface {
+	// rebind converts a statement using '?' placeholders to the placeholder syntax of the database.
+	rebind(stmt string) string
+	// isUniqueViolation reports whether err was caused by a violated unique constraint.
+	isUniqueViolation(err error) bool
+	// lockTable returns a statement that makes transactions writing to table wait for the transaction running it, or
+	// an empty string if transactions of the database already wait for each other.
+	lockTable(table string) string
+	// migrations returns the directory of the embedded migrations written for the database.
+	migrations() string
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) rebind(stmt string) string {
+	return stmt
+}
+
+func (sqliteDialect) isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// lockTable returns an empty string, as transactions of a [SQLStore] opened by [NewSQLiteStore] take the write lock of
+// the whole database when they begin.
+func (sqliteDialect) lockTable(table string) string {
+	return ""
+}
+
+func (sqliteDialect) migrations() string {
+	return "migrations/sqlite"
+}
+
+type postgresDialect struct{}
+
+// rebind replaces every '?' in stmt with a numbered '$n' placeholder. Statements must not contain a literal '?'.
+func (postgresDialect) rebind(stmt string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range stmt {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDialect) isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (postgresDialect) lockTable(table string) string {
+	return "LOCK TABLE " + table + " IN SHARE ROW EXCLUSIVE MODE"
+}
+
+func (postgresDialect) migrations() string {
+	return "migrations/postgres"
+}
+
+// SQLStore is a [Store] that persists everything in a SQL database. The statements are written for SQLite and
+// converted by a dialect if another database, like PostgreSQL, is used.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+	// TokenLifetimes are the lifetimes of the tokens issued for new and refreshed sessions.
+	TokenLifetimes TokenLifetimes
+	// Hasher hashes new passwords. Outdated hashes are replaced on login.
+	Hasher password.Hasher
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// NewSQLiteStore opens the SQLite database at path and returns a [SQLStore] using it. Foreign keys are enforced, so
+// that deleting a user deletes everything that references it. Transactions take the write lock when they begin, so
+// that concurrent transactions wait for each other instead of failing when they read before they write.
+func NewSQLiteStore(path string) (*SQLStore, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	conn, err := sql.Open("sqlite3", path+separator+"_foreign_keys=on&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: conn, dialect: sqliteDialect{}, TokenLifetimes: DefaultTokenLifetimes, Hasher: password.DefaultHasher}, nil
+}
+
+// NewPostgresStore connects to the PostgreSQL database described by the connection string dsn and returns a
+// [SQLStore] using it.
+func NewPostgresStore(dsn string) (*SQLStore, error) {
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &SQLStore{db: conn, dialect: postgresDialect{}, TokenLifetimes: DefaultTokenLifetimes, Hasher: password.DefaultHasher}, nil
+}
+
+// Close closes the underlying database connection.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) queryRow(stmt string, args ...any) *sql.Row {
+	return s.db.QueryRow(s.dialect.rebind(stmt), args...)
+}
+
+func (s *SQLStore) query(stmt string, args ...any) (*sql.Rows, error) {
+	return s.db.Query(s.dialect.rebind(stmt), args...)
+}
+
+func (s *SQLStore) exec(stmt string, args ...any) (sql.Result, error) {
+	return s.db.Exec(s.dialect.rebind(stmt), args...)
+}
+
+// updateOne executes stmt, which changes a single row, and returns [sql.ErrNoRows] if it did not affect any row.
+func (s *SQLStore) updateOne(stmt string, args ...any) error {
+	result, err := s.exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
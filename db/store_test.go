@@ -0,0 +1,1242 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"todo/models"
+	"todo/password"
+	"todo/totp"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testStore runs the same checks against every [Store] implementation so that the backends stay interchangeable.
+func testStore(t *testing.T, store Store) {
+	john := models.User{Name: "john"}
+	john.SetPassword("doe")
+	john, err := store.CreateUser(john)
+	if err != nil {
+		t.Fatalf("Creating a user failed: %v", err)
+	}
+	jane := models.User{Name: "jane"}
+	jane.SetPassword("doe")
+	jane, err = store.CreateUser(jane)
+	if err != nil {
+		t.Fatalf("Creating a second user failed: %v", err)
+	}
+	if _, err := store.CreateUser(jane); err == nil {
+		t.Fatalf("Creating a user with an existing name succeeded")
+	}
+
+	tokens, err := store.LoginUser(john, models.Client{UserAgent: "curl", IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	user, session, err := store.AuthenticateUser(tokens.AccessToken)
+	if err != nil || user.Id != john.Id {
+		t.Fatalf("The login token does not authenticate john: %+v, %v", user, err)
+	}
+	if session.UserId != john.Id || session.UserAgent != "curl" || session.IP != "127.0.0.1" {
+		t.Fatalf("The session does not match the login: %+v", session)
+	}
+	if _, _, err := store.AuthenticateUser("invalid"); err == nil {
+		t.Fatalf("An invalid token was accepted")
+	}
+	wrongPassword := models.User{Name: "john"}
+	wrongPassword.SetPassword("wrong")
+	if _, err := store.LoginUser(wrongPassword, models.Client{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login with a wrong password did not fail with invalid credentials: %v", err)
+	}
+	unknown := models.User{Name: "unknown"}
+	unknown.SetPassword("doe")
+	if _, err := store.LoginUser(unknown, models.Client{}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login of an unknown user did not fail with invalid credentials: %v", err)
+	}
+	testSessions(t, store, john, tokens.AccessToken)
+	testRefreshSession(t, store, john)
+	testAccessTokens(t, store, john)
+	testRevocations(t, store)
+	testExternalUsers(t, store, john)
+	testTwoFactor(t, store, jane)
+	testPasswords(t, store)
+	testLoginAttempts(t, store, jane)
+	testRehash(t, store)
+
+	todo, err := store.CreateTodo(models.Todo{Title: "title", Text: "text", UserId: john.Id})
+	if err != nil {
+		t.Fatalf("Creating a todo failed: %v", err)
+	}
+	todo.SetStatus(models.StatusDone, time.Now())
+	todo.Title = "new title"
+	if err := store.UpdateTodo(todo); err != nil {
+		t.Fatalf("Updating the todo failed: %v", err)
+	}
+	if stored, err := store.GetTodo(todo.Id); err != nil || !reflect.DeepEqual(stored, todo) {
+		t.Fatalf("The stored todo %+v does not match the updated todo %+v (%v)", stored, todo, err)
+	}
+	if _, err := store.CreateTodoShare(todo.Id, jane.Id); err != nil {
+		t.Fatalf("Sharing the todo failed: %v", err)
+	}
+	if shares, err := store.GetTodoShares(todo.Id); err != nil || !slices.Equal(shares, []int{jane.Id}) {
+		t.Fatalf("Unexpected shares %v (%v)", shares, err)
+	}
+	if todos, err := store.GetTodos(jane.Id, models.TodoFilter{}); err != nil || len(todos) != 0 {
+		t.Fatalf("Shared todos were returned without asking for them: %v (%v)", todos, err)
+	}
+	if todos, err := store.GetTodos(jane.Id, models.TodoFilter{IncludeShared: true}); err != nil || len(todos) != 1 || todos[0].Id != todo.Id {
+		t.Fatalf("The shared todo was not returned: %v (%v)", todos, err)
+	}
+	if _, err := store.DeleteTodoShare(todo.Id, jane.Id); err != nil {
+		t.Fatalf("Removing the share failed: %v", err)
+	}
+	if deleted, err := store.DeleteTodo(todo.Id); err != nil || deleted.Id != todo.Id {
+		t.Fatalf("Deleting the todo failed: %+v (%v)", deleted, err)
+	}
+	if _, err := store.GetTodo(todo.Id); err == nil {
+		t.Fatalf("The todo can still be fetched after deleting it")
+	}
+	testDueDates(t, store, john)
+	testTags(t, store, john, jane)
+	testLists(t, store, john, jane)
+	testSubtasks(t, store, john)
+	testReminders(t, store, john)
+	testOrder(t, store, john)
+	testDependencies(t, store, john)
+	testChecklists(t, store, john)
+	testRoles(t, store, john)
+}
+
+// testDueDates checks storing the dates of todos and filtering by due dates in the timezone of the user.
+func testDueDates(t *testing.T, store Store, user models.User) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 5, 31, 9, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	timed, err := store.CreateTodo(models.Todo{Title: "timed", UserId: user.Id, StartAt: &start, DueAt: &due})
+	if err != nil {
+		t.Fatalf("Creating a todo with dates failed: %v", err)
+	}
+	allDay, _ := store.CreateTodo(models.Todo{Title: "all day", UserId: user.Id, DueAt: &date, AllDay: true, Recurrence: "FREQ=WEEKLY"})
+	undated, _ := store.CreateTodo(models.Todo{Title: "undated", UserId: user.Id})
+	stored, err := store.GetTodo(timed.Id)
+	if err != nil || !stored.StartAt.Equal(start) || !stored.DueAt.Equal(due) || stored.AllDay {
+		t.Fatalf("The dates were not stored: %+v (%v)", stored, err)
+	}
+	stored.DueAt = nil
+	if err := store.UpdateTodo(stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := store.GetTodo(timed.Id); stored.DueAt != nil || stored.StartAt == nil {
+		t.Fatalf("The due date was not removed: %+v", stored)
+	}
+	store.UpdateTodo(timed)
+	if stored, _ := store.GetTodo(allDay.Id); stored.Recurrence != "FREQ=WEEKLY" {
+		t.Fatalf("The recurrence was not stored: %+v", stored)
+	}
+
+	// the all-day todo is due at 2024-06-01T15:00:00Z in Tokyo
+	bound := func(hour int) *time.Time {
+		t := time.Date(2024, 6, 1, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+	tests := []struct {
+		name   string
+		filter models.TodoFilter
+		want   []int
+	}{
+		{"no filter", models.TodoFilter{}, []int{timed.Id, allDay.Id, undated.Id}},
+		{"due before noon", models.TodoFilter{DueBefore: bound(12), Location: tokyo}, []int{timed.Id}},
+		{"due before the end of the date", models.TodoFilter{DueBefore: bound(15), Location: tokyo}, []int{timed.Id, allDay.Id}},
+		{"due after noon", models.TodoFilter{DueAfter: bound(12), Location: tokyo}, []int{allDay.Id}},
+		{"due in UTC", models.TodoFilter{DueAfter: bound(15)}, []int{allDay.Id}},
+		{"due in Tokyo", models.TodoFilter{DueAfter: bound(15), Location: tokyo}, nil},
+	}
+	for _, test := range tests {
+		todos, err := store.GetTodos(user.Id, test.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var ids []int
+		for _, todo := range todos {
+			ids = append(ids, todo.Id)
+		}
+		if !slices.Equal(ids, test.want) {
+			t.Fatalf("%s: expected the todos %v but got %v", test.name, test.want, ids)
+		}
+	}
+	for _, todo := range []models.Todo{timed, allDay, undated} {
+		store.DeleteTodo(todo.Id)
+	}
+}
+
+// testTags checks renaming, merging and deleting tags and filtering todos by tags.
+func testTags(t *testing.T, store Store, user models.User, other models.User) {
+	work, err := store.CreateTag(models.Tag{UserId: user.Id, Name: "work", Color: "#ff0000"})
+	if err != nil {
+		t.Fatalf("Creating a tag failed: %v", err)
+	}
+	if _, err := store.CreateTag(models.Tag{UserId: user.Id, Name: "work"}); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("Creating a tag with a taken name did not fail with ErrTagExists: %v", err)
+	}
+	if _, err := store.CreateTag(models.Tag{UserId: other.Id, Name: "work"}); err != nil {
+		t.Fatalf("Creating a tag with the name of a tag of another user failed: %v", err)
+	}
+	home, _ := store.CreateTag(models.Tag{UserId: user.Id, Name: "home"})
+	urgent, _ := store.CreateTag(models.Tag{UserId: user.Id, Name: "urgent"})
+	if tags, err := store.GetTags(user.Id); err != nil || !reflect.DeepEqual(tags, []models.Tag{home, urgent, work}) {
+		t.Fatalf("Unexpected tags %+v (%v)", tags, err)
+	}
+
+	both, err := store.CreateTodo(models.Todo{Title: "both", UserId: user.Id, Tags: []models.Tag{work, urgent}})
+	if err != nil {
+		t.Fatalf("Creating a todo with tags failed: %v", err)
+	}
+	private, _ := store.CreateTodo(models.Todo{Title: "private", UserId: user.Id, Tags: []models.Tag{home}})
+	untagged, _ := store.CreateTodo(models.Todo{Title: "untagged", UserId: user.Id})
+	// the tags other users give to the todos they share must not match the filters of the user
+	otherHome, _ := store.CreateTag(models.Tag{UserId: other.Id, Name: "home"})
+	theirs, _ := store.CreateTodo(models.Todo{Title: "theirs", UserId: other.Id, Tags: []models.Tag{otherHome}})
+	store.CreateTodoShare(theirs.Id, user.Id)
+	if stored, err := store.GetTodo(both.Id); err != nil || !reflect.DeepEqual(stored.Tags, []models.Tag{urgent, work}) {
+		t.Fatalf("The tags of the todo were not stored: %+v (%v)", stored.Tags, err)
+	}
+	work.Name = "office"
+	if err := store.UpdateTag(work); err != nil {
+		t.Fatalf("Renaming the tag failed: %v", err)
+	}
+	work.Name = "urgent"
+	if err := store.UpdateTag(work); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("Renaming the tag to a taken name did not fail with ErrTagExists: %v", err)
+	}
+	work.Name = "office"
+	if stored, _ := store.GetTodo(both.Id); !reflect.DeepEqual(stored.Tags, []models.Tag{work, urgent}) {
+		t.Fatalf("The todo does not show the new name of the tag: %+v", stored.Tags)
+	}
+
+	tests := []struct {
+		name   string
+		filter models.TodoFilter
+		want   []int
+	}{
+		{"any tag", models.TodoFilter{Tags: []string{"office", "home"}}, []int{both.Id, private.Id}},
+		{"all tags", models.TodoFilter{Tags: []string{"office", "urgent"}, AllTags: true}, []int{both.Id}},
+		{"all tags without match", models.TodoFilter{Tags: []string{"office", "home"}, AllTags: true}, nil},
+		{"unknown tag", models.TodoFilter{Tags: []string{"unknown"}}, nil},
+		{"tags of others", models.TodoFilter{Tags: []string{"home"}, IncludeShared: true}, []int{private.Id}},
+	}
+	for _, test := range tests {
+		todos, err := store.GetTodos(user.Id, test.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var ids []int
+		for _, todo := range todos {
+			ids = append(ids, todo.Id)
+		}
+		if !slices.Equal(ids, test.want) {
+			t.Fatalf("%s: expected the todos %v but got %v", test.name, test.want, ids)
+		}
+	}
+
+	if err := store.MergeTags(urgent.Id, home.Id); err != nil {
+		t.Fatalf("Merging the tags failed: %v", err)
+	}
+	if _, err := store.GetTag(urgent.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("The merged tag still exists: %v", err)
+	}
+	if stored, _ := store.GetTodo(both.Id); !reflect.DeepEqual(stored.Tags, []models.Tag{home, work}) {
+		t.Fatalf("The todo was not moved to the merged tag: %+v", stored.Tags)
+	}
+	if err := store.DeleteTag(home.Id); err != nil {
+		t.Fatalf("Deleting the tag failed: %v", err)
+	}
+	if stored, _ := store.GetTodo(private.Id); len(stored.Tags) != 0 {
+		t.Fatalf("The deleted tag is still attached: %+v", stored.Tags)
+	}
+	for _, todo := range []models.Todo{both, private, untagged, theirs} {
+		store.DeleteTodo(todo.Id)
+	}
+	store.DeleteTag(work.Id)
+	store.DeleteTag(otherHome.Id)
+}
+
+// testLists checks moving todos between lists and sharing the todos of a list by sharing the list.
+func testLists(t *testing.T, store Store, user models.User, other models.User) {
+	work, err := store.CreateList(models.List{UserId: user.Id, Name: "work"})
+	if err != nil {
+		t.Fatalf("Creating a list failed: %v", err)
+	}
+	home, _ := store.CreateList(models.List{UserId: user.Id, Name: "home"})
+	work.Name = "office"
+	work.Workflow = models.Workflow{models.StatusOpen: {models.StatusInProgress}, models.StatusInProgress: {models.StatusDone}}
+	if err := store.UpdateList(work); err != nil {
+		t.Fatalf("Renaming the list failed: %v", err)
+	}
+	if lists, err := store.GetLists(user.Id, false); err != nil || !reflect.DeepEqual(lists, []models.List{work, home}) {
+		t.Fatalf("Unexpected lists %+v (%v)", lists, err)
+	}
+
+	todo, err := store.CreateTodo(models.Todo{Title: "in list", UserId: user.Id, ListId: &work.Id})
+	if err != nil {
+		t.Fatalf("Creating a todo in a list failed: %v", err)
+	}
+	unlisted, _ := store.CreateTodo(models.Todo{Title: "not in list", UserId: user.Id})
+	if stored, err := store.GetTodo(todo.Id); err != nil || stored.ListId == nil || *stored.ListId != work.Id {
+		t.Fatalf("The list of the todo was not stored: %+v (%v)", stored, err)
+	}
+	if todos, err := store.GetTodos(user.Id, models.TodoFilter{ListId: &work.Id}); err != nil || len(todos) != 1 || todos[0].Id != todo.Id {
+		t.Fatalf("Unexpected todos in the list %+v (%v)", todos, err)
+	}
+	todo.ListId = &home.Id
+	if err := store.UpdateTodo(todo); err != nil {
+		t.Fatalf("Moving the todo to another list failed: %v", err)
+	}
+	if todos, _ := store.GetTodos(user.Id, models.TodoFilter{ListId: &work.Id}); len(todos) != 0 {
+		t.Fatalf("The moved todo is still in the list: %+v", todos)
+	}
+
+	if _, err := store.CreateListShare(home.Id, other.Id); err != nil {
+		t.Fatalf("Sharing the list failed: %v", err)
+	}
+	if shares, err := store.GetListShares(home.Id); err != nil || !slices.Equal(shares, []int{other.Id}) {
+		t.Fatalf("Unexpected list shares %v (%v)", shares, err)
+	}
+	if lists, err := store.GetLists(other.Id, true); err != nil || !reflect.DeepEqual(lists, []models.List{home}) {
+		t.Fatalf("The shared list was not returned: %+v (%v)", lists, err)
+	}
+	if todos, err := store.GetTodos(other.Id, models.TodoFilter{IncludeShared: true}); err != nil || len(todos) != 1 || todos[0].Id != todo.Id {
+		t.Fatalf("The todo in the shared list was not returned: %+v (%v)", todos, err)
+	}
+	if _, err := store.DeleteListShare(home.Id, other.Id); err != nil {
+		t.Fatalf("Removing the list share failed: %v", err)
+	}
+	if todos, _ := store.GetTodos(other.Id, models.TodoFilter{IncludeShared: true}); len(todos) != 0 {
+		t.Fatalf("The todo is still shared after removing the list share: %+v", todos)
+	}
+
+	store.CreateListShare(home.Id, other.Id)
+	if err := store.DeleteList(home.Id); err != nil {
+		t.Fatalf("Deleting the list failed: %v", err)
+	}
+	if stored, err := store.GetTodo(todo.Id); err != nil || stored.ListId != nil {
+		t.Fatalf("The todo was not taken out of the deleted list: %+v (%v)", stored, err)
+	}
+	if shares, _ := store.GetListShares(home.Id); len(shares) != 0 {
+		t.Fatalf("The shares of the deleted list still exist: %v", shares)
+	}
+	if err := store.DeleteList(home.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Deleting an unknown list did not fail: %v", err)
+	}
+	for _, todo := range []models.Todo{todo, unlisted} {
+		store.DeleteTodo(todo.Id)
+	}
+	store.DeleteList(work.Id)
+}
+
+// testSubtasks checks fetching the subtasks of a todo, that deleting a todo keeps its subtasks as top-level todos and
+// deleting a todo together with its subtasks.
+func testSubtasks(t *testing.T, store Store, user models.User) {
+	parent, _ := store.CreateTodo(models.Todo{Title: "parent", UserId: user.Id})
+	child, err := store.CreateTodo(models.Todo{Title: "child", UserId: user.Id, ParentId: &parent.Id})
+	if err != nil {
+		t.Fatalf("Creating a subtask failed: %v", err)
+	}
+	grandchild, _ := store.CreateTodo(models.Todo{Title: "grandchild", UserId: user.Id, ParentId: &child.Id})
+	if stored, err := store.GetTodo(child.Id); err != nil || stored.ParentId == nil || *stored.ParentId != parent.Id {
+		t.Fatalf("The parent of the subtask was not stored: %+v (%v)", stored, err)
+	}
+	if todos, err := store.GetTodos(user.Id, models.TodoFilter{ParentId: &parent.Id}); err != nil || len(todos) != 1 || todos[0].Id != child.Id {
+		t.Fatalf("Unexpected subtasks %+v (%v)", todos, err)
+	}
+	if _, err := store.DeleteTodo(parent.Id); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := store.GetTodo(child.Id); err != nil || stored.ParentId != nil {
+		t.Fatalf("The subtask of the deleted todo was not kept as top-level todo: %+v (%v)", stored, err)
+	}
+	if stored, _ := store.GetTodo(grandchild.Id); stored.ParentId == nil || *stored.ParentId != child.Id {
+		t.Fatalf("The parent of a subtask further down was changed: %+v", stored)
+	}
+	child, _ = store.GetTodo(child.Id)
+	grandchild, _ = store.GetTodo(grandchild.Id)
+	child.IsDone, grandchild.IsDone = true, true
+	if err := store.UpdateTodos([]models.Todo{grandchild, child}); err != nil {
+		t.Fatalf("Updating the todos together failed: %v", err)
+	}
+	for _, id := range []int{child.Id, grandchild.Id} {
+		if stored, _ := store.GetTodo(id); !stored.IsDone {
+			t.Fatalf("Todo %d was not updated together with the other: %+v", id, stored)
+		}
+	}
+	sibling, _ := store.CreateTodo(models.Todo{Title: "sibling", UserId: user.Id})
+	if deleted, err := store.DeleteTodoWithSubtasks(child.Id); err != nil || deleted.Id != child.Id {
+		t.Fatalf("Deleting the todo with its subtasks failed: %+v (%v)", deleted, err)
+	}
+	for _, id := range []int{child.Id, grandchild.Id} {
+		if _, err := store.GetTodo(id); !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("Todo %d was not deleted with its parent: %v", id, err)
+		}
+	}
+	if _, err := store.DeleteTodo(sibling.Id); err != nil {
+		t.Fatalf("A todo outside of the subtasks was deleted: %v", err)
+	}
+}
+
+// testOrder checks that new todos are placed last and that todos are sorted by several keys.
+func testOrder(t *testing.T, store Store, user models.User) {
+	due := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	later := due.Add(time.Hour)
+	first, err := store.CreateTodo(models.Todo{Title: "first", UserId: user.Id, Priority: models.PriorityHigh})
+	if err != nil {
+		t.Fatalf("Creating a todo failed: %v", err)
+	}
+	second, _ := store.CreateTodo(models.Todo{Title: "second", UserId: user.Id, DueAt: &later})
+	third, _ := store.CreateTodo(models.Todo{Title: "third", UserId: user.Id, Priority: models.PriorityHigh, DueAt: &due})
+	if first.Position == "" || first.Position >= second.Position || second.Position >= third.Position {
+		t.Fatalf("The todos were not placed in the order they were created: %q, %q, %q", first.Position, second.Position, third.Position)
+	}
+	if stored, _ := store.GetTodo(first.Id); stored.Priority != models.PriorityHigh || stored.Position != first.Position {
+		t.Fatalf("The priority or position was not stored: %+v", stored)
+	}
+	// move the third todo to the front
+	third.Position, _ = models.PositionBetween("", first.Position)
+	if err := store.UpdateTodo(third); err != nil {
+		t.Fatal(err)
+	}
+	ids := func(sort string) []int {
+		sorts, _ := models.ParseTodoSort(sort)
+		var ids []int
+		todos, err := store.GetTodos(user.Id, models.TodoFilter{Sort: sorts})
+		if err != nil {
+			t.Fatalf("Fetching the todos sorted by %q failed: %v", sort, err)
+		}
+		for _, todo := range todos {
+			ids = append(ids, todo.Id)
+		}
+		return ids
+	}
+	for sort, want := range map[string][]int{
+		"position":          {third.Id, first.Id, second.Id},
+		"-priority,dueAt":   {third.Id, first.Id, second.Id},
+		"-dueAt":            {second.Id, third.Id, first.Id},
+		"priority,-dueAt":   {second.Id, third.Id, first.Id},
+		"-priority,-id":     {third.Id, first.Id, second.Id},
+		"startAt,-position": {second.Id, first.Id, third.Id},
+	} {
+		if got := ids(sort); !slices.Equal(got, want) {
+			t.Errorf("Sorting by %q returned %v, want %v", sort, got, want)
+		}
+	}
+	for _, todo := range []models.Todo{first, second, third} {
+		store.DeleteTodo(todo.Id)
+	}
+}
+
+// testDependencies checks storing dependencies, filtering actionable todos and deleting dependencies with their todos.
+func testDependencies(t *testing.T, store Store, user models.User) {
+	first, _ := store.CreateTodo(models.Todo{Title: "first", UserId: user.Id})
+	second, _ := store.CreateTodo(models.Todo{Title: "second", UserId: user.Id})
+	third, _ := store.CreateTodo(models.Todo{Title: "third", UserId: user.Id})
+	dependency := models.Dependency{TodoId: second.Id, BlockerId: first.Id}
+	if err := store.CreateDependency(dependency); err != nil {
+		t.Fatalf("Creating a dependency failed: %v", err)
+	}
+	if err := store.CreateDependency(dependency); !errors.Is(err, ErrDependencyExists) {
+		t.Fatalf("Creating a dependency twice returned %v", err)
+	}
+	store.CreateDependency(models.Dependency{TodoId: third.Id, BlockerId: second.Id})
+	if err := store.CreateDependency(models.Dependency{TodoId: first.Id, BlockerId: third.Id}); !errors.Is(err, ErrDependencyCycle) {
+		t.Fatalf("Creating a cycle returned %v", err)
+	}
+	want := []models.Dependency{dependency, {TodoId: third.Id, BlockerId: second.Id}}
+	if dependencies, err := store.GetDependencies(second.Id); err != nil || !slices.Equal(dependencies, want) {
+		t.Fatalf("Unexpected dependencies %+v (%v)", dependencies, err)
+	}
+	actionable := func() []int {
+		todos, err := store.GetTodos(user.Id, models.TodoFilter{Actionable: true})
+		if err != nil {
+			t.Fatalf("Fetching the actionable todos failed: %v", err)
+		}
+		var ids []int
+		for _, todo := range todos {
+			ids = append(ids, todo.Id)
+		}
+		return ids
+	}
+	if ids := actionable(); !slices.Equal(ids, []int{first.Id}) {
+		t.Fatalf("Unexpected actionable todos %v", ids)
+	}
+	first.SetStatus(models.StatusDone, time.Now())
+	store.UpdateTodo(first)
+	if ids := actionable(); !slices.Equal(ids, []int{first.Id, second.Id}) {
+		t.Fatalf("Unexpected actionable todos after completing the blocker %v", ids)
+	}
+	if err := store.DeleteDependency(dependency); err != nil {
+		t.Fatalf("Deleting the dependency failed: %v", err)
+	}
+	if err := store.DeleteDependency(dependency); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Deleting a missing dependency returned %v", err)
+	}
+	taken := second
+	taken.Position = first.Position
+	if err := store.UpdateTodo(taken); !errors.Is(err, ErrPositionTaken) {
+		t.Fatalf("Moving a todo to the position of another returned %v", err)
+	}
+	if stored, _ := store.GetTodo(second.Id); stored.Position != second.Position {
+		t.Fatalf("The todo was moved to a taken position: %+v", stored)
+	}
+	store.DeleteTodo(second.Id)
+	if dependencies, err := store.GetDependencies(third.Id); err != nil || len(dependencies) != 0 {
+		t.Fatalf("The dependency on a deleted todo was kept: %+v (%v)", dependencies, err)
+	}
+	store.DeleteTodo(first.Id)
+	store.DeleteTodo(third.Id)
+
+	todos := make([]models.Todo, 10)
+	errs := make([]error, len(todos))
+	var wg sync.WaitGroup
+	for i := range todos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			todos[i], errs[i] = store.CreateTodo(models.Todo{Title: "parallel", UserId: user.Id})
+		}()
+	}
+	wg.Wait()
+	positions := map[string]bool{}
+	for i, todo := range todos {
+		if errs[i] != nil {
+			t.Fatalf("Creating todos in parallel failed: %v", errs[i])
+		}
+		positions[todo.Position] = true
+		store.DeleteTodo(todo.Id)
+	}
+	if len(positions) != len(todos) {
+		t.Fatalf("Todos created in parallel got the same positions: %+v", todos)
+	}
+}
+
+// testChecklists checks storing checklist items in order, counting the checked items of todos and deleting the items
+// with their todo.
+func testChecklists(t *testing.T, store Store, user models.User) {
+	todo, _ := store.CreateTodo(models.Todo{Title: "todo", UserId: user.Id, ChecklistAutoComplete: true})
+	first, err := store.CreateChecklistItem(models.ChecklistItem{TodoId: todo.Id, Text: "first"})
+	if err != nil || first.Id == 0 || first.Position == "" {
+		t.Fatalf("Creating a checklist item failed: %+v (%v)", first, err)
+	}
+	second, _ := store.CreateChecklistItem(models.ChecklistItem{TodoId: todo.Id, Text: "second", Checked: true})
+	if second.Position <= first.Position {
+		t.Fatalf("The second item %q was not placed after the first %q", second.Position, first.Position)
+	}
+	first.Text, first.Checked, first.Position = "changed", true, "0"
+	if err := store.UpdateChecklistItem(first); err != nil {
+		t.Fatalf("Updating the checklist item failed: %v", err)
+	}
+	if item, err := store.GetChecklistItem(first.Id); err != nil || item != first {
+		t.Fatalf("Unexpected checklist item %+v (%v)", item, err)
+	}
+	items, err := store.GetChecklistItems(todo.Id)
+	if err != nil || !slices.Equal(items, []models.ChecklistItem{first, second}) {
+		t.Fatalf("Unexpected checklist items %+v (%v)", items, err)
+	}
+	third, _ := store.CreateChecklistItem(models.ChecklistItem{TodoId: todo.Id, Text: "third"})
+	stored, err := store.GetTodo(todo.Id)
+	if err != nil || stored.Checklist != (models.ChecklistProgress{Checked: 2, Total: 3}) || !stored.ChecklistAutoComplete {
+		t.Fatalf("Unexpected checklist of the todo %+v (%v)", stored, err)
+	}
+	todos, err := store.GetTodos(user.Id, models.TodoFilter{})
+	if i := slices.IndexFunc(todos, func(other models.Todo) bool { return other.Id == todo.Id }); err != nil || i < 0 ||
+		todos[i].Checklist != stored.Checklist {
+		t.Fatalf("Unexpected checklists of the todos %+v (%v)", todos, err)
+	}
+	if err := store.DeleteChecklistItem(third.Id); err != nil {
+		t.Fatalf("Deleting the checklist item failed: %v", err)
+	}
+	if err := store.DeleteChecklistItem(third.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Deleting a missing checklist item returned %v", err)
+	}
+	taken := second
+	taken.Position = first.Position
+	if err := store.UpdateChecklistItem(taken); !errors.Is(err, ErrPositionTaken) {
+		t.Fatalf("Moving a checklist item to the position of another returned %v", err)
+	}
+	moved := first
+	moved.Position = "1"
+	if err := store.UpdateChecklistItems([]models.ChecklistItem{moved, third}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Updating a missing checklist item together with another returned %v", err)
+	}
+	if item, _ := store.GetChecklistItem(first.Id); item != first {
+		t.Fatalf("The checklist item was updated together with a missing one: %+v", item)
+	}
+	items = make([]models.ChecklistItem, 10)
+	errs := make([]error, len(items))
+	var wg sync.WaitGroup
+	for i := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			items[i], errs[i] = store.CreateChecklistItem(models.ChecklistItem{TodoId: todo.Id, Text: "parallel"})
+		}()
+	}
+	wg.Wait()
+	positions := map[string]bool{}
+	for i, item := range items {
+		if errs[i] != nil {
+			t.Fatalf("Creating checklist items in parallel failed: %v", errs[i])
+		}
+		positions[item.Position] = true
+	}
+	if len(positions) != len(items) {
+		t.Fatalf("Checklist items created in parallel got the same positions: %+v", items)
+	}
+	store.DeleteTodo(todo.Id)
+	if _, err := store.GetChecklistItem(first.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("The checklist item of a deleted todo was kept: %v", err)
+	}
+}
+
+// testReminders checks storing reminders, fetching the due ones and claiming each of them only once.
+func testReminders(t *testing.T, store Store, user models.User) {
+	todo, _ := store.CreateTodo(models.Todo{Title: "reminded", UserId: user.Id})
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := now.Add(-time.Hour)
+	minutes := 30
+	early, err := store.CreateReminder(models.Reminder{TodoId: todo.Id, At: &at, RemindAt: at})
+	if err != nil {
+		t.Fatalf("Creating a reminder failed: %v", err)
+	}
+	late, _ := store.CreateReminder(models.Reminder{TodoId: todo.Id, BeforeMinutes: &minutes, RemindAt: now.Add(time.Hour)})
+	if stored, err := store.GetReminder(early.Id); err != nil || !reflect.DeepEqual(stored, early) {
+		t.Fatalf("The stored reminder %+v does not match the created reminder %+v (%v)", stored, early, err)
+	}
+	if reminders, err := store.GetReminders(todo.Id); err != nil || len(reminders) != 2 || !reflect.DeepEqual(reminders[1], late) {
+		t.Fatalf("Unexpected reminders %+v (%v)", reminders, err)
+	}
+	due, err := store.GetDueReminders(now)
+	if err != nil || len(due) != 1 || due[0].Id != early.Id {
+		t.Fatalf("Unexpected due reminders %+v (%v)", due, err)
+	}
+	lease := now.Add(5 * time.Minute)
+	if claimed, err := store.ClaimReminder(due[0], now, lease); err != nil || !claimed {
+		t.Fatalf("Claiming the due reminder failed: %v", err)
+	}
+	if claimed, err := store.ClaimReminder(due[0], now, lease); err != nil || claimed {
+		t.Fatalf("A reminder was claimed twice (%v)", err)
+	}
+	if due, _ := store.GetDueReminders(now); len(due) != 0 {
+		t.Fatalf("A claimed reminder is still due: %+v", due)
+	}
+	// a claim that was neither completed nor released is taken over once it expired
+	if due, _ := store.GetDueReminders(lease); len(due) != 1 {
+		t.Fatalf("A reminder with an expired claim is not due: %+v", due)
+	}
+	if claimed, err := store.ClaimReminder(due[0], lease, lease.Add(5*time.Minute)); err != nil || !claimed {
+		t.Fatalf("Taking over the expired claim failed: %v", err)
+	}
+	if err := store.ReleaseReminder(due[0]); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := store.ClaimReminder(due[0], now, lease); err != nil || !claimed {
+		t.Fatalf("Claiming the released reminder failed: %v", err)
+	}
+	if err := store.CompleteReminder(due[0], now); err != nil {
+		t.Fatal(err)
+	}
+	if due, _ := store.GetDueReminders(lease); len(due) != 0 {
+		t.Fatalf("A sent reminder is still due: %+v", due)
+	}
+	if claimed, _ := store.ClaimReminder(due[0], lease, lease); claimed {
+		t.Fatalf("A sent reminder was claimed")
+	}
+	if stored, _ := store.GetReminder(early.Id); stored.SentAt == nil || !stored.SentAt.Equal(now) {
+		t.Fatalf("The time the reminder was sent was not stored: %+v", stored)
+	}
+	// a reminder snoozed after it was fetched can't be claimed for its old time
+	snoozed := late
+	snoozed.Snooze(now.Add(2 * time.Hour))
+	if err := store.UpdateReminder(snoozed); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, _ := store.ClaimReminder(late, now, lease); claimed {
+		t.Fatalf("A snoozed reminder was claimed for its old time")
+	}
+	if err := store.DeleteReminder(late.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetReminder(late.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("The deleted reminder can still be fetched: %v", err)
+	}
+	store.DeleteTodo(todo.Id)
+	if _, err := store.GetReminder(early.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("The reminder of the deleted todo was kept: %v", err)
+	}
+}
+
+// testRoles checks changing roles, disabling users and deleting a user with everything that belongs to it.
+func testRoles(t *testing.T, store Store, other models.User) {
+	alice := models.User{Name: "alice"}
+	alice.SetPassword("doe")
+	alice, err := store.CreateUser(alice)
+	if err != nil || alice.Role != models.RoleMember {
+		t.Fatalf("A new user is not a member: %+v (%v)", alice, err)
+	}
+	if err := store.SetUserRole(alice.Id, models.RoleAdmin); err != nil {
+		t.Fatalf("Setting the role failed: %v", err)
+	}
+	if user, err := store.GetUser(alice.Id); err != nil || user.Role != models.RoleAdmin || user.Name != "alice" {
+		t.Fatalf("Unexpected user %+v (%v)", user, err)
+	}
+	tokens, err := store.LoginUser(alice, models.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, _, err := store.AuthenticateUser(tokens.AccessToken); err != nil || user.Role != models.RoleAdmin {
+		t.Fatalf("The session does not carry the role: %+v (%v)", user, err)
+	}
+	_, accessToken, err := store.CreateAccessToken(alice.Id, "script", []models.Scope{models.ScopeTodosRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SetUserDisabled(alice.Id, true); err != nil {
+		t.Fatalf("Disabling the user failed: %v", err)
+	}
+	if _, err := store.LoginUser(alice, models.Client{}); !errors.Is(err, ErrUserDisabled) {
+		t.Fatalf("A disabled user could log in: %v", err)
+	}
+	if _, _, err := store.AuthenticateUser(tokens.AccessToken); err == nil {
+		t.Fatalf("The session of a disabled user is still valid")
+	}
+	if _, _, err := store.AuthenticateAccessToken(accessToken); err == nil {
+		t.Fatalf("The access token of a disabled user is still valid")
+	}
+	users, err := store.GetUsers()
+	if err != nil || !slices.ContainsFunc(users, func(u models.User) bool { return u.Id == alice.Id && u.Disabled }) {
+		t.Fatalf("The disabled user is missing in %+v (%v)", users, err)
+	}
+	if err := store.SetUserDisabled(alice.Id, false); err != nil {
+		t.Fatalf("Enabling the user failed: %v", err)
+	}
+	if _, err := store.LoginUser(alice, models.Client{}); err != nil {
+		t.Fatalf("The enabled user can't log in: %v", err)
+	}
+
+	todo, _ := store.CreateTodo(models.Todo{Title: "title", Text: "text", UserId: alice.Id})
+	store.CreateTodoShare(todo.Id, other.Id)
+	otherTodo, _ := store.CreateTodo(models.Todo{Title: "title", Text: "text", UserId: other.Id})
+	store.CreateTodoShare(otherTodo.Id, alice.Id)
+	tag, _ := store.CreateTag(models.Tag{UserId: alice.Id, Name: "work"})
+	if err := store.DeleteUser(alice.Id); err != nil {
+		t.Fatalf("Deleting the user failed: %v", err)
+	}
+	if _, err := store.GetUser(alice.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("The deleted user can still be fetched: %v", err)
+	}
+	if _, err := store.GetTodo(todo.Id); err == nil {
+		t.Fatalf("The todo of the deleted user still exists")
+	}
+	if shares, err := store.GetTodoShares(otherTodo.Id); err != nil || len(shares) != 0 {
+		t.Fatalf("The share with the deleted user still exists: %v (%v)", shares, err)
+	}
+	if _, err := store.GetTag(tag.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("The tag of the deleted user still exists: %v", err)
+	}
+	if sessions, err := store.GetSessions(alice.Id); err != nil || len(sessions) != 0 {
+		t.Fatalf("The sessions of the deleted user still exist: %v (%v)", sessions, err)
+	}
+	if err := store.DeleteUser(alice.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Deleting an unknown user did not fail: %v", err)
+	}
+	for _, err := range []error{store.SetUserRole(alice.Id, models.RoleMember), store.SetUserDisabled(alice.Id, true)} {
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("Changing an unknown user did not fail: %v", err)
+		}
+	}
+	store.DeleteTodo(otherTodo.Id)
+}
+
+// testSessions checks that several sessions of a user are valid at the same time and can be revoked.
+func testSessions(t *testing.T, store Store, user models.User, token string) {
+	secondTokens, err := store.LoginUser(user, models.Client{})
+	if err != nil {
+		t.Fatalf("The second login failed: %v", err)
+	}
+	secondToken := secondTokens.AccessToken
+	_, session, err := store.AuthenticateUser(token)
+	if err != nil {
+		t.Fatalf("The first session was invalidated by the second login: %v", err)
+	}
+	_, secondSession, err := store.AuthenticateUser(secondToken)
+	if err != nil {
+		t.Fatalf("The second session is not valid: %v", err)
+	}
+	if sessions, err := store.GetSessions(user.Id); err != nil || len(sessions) != 2 {
+		t.Fatalf("Expected two sessions but got %v (%v)", sessions, err)
+	}
+	if count, err := store.DeleteOtherSessions(user.Id, session.Id); err != nil || count != 1 {
+		t.Fatalf("Expected one revoked session but got %d (%v)", count, err)
+	}
+	if _, _, err := store.AuthenticateUser(secondToken); err == nil {
+		t.Fatalf("A revoked session is still valid")
+	}
+	if err := store.DeleteSession(user.Id, secondSession.Id); err == nil {
+		t.Fatalf("Deleting a session twice succeeded")
+	}
+	if err := store.DeleteSession(user.Id+1, session.Id); err == nil {
+		t.Fatalf("A session of another user was deleted")
+	}
+	if _, _, err := store.AuthenticateUser(token); err != nil {
+		t.Fatalf("The kept session is not valid anymore: %v", err)
+	}
+	if _, err := store.LoginUser(user, models.Client{}); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := store.DeleteAllSessions(user.Id); err != nil || count != 2 {
+		t.Fatalf("Expected two revoked sessions but got %d (%v)", count, err)
+	}
+	if _, _, err := store.AuthenticateUser(token); err == nil {
+		t.Fatalf("A session is still valid after revoking all sessions")
+	}
+}
+
+// testRefreshSession checks the rotation of session tokens and that reusing a refresh token revokes the session.
+func testRefreshSession(t *testing.T, store Store, user models.User) {
+	tokens, err := store.LoginUser(user, models.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := store.RefreshSession(tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refreshing the session failed: %v", err)
+	}
+	if refreshed.AccessToken == tokens.AccessToken || refreshed.RefreshToken == tokens.RefreshToken {
+		t.Fatalf("The tokens were not rotated")
+	}
+	if _, _, err := store.AuthenticateUser(tokens.AccessToken); err == nil {
+		t.Fatalf("The previous access token is still valid after a refresh")
+	}
+	if _, _, err := store.AuthenticateUser(refreshed.AccessToken); err != nil {
+		t.Fatalf("The refreshed access token is not valid: %v", err)
+	}
+	if _, err := store.RefreshSession("unknown"); err == nil || errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Expected an error for an unknown refresh token but got %v", err)
+	}
+	if _, err := store.RefreshSession(tokens.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Reusing a refresh token was not detected: %v", err)
+	}
+	if _, _, err := store.AuthenticateUser(refreshed.AccessToken); err == nil {
+		t.Fatalf("The session is still valid after its refresh token was reused")
+	}
+	if _, err := store.RefreshSession(refreshed.RefreshToken); err == nil {
+		t.Fatalf("The session could be refreshed after its refresh token was reused")
+	}
+}
+
+// testAccessTokens checks creating, authenticating with and revoking personal access tokens.
+func testAccessTokens(t *testing.T, store Store, user models.User) {
+	scopes := []models.Scope{models.ScopeTodosRead, models.ScopeTodosWrite}
+	created, token, err := store.CreateAccessToken(user.Id, "ci", scopes, nil)
+	if err != nil {
+		t.Fatalf("Creating an access token failed: %v", err)
+	}
+	if !strings.HasPrefix(token, AccessTokenPrefix) {
+		t.Fatalf("The access token %s does not start with %s", token, AccessTokenPrefix)
+	}
+	authenticated, accessToken, err := store.AuthenticateAccessToken(token)
+	if err != nil || authenticated.Id != user.Id {
+		t.Fatalf("The access token does not authenticate the user: %+v (%v)", authenticated, err)
+	}
+	if accessToken.Id != created.Id || !slices.Equal(accessToken.Scopes, scopes) || accessToken.LastUsedAt == nil {
+		t.Fatalf("The authenticated access token %+v does not match the created one %+v", accessToken, created)
+	}
+	expired := time.Now().Add(-time.Minute)
+	_, expiredToken, err := store.CreateAccessToken(user.Id, "expired", scopes, &expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.AuthenticateAccessToken(expiredToken); err == nil {
+		t.Fatalf("An expired access token was accepted")
+	}
+	if tokens, err := store.GetAccessTokens(user.Id); err != nil || len(tokens) != 2 {
+		t.Fatalf("Expected two access tokens but got %v (%v)", tokens, err)
+	}
+	if err := store.DeleteAccessToken(user.Id+1, created.Id); err == nil {
+		t.Fatalf("An access token of another user was deleted")
+	}
+	if err := store.DeleteAccessToken(user.Id, created.Id); err != nil {
+		t.Fatalf("Deleting the access token failed: %v", err)
+	}
+	if _, _, err := store.AuthenticateAccessToken(token); err == nil {
+		t.Fatalf("A deleted access token was accepted")
+	}
+}
+
+// testRevocations checks that revoked sessions are listed until their revocation expires.
+func testRevocations(t *testing.T, store Store) {
+	if err := store.RevokeSessions([]int{1, 2}, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Revoking sessions failed: %v", err)
+	}
+	if err := store.RevokeSessions([]int{2}, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Revoking a session again failed: %v", err)
+	}
+	if revoked, err := store.GetRevokedSessions(); err != nil || !slices.Equal(revoked, []int{1}) {
+		t.Fatalf("Expected only session 1 to be revoked but got %v (%v)", revoked, err)
+	}
+}
+
+// testExternalUsers checks that an external identity gets its own user on the first login and the same user later on.
+func testExternalUsers(t *testing.T, store Store, existing models.User) {
+	identity := models.ExternalIdentity{Issuer: "https://idp.example.com", Subject: "1234", Name: existing.Name}
+	tokens, err := store.LoginExternalUser(identity, models.Client{})
+	if err != nil {
+		t.Fatalf("The first external login failed: %v", err)
+	}
+	user, _, err := store.AuthenticateUser(tokens.AccessToken)
+	if err != nil || user.Id == existing.Id || user.Name != existing.Name+"-2" {
+		t.Fatalf("Expected a new user named %s-2 but got %+v (%v)", existing.Name, user, err)
+	}
+	tokens, err = store.LoginExternalUser(identity, models.Client{})
+	if err != nil || tokens.User.Id != user.Id {
+		t.Fatalf("The second external login did not return the same user: %+v (%v)", tokens.User, err)
+	}
+	identity.Issuer = "https://other.example.com"
+	if tokens, err := store.LoginExternalUser(identity, models.Client{}); err != nil || tokens.User.Name != existing.Name+"-3" {
+		t.Fatalf("Expected a new user named %s-3 for another issuer but got %+v (%v)", existing.Name, tokens.User, err)
+	}
+	noPassword := models.User{Name: user.Name}
+	noPassword.SetPassword("")
+	if _, err := store.LoginUser(noPassword, models.Client{}); err == nil {
+		t.Fatalf("An external user could log in with an empty password")
+	}
+}
+
+// testTwoFactor checks enrolling a TOTP secret, the two-step login with one-time passwords and recovery codes and
+// disabling the second factor again.
+func testTwoFactor(t *testing.T, store Store, user models.User) {
+	enrollment, err := store.EnrollTOTP(user.Id)
+	if err != nil || len(enrollment.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("Enrolling failed: %+v (%v)", enrollment, err)
+	}
+	if _, err := store.LoginUser(user, models.Client{}); err != nil {
+		t.Fatalf("An unconfirmed enrollment is already required on login: %v", err)
+	}
+	if err := store.ConfirmTOTP(user.Id, "abcdef"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("A wrong code confirmed the enrollment: %v", err)
+	}
+	code, _ := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	if err := store.ConfirmTOTP(user.Id, code); err != nil {
+		t.Fatalf("Confirming the enrollment failed: %v", err)
+	}
+	if _, err := store.EnrollTOTP(user.Id); !errors.Is(err, ErrTOTPEnabled) {
+		t.Fatalf("Enrolling twice succeeded: %v", err)
+	}
+
+	tokens, err := store.LoginUser(user, models.Client{})
+	if !errors.Is(err, ErrSecondFactorRequired) || tokens.Challenge == "" || tokens.AccessToken != "" {
+		t.Fatalf("Expected a challenge but got %+v (%v)", tokens, err)
+	}
+	if _, err := store.CompleteLogin(tokens.Challenge, code, models.Client{}); err == nil {
+		t.Fatalf("A one-time password was accepted twice")
+	}
+	next, _ := totp.Code(enrollment.Secret, totp.Step(time.Now())+1)
+	completed, err := store.CompleteLogin(tokens.Challenge, next, models.Client{})
+	if err != nil {
+		t.Fatalf("Completing the login failed: %v", err)
+	}
+	if authenticated, _, err := store.AuthenticateUser(completed.AccessToken); err != nil || authenticated.Id != user.Id {
+		t.Fatalf("The completed login does not authenticate the user: %+v (%v)", authenticated, err)
+	}
+	if _, err := store.CompleteLogin(tokens.Challenge, next, models.Client{}); err == nil {
+		t.Fatalf("A challenge was completed twice")
+	}
+
+	recoveryCode := strings.ToUpper(enrollment.RecoveryCodes[0])
+	tokens, _ = store.LoginUser(user, models.Client{})
+	if _, err := store.CompleteLogin(tokens.Challenge, recoveryCode, models.Client{}); err != nil {
+		t.Fatalf("Completing the login with a recovery code failed: %v", err)
+	}
+	tokens, _ = store.LoginUser(user, models.Client{})
+	if _, err := store.CompleteLogin(tokens.Challenge, recoveryCode, models.Client{}); err == nil {
+		t.Fatalf("A recovery code was accepted twice")
+	}
+	for i := 1; i < maxChallengeAttempts; i++ {
+		store.CompleteLogin(tokens.Challenge, "abcdef", models.Client{})
+	}
+	if _, err := store.CompleteLogin(tokens.Challenge, enrollment.RecoveryCodes[1], models.Client{}); err == nil {
+		t.Fatalf("A challenge was completed after too many wrong codes")
+	}
+	if account, _, err := store.GetLoginFailures(user.Name, ""); err != nil || account.Count < maxChallengeAttempts {
+		t.Fatalf("The wrong codes were not counted as failed logins: %+v (%v)", account, err)
+	}
+	store.ResetLoginFailures(user.Name)
+	earlier, _ := store.LoginUser(user, models.Client{})
+	tokens, _ = store.LoginUser(user, models.Client{})
+	if _, err := store.CompleteLogin(earlier.Challenge, enrollment.RecoveryCodes[1], models.Client{}); err == nil {
+		t.Fatalf("A challenge was completed after logging in again")
+	}
+
+	if err := store.DisableTOTP(user.Id, "abcdef"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("A wrong code disabled the second factor: %v", err)
+	}
+	if err := store.DisableTOTP(user.Id, enrollment.RecoveryCodes[2]); err != nil {
+		t.Fatalf("Disabling the second factor failed: %v", err)
+	}
+	if _, err := store.LoginUser(user, models.Client{}); err != nil {
+		t.Fatalf("The login still requires a second factor after disabling it: %v", err)
+	}
+}
+
+// testPasswords checks changing a password and resetting it with a single-use token.
+func testPasswords(t *testing.T, store Store) {
+	user := models.User{Name: "max", Email: "max@example.com"}
+	user.SetPassword("old")
+	user, err := store.CreateUser(user)
+	if err != nil {
+		t.Fatalf("Creating a user with an email address failed: %v", err)
+	}
+	if err := store.ChangePassword(user.Id, "wrong", "new"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("Changing the password with a wrong current password did not fail: %v", err)
+	}
+	if err := store.ChangePassword(user.Id, "old", "new"); err != nil {
+		t.Fatalf("Changing the password failed: %v", err)
+	}
+	login := models.User{Name: "max"}
+	login.SetPassword("new")
+	if _, err := store.LoginUser(login, models.Client{}); err != nil {
+		t.Fatalf("Login with the changed password failed: %v", err)
+	}
+
+	if _, _, err := store.CreatePasswordReset("unknown"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("A reset was created for an unknown user: %v", err)
+	}
+	stale, _, err := store.CreatePasswordReset("max")
+	if err != nil || stale.Id != user.Id || stale.Email != "max@example.com" {
+		t.Fatalf("Creating a reset failed: %+v (%v)", stale, err)
+	}
+	_, staleToken, _ := store.CreatePasswordReset("max")
+	_, token, err := store.CreatePasswordReset("max")
+	if err != nil {
+		t.Fatalf("Creating a second reset failed: %v", err)
+	}
+	if _, err := store.ResetPassword(staleToken, "stale"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("A replaced reset token was accepted: %v", err)
+	}
+	if resetUser, err := store.GetPasswordReset(token); err != nil || resetUser.Id != user.Id || resetUser.Name != "max" {
+		t.Fatalf("Unexpected user of the reset token %+v (%v)", resetUser, err)
+	}
+	if _, err := store.GetPasswordReset(staleToken); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("A replaced reset token was found: %v", err)
+	}
+	if userId, err := store.ResetPassword(token, "reset"); err != nil || userId != user.Id {
+		t.Fatalf("Resetting the password failed: %d (%v)", userId, err)
+	}
+	if _, err := store.ResetPassword(token, "again"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("A reset token was accepted twice: %v", err)
+	}
+	login.SetPassword("reset")
+	if _, err := store.LoginUser(login, models.Client{}); err != nil {
+		t.Fatalf("Login with the reset password failed: %v", err)
+	}
+}
+
+// testLoginAttempts checks counting failed logins per name and address, forgetting old failures and unlocking users.
+func testLoginAttempts(t *testing.T, store Store, user models.User) {
+	account, client, err := store.GetLoginFailures(user.Name, "10.0.0.1")
+	if err != nil || account.Count != 0 || client.Count != 0 {
+		t.Fatalf("Expected no failures but got %+v and %+v (%v)", account, client, err)
+	}
+	store.ReserveLoginAttempt(user.Name, "10.0.0.1", time.Hour)
+	store.ReserveLoginAttempt("unknown", "10.0.0.1", time.Hour)
+	account, client, err = store.ReserveLoginAttempt(user.Name, "10.0.0.2", time.Hour)
+	if err != nil || account.Count != 1 || client.Count != 0 || time.Since(account.LastFailureAt) > time.Minute {
+		t.Fatalf("Unexpected failures before the attempt %+v and %+v (%v)", account, client, err)
+	}
+	first := account.LastFailureAt
+	time.Sleep(10 * time.Millisecond)
+	store.ReserveLoginAttempt(user.Name, "10.0.0.2", time.Hour)
+	if err := store.ReleaseLoginAttempt(user.Name, "10.0.0.2"); err != nil {
+		t.Fatalf("Releasing an attempt failed: %v", err)
+	}
+	account, client, _ = store.GetLoginFailures(user.Name, "10.0.0.2")
+	if account.Count != 2 || client.Count != 1 || !account.LastFailureAt.After(first) {
+		t.Fatalf("Unexpected failures after releasing an attempt %+v and %+v", account, client)
+	}
+	if account, client, err := store.GetLoginFailures(user.Name, "10.0.0.1"); err != nil || account.Count != 2 || client.Count != 2 {
+		t.Fatalf("Unexpected stored failures %+v and %+v (%v)", account, client, err)
+	}
+	if err := store.UnlockUser(user.Id); err != nil {
+		t.Fatalf("Unlocking the user failed: %v", err)
+	}
+	if account, client, _ := store.GetLoginFailures(user.Name, "10.0.0.1"); account.Count != 0 || client.Count != 2 {
+		t.Fatalf("Unlocking did not only reset the failures of the user: %+v and %+v", account, client)
+	}
+	if err := store.UnlockUser(-1); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Unlocking an unknown user did not fail: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if account, _, _ := store.ReserveLoginAttempt("unknown", "10.0.0.3", time.Millisecond); account.Count != 0 {
+		t.Fatalf("An old failure was not forgotten: %+v", account)
+	}
+	if _, client, _ := store.GetLoginFailures("unknown", "10.0.0.1"); client.Count != 0 {
+		t.Fatalf("An old failure of an address was not deleted: %+v", client)
+	}
+	if err := store.ResetLoginFailures("unknown"); err != nil {
+		t.Fatalf("Resetting the failures failed: %v", err)
+	}
+}
+
+// testHasher hashes passwords with the lowest bcrypt cost to keep the tests fast.
+var testHasher = password.Hasher{Algorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost}
+
+// setHasher sets the [password.Hasher] of store, which is not part of the [Store] interface.
+func setHasher(store Store, hasher password.Hasher) {
+	switch s := store.(type) {
+	case *MemoryStore:
+		s.mu.Lock()
+		s.Hasher = hasher
+		s.mu.Unlock()
+	case *SQLStore:
+		s.Hasher = hasher
+	}
+}
+
+// storedPasswordHash returns the password hash of the user with id userId as it is stored.
+func storedPasswordHash(t *testing.T, store Store, userId int) string {
+	switch s := store.(type) {
+	case *MemoryStore:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return string(s.users[s.userIndexById(userId)].password)
+	case *SQLStore:
+		var hash string
+		if err := s.queryRow(`SELECT password FROM users WHERE id = ?`, userId).Scan(&hash); err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	t.Fatalf("Unknown store %T", store)
+	return ""
+}
+
+// testRehash checks that logins replace password hashes created with another algorithm or other parameters.
+func testRehash(t *testing.T, store Store) {
+	user := models.User{Name: "rehash"}
+	user.SetPassword("old secret")
+	user, err := store.CreateUser(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer setHasher(store, testHasher)
+	setHasher(store, password.Hasher{Algorithm: password.Argon2id, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1})
+	wrong := models.User{Name: "rehash"}
+	wrong.SetPassword("wrong")
+	store.LoginUser(wrong, models.Client{})
+	if hash := storedPasswordHash(t, store, user.Id); !strings.HasPrefix(hash, "$2a$") {
+		t.Fatalf("A failed login replaced the hash: %s", hash)
+	}
+	if _, err := store.LoginUser(user, models.Client{}); err != nil {
+		t.Fatalf("Login with the outdated hash failed: %v", err)
+	}
+	if hash := storedPasswordHash(t, store, user.Id); !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("The outdated bcrypt hash was not replaced: %s", hash)
+	}
+	if _, err := store.LoginUser(user, models.Client{}); err != nil {
+		t.Fatalf("Login with the new hash failed: %v", err)
+	}
+	setHasher(store, testHasher)
+	if _, err := store.LoginUser(user, models.Client{}); err != nil {
+		t.Fatalf("Login with the argon2id hash failed: %v", err)
+	}
+	if hash := storedPasswordHash(t, store, user.Id); !strings.HasPrefix(hash, "$2a$04$") {
+		t.Fatalf("The outdated argon2id hash was not replaced: %s", hash)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	store.Hasher = testHasher
+	testStore(t, store)
+}
+
+func TestSQLiteStore(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Hasher = testHasher
+	migrate(t, store)
+	testStore(t, store)
+}
+
+func TestPostgresStore(t *testing.T) {
+	store, err := NewPostgresStore(startTestPostgres(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Hasher = testHasher
+	migrate(t, store)
+	testStore(t, store)
+}
+
+func TestPostgresDialect_rebind(t *testing.T) {
+	got := postgresDialect{}.rebind(`SELECT id FROM todos WHERE user_id = ? AND id = ?`)
+	want := `SELECT id FROM todos WHERE user_id = $1 AND id = $2`
+	if got != want {
+		t.Fatalf("Expected %q but got %q", want, got)
+	}
+}
+
+func migrate(t *testing.T, store *SQLStore) {
+	if _, err := store.MigrateUp(); err != nil {
+		t.Fatalf("Applying the migrations failed: %v", err)
+	}
+}
+
+// startTestPostgres returns the connection string of a PostgreSQL database for tests. If TODO_TEST_POSTGRES_URL is set
+// it is used, otherwise a temporary server is started with the initdb and pg_ctl binaries found in PATH. The test is
+// skipped if neither is available.
+func startTestPostgres(t *testing.T) string {
+	if url := os.Getenv("TODO_TEST_POSTGRES_URL"); url != "" {
+		return url
+	}
+	initdb, err := exec.LookPath("initdb")
+	if err != nil {
+		t.Skip("TODO_TEST_POSTGRES_URL is not set and initdb was not found in PATH")
+	}
+	pgCtl := filepath.Join(filepath.Dir(initdb), "pg_ctl")
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	if out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "--no-sync").CombinedOutput(); err != nil {
+		t.Fatalf("initdb failed: %v\n%s", err, out)
+	}
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=localhost", port, dir)
+	if out, err := exec.Command(pgCtl, "-D", data, "-o", options, "-w", "start").CombinedOutput(); err != nil {
+		t.Fatalf("Starting postgres failed: %v\n%s", err, out)
+	}
+	t.Cleanup(func() {
+		exec.Command(pgCtl, "-D", data, "-m", "immediate", "stop").Run()
+	})
+	return fmt.Sprintf("postgres://postgres@localhost:%d/postgres?sslmode=disable", port)
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"todo/models"
+)
+
+const tagColumns = `g.id, g.user_id, g.name, g.color`
+
+// scanTag scans a row with the columns of tagColumns preceded by dest into tag.
+func scanTag(row interface{ Scan(...any) error }, tag *models.Tag, dest ...any) error {
+	return row.Scan(append(dest, &tag.Id, &tag.UserId, &tag.Name, &tag.Color)...)
+}
+
+// CreateTag inserts tag into 'tags' and returns it with its id set. If the user already has a tag with the same name,
+// [ErrTagExists] is returned.
+func (s *SQLStore) CreateTag(tag models.Tag) (models.Tag, error) {
+	stmt := `INSERT INTO tags (user_id, name, color) VALUES (?, ?, ?) RETURNING id`
+	err := s.queryRow(stmt, tag.UserId, tag.Name, tag.Color).Scan(&tag.Id)
+	if s.dialect.isUniqueViolation(err) {
+		return tag, ErrTagExists
+	}
+	return tag, err
+}
+
+// GetTags selects the tags of the user with id userId from 'tags' ordered by name.
+func (s *SQLStore) GetTags(userId int) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	rows, err := s.query(`SELECT `+tagColumns+` FROM tags AS g WHERE g.user_id = ? ORDER BY g.name`, userId)
+	if err != nil {
+		return tags, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag models.Tag
+		if err := scanTag(rows, &tag); err != nil {
+			return tags, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// GetTag selects the tag with id tagId from 'tags'.
+func (s *SQLStore) GetTag(tagId int) (models.Tag, error) {
+	var tag models.Tag
+	err := scanTag(s.queryRow(`SELECT `+tagColumns+` FROM tags AS g WHERE g.id = ?`, tagId), &tag)
+	return tag, err
+}
+
+// UpdateTag sets the name and color of the row in 'tags' with the id of tag.
+func (s *SQLStore) UpdateTag(tag models.Tag) error {
+	err := s.updateOne(`UPDATE tags SET name = ?, color = ? WHERE id = ?`, tag.Name, tag.Color, tag.Id)
+	if s.dialect.isUniqueViolation(err) {
+		return ErrTagExists
+	}
+	return err
+}
+
+// MergeTags attaches the tag targetId to all todos of the tag sourceId in 'todos_tags' and deletes the source tag,
+// which detaches it from the todos.
+func (s *SQLStore) MergeTags(sourceId int, targetId int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt := `INSERT INTO todos_tags (todo_id, tag_id) SELECT todo_id, ? FROM todos_tags WHERE tag_id = ?
+		AND todo_id NOT IN (SELECT todo_id FROM todos_tags WHERE tag_id = ?)`
+	if _, err := tx.Exec(s.dialect.rebind(stmt), targetId, sourceId, targetId); err != nil {
+		return err
+	}
+	result, err := tx.Exec(s.dialect.rebind(`DELETE FROM tags WHERE id = ?`), sourceId)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return err
+	} else if count == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// DeleteTag deletes the tag with id tagId from 'tags'. The foreign keys detach it from all todos.
+func (s *SQLStore) DeleteTag(tagId int) error {
+	return s.updateOne(`DELETE FROM tags WHERE id = ?`, tagId)
+}
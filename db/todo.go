@@ -3,86 +3,365 @@ package db
 import (
 	"database/sql"
 	"errors"
+	"strings"
+	"time"
 	"todo/models"
 )
 
-// CreateTodo creates a [models.Todo] from the parameters and inserts it into the database. If the insert was successful
-// the object is returned, otherwise an error is returned
-func CreateTodo(title string, text string, userId int, isDone bool) (models.Todo, error) {
-	stmt := `INSERT INTO todos(title, text, user_id, is_done) VALUES (?, ?, ?, ?) RETURNING id`
-	id := 0
-	err := getDb().QueryRow(stmt, title, text, userId, false).Scan(&id)
-	var todo models.Todo
+const todoColumns = `id, title, text, is_done, user_id, start_at, due_at, all_day, list_id, parent_id, recurrence, priority, position, status, completed_at, checklist_auto_complete`
+
+// scanTodo scans a row with the columns of todoColumns into todo.
+func scanTodo(row interface{ Scan(...any) error }, todo *models.Todo) error {
+	var startAt, dueAt, completedAt sql.NullTime
+	var listId, parentId sql.NullInt64
+	if err := row.Scan(&todo.Id, &todo.Title, &todo.Text, &todo.IsDone, &todo.UserId, &startAt, &dueAt, &todo.AllDay,
+		&listId, &parentId, &todo.Recurrence, &todo.Priority, &todo.Position, &todo.Status, &completedAt,
+		&todo.ChecklistAutoComplete); err != nil {
+		return err
+	}
+	todo.ListId, todo.ParentId = intPtr(listId), intPtr(parentId)
+	todo.CompletedAt = timePtr(completedAt)
+	todo.StartAt, todo.DueAt = nil, nil
+	if startAt.Valid {
+		startAt.Time = startAt.Time.UTC()
+		todo.StartAt = &startAt.Time
+	}
+	if dueAt.Valid {
+		dueAt.Time = dueAt.Time.UTC()
+		todo.DueAt = &dueAt.Time
+	}
+	return nil
+}
+
+// nullTime converts an optional time to a nullable column value in UTC.
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// nullInt converts an optional id to a nullable column value.
+func nullInt(i *int) sql.NullInt64 {
+	if i == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*i), Valid: true}
+}
+
+// intPtr converts a nullable column value to an optional id.
+func intPtr(i sql.NullInt64) *int {
+	if !i.Valid {
+		return nil
+	}
+	id := int(i.Int64)
+	return &id
+}
+
+// CreateTodo inserts todo into the database and its tags into 'todos_tags'. A todo without a position is placed after
+// all other todos of its user, a todo without a status gets it from [models.Todo.IsDone]. If the insert was successful
+// the todo is returned with its id and position set, otherwise an error is returned
+func (s *SQLStore) CreateTodo(todo models.Todo) (models.Todo, error) {
+	for {
+		created, err := s.createTodo(todo)
+		// another todo created at the same time took the last position, which the unique index rejects
+		if todo.Position != "" || !errors.Is(err, ErrPositionTaken) {
+			return created, err
+		}
+	}
+}
+
+// createTodo inserts todo like [SQLStore.CreateTodo] in one attempt. It returns [ErrPositionTaken] if the position
+// violates the unique index on the positions of the todos of a user.
+func (s *SQLStore) createTodo(todo models.Todo) (models.Todo, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return todo, err
 	}
-	todo = models.Todo{
-		Id:     id,
-		Title:  title,
-		Text:   text,
-		UserId: userId,
-		IsDone: isDone,
+	defer tx.Rollback()
+	todo.NormalizeStatus()
+	if todo.Position == "" {
+		var last string
+		stmt := `SELECT COALESCE(MAX(position), '') FROM todos WHERE user_id = ?`
+		if err := tx.QueryRow(s.dialect.rebind(stmt), todo.UserId).Scan(&last); err != nil {
+			return todo, err
+		}
+		if todo.Position, err = models.PositionBetween(last, ""); err != nil {
+			return todo, err
+		}
+	}
+	stmt := `INSERT INTO todos(title, text, user_id, is_done, start_at, due_at, all_day, list_id, parent_id, recurrence, priority, position,
+		status, completed_at, checklist_auto_complete) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	err = tx.QueryRow(s.dialect.rebind(stmt), todo.Title, todo.Text, todo.UserId, todo.IsDone, nullTime(todo.StartAt),
+		nullTime(todo.DueAt), todo.AllDay, nullInt(todo.ListId), nullInt(todo.ParentId), todo.Recurrence, todo.Priority,
+		todo.Position, todo.Status, nullTime(todo.CompletedAt), todo.ChecklistAutoComplete).Scan(&todo.Id)
+	if s.dialect.isUniqueViolation(err) {
+		return todo, ErrPositionTaken
 	}
-	return todo, nil
+	if err != nil {
+		return todo, err
+	}
+	if err := s.setTodoTags(tx, todo.Id, todo.Tags); err != nil {
+		return todo, err
+	}
+	if todo.Tags == nil {
+		todo.Tags = []models.Tag{}
+	}
+	return todo, tx.Commit()
+}
+
+// setTodoTags replaces the rows of the todo todoId in 'todos_tags' with the ids of tags.
+func (s *SQLStore) setTodoTags(tx *sql.Tx, todoId int, tags []models.Tag) error {
+	if _, err := tx.Exec(s.dialect.rebind(`DELETE FROM todos_tags WHERE todo_id = ?`), todoId); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(s.dialect.rebind(`INSERT INTO todos_tags (todo_id, tag_id) VALUES (?, ?)`), todoId, tag.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTags sets the tags of todos, which are fetched with a single query.
+func (s *SQLStore) loadTags(todos []models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	indexes := make(map[int]int, len(todos))
+	placeholders := make([]string, len(todos))
+	args := make([]any, len(todos))
+	for i := range todos {
+		indexes[todos[i].Id] = i
+		placeholders[i] = "?"
+		args[i] = todos[i].Id
+		todos[i].Tags = []models.Tag{}
+	}
+	stmt := `SELECT tt.todo_id, ` + tagColumns + ` FROM todos_tags AS tt JOIN tags AS g ON (g.id = tt.tag_id)
+		WHERE tt.todo_id IN (` + strings.Join(placeholders, ", ") + `) ORDER BY g.name`
+	rows, err := s.query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var todoId int
+		var tag models.Tag
+		if err := scanTag(rows, &tag, &todoId); err != nil {
+			return err
+		}
+		i := indexes[todoId]
+		todos[i].Tags = append(todos[i].Tags, tag)
+	}
+	return rows.Err()
 }
 
-// GetTodos fetches the [models.Todo]s created by a [models.User] with id userId. If includeShared is set to true, all
-// the todos shared with that user are also included
-func GetTodos(userId int, includeShared bool) ([]models.Todo, error) {
+// GetTodos fetches the [models.Todo]s created by a [models.User] with id userId that match filter in the order of
+// [models.TodoFilter.Sort]. If [models.TodoFilter.IncludeShared] is set, all the todos shared with that user in
+// 'users_todos' and the todos in the lists shared with that user in 'users_lists' are also included
+func (s *SQLStore) GetTodos(userId int, filter models.TodoFilter) ([]models.Todo, error) {
 	var todos []models.Todo
-	var stmt string
-	if includeShared {
-		stmt = `SELECT DISTINCT(t.id), t.title, t.text, t.is_done, t.user_id FROM todos AS t LEFT JOIN users_todos AS u ON (t.id = u.todo_id) WHERE t.user_id = $1 OR u.user_id = $1`
-	} else {
-		stmt = `SELECT id, title, text, is_done, user_id FROM todos WHERE user_id = ?`
+	stmt := `SELECT ` + todoColumns + ` FROM todos WHERE user_id = ?`
+	args := []any{userId}
+	if filter.IncludeShared {
+		stmt = `SELECT ` + todoColumns + ` FROM todos WHERE (user_id = ? OR id IN (SELECT todo_id FROM users_todos WHERE user_id = ?)
+			OR list_id IN (SELECT list_id FROM users_lists WHERE user_id = ?))`
+		args = append(args, userId, userId)
 	}
-	rows, err := getDb().Query(stmt, userId)
+	conditions, conditionArgs := todoFilterConditions(userId, filter)
+	rows, err := s.query(stmt+conditions+todoOrder(filter.Sort), append(args, conditionArgs...)...)
 	if err != nil {
 		return todos, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var todo models.Todo
-		err := rows.Scan(&todo.Id, &todo.Title, &todo.Text, &todo.IsDone, &todo.UserId)
-		if err != nil {
+		if err := scanTodo(rows, &todo); err != nil {
 			return todos, err
 		}
 		todos = append(todos, todo)
 	}
-	return todos, nil
+	if err := rows.Err(); err != nil {
+		return todos, err
+	}
+	if err := s.loadTags(todos); err != nil {
+		return todos, err
+	}
+	return todos, s.loadChecklists(todos)
+}
+
+// todoFilterConditions returns the conditions of filter for the user with id userId except
+// [models.TodoFilter.IncludeShared] as SQL to append to a WHERE clause together with their arguments. The dates of
+// all-day todos are stored as midnight UTC and are due a day later in the location of the filter, so they are compared
+// to the wall clock time of the bounds in that location shifted back by a day.
+func todoFilterConditions(userId int, filter models.TodoFilter) (string, []any) {
+	var stmt string
+	var args []any
+	if filter.IsDone != nil {
+		stmt += ` AND is_done = ?`
+		args = append(args, *filter.IsDone)
+	}
+	if filter.ListId != nil {
+		stmt += ` AND list_id = ?`
+		args = append(args, *filter.ListId)
+	}
+	if filter.ParentId != nil {
+		stmt += ` AND parent_id = ?`
+		args = append(args, *filter.ParentId)
+	}
+	loc := filter.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	allDayBound := func(t time.Time) time.Time {
+		wall := t.In(loc)
+		return time.Date(wall.Year(), wall.Month(), wall.Day()-1, wall.Hour(), wall.Minute(), wall.Second(), 0, time.UTC)
+	}
+	if filter.DueAfter != nil {
+		stmt += ` AND ((NOT all_day AND due_at > ?) OR (all_day AND due_at > ?))`
+		args = append(args, filter.DueAfter.UTC().Truncate(time.Second), allDayBound(*filter.DueAfter))
+	}
+	if filter.DueBefore != nil {
+		stmt += ` AND ((NOT all_day AND due_at <= ?) OR (all_day AND due_at <= ?))`
+		args = append(args, filter.DueBefore.UTC().Truncate(time.Second), allDayBound(*filter.DueBefore))
+	}
+	if filter.Actionable {
+		stmt += ` AND NOT EXISTS (SELECT 1 FROM todo_dependencies AS d JOIN todos AS b ON (b.id = d.blocker_id)
+			WHERE d.todo_id = todos.id AND NOT COALESCE(b.is_done, FALSE))`
+	}
+	if len(filter.Tags) > 0 {
+		args = append(args, userId)
+		placeholders := make([]string, len(filter.Tags))
+		for i, name := range filter.Tags {
+			placeholders[i] = "?"
+			args = append(args, name)
+		}
+		tagged := `SELECT tt.todo_id FROM todos_tags AS tt JOIN tags AS g ON (g.id = tt.tag_id)
+			WHERE g.user_id = ? AND g.name IN (` +
+			strings.Join(placeholders, ", ") + `)`
+		if filter.AllTags {
+			tagged += ` GROUP BY tt.todo_id HAVING COUNT(DISTINCT g.name) = ?`
+			args = append(args, len(filter.Tags))
+		}
+		stmt += ` AND id IN (` + tagged + `)`
+	}
+	return stmt, args
+}
+
+// todoColumnsBySortKey are the columns of the keys of [models.TodoSort].
+var todoColumnsBySortKey = map[string]string{
+	"priority": "priority",
+	"dueAt":    "due_at",
+	"startAt":  "start_at",
+	"position": "position",
+	"id":       "id",
+}
+
+// todoOrder returns the ORDER BY clause for sorts, or for [models.DefaultTodoSort] if sorts is empty, which is the same
+// order as [models.CompareTodos]. Todos without the date a key sorts by come last in both directions.
+func todoOrder(sorts []models.TodoSort) string {
+	if len(sorts) == 0 {
+		sorts = models.DefaultTodoSort
+	}
+	var terms []string
+	for _, sort := range sorts {
+		column := todoColumnsBySortKey[sort.Key]
+		if column == "due_at" || column == "start_at" {
+			terms = append(terms, "("+column+" IS NULL)")
+		}
+		if sort.Desc {
+			column += " DESC"
+		}
+		terms = append(terms, column)
+	}
+	return ` ORDER BY ` + strings.Join(append(terms, "id"), ", ")
 }
 
 // GetTodo fetches the [models.Todo] with the [models.Todo.Id] equal to todoId from the database.
-func GetTodo(todoId int) (models.Todo, error) {
+func (s *SQLStore) GetTodo(todoId int) (models.Todo, error) {
 	var todo models.Todo
-	stmt := `SELECT id, title, text, is_done, user_id FROM todos WHERE id = ?`
-	err := getDb().QueryRow(stmt, todoId).Scan(&todo.Id, &todo.Title, &todo.Text, &todo.IsDone, &todo.UserId)
-	return todo, err
+	stmt := `SELECT ` + todoColumns + ` FROM todos WHERE id = ?`
+	if err := scanTodo(s.queryRow(stmt, todoId), &todo); err != nil {
+		return todo, err
+	}
+	todos := []models.Todo{todo}
+	err := s.loadTags(todos)
+	if err == nil {
+		err = s.loadChecklists(todos)
+	}
+	return todos[0], err
 }
 
-// UpdateTodo sets all values of the row in the database equal to the editable fields of todo. The id for the row is
-// taken from the [models.Todo.Id] of todo.
-func UpdateTodo(todo models.Todo) error {
-	stmt := `UPDATE todos SET title = ?, text = ?, is_done = ?, user_id = ? WHERE id = ?`
-	err := getDb().QueryRow(stmt, todo.Title, todo.Text, todo.IsDone, todo.UserId, todo.Id).Scan()
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil
+// UpdateTodo sets all values of the row in the database equal to the editable fields of todo and replaces its rows
+// in 'todos_tags'. The id for the row is taken from the [models.Todo.Id] of todo.
+func (s *SQLStore) UpdateTodo(todo models.Todo) error {
+	return s.UpdateTodos([]models.Todo{todo})
+}
+
+// UpdateTodos updates each of todos like [SQLStore.UpdateTodo] in a single transaction.
+func (s *SQLStore) UpdateTodos(todos []models.Todo) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, todo := range todos {
+		if err := s.updateTodo(tx, todo); err != nil {
+			return err
+		}
 	}
-	return err
+	return tx.Commit()
 }
 
-// DeleteTodo deletes a [models.Todo] from the database where its [models.Todo.Id] is equal to todoId
-func DeleteTodo(todoId int) (models.Todo, error) {
-	var todo models.Todo
-	stmt := `DELETE FROM todos WHERE id = ? RETURNING id, title, text, is_done, user_id;`
-	err := getDb().QueryRow(stmt, todoId).Scan(&todo.Id, &todo.Title, &todo.Text, &todo.IsDone, &todo.UserId)
+// updateTodo updates the row of todo in 'todos' and its tags within tx.
+func (s *SQLStore) updateTodo(tx *sql.Tx, todo models.Todo) error {
+	todo.NormalizeStatus()
+	stmt := `UPDATE todos SET title = ?, text = ?, is_done = ?, user_id = ?, start_at = ?, due_at = ?, all_day = ?, list_id = ?, parent_id = ?,
+		recurrence = ?, priority = ?, position = ?, status = ?, completed_at = ?,
+		checklist_auto_complete = ? WHERE id = ?`
+	_, err := tx.Exec(s.dialect.rebind(stmt), todo.Title, todo.Text, todo.IsDone, todo.UserId, nullTime(todo.StartAt),
+		nullTime(todo.DueAt), todo.AllDay, nullInt(todo.ListId), nullInt(todo.ParentId), todo.Recurrence, todo.Priority,
+		todo.Position, todo.Status, nullTime(todo.CompletedAt), todo.ChecklistAutoComplete, todo.Id)
+	if s.dialect.isUniqueViolation(err) {
+		return ErrPositionTaken
+	}
+	if err != nil {
+		return err
+	}
+	return s.setTodoTags(tx, todo.Id, todo.Tags)
+}
+
+// DeleteTodo deletes a [models.Todo] from the database where its [models.Todo.Id] is equal to todoId. The deleted todo
+// is returned with the tags it had. The foreign key turns its subtasks into top-level todos.
+func (s *SQLStore) DeleteTodo(todoId int) (models.Todo, error) {
+	todo, err := s.GetTodo(todoId)
+	if err != nil {
+		return todo, err
+	}
+	_, err = s.exec(`DELETE FROM todos WHERE id = ?`, todoId)
+	return todo, err
+}
+
+// DeleteTodoWithSubtasks deletes the row of the todo todoId and the rows of its subtasks from 'todos' in a single
+// statement, so that they are deleted in one transaction.
+func (s *SQLStore) DeleteTodoWithSubtasks(todoId int) (models.Todo, error) {
+	todo, err := s.GetTodo(todoId)
+	if err != nil {
+		return todo, err
+	}
+	stmt := `WITH RECURSIVE subtasks(id) AS (
+			SELECT CAST(? AS INTEGER)
+			UNION SELECT t.id FROM todos AS t JOIN subtasks AS p ON (t.parent_id = p.id)
+		) DELETE FROM todos WHERE id IN (SELECT id FROM subtasks)`
+	_, err = s.exec(stmt, todoId)
 	return todo, err
 }
 
 // GetTodoShares fetches all the user ids the [models.Todo] is shared with
-func GetTodoShares(todoId int) ([]int, error) {
+func (s *SQLStore) GetTodoShares(todoId int) ([]int, error) {
 	var shares []int
 	stmt := `SELECT user_id FROM users_todos WHERE todo_id = ?`
-	rows, err := getDb().Query(stmt, todoId)
+	rows, err := s.query(stmt, todoId)
 	if err != nil {
 		return shares, err
 	}
@@ -98,17 +377,17 @@ func GetTodoShares(todoId int) ([]int, error) {
 }
 
 // CreateTodoShare inserts a share of a [models.Todo] with user userId in the database
-func CreateTodoShare(todoId int, userId int) (int, error) {
+func (s *SQLStore) CreateTodoShare(todoId int, userId int) (int, error) {
 	stmt := `INSERT INTO users_todos(todo_id, user_id) VALUES (?, ?) RETURNING id`
 	var shareId int
-	err := getDb().QueryRow(stmt, todoId, userId).Scan(&shareId)
+	err := s.queryRow(stmt, todoId, userId).Scan(&shareId)
 	return shareId, err
 }
 
-// CreateTodoShare deletes a share of a [models.Todo] with user userId in the database
-func DeleteTodoShare(todoId int, userId int) (int, error) {
+// DeleteTodoShare deletes a share of a [models.Todo] with user userId in the database
+func (s *SQLStore) DeleteTodoShare(todoId int, userId int) (int, error) {
 	stmt := `DELETE FROM users_todos WHERE todo_id = ? AND user_id = ? RETURNING id`
 	var shareId int
-	err := getDb().QueryRow(stmt, todoId, userId).Scan(&shareId)
+	err := s.queryRow(stmt, todoId, userId).Scan(&shareId)
 	return shareId, err
 }
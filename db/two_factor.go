@@ -0,0 +1,235 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"todo/models"
+	"todo/totp"
+)
+
+// recoveryCodeCount is the number of recovery codes created on enrollment.
+const recoveryCodeCount = 10
+
+// challengeLifetime is how long a user has to enter the second factor after the password.
+const challengeLifetime = 5 * time.Minute
+
+// maxChallengeAttempts is the number of wrong codes after which a login challenge is deleted.
+const maxChallengeAttempts = 5
+
+// newRecoveryCodes creates random recovery codes in the form "xxxxx-xxxxx".
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := createUserToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode hashes code ignoring its case and separators, so that it can be typed in either way.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return hashToken(code)
+}
+
+// newEnrollment creates a TOTP secret and recovery codes.
+func newEnrollment() (models.TOTPEnrollment, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return models.TOTPEnrollment{}, err
+	}
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return models.TOTPEnrollment{}, err
+	}
+	return models.TOTPEnrollment{Secret: secret, RecoveryCodes: codes}, nil
+}
+
+// EnrollTOTP replaces an unconfirmed row in 'totp_credentials' and the recovery codes of the user with new ones.
+func (s *SQLStore) EnrollTOTP(userId int) (models.TOTPEnrollment, error) {
+	enrollment, err := newEnrollment()
+	if err != nil {
+		return enrollment, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return enrollment, err
+	}
+	defer tx.Rollback()
+	var confirmed int
+	stmt := `SELECT COUNT(*) FROM totp_credentials WHERE user_id = ? AND confirmed_at IS NOT NULL`
+	if err := tx.QueryRow(s.dialect.rebind(stmt), userId).Scan(&confirmed); err != nil {
+		return enrollment, err
+	}
+	if confirmed > 0 {
+		return enrollment, ErrTOTPEnabled
+	}
+	for _, stmt := range []string{`DELETE FROM totp_credentials WHERE user_id = ?`, `DELETE FROM recovery_codes WHERE user_id = ?`} {
+		if _, err := tx.Exec(s.dialect.rebind(stmt), userId); err != nil {
+			return enrollment, err
+		}
+	}
+	stmt = `INSERT INTO totp_credentials (user_id, secret, created_at) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(s.dialect.rebind(stmt), userId, enrollment.Secret, time.Now().UTC()); err != nil {
+		return enrollment, err
+	}
+	stmt = `INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`
+	for _, code := range enrollment.RecoveryCodes {
+		if _, err := tx.Exec(s.dialect.rebind(stmt), userId, hashRecoveryCode(code)); err != nil {
+			return enrollment, err
+		}
+	}
+	return enrollment, tx.Commit()
+}
+
+// ConfirmTOTP sets 'confirmed_at' of the unconfirmed secret of the user if code is valid.
+func (s *SQLStore) ConfirmTOTP(userId int, code string) error {
+	var secret string
+	var lastStep int64
+	stmt := `SELECT secret, last_used_step FROM totp_credentials WHERE user_id = ? AND confirmed_at IS NULL`
+	if err := s.queryRow(stmt, userId).Scan(&secret, &lastStep); err != nil {
+		return err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), lastStep)
+	if !ok {
+		return ErrInvalidCode
+	}
+	stmt = `UPDATE totp_credentials SET confirmed_at = ?, last_used_step = ? WHERE user_id = ?`
+	_, err := s.exec(stmt, time.Now().UTC(), step, userId)
+	return err
+}
+
+// DisableTOTP deletes the secret and the recovery codes of the user if code is valid.
+func (s *SQLStore) DisableTOTP(userId int, code string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.verifySecondFactor(tx, userId, code); err != nil {
+		return err
+	}
+	for _, stmt := range []string{`DELETE FROM totp_credentials WHERE user_id = ?`, `DELETE FROM recovery_codes WHERE user_id = ?`} {
+		if _, err := tx.Exec(s.dialect.rebind(stmt), userId); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// verifySecondFactor checks code against the confirmed TOTP secret of the user and, if it is not a valid one-time
+// password, against the recovery codes. The step of a valid one-time password is stored so that it can't be used
+// again, a valid recovery code is deleted. It returns [sql.ErrNoRows] if the user has no confirmed secret.
+func (s *SQLStore) verifySecondFactor(tx *sql.Tx, userId int, code string) error {
+	var secret string
+	var lastStep int64
+	stmt := `SELECT secret, last_used_step FROM totp_credentials WHERE user_id = ? AND confirmed_at IS NOT NULL`
+	if err := tx.QueryRow(s.dialect.rebind(stmt), userId).Scan(&secret, &lastStep); err != nil {
+		return err
+	}
+	if step, ok := totp.Validate(secret, code, time.Now(), lastStep); ok {
+		stmt = `UPDATE totp_credentials SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?`
+		result, err := tx.Exec(s.dialect.rebind(stmt), step, userId, step)
+		if err != nil {
+			return err
+		}
+		if updated, err := result.RowsAffected(); err != nil || updated == 0 {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+	stmt = `DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?`
+	result, err := tx.Exec(s.dialect.rebind(stmt), userId, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil || deleted == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// hasSecondFactor reports whether the user confirmed a TOTP secret.
+func (s *SQLStore) hasSecondFactor(userId int) (bool, error) {
+	var confirmed int
+	stmt := `SELECT COUNT(*) FROM totp_credentials WHERE user_id = ? AND confirmed_at IS NOT NULL`
+	err := s.queryRow(stmt, userId).Scan(&confirmed)
+	return confirmed > 0, err
+}
+
+// createChallenge inserts a login challenge for the user into 'login_challenges'. Earlier challenges of the user are
+// deleted, so that the wrong codes allowed per challenge can't be multiplied by logging in again.
+func (s *SQLStore) createChallenge(userId int) (models.SessionTokens, error) {
+	challenge, err := createUserToken(32)
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	now := time.Now().UTC()
+	tokens := models.SessionTokens{Challenge: challenge, ChallengeExpiresAt: now.Add(challengeLifetime)}
+	if _, err := s.exec(`DELETE FROM login_challenges WHERE expires_at <= ? OR user_id = ?`, now, userId); err != nil {
+		return models.SessionTokens{}, err
+	}
+	stmt := `INSERT INTO login_challenges (user_id, token_hash, expires_at) VALUES (?, ?, ?)`
+	if _, err := s.exec(stmt, userId, hashToken(challenge), tokens.ChallengeExpiresAt); err != nil {
+		return models.SessionTokens{}, err
+	}
+	return tokens, nil
+}
+
+// CompleteLogin looks up the challenge in 'login_challenges' and creates a session if code is a valid second factor of
+// its user. Wrong codes are counted in 'attempts' and as failed logins of the user name in 'login_failures'.
+func (s *SQLStore) CompleteLogin(challenge string, code string, client models.Client) (models.SessionTokens, error) {
+	user, err := s.completeChallenge(challenge, code)
+	if err != nil {
+		return models.SessionTokens{}, fmt.Errorf("CompleteLogin: %w", err)
+	}
+	return s.createSession(user, client)
+}
+
+func (s *SQLStore) completeChallenge(challenge string, code string) (models.User, error) {
+	var user models.User
+	tx, err := s.db.Begin()
+	if err != nil {
+		return user, err
+	}
+	defer tx.Rollback()
+	var challengeId, attempts int
+	stmt := `SELECT c.id, c.attempts, u.id, u.name, u.role FROM login_challenges AS c JOIN users AS u ON (u.id = c.user_id) WHERE c.token_hash = ? AND c.expires_at > ?`
+	err = tx.QueryRow(s.dialect.rebind(stmt), hashToken(challenge), time.Now().UTC()).Scan(&challengeId, &attempts, &user.Id, &user.Name, &user.Role)
+	if err != nil {
+		return user, err
+	}
+	err = s.verifySecondFactor(tx, user.Id, code)
+	if err != nil && !errors.Is(err, ErrInvalidCode) {
+		return user, err
+	}
+	if errors.Is(err, ErrInvalidCode) {
+		stmt = `INSERT INTO login_failures (kind, identifier, failures, last_failure_at) VALUES (?, ?, 1, ?)
+			ON CONFLICT (kind, identifier) DO UPDATE SET failures = login_failures.failures + 1,
+				previous_failure_at = login_failures.last_failure_at, last_failure_at = excluded.last_failure_at`
+		if _, err := tx.Exec(s.dialect.rebind(stmt), loginFailureAccount, user.Name, time.Now().UTC()); err != nil {
+			return user, err
+		}
+		if attempts+1 < maxChallengeAttempts {
+			stmt = `UPDATE login_challenges SET attempts = attempts + 1 WHERE id = ?`
+			if _, err := tx.Exec(s.dialect.rebind(stmt), challengeId); err != nil {
+				return user, err
+			}
+			return user, errors.Join(ErrInvalidCode, tx.Commit())
+		}
+	}
+	if _, deleteErr := tx.Exec(s.dialect.rebind(`DELETE FROM login_challenges WHERE id = ?`), challengeId); deleteErr != nil {
+		return user, deleteErr
+	}
+	if commitErr := tx.Commit(); commitErr != nil {
+		return user, commitErr
+	}
+	return user, err
+}
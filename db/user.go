@@ -1,25 +1,66 @@
 package db
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/mattn/go-sqlite3"
+	"sync"
 	"time"
 	"todo/logger"
 	"todo/models"
+	"todo/password"
 )
 
-// CreateUser inserts a [models.User] into the database. On success the user is returned.
-func CreateUser(user models.User) (models.User, error) {
-	stmt := `INSERT INTO users (name, password) VALUES (?, ?) RETURNING id;`
+// dummyPasswordHashes caches a hash per [password.Hasher] that is checked instead of a stored hash if the user of a
+// login does not exist or has no password, so that these logins take as long as logins with a wrong password.
+var dummyPasswordHashes sync.Map
+
+func dummyPasswordHash(hasher password.Hasher) ([]byte, error) {
+	if hash, ok := dummyPasswordHashes.Load(hasher); ok {
+		return hash.([]byte), nil
+	}
+	user := models.User{}
+	user.SetPassword("not the password of any user")
+	hash, err := user.GetPasswordHash(hasher)
+	if err != nil {
+		return nil, err
+	}
+	dummyPasswordHashes.Store(hasher, hash)
+	return hash, nil
+}
+
+// checkLoginPassword checks the password of user against hash, which is empty if the user does not exist. All
+// mismatches return [ErrInvalidCredentials]. On success it reports whether hash is outdated and should be replaced.
+func checkLoginPassword(hasher password.Hasher, user models.User, hash []byte) (rehash bool, err error) {
+	if len(hash) == 0 {
+		dummy, err := dummyPasswordHash(hasher)
+		if err != nil {
+			return false, err
+		}
+		user.CheckPassword(hasher, dummy)
+		return false, ErrInvalidCredentials
+	}
+	rehash, err = user.CheckPassword(hasher, hash)
+	if err != nil {
+		return false, ErrInvalidCredentials
+	}
+	return rehash, nil
+}
+
+// CreateUser inserts a [models.User] into the database. On success the user is returned. Users without a role become
+// a [models.RoleMember].
+func (s *SQLStore) CreateUser(user models.User) (models.User, error) {
+	stmt := `INSERT INTO users (name, password, email, role) VALUES (?, ?, ?, ?) RETURNING id;`
 	id := 0
-	pw, err := user.GetPasswordHash()
+	pw, err := user.GetPasswordHash(s.Hasher)
 	if err != nil {
 		return user, err
 	}
-	err = getDb().QueryRow(stmt, user.Name, pw).Scan(&id)
+	email := sql.NullString{String: user.Email, Valid: user.Email != ""}
+	if user.Role == "" {
+		user.Role = models.RoleMember
+	}
+	err = s.queryRow(stmt, user.Name, string(pw), email, user.Role).Scan(&id)
 	if err != nil {
 		return user, err
 	}
@@ -28,21 +69,22 @@ func CreateUser(user models.User) (models.User, error) {
 }
 
 // GetUsers retrieves a list of [models.User] from the database.
-// It executes a SQL query to fetch the user IDs and names from the 'users' table.
+// It executes a SQL query to fetch the user IDs, names, roles and whether they are disabled from the 'users' table.
 // The function returns a slice of models.User containing the retrieved users and an error.
 // If the query execution encounters an error, it returns an empty slice of users and the error.
 // If the query executes successfully, it iterates over the result set, populates the users,
 // and appends them to the slice. Finally, it returns the populated slice of users and a nil error.
-func GetUsers() ([]models.User, error) {
+func (s *SQLStore) GetUsers() ([]models.User, error) {
 	var users []models.User
-	stmt := `SELECT id, name FROM users`
-	rows, err := getDb().Query(stmt)
+	stmt := `SELECT id, name, role, disabled FROM users ORDER BY id`
+	rows, err := s.query(stmt)
 	if err != nil {
 		return users, err
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var user models.User
-		err := rows.Scan(&user.Id, &user.Name)
+		err := rows.Scan(&user.Id, &user.Name, &user.Role, &user.Disabled)
 		if err != nil {
 			return users, err
 		}
@@ -51,76 +93,125 @@ func GetUsers() ([]models.User, error) {
 	return users, nil
 }
 
-func createUserToken(length int) (string, error) {
-	b := make([]byte, length)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(b), nil
-}
-
-func updateToken(userId int) (string, error) {
-	tokenLength := 32
-	expiration := time.Now().Add(time.Minute * time.Duration(5))
-	stmt := `UPDATE users SET token = ?, expiration = ? WHERE id = ? RETURNING token`
-	// while loop if random token is not unique
-	created := false
-	var token string
-	for !created {
-		var err error
-		token, err = createUserToken(tokenLength)
-		if err != nil {
-			return "", err
-		}
-		if err = getDb().QueryRow(stmt, token, expiration, userId).Scan(&token); err != nil {
-			var sqliteErr sqlite3.Error
-			if errors.As(err, &sqliteErr) {
-				if sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
-					newErr := fmt.Errorf("updateToken: Token generation collision: %w", err)
-					logger.Warning(newErr.Error())
-					continue
-				}
-			}
-			return "", err
-		}
-		created = true
-	}
-
-	return token, nil
-}
-
 // LoginUser verifies the credentials of a user attempting to log in and generates an authentication token upon successful validation.
 // It takes a [models.User] object representing the user attempting to log in.
 // The function executes a SQL query to retrieve the user's ID and hashed password from the 'users' table based on the provided username.
-// If the user is found and the password matches, it generates an authentication token for the user and returns it along with a nil error.
-// If the provided username is not found in the database or the password doesn't match, it returns an empty string and an error.
-// If any database operation fails, it returns an error wrapping the original error encountered during the database interaction.
-func LoginUser(user models.User) (string, error) {
+// If the user is found and the password matches, it generates the tokens of a new session for the user and returns them along with a nil error.
+// If the provided username is not found in the database or the password doesn't match, it returns empty tokens and
+// [ErrInvalidCredentials] after the same amount of work, so that the existence of a user is not revealed.
+// If any database operation fails, it returns the error encountered during the database interaction.
+// If the password hash was created with another algorithm or other parameters than [SQLStore.Hasher], it is replaced.
+// The tokens belong to a new [models.Session] for client, existing sessions of the user stay valid.
+// If the user confirmed a TOTP secret, a challenge is stored in 'login_challenges' and returned with [ErrSecondFactorRequired] instead.
+func (s *SQLStore) LoginUser(user models.User, client models.Client) (models.SessionTokens, error) {
 	// check user and password
 	var userId int
 	var password string
-	stmt := `SELECT id, password FROM users WHERE name = ?`
-	if err := getDb().QueryRow(stmt, user.Name).Scan(&userId, &password); err != nil {
-		return "", fmt.Errorf("LoginUser: failed to find user %s: %w", user.Name, err)
+	var role models.Role
+	var disabled bool
+	stmt := `SELECT id, password, role, disabled FROM users WHERE name = ?`
+	if err := s.queryRow(stmt, user.Name).Scan(&userId, &password, &role, &disabled); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.SessionTokens{}, err
+	}
+	rehash, err := checkLoginPassword(s.Hasher, user, []byte(password))
+	if err != nil {
+		return models.SessionTokens{}, err
 	}
-	if err := user.CheckPassword([]byte(password)); err != nil {
-		return "", err
+	if disabled {
+		return models.SessionTokens{}, ErrUserDisabled
 	}
-	// create a token for the user
-	return updateToken(userId)
+	if rehash {
+		s.rehashPassword(user, userId, password)
+	}
+	// users with a second factor get a challenge instead of a session
+	hasSecondFactor, err := s.hasSecondFactor(userId)
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	if hasSecondFactor {
+		tokens, err := s.createChallenge(userId)
+		if err != nil {
+			return tokens, err
+		}
+		return tokens, ErrSecondFactorRequired
+	}
+	// create a session for the user
+	return s.createSession(models.User{Id: userId, Name: user.Name, Role: role}, client)
 }
 
-// AuthenticateUser verifies the validity of an authentication token and retrieves the corresponding user.
+// rehashPassword replaces the outdated hash of the password of user in 'users' unless it was changed in the meantime.
+// A failure is only logged, as the login itself succeeded.
+func (s *SQLStore) rehashPassword(user models.User, userId int, outdatedHash string) {
+	hash, err := user.GetPasswordHash(s.Hasher)
+	if err == nil {
+		stmt := `UPDATE users SET password = ? WHERE id = ? AND password = ?`
+		_, err = s.exec(stmt, string(hash), userId, outdatedHash)
+	}
+	if err != nil {
+		logger.Warning(fmt.Sprintf("failed to rehash the password of user %d: %v", userId, err))
+	}
+}
+
+// AuthenticateUser verifies the validity of an authentication token and retrieves the corresponding user and session.
 // It takes a token string as input representing the authentication token to be verified.
-// The function executes a SQL query to retrieve the user's ID and name from the 'users' table based on the provided token.
-// It also ensures that the token has not expired by checking the expiration time against the current time.
-// If the token is valid and corresponds to an existing user whose token has not expired,
-// it returns the user object along with a nil error.
-// If the token is invalid, expired, or if any database operation fails, it returns an empty user object and an error.
-func AuthenticateUser(token string) (models.User, error) {
+// The function looks up the session by the hash of the token in the 'sessions' table and joins the session's user.
+// It also ensures that the session has not expired by checking the expiration time against the current time and
+// that the user is not disabled.
+// If the token is valid and corresponds to a session that has not expired, the last use of the session is updated
+// and the user and session objects are returned along with a nil error.
+// If the token is invalid, expired, or if any database operation fails, it returns empty objects and an error.
+func (s *SQLStore) AuthenticateUser(token string) (models.User, models.Session, error) {
+	var user models.User
+	var session models.Session
+	now := time.Now().UTC()
+	stmt := `SELECT u.id, u.name, u.role, ` + sessionColumns + ` FROM sessions AS s JOIN users AS u ON (u.id = s.user_id) WHERE s.token_hash = ? AND s.expires_at > ? AND NOT u.disabled`
+	dest := append([]any{&user.Id, &user.Name, &user.Role}, sessionFields(&session)...)
+	if err := s.queryRow(stmt, hashToken(token), now).Scan(dest...); err != nil {
+		return user, session, err
+	}
+	stmt = `UPDATE sessions SET last_used_at = ? WHERE id = ?`
+	if _, err := s.exec(stmt, now, session.Id); err != nil {
+		return user, session, err
+	}
+	session.LastUsedAt = now
+	return user, session, nil
+}
+
+// GetUser selects the user with id userId from 'users'.
+func (s *SQLStore) GetUser(userId int) (models.User, error) {
 	var user models.User
-	expiration := time.Now()
-	stmt := `SELECT id, name FROM users WHERE token = ? AND expiration > ?`
-	err := getDb().QueryRow(stmt, token, expiration).Scan(&user.Id, &user.Name)
+	var email sql.NullString
+	stmt := `SELECT id, name, email, role, disabled, timezone FROM users WHERE id = ?`
+	err := s.queryRow(stmt, userId).Scan(&user.Id, &user.Name, &email, &user.Role, &user.Disabled, &user.Timezone)
+	user.Email = email.String
 	return user, err
 }
+
+// SetUserRole updates the role of the user with id userId in 'users'.
+func (s *SQLStore) SetUserRole(userId int, role models.Role) error {
+	return s.updateOne(`UPDATE users SET role = ? WHERE id = ?`, role, userId)
+}
+
+// SetUserTimezone updates the timezone of the user with id userId in 'users'.
+func (s *SQLStore) SetUserTimezone(userId int, timezone string) error {
+	return s.updateOne(`UPDATE users SET timezone = ? WHERE id = ?`, timezone, userId)
+}
+
+// SetUserDisabled updates whether the user with id userId is disabled in 'users'. Pending second factor logins of a
+// disabled user are deleted from 'login_challenges'.
+func (s *SQLStore) SetUserDisabled(userId int, disabled bool) error {
+	if err := s.updateOne(`UPDATE users SET disabled = ? WHERE id = ?`, disabled, userId); err != nil {
+		return err
+	}
+	if !disabled {
+		return nil
+	}
+	_, err := s.exec(`DELETE FROM login_challenges WHERE user_id = ?`, userId)
+	return err
+}
+
+// DeleteUser deletes the user with id userId from 'users'. The foreign keys delete the rows of the user in all other
+// tables.
+func (s *SQLStore) DeleteUser(userId int) error {
+	return s.updateOne(`DELETE FROM users WHERE id = ?`, userId)
+}
@@ -0,0 +1,198 @@
+// Package jwt issues and verifies the signed JSON Web Tokens used as access tokens when the server runs in the
+// stateless authentication mode.
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// HS256 signs tokens with HMAC-SHA256 and a shared secret.
+	HS256 = "HS256"
+	// EdDSA signs tokens with an Ed25519 private key.
+	EdDSA = "EdDSA"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, signed with an unknown key or have a wrong signature.
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrExpiredToken is returned for correctly signed tokens that expired.
+var ErrExpiredToken = errors.New("expired token")
+
+// Key is a key tokens are signed with. Its id is written to the "kid" header of every token it signs.
+type Key struct {
+	Id         string
+	Algorithm  string
+	secret     []byte
+	privateKey ed25519.PrivateKey
+}
+
+// NewHMACKey returns an [HS256] key. The secret must be at least 32 bytes long.
+func NewHMACKey(id string, secret []byte) (Key, error) {
+	if len(secret) < 32 {
+		return Key{}, fmt.Errorf("the secret of key %s must be at least 32 bytes long", id)
+	}
+	return Key{Id: id, Algorithm: HS256, secret: secret}, nil
+}
+
+// NewEd25519Key returns an [EdDSA] key derived from a 32 byte seed.
+func NewEd25519Key(id string, seed []byte) (Key, error) {
+	if len(seed) != ed25519.SeedSize {
+		return Key{}, fmt.Errorf("the seed of key %s must be %d bytes long", id, ed25519.SeedSize)
+	}
+	return Key{Id: id, Algorithm: EdDSA, privateKey: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// ParseKeys parses a comma-separated list of keys in the form "<kid>:<algorithm>:<base64 secret or seed>", for
+// example "2024-06:EdDSA:kXy...,2024-01:HS256:c2Vj...".
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.SplitN(strings.TrimSpace(part), ":", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("the key %q is not in the form <kid>:<algorithm>:<base64>", part)
+		}
+		material, err := base64.StdEncoding.DecodeString(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("the key %s is not valid base64: %w", fields[0], err)
+		}
+		var key Key
+		switch fields[1] {
+		case HS256:
+			key, err = NewHMACKey(fields[0], material)
+		case EdDSA:
+			key, err = NewEd25519Key(fields[0], material)
+		default:
+			err = fmt.Errorf("the algorithm %s of key %s is not supported", fields[1], fields[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (key Key) sign(data []byte) []byte {
+	if key.Algorithm == EdDSA {
+		return ed25519.Sign(key.privateKey, data)
+	}
+	mac := hmac.New(sha256.New, key.secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func (key Key) verify(data []byte, signature []byte) bool {
+	if key.Algorithm == EdDSA {
+		return ed25519.Verify(key.privateKey.Public().(ed25519.PublicKey), data, signature)
+	}
+	return hmac.Equal(key.sign(data), signature)
+}
+
+// KeySet signs new tokens with its first key and verifies tokens signed with any of its keys. Keys are rotated by
+// adding a new key in front and removing the old key once all tokens signed with it expired.
+type KeySet struct {
+	keys []Key
+}
+
+// NewKeySet returns a [KeySet] of keys with unique ids. The first key is used for signing.
+func NewKeySet(keys ...Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("a key set needs at least one key")
+	}
+	ids := map[string]bool{}
+	for _, key := range keys {
+		if ids[key.Id] {
+			return nil, fmt.Errorf("the key id %s is used twice", key.Id)
+		}
+		ids[key.Id] = true
+	}
+	return &KeySet{keys: keys}, nil
+}
+
+func (set *KeySet) key(id string) (Key, bool) {
+	for _, key := range set.keys {
+		if key.Id == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyId     string `json:"kid"`
+}
+
+// Claims are the contents of an access token.
+type Claims struct {
+	Subject   string `json:"sub"`
+	Name      string `json:"name"`
+	Role      string `json:"role,omitempty"`
+	SessionId int    `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// Sign returns the compact serialization of a token containing claims signed with the first key of the set.
+func (set *KeySet) Sign(claims Claims) (string, error) {
+	key := set.keys[0]
+	headerJSON, err := json.Marshal(header{Algorithm: key.Algorithm, Type: "JWT", KeyId: key.Id})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := encoding.EncodeToString(headerJSON) + "." + encoding.EncodeToString(claimsJSON)
+	return signingInput + "." + encoding.EncodeToString(key.sign([]byte(signingInput))), nil
+}
+
+// Verify checks the signature of token with the key named in its "kid" header and returns its claims if it has not
+// expired at now. The algorithm in the header must match the algorithm of the key.
+func (set *KeySet) Verify(token string, now time.Time) (Claims, error) {
+	var claims Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrInvalidToken
+	}
+	headerJSON, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, ErrInvalidToken
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return claims, ErrInvalidToken
+	}
+	key, ok := set.key(h.KeyId)
+	if !ok || key.Algorithm != h.Algorithm {
+		return claims, ErrInvalidToken
+	}
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return claims, ErrInvalidToken
+	}
+	claimsJSON, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, ErrInvalidToken
+	}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return claims, ErrInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return claims, ErrExpiredToken
+	}
+	return claims, nil
+}
@@ -0,0 +1,219 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todo/db"
+	"todo/models"
+	"todo/password"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestKeys(t *testing.T) (Key, Key) {
+	hmacKey, err := NewHMACKey("hmac", bytes.Repeat([]byte("s"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, err := NewEd25519Key("ed", bytes.Repeat([]byte("e"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hmacKey, edKey
+}
+
+func TestKeySet(t *testing.T) {
+	hmacKey, edKey := newTestKeys(t)
+	now := time.Now()
+	claims := Claims{Subject: "1", Name: "john", Role: "admin", SessionId: 2, IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+	for _, key := range []Key{hmacKey, edKey} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			keys, err := NewKeySet(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := keys.Sign(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verified, err := keys.Verify(token, now); err != nil || verified != claims {
+				t.Fatalf("Expected %+v but got %+v (%v)", claims, verified, err)
+			}
+			if _, err := keys.Verify(token, now.Add(2*time.Minute)); !errors.Is(err, ErrExpiredToken) {
+				t.Fatalf("An expired token was accepted: %v", err)
+			}
+			parts := strings.Split(token, ".")
+			forged, _ := keys.Sign(Claims{Subject: "2", SessionId: 2, ExpiresAt: claims.ExpiresAt})
+			tampered := parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+			if _, err := keys.Verify(tampered, now); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("A tampered token was accepted: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeySet_rotation(t *testing.T) {
+	hmacKey, edKey := newTestKeys(t)
+	old, err := NewKeySet(hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := NewKeySet(edKey, hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	claims := Claims{Subject: "1", ExpiresAt: now.Add(time.Minute).Unix()}
+	oldToken, _ := old.Sign(claims)
+	if _, err := rotated.Verify(oldToken, now); err != nil {
+		t.Fatalf("A token signed with the previous key was rejected after the rotation: %v", err)
+	}
+	newToken, _ := rotated.Sign(claims)
+	if _, err := old.Verify(newToken, now); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("A token signed with an unknown key was accepted: %v", err)
+	}
+	if _, err := NewKeySet(hmacKey, hmacKey); err == nil {
+		t.Fatalf("Duplicate key ids were accepted")
+	}
+}
+
+func TestKeySet_algorithmMismatch(t *testing.T) {
+	hmacKey, _ := newTestKeys(t)
+	keys, err := NewKeySet(hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	token, _ := keys.Sign(Claims{Subject: "1", ExpiresAt: now.Add(time.Minute).Unix()})
+	parts := strings.Split(token, ".")
+	parts[0] = encoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT","kid":"hmac"}`))
+	if _, err := keys.Verify(strings.Join(parts, "."), now); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("A token with a different algorithm than its key was accepted: %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("s"), 32))
+	keys, err := ParseKeys("a:EdDSA:" + secret + ", b:HS256:" + secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Id != "a" || keys[0].Algorithm != EdDSA || keys[1].Id != "b" || keys[1].Algorithm != HS256 {
+		t.Fatalf("Unexpected keys %+v", keys)
+	}
+	for _, spec := range []string{"a:HS256", "a:RS256:" + secret, "a:HS256:short", "a:HS256:c2hvcnQ="} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Fatalf("The invalid keys %q were accepted", spec)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	hmacKey, _ := newTestKeys(t)
+	keys, err := NewKeySet(hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := db.NewMemoryStore()
+	store := NewStore(memory, keys, memory.TokenLifetimes.Access)
+	john := models.User{Name: "john"}
+	john.SetPassword("doe")
+	john, err = store.CreateUser(john)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := store.LoginUser(john, models.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := memory.AuthenticateUser(tokens.AccessToken); err == nil {
+		t.Fatalf("The wrapped store accepted the JWT")
+	}
+	user, session, err := store.AuthenticateUser(tokens.AccessToken)
+	if err != nil || user.Id != john.Id || user.Name != "john" || user.Role != models.RoleMember || session.Id != tokens.SessionId {
+		t.Fatalf("The JWT does not authenticate john: %+v, %+v (%v)", user, session, err)
+	}
+	refreshed, err := store.RefreshSession(tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.AuthenticateUser(refreshed.AccessToken); err != nil {
+		t.Fatalf("The refreshed JWT is not valid: %v", err)
+	}
+
+	// a second replica sharing the database only learns about the revocation when it reloads the list
+	replica := NewStore(memory, keys, memory.TokenLifetimes.Access)
+	if err := store.DeleteSession(john.Id, session.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.AuthenticateUser(refreshed.AccessToken); err == nil {
+		t.Fatalf("The JWT of a deleted session is still valid")
+	}
+	if err := replica.LoadRevocations(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := replica.AuthenticateUser(refreshed.AccessToken); err == nil {
+		t.Fatalf("The replica still accepts the JWT of a deleted session after reloading the revocations")
+	}
+
+	tokens, err = store.LoginUser(john, models.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.RefreshSession(tokens.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.RefreshSession(tokens.RefreshToken); !errors.Is(err, db.ErrRefreshTokenReused) {
+		t.Fatalf("Reusing a refresh token was not detected: %v", err)
+	}
+	if _, _, err := store.AuthenticateUser(tokens.AccessToken); err == nil {
+		t.Fatalf("The JWT is still valid after its refresh token was reused")
+	}
+}
+
+func TestStore_sqliteSessionIds(t *testing.T) {
+	hmacKey, _ := newTestKeys(t)
+	keys, err := NewKeySet(hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := db.NewSQLiteStore(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+	sqlite.Hasher = password.Hasher{Algorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost}
+	if _, err := sqlite.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(sqlite, keys, sqlite.TokenLifetimes.Access)
+	john := models.User{Name: "john"}
+	john.SetPassword("doe")
+	john, err = store.CreateUser(john)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// logging out of the newest session must not revoke the next one
+	tokens, err := store.LoginUser(john, models.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteSession(john.Id, tokens.SessionId); err != nil {
+		t.Fatal(err)
+	}
+	next, err := store.LoginUser(john, models.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.SessionId == tokens.SessionId {
+		t.Fatalf("The session id %d of the logged out session was used again", next.SessionId)
+	}
+	if _, _, err := store.AuthenticateUser(next.AccessToken); err != nil {
+		t.Fatalf("The JWT of the login after a logout is not valid: %v", err)
+	}
+}
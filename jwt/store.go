@@ -0,0 +1,215 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+	"todo/db"
+	"todo/logger"
+	"todo/models"
+)
+
+// Store wraps a [db.Store] so that sessions use signed tokens as access tokens. Logins and refreshes still create and
+// rotate the sessions and refresh tokens in the wrapped store, but the access token handed out is a JWT that is
+// validated by [Store.AuthenticateUser] without a database lookup. Revoking a session puts it on a revocation list
+// that is shared through the wrapped store and cached in memory, see [Store.WatchRevocations].
+type Store struct {
+	db.Store
+	keys           *KeySet
+	accessLifetime time.Duration
+	mu             sync.RWMutex
+	revoked        map[int]bool
+}
+
+var _ db.Store = (*Store)(nil)
+
+// NewStore returns a [Store] signing access tokens with keys. accessLifetime must match the access token lifetime of
+// the wrapped store, it is how long a revoked session stays on the revocation list.
+func NewStore(store db.Store, keys *KeySet, accessLifetime time.Duration) *Store {
+	return &Store{Store: store, keys: keys, accessLifetime: accessLifetime, revoked: map[int]bool{}}
+}
+
+// sign replaces the access token of tokens with a JWT for the same session and user.
+func (s *Store) sign(tokens models.SessionTokens) (models.SessionTokens, error) {
+	token, err := s.keys.Sign(Claims{
+		Subject:   strconv.Itoa(tokens.User.Id),
+		Name:      tokens.User.Name,
+		Role:      string(tokens.User.Role),
+		SessionId: tokens.SessionId,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: tokens.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return models.SessionTokens{}, err
+	}
+	tokens.AccessToken = token
+	return tokens, nil
+}
+
+// LoginUser creates a session in the wrapped store and returns a JWT as its access token.
+func (s *Store) LoginUser(user models.User, client models.Client) (models.SessionTokens, error) {
+	tokens, err := s.Store.LoginUser(user, client)
+	if err != nil {
+		return tokens, err
+	}
+	return s.sign(tokens)
+}
+
+// LoginExternalUser creates a session in the wrapped store and returns a JWT as its access token.
+func (s *Store) LoginExternalUser(identity models.ExternalIdentity, client models.Client) (models.SessionTokens, error) {
+	tokens, err := s.Store.LoginExternalUser(identity, client)
+	if err != nil {
+		return tokens, err
+	}
+	return s.sign(tokens)
+}
+
+// CompleteLogin completes a login with a second factor in the wrapped store and returns a JWT as its access token.
+func (s *Store) CompleteLogin(challenge string, code string, client models.Client) (models.SessionTokens, error) {
+	tokens, err := s.Store.CompleteLogin(challenge, code, client)
+	if err != nil {
+		return tokens, err
+	}
+	return s.sign(tokens)
+}
+
+// RefreshSession rotates the refresh token in the wrapped store and returns a new JWT. If the wrapped store revokes
+// the session because a refresh token was reused, the session is also added to the revocation list.
+func (s *Store) RefreshSession(refreshToken string) (models.SessionTokens, error) {
+	tokens, err := s.Store.RefreshSession(refreshToken)
+	if errors.Is(err, db.ErrRefreshTokenReused) {
+		if revokeErr := s.revoke([]int{tokens.SessionId}); revokeErr != nil {
+			return models.SessionTokens{}, errors.Join(err, revokeErr)
+		}
+		return models.SessionTokens{}, err
+	}
+	if err != nil {
+		return tokens, err
+	}
+	return s.sign(tokens)
+}
+
+// AuthenticateUser verifies the signature and expiry of a JWT and checks that its session is not revoked. The
+// returned user and session only contain the fields stored in the token. Role changes and disabled users therefore
+// only take effect once the sessions of the user are revoked.
+func (s *Store) AuthenticateUser(token string) (models.User, models.Session, error) {
+	claims, err := s.keys.Verify(token, time.Now())
+	if err != nil {
+		return models.User{}, models.Session{}, err
+	}
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return models.User{}, models.Session{}, ErrInvalidToken
+	}
+	if s.isRevoked(claims.SessionId) {
+		return models.User{}, models.Session{}, errors.New("the session of the token was revoked")
+	}
+	user := models.User{Id: userId, Name: claims.Name, Role: models.Role(claims.Role)}
+	session := models.Session{
+		Id:         claims.SessionId,
+		UserId:     userId,
+		LastUsedAt: time.Now().UTC(),
+		ExpiresAt:  time.Unix(claims.ExpiresAt, 0).UTC(),
+	}
+	return user, session, nil
+}
+
+// DeleteSession deletes the session in the wrapped store and revokes its access tokens.
+func (s *Store) DeleteSession(userId int, sessionId int) error {
+	if err := s.Store.DeleteSession(userId, sessionId); err != nil {
+		return err
+	}
+	return s.revoke([]int{sessionId})
+}
+
+// DeleteOtherSessions deletes all sessions but keepSessionId in the wrapped store and revokes their access tokens.
+func (s *Store) DeleteOtherSessions(userId int, keepSessionId int) (int, error) {
+	sessions, err := s.Store.GetSessions(userId)
+	if err != nil {
+		return 0, err
+	}
+	var sessionIds []int
+	for _, session := range sessions {
+		if session.Id != keepSessionId {
+			sessionIds = append(sessionIds, session.Id)
+		}
+	}
+	if err := s.revoke(sessionIds); err != nil {
+		return 0, err
+	}
+	return s.Store.DeleteOtherSessions(userId, keepSessionId)
+}
+
+// DeleteAllSessions deletes every session of the user in the wrapped store and revokes their access tokens.
+func (s *Store) DeleteAllSessions(userId int) (int, error) {
+	sessions, err := s.Store.GetSessions(userId)
+	if err != nil {
+		return 0, err
+	}
+	sessionIds := make([]int, len(sessions))
+	for i, session := range sessions {
+		sessionIds[i] = session.Id
+	}
+	if err := s.revoke(sessionIds); err != nil {
+		return 0, err
+	}
+	return s.Store.DeleteAllSessions(userId)
+}
+
+// revoke puts the sessions on the shared revocation list and into the local cache.
+func (s *Store) revoke(sessionIds []int) error {
+	if len(sessionIds) == 0 {
+		return nil
+	}
+	if err := s.Store.RevokeSessions(sessionIds, time.Now().Add(s.accessLifetime)); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sessionId := range sessionIds {
+		s.revoked[sessionId] = true
+	}
+	return nil
+}
+
+func (s *Store) isRevoked(sessionId int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.revoked[sessionId]
+}
+
+// LoadRevocations replaces the cached revocation list with the one in the wrapped store. This picks up the sessions
+// revoked by other replicas.
+func (s *Store) LoadRevocations() error {
+	sessionIds, err := s.Store.GetRevokedSessions()
+	if err != nil {
+		return err
+	}
+	revoked := make(map[int]bool, len(sessionIds))
+	for _, sessionId := range sessionIds {
+		revoked[sessionId] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked = revoked
+	return nil
+}
+
+// WatchRevocations loads the revocation list every interval until ctx is done. Sessions revoked by another replica are
+// therefore accepted by this replica for at most interval.
+func (s *Store) WatchRevocations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.LoadRevocations(); err != nil {
+				logger.Error(err.Error())
+			}
+		}
+	}
+}
@@ -1,25 +1,205 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
 	"todo/controllers"
+	"todo/db"
+	"todo/jwt"
+	"todo/logger"
 	"todo/middlewares"
+	"todo/models"
+	"todo/notify"
+	"todo/oidc"
+	"todo/password"
+	"todo/reminder"
 )
 
 func main() {
+	database := flag.String("database", envOrDefault("DATABASE_URL", "todo.db"), "SQLite file, PostgreSQL URL or \"memory\"")
+	accessLifetime := flag.Duration("access-token-lifetime", envDurationOrDefault("ACCESS_TOKEN_LIFETIME", db.DefaultTokenLifetimes.Access), "lifetime of access tokens")
+	refreshLifetime := flag.Duration("refresh-token-lifetime", envDurationOrDefault("REFRESH_TOKEN_LIFETIME", db.DefaultTokenLifetimes.Refresh), "lifetime of refresh tokens, extended on every refresh")
+	jwtKeys := flag.String("jwt-keys", os.Getenv("JWT_KEYS"), "comma-separated signing keys <kid>:<HS256|EdDSA>:<base64>, enables JWT access tokens, the first key signs")
+	revocationInterval := flag.Duration("jwt-revocation-interval", envDurationOrDefault("JWT_REVOCATION_INTERVAL", 30*time.Second), "how often the list of revoked sessions is reloaded in JWT mode")
+	loginThrottle := controllers.DefaultLoginThrottle
+	hasher := password.DefaultHasher
+	passwordPolicy := password.DefaultPolicy
+	subtaskPolicy := flag.String("subtask-policy", envOrDefault("SUBTASK_POLICY", string(models.SubtasksBlock)), "what happens to the subtasks of completed and deleted todos unless a request asks otherwise, cascade, orphan or block")
+	oidcConfig := oidc.Config{}
+	flag.StringVar(&oidcConfig.Issuer, "oidc-issuer", os.Getenv("OIDC_ISSUER"), "issuer URL of the OpenID Connect provider, enables logging in with it")
+	flag.StringVar(&oidcConfig.ClientId, "oidc-client-id", os.Getenv("OIDC_CLIENT_ID"), "client id registered at the OpenID Connect provider")
+	flag.StringVar(&oidcConfig.ClientSecret, "oidc-client-secret", os.Getenv("OIDC_CLIENT_SECRET"), "client secret registered at the OpenID Connect provider")
+	flag.StringVar(&oidcConfig.RedirectURL, "oidc-redirect-url", envOrDefault("OIDC_REDIRECT_URL", "http://localhost:8080/oidc/callback"), "URL of /oidc/callback registered at the OpenID Connect provider")
+	smtpAddr := flag.String("smtp-addr", os.Getenv("SMTP_ADDR"), "host:port of the SMTP server sending password reset emails, they are only logged if empty")
+	smtpUsername := flag.String("smtp-username", os.Getenv("SMTP_USERNAME"), "user name at the SMTP server")
+	smtpPassword := flag.String("smtp-password", os.Getenv("SMTP_PASSWORD"), "password at the SMTP server")
+	mailFrom := flag.String("mail-from", envOrDefault("MAIL_FROM", "todo@localhost"), "sender address of emails")
+	reminderInterval := flag.Duration("reminder-interval", envDurationOrDefault("REMINDER_INTERVAL", time.Minute), "how often due reminders are sent")
+	reminderWebhookURL := flag.String("reminder-webhook-url", os.Getenv("REMINDER_WEBHOOK_URL"), "URL reminders are posted to as JSON, they are sent like password reset emails if empty")
+	passwordResetURL := flag.String("password-reset-url", os.Getenv("PASSWORD_RESET_URL"), "URL of the page to reset a password, the token is appended as query parameter")
+	flag.StringVar((*string)(&hasher.Algorithm), "password-hash", envOrDefault("PASSWORD_HASH", string(hasher.Algorithm)), "algorithm new passwords are hashed with, bcrypt or argon2id, outdated hashes are replaced on login")
+	flag.IntVar(&hasher.BcryptCost, "bcrypt-cost", envIntOrDefault("BCRYPT_COST", hasher.BcryptCost), "cost of bcrypt password hashes")
+	argon2Time := flag.Int("argon2-time", envIntOrDefault("ARGON2_TIME", int(hasher.Argon2Time)), "passes of argon2id password hashes")
+	argon2Memory := flag.Int("argon2-memory", envIntOrDefault("ARGON2_MEMORY", int(hasher.Argon2Memory)), "memory of argon2id password hashes in KiB")
+	argon2Threads := flag.Int("argon2-threads", envIntOrDefault("ARGON2_THREADS", int(hasher.Argon2Threads)), "threads of argon2id password hashes")
+	flag.IntVar(&passwordPolicy.MinLength, "password-min-length", envIntOrDefault("PASSWORD_MIN_LENGTH", passwordPolicy.MinLength), "minimum number of characters of new passwords")
+	flag.BoolVar(&passwordPolicy.RejectCommon, "password-reject-common", envBoolOrDefault("PASSWORD_REJECT_COMMON", passwordPolicy.RejectCommon), "reject new passwords on the list of common passwords")
+	flag.IntVar(&loginThrottle.MaxFailures, "login-max-failures", envIntOrDefault("LOGIN_MAX_FAILURES", loginThrottle.MaxFailures), "failed logins after which a user name is locked")
+	flag.IntVar(&loginThrottle.MaxClientFailures, "login-max-client-failures", envIntOrDefault("LOGIN_MAX_CLIENT_FAILURES", loginThrottle.MaxClientFailures), "failed logins after which a client IP address is blocked")
+	flag.DurationVar(&loginThrottle.BaseDelay, "login-base-delay", envDurationOrDefault("LOGIN_BASE_DELAY", loginThrottle.BaseDelay), "delay after the first failed login for a user name, doubled with every further failure")
+	flag.DurationVar(&loginThrottle.LockoutDuration, "login-lockout-duration", envDurationOrDefault("LOGIN_LOCKOUT_DURATION", loginThrottle.LockoutDuration), "how long locked user names and blocked addresses can't log in")
+	flag.Parse()
+	hasher.Argon2Time, hasher.Argon2Memory, hasher.Argon2Threads = uint32(*argon2Time), uint32(*argon2Memory), uint8(*argon2Threads)
+	store, err := db.Open(*database, db.TokenLifetimes{Access: *accessLifetime, Refresh: *refreshLifetime}, hasher)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	migrator, isMigrator := store.(db.Migrator)
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "migrate" {
+		if !isMigrator {
+			log.Fatalf("the database %q has no migrations", *database)
+		}
+		if err := runMigrate(migrator, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if isMigrator {
+		count, err := migrator.MigrateUp()
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.Info(fmt.Sprintf("applied %d migration(s)", count))
+	}
+	if len(args) > 0 {
+		if args[0] != "role" {
+			log.Fatalf("unknown command %q", strings.Join(args, " "))
+		}
+		if err := runRole(store, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *jwtKeys != "" {
+		jwtStore, err := newJWTStore(store, *jwtKeys, *accessLifetime)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go jwtStore.WatchRevocations(context.Background(), *revocationInterval)
+		store = jwtStore
+		logger.Info("issuing signed JWTs as access tokens")
+	}
+	server := controllers.NewServer(store)
+	server.PasswordResetURL = *passwordResetURL
+	server.LoginThrottle = loginThrottle
+	server.PasswordPolicy = passwordPolicy
+	if server.SubtaskPolicy, err = models.ParseSubtaskPolicy(*subtaskPolicy); err != nil {
+		log.Fatal(err)
+	}
+	if *smtpAddr != "" {
+		server.Notifier = notify.NewSMTPNotifier(*smtpAddr, *mailFrom, *smtpUsername, *smtpPassword)
+		logger.Info("sending emails through " + *smtpAddr)
+	}
+	reminderNotifier := server.Notifier
+	if *reminderWebhookURL != "" {
+		reminderNotifier = &notify.WebhookNotifier{URL: *reminderWebhookURL}
+		logger.Info("posting reminders to " + *reminderWebhookURL)
+	}
+	go reminder.NewScheduler(store, reminderNotifier).Run(context.Background(), *reminderInterval)
+	if oidcConfig.Issuer != "" {
+		server.OIDC, err = oidc.NewProvider(context.Background(), oidcConfig, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.Info("users can log in with " + oidcConfig.Issuer)
+	}
+	// routes without scopes can only be accessed with the token of a session, not with a personal access token
+	auth := func(next http.HandlerFunc, scopes ...models.Scope) http.HandlerFunc {
+		return middlewares.AuthenticateUser(store, scopes, next)
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /login", controllers.LoginUser)
-	mux.HandleFunc("GET /users", controllers.GetUsers)
-	mux.HandleFunc("POST /users", controllers.CreateUser)
-	mux.Handle("GET /todos", middlewares.AuthenticateUser(http.HandlerFunc(controllers.GetTodos)))
-	mux.Handle("GET /todos/{id}", middlewares.AuthenticateUser(http.HandlerFunc(controllers.GetTodo)))
-	mux.Handle("POST /todos", middlewares.AuthenticateUser(http.HandlerFunc(controllers.CreateTodo)))
-	mux.Handle("DELETE /todos/{id}", middlewares.AuthenticateUser(http.HandlerFunc(controllers.DeleteTodo)))
+	mux.HandleFunc("POST /login", server.LoginUser)
+	mux.HandleFunc("POST /login/2fa", server.CompleteLogin)
+	mux.HandleFunc("POST /token/refresh", server.RefreshToken)
+	mux.HandleFunc("GET /oidc/login", server.OIDCLogin)
+	mux.HandleFunc("GET /oidc/callback", server.OIDCCallback)
+	mux.Handle("POST /logout", auth(server.Logout))
+	mux.Handle("POST /logout/all", auth(server.LogoutAll))
+	mux.Handle("POST /me/password", auth(server.ChangePassword))
+	mux.Handle("PATCH /me", auth(server.UpdateAccount))
+	mux.Handle("GET /me/export", auth(server.ExportAccount))
+	mux.Handle("DELETE /me", auth(server.DeleteAccount))
+	mux.HandleFunc("POST /password/reset", server.RequestPasswordReset)
+	mux.HandleFunc("POST /password/reset/confirm", server.ResetPassword)
+	mux.Handle("GET /users", auth(server.GetUsers))
+	mux.HandleFunc("POST /users", server.CreateUser)
+	mux.Handle("GET /sessions", auth(server.GetSessions))
+	mux.Handle("DELETE /sessions", auth(server.DeleteOtherSessions))
+	mux.Handle("DELETE /sessions/{id}", auth(server.DeleteSession))
+	mux.Handle("POST /2fa/totp", auth(server.EnrollTOTP))
+	mux.Handle("POST /2fa/totp/confirm", auth(server.ConfirmTOTP))
+	mux.Handle("DELETE /2fa/totp", auth(server.DisableTOTP))
+	mux.Handle("GET /tokens", auth(server.GetAccessTokens))
+	mux.Handle("POST /tokens", auth(server.CreateAccessToken))
+	mux.Handle("DELETE /tokens/{id}", auth(server.DeleteAccessToken))
+	// the admin routes are session-only like all routes without scopes
+	admin := func(next http.HandlerFunc) http.HandlerFunc {
+		return auth(middlewares.RequireRole(models.RoleAdmin, next))
+	}
+	mux.Handle("GET /admin/users", admin(server.AdminGetUsers))
+	mux.Handle("POST /admin/users/{id}/disable", admin(server.DisableUser))
+	mux.Handle("POST /admin/users/{id}/enable", admin(server.EnableUser))
+	mux.Handle("PUT /admin/users/{id}/role", admin(server.SetUserRole))
+	mux.Handle("DELETE /admin/users/{id}", admin(server.DeleteUser))
+	mux.Handle("POST /admin/users/{id}/unlock", admin(server.UnlockUser))
+	mux.Handle("GET /admin/todos/{id}", admin(server.AdminGetTodo))
+	mux.Handle("GET /todos", auth(server.GetTodos, models.ScopeTodosRead))
+	mux.Handle("GET /todos/{id}", auth(server.GetTodo, models.ScopeTodosRead))
+	mux.Handle("GET /todos/{id}/children", auth(server.GetTodoChildren, models.ScopeTodosRead))
+	mux.Handle("GET /todos/{id}/occurrences", auth(server.GetTodoOccurrences, models.ScopeTodosRead))
+	mux.Handle("POST /todos", auth(server.CreateTodo, models.ScopeTodosWrite))
+	mux.Handle("DELETE /todos/{id}", auth(server.DeleteTodo, models.ScopeTodosWrite))
+	mux.Handle("POST /todos/{id}/move", auth(server.MoveTodo, models.ScopeTodosWrite))
 	// according to https://stackoverflow.com/questions/28459418/use-of-put-vs-patch-methods-in-rest-api-real-life-scenarios
-	mux.Handle("PATCH /todos/{id}", middlewares.AuthenticateUser(http.HandlerFunc(controllers.UpdateTodo)))
-	mux.Handle("POST /todos/{id}/share", middlewares.AuthenticateUser(http.HandlerFunc(controllers.ShareTodo)))
-	mux.Handle("DELETE /todos/{id}/share", middlewares.AuthenticateUser(http.HandlerFunc(controllers.UnshareTodo)))
+	mux.Handle("PATCH /todos/{id}", auth(server.UpdateTodo, models.ScopeTodosWrite))
+	mux.Handle("POST /todos/{id}/share", auth(server.ShareTodo, models.ScopeSharesWrite))
+	mux.Handle("DELETE /todos/{id}/share", auth(server.UnshareTodo, models.ScopeSharesWrite))
+	mux.Handle("GET /todos/{id}/dependencies", auth(server.GetTodoDependencies, models.ScopeTodosRead))
+	mux.Handle("POST /todos/{id}/dependencies", auth(server.CreateTodoDependency, models.ScopeTodosWrite))
+	mux.Handle("DELETE /todos/{id}/dependencies", auth(server.DeleteTodoDependency, models.ScopeTodosWrite))
+	mux.Handle("GET /todos/{id}/checklist", auth(server.GetChecklist, models.ScopeTodosRead))
+	mux.Handle("POST /todos/{id}/checklist", auth(server.CreateChecklistItem, models.ScopeTodosWrite))
+	mux.Handle("PATCH /checklist-items/{id}", auth(server.UpdateChecklistItem, models.ScopeTodosWrite))
+	mux.Handle("DELETE /checklist-items/{id}", auth(server.DeleteChecklistItem, models.ScopeTodosWrite))
+	mux.Handle("POST /checklist-items/{id}/move", auth(server.MoveChecklistItem, models.ScopeTodosWrite))
+	mux.Handle("GET /todos/{id}/reminders", auth(server.GetReminders, models.ScopeTodosRead))
+	mux.Handle("POST /todos/{id}/reminders", auth(server.CreateReminder, models.ScopeTodosWrite))
+	mux.Handle("DELETE /reminders/{id}", auth(server.DeleteReminder, models.ScopeTodosWrite))
+	mux.Handle("POST /reminders/{id}/snooze", auth(server.SnoozeReminder, models.ScopeTodosWrite))
+	mux.Handle("GET /tags", auth(server.GetTags, models.ScopeTodosRead))
+	mux.Handle("POST /tags", auth(server.CreateTag, models.ScopeTodosWrite))
+	mux.Handle("PATCH /tags/{id}", auth(server.UpdateTag, models.ScopeTodosWrite))
+	mux.Handle("POST /tags/{id}/merge", auth(server.MergeTags, models.ScopeTodosWrite))
+	mux.Handle("DELETE /tags/{id}", auth(server.DeleteTag, models.ScopeTodosWrite))
+	mux.Handle("GET /lists", auth(server.GetLists, models.ScopeTodosRead))
+	mux.Handle("GET /lists/{id}", auth(server.GetList, models.ScopeTodosRead))
+	mux.Handle("GET /lists/{id}/todos", auth(server.GetListTodos, models.ScopeTodosRead))
+	mux.Handle("POST /lists", auth(server.CreateList, models.ScopeTodosWrite))
+	mux.Handle("PATCH /lists/{id}", auth(server.UpdateList, models.ScopeTodosWrite))
+	mux.Handle("DELETE /lists/{id}", auth(server.DeleteList, models.ScopeTodosWrite))
+	mux.Handle("POST /lists/{id}/share", auth(server.ShareList, models.ScopeSharesWrite))
+	mux.Handle("DELETE /lists/{id}/share", auth(server.UnshareList, models.ScopeSharesWrite))
 
 	srv := http.Server{
 		Addr:    ":8080",
@@ -29,3 +209,70 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// newJWTStore wraps store in a [jwt.Store] signing with the keys in spec and loads the current revocation list.
+func newJWTStore(store db.Store, spec string, accessLifetime time.Duration) (*jwt.Store, error) {
+	keys, err := jwt.ParseKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+	keySet, err := jwt.NewKeySet(keys...)
+	if err != nil {
+		return nil, err
+	}
+	jwtStore := jwt.NewStore(store, keySet, accessLifetime)
+	if err := jwtStore.LoadRevocations(); err != nil {
+		return nil, err
+	}
+	return jwtStore, nil
+}
+
+// envOrDefault returns the value of the environment variable key or fallback if it is not set.
+func envOrDefault(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// envDurationOrDefault returns the duration in the environment variable key or fallback if it is not set or invalid.
+func envDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Warning(fmt.Sprintf("%s is not a valid duration, using %s: %v", key, fallback, err))
+		return fallback
+	}
+	return duration
+}
+
+// envIntOrDefault returns the integer in the environment variable key or fallback if it is not set or invalid.
+func envIntOrDefault(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Warning(fmt.Sprintf("%s is not a valid number, using %d: %v", key, fallback, err))
+		return fallback
+	}
+	return number
+}
+
+// envBoolOrDefault returns the boolean in the environment variable key or fallback if it is not set or invalid.
+func envBoolOrDefault(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Warning(fmt.Sprintf("%s is not a valid boolean, using %t: %v", key, fallback, err))
+		return fallback
+	}
+	return b
+}
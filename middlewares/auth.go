@@ -6,9 +6,18 @@ import (
 	"net/http"
 	"strings"
 	"todo/db"
+	"todo/models"
 )
 
 const ContextUserKey = "user"
+const ContextSessionKey = "session"
+const ContextAccessTokenKey = "accessToken"
+
+// Authenticator looks up the user a session token or a personal access token belongs to.
+type Authenticator interface {
+	AuthenticateUser(token string) (models.User, models.Session, error)
+	AuthenticateAccessToken(token string) (models.User, models.AccessToken, error)
+}
 
 func preprocessToken(token string) (string, error) {
 	split := strings.Split(token, " ")
@@ -21,29 +30,64 @@ func preprocessToken(token string) (string, error) {
 	return split[1], nil
 }
 
+// grantsScopes reports whether accessToken was granted all scopes. Routes that do not require any scope can't be
+// accessed with access tokens at all.
+func grantsScopes(accessToken models.AccessToken, scopes []models.Scope) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		if !accessToken.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
 // AuthenticateUser is a middleware that authenticates incoming requests by verifying the provided authorization token.
-// It takes an [http.HandlerFunc] 'next' as input, representing the next HTTP handler function to be executed in the chain.
+// It takes the [Authenticator] the tokens are looked up in, the scopes a personal access token needs for the route
+// and an [http.HandlerFunc] 'next' as input, representing the next HTTP handler function to be executed in the chain.
 // The middleware intercepts the incoming request, extracts the authorization token from the request header,
 // and preprocesses it to remove any prefix or formatting.
-// It then attempts to authenticate the user based on the extracted token by calling the [db.AuthenticateUser] function.
-// If the token is valid and corresponds to an existing user, it adds the authenticated user to the request context
-// using the ContextUserKey key.
+// Tokens starting with [db.AccessTokenPrefix] are personal access tokens and are authenticated by
+// [Authenticator.AuthenticateAccessToken]. They are only accepted if the route requires at least one scope and the
+// token was granted all of the required scopes, otherwise it responds with an HTTP status code 403 (Forbidden).
+// All other tokens belong to sessions and are authenticated by [Authenticator.AuthenticateUser]. A session grants
+// access to every route.
+// If the token is valid, it adds the authenticated user and the session or access token to the request context
+// using the ContextUserKey and the ContextSessionKey or ContextAccessTokenKey keys.
 // If the token is invalid or authentication fails, it responds with an HTTP status code 401 (Unauthorized).
 // If the authorization token is missing or malformed, it responds with an HTTP status code 400 (Bad Request).
 // After authentication, the middleware passes the request to the next handler in the chain.
-func AuthenticateUser(next http.HandlerFunc) http.HandlerFunc {
+func AuthenticateUser(auth Authenticator, scopes []models.Scope, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		token, err := preprocessToken(r.Header.Get("Authorization"))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		user, err := db.AuthenticateUser(token)
-		if err != nil {
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-			return
+		var ctx context.Context
+		if strings.HasPrefix(token, db.AccessTokenPrefix) {
+			user, accessToken, err := auth.AuthenticateAccessToken(token)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if !grantsScopes(accessToken, scopes) {
+				http.Error(w, "The access token lacks the scope required for this route.", http.StatusForbidden)
+				return
+			}
+			ctx = context.WithValue(r.Context(), ContextUserKey, user)
+			ctx = context.WithValue(ctx, ContextAccessTokenKey, accessToken)
+		} else {
+			user, session, err := auth.AuthenticateUser(token)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			ctx = context.WithValue(r.Context(), ContextUserKey, user)
+			ctx = context.WithValue(ctx, ContextSessionKey, session)
 		}
-		ctx := context.WithValue(r.Context(), ContextUserKey, user)
 		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
 	})
@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"todo/db"
+	"todo/models"
+)
+
+func TestAuthenticateUser(t *testing.T) {
+	store := db.NewMemoryStore()
+	user := models.User{Name: "john"}
+	user.SetPassword("doe")
+	user, _ = store.CreateUser(user)
+	tokens, _ := store.LoginUser(user, models.Client{})
+	_, readToken, _ := store.CreateAccessToken(user.Id, "read", []models.Scope{models.ScopeTodosRead}, nil)
+
+	var authenticated models.User
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		authenticated, _ = r.Context().Value(ContextUserKey).(models.User)
+	}
+	tests := []struct {
+		name   string
+		header string
+		scopes []models.Scope
+		status int
+	}{
+		{"session", "Bearer " + tokens.AccessToken, nil, http.StatusOK},
+		{"session on scoped route", "Bearer " + tokens.AccessToken, []models.Scope{models.ScopeTodosWrite}, http.StatusOK},
+		{"access token with scope", "Bearer " + readToken, []models.Scope{models.ScopeTodosRead}, http.StatusOK},
+		{"access token without scope", "Bearer " + readToken, []models.Scope{models.ScopeTodosWrite}, http.StatusForbidden},
+		{"access token on session route", "Bearer " + readToken, nil, http.StatusForbidden},
+		{"unknown token", "Bearer unknown", nil, http.StatusUnauthorized},
+		{"unknown access token", "Bearer " + db.AccessTokenPrefix + "unknown", []models.Scope{models.ScopeTodosRead}, http.StatusUnauthorized},
+		{"malformed header", tokens.AccessToken, nil, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		authenticated = models.User{}
+		r := httptest.NewRequest(http.MethodGet, "/todos", nil)
+		r.Header.Set("Authorization", test.header)
+		w := httptest.NewRecorder()
+		AuthenticateUser(store, test.scopes, handler)(w, r)
+		if w.Code != test.status {
+			t.Fatalf("%s: expected status %d but got %d", test.name, test.status, w.Code)
+		}
+		if test.status == http.StatusOK && authenticated.Id != user.Id {
+			t.Fatalf("%s: the user was not added to the request context", test.name)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		user   any
+		status int
+	}{
+		{models.User{Id: 1, Role: models.RoleAdmin}, http.StatusOK},
+		{models.User{Id: 2, Role: models.RoleMember}, http.StatusForbidden},
+		{nil, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		if test.user != nil {
+			r = r.WithContext(context.WithValue(r.Context(), ContextUserKey, test.user))
+		}
+		w := httptest.NewRecorder()
+		RequireRole(models.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {})(w, r)
+		if w.Code != test.status {
+			t.Fatalf("%+v: expected status %d but got %d", test.user, test.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"net/http"
+	"todo/models"
+)
+
+// RequireRole is a middleware that only passes requests to next whose authenticated user has the given role. It has
+// to be wrapped by [AuthenticateUser], which puts the user into the request context. Requests of users with another
+// role are answered with an HTTP status code 403 (Forbidden).
+func RequireRole(role models.Role, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(ContextUserKey).(models.User)
+		if !ok || user.Role != role {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
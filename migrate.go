@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"todo/db"
+)
+
+// runMigrate executes the "migrate up|down [steps]|status" subcommand against the migrations of the store.
+func runMigrate(migrator db.Migrator, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: todo migrate up|down [steps]|status")
+	}
+	switch args[0] {
+	case "up":
+		count, err := migrator.MigrateUp()
+		fmt.Printf("applied %d migration(s)\n", count)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		count, err := migrator.MigrateDown(steps)
+		fmt.Printf("reverted %d migration(s)\n", count)
+		return err
+	case "status":
+		statuses, err := migrator.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
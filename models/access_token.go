@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Scope is a permission granted to a personal [AccessToken].
+type Scope string
+
+const (
+	ScopeTodosRead   Scope = "todos:read"
+	ScopeTodosWrite  Scope = "todos:write"
+	ScopeSharesWrite Scope = "shares:write"
+)
+
+// Scopes contains all scopes an [AccessToken] can be granted.
+var Scopes = []Scope{ScopeTodosRead, ScopeTodosWrite, ScopeSharesWrite}
+
+// AccessToken is a named, long-lived token a user creates for automation. Unlike a [Session] it only grants access to
+// the routes requiring one of its scopes. Only a hash of the token is persisted.
+type AccessToken struct {
+	Id         int        `json:"id"`
+	UserId     int        `json:"userId"`
+	Name       string     `json:"name"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	// ExpiresAt is nil if the token does not expire.
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (token AccessToken) HasScope(scope Scope) bool {
+	return slices.Contains(token.Scopes, scope)
+}
+
+// AccessTokenCreate represents the fields a user provides to create an [AccessToken].
+type AccessTokenCreate struct {
+	Name      *string    `json:"name"`
+	Scopes    []Scope    `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// Validate checks that a name and at least one known scope are given and that the expiry time is in the future.
+func (create AccessTokenCreate) Validate() error {
+	if create.Name == nil || *create.Name == "" {
+		return errors.New("The name of the access token is missing.")
+	}
+	if len(create.Scopes) == 0 {
+		return errors.New("The access token needs at least one scope.")
+	}
+	for _, scope := range create.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("The scope %q is unknown.", scope)
+		}
+	}
+	if create.ExpiresAt != nil && !create.ExpiresAt.After(time.Now()) {
+		return errors.New("The expiry time of the access token is in the past.")
+	}
+	return nil
+}
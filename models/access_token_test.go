@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessTokenCreate_Validate(t *testing.T) {
+	name := "ci"
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		create AccessTokenCreate
+		valid  bool
+	}{
+		{AccessTokenCreate{Name: &name, Scopes: []Scope{ScopeTodosRead}}, true},
+		{AccessTokenCreate{Name: &name, Scopes: []Scope{ScopeTodosWrite, ScopeSharesWrite}, ExpiresAt: &future}, true},
+		{AccessTokenCreate{Scopes: []Scope{ScopeTodosRead}}, false},
+		{AccessTokenCreate{Name: &name}, false},
+		{AccessTokenCreate{Name: &name, Scopes: []Scope{"admin"}}, false},
+		{AccessTokenCreate{Name: &name, Scopes: []Scope{ScopeTodosRead}, ExpiresAt: &past}, false},
+	}
+	for _, test := range tests {
+		if err := test.create.Validate(); (err == nil) != test.valid {
+			t.Fatalf("Validation of %+v returned %v", test.create, err)
+		}
+	}
+}
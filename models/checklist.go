@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// ChecklistItem is a step of a [Todo] that is too small for a subtask. The items of a todo are ordered by their
+// Position, see [PositionBetween].
+type ChecklistItem struct {
+	Id       int    `json:"id"`
+	TodoId   int    `json:"todoId"`
+	Text     string `json:"text"`
+	Checked  bool   `json:"checked"`
+	Position string `json:"position"`
+}
+
+// ChecklistItemUpdate represents the fields a user provides to add or change a [ChecklistItem]. Fields with nil values
+// are not changed.
+type ChecklistItemUpdate struct {
+	Text    *string `json:"text"`
+	Checked *bool   `json:"checked"`
+}
+
+// Update applies the non-nil fields of update to item and validates the result. The text is trimmed.
+func (item *ChecklistItem) Update(update ChecklistItemUpdate) error {
+	if update.Text != nil {
+		item.Text = strings.TrimSpace(*update.Text)
+	}
+	if update.Checked != nil {
+		item.Checked = *update.Checked
+	}
+	if item.Text == "" {
+		return errors.New("The text of the checklist item is missing.")
+	}
+	return nil
+}
+
+// ChecklistProgress counts the checked items of the checklist of a [Todo]. A progress of 3/5 is encoded in JSON as
+// {"checked": 3, "total": 5}, so that clients don't have to parse it.
+type ChecklistProgress struct {
+	Checked int `json:"checked"`
+	Total   int `json:"total"`
+}
+
+// NewChecklistProgress returns the progress of the checklist with items.
+func NewChecklistProgress(items []ChecklistItem) ChecklistProgress {
+	progress := ChecklistProgress{Total: len(items)}
+	for _, item := range items {
+		if item.Checked {
+			progress.Checked++
+		}
+	}
+	return progress
+}
+
+// Complete reports whether the checklist has items and all of them are checked.
+func (p ChecklistProgress) Complete() bool {
+	return p.Total > 0 && p.Checked == p.Total
+}
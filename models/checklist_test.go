@@ -0,0 +1,32 @@
+package models
+
+import "testing"
+
+func TestChecklistItem_Update(t *testing.T) {
+	item := ChecklistItem{Text: "passport"}
+	text, checked := " tickets ", true
+	if err := item.Update(ChecklistItemUpdate{Text: &text, Checked: &checked}); err != nil {
+		t.Fatalf("Updating the item failed: %v", err)
+	}
+	if item.Text != "tickets" || !item.Checked {
+		t.Fatalf("Unexpected item %+v", item)
+	}
+	text = " "
+	if err := item.Update(ChecklistItemUpdate{Text: &text}); err == nil {
+		t.Fatalf("An item without text is valid")
+	}
+}
+
+func TestChecklistProgress_Complete(t *testing.T) {
+	items := []ChecklistItem{{Checked: true}, {Checked: false}}
+	if progress := NewChecklistProgress(items); progress != (ChecklistProgress{Checked: 1, Total: 2}) || progress.Complete() {
+		t.Fatalf("Unexpected progress %+v", progress)
+	}
+	items[1].Checked = true
+	if progress := NewChecklistProgress(items); !progress.Complete() {
+		t.Fatalf("The checked checklist %+v is not complete", progress)
+	}
+	if (ChecklistProgress{}).Complete() {
+		t.Fatalf("An empty checklist is complete")
+	}
+}
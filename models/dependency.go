@@ -0,0 +1,48 @@
+package models
+
+import "errors"
+
+// Dependency makes the todo TodoId wait until the todo BlockerId is done.
+type Dependency struct {
+	TodoId    int `json:"todoId"`
+	BlockerId int `json:"blockerId"`
+}
+
+// ErrDependencyChange is returned by [DependencyChange.Validate] unless a change names exactly one other todo.
+var ErrDependencyChange = errors.New("a dependency needs either the id of the todo that blocks this one or the one it blocks")
+
+// DependencyChange adds or removes a [Dependency] of a todo on the todo it is BlockedBy or to the todo it Blocks.
+type DependencyChange struct {
+	Blocks    *int `json:"blocks"`
+	BlockedBy *int `json:"blockedBy"`
+}
+
+// Validate checks that the change of the dependencies of the todo todoId names exactly one other todo.
+func (change DependencyChange) Validate(todoId int) error {
+	if (change.Blocks == nil) == (change.BlockedBy == nil) || change.OtherId() == todoId {
+		return ErrDependencyChange
+	}
+	return nil
+}
+
+// OtherId returns the id of the todo the change is relative to.
+func (change DependencyChange) OtherId() int {
+	if change.Blocks != nil {
+		return *change.Blocks
+	}
+	return *change.BlockedBy
+}
+
+// Dependency returns the dependency the change of the todo todoId is about.
+func (change DependencyChange) Dependency(todoId int) Dependency {
+	if change.Blocks != nil {
+		return Dependency{TodoId: *change.Blocks, BlockerId: todoId}
+	}
+	return Dependency{TodoId: todoId, BlockerId: *change.BlockedBy}
+}
+
+// Dependencies are the todos a todo is blocked by and the todos it blocks.
+type Dependencies struct {
+	BlockedBy []Todo `json:"blockedBy"`
+	Blocks    []Todo `json:"blocks"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// ExternalIdentity is a user account at an external identity provider, for example the subject of an OpenID Connect
+// ID token. The pair of Issuer and Subject identifies the account, Name is the user name suggested by the provider.
+type ExternalIdentity struct {
+	Issuer  string
+	Subject string
+	Name    string
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// List is a named project of a user that groups todos. A todo belongs to at most one list, and sharing a list with
+// another user shares all of its todos.
+type List struct {
+	Id     int    `json:"id"`
+	UserId int    `json:"userId"`
+	Name   string `json:"name"`
+	// Workflow restricts how the statuses of the todos in the list can change, all changes are allowed if it is nil.
+	Workflow Workflow `json:"workflow"`
+}
+
+// ListUpdate represents the fields a user provides to create or change a [List]. Fields with nil values are not
+// changed.
+type ListUpdate struct {
+	Name *string `json:"name"`
+	// Workflow replaces the workflow of the list or removes it if it is null.
+	Workflow NullableWorkflow `json:"workflow"`
+}
+
+// Update applies the non-nil fields of update to list and validates the result. Names are trimmed.
+func (list *List) Update(update ListUpdate) error {
+	if update.Name != nil {
+		list.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Workflow.Set {
+		list.Workflow = update.Workflow.Workflow
+	}
+	if list.Name == "" {
+		return errors.New("The name of the list is missing.")
+	}
+	if list.Workflow != nil {
+		return list.Workflow.Validate()
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// LoginFailures counts the recent failed logins for a user name or from a client IP address.
+type LoginFailures struct {
+	Count         int
+	LastFailureAt time.Time
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// positionDigits are the digits of positions in ascending order, so that positions sort like strings, byte by byte.
+const positionDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// ErrNoPositionBetween is returned by [PositionBetween] if the bounds are not in ascending order.
+var ErrNoPositionBetween = errors.New("there is no position between the bounds")
+
+// minStepDigits is the number of digits positions are stepped at when a todo is added at the start or the end, which
+// leaves room for billions of todos before positions have to get longer.
+const minStepDigits = 6
+
+// PositionBetween returns a position that sorts after a and before b, where an empty a is before all positions and an
+// empty b after all of them. Positions are fractions in base 36 written without the leading "0.", so there is always
+// room for another position between two others and a todo can be moved without renumbering any other todo. Positions
+// never end with the digit 0, which would leave no room before them.
+func PositionBetween(a string, b string) (string, error) {
+	if b != "" && a >= b {
+		return "", ErrNoPositionBetween
+	}
+	if strings.Trim(a+b, positionDigits) != "" || strings.HasSuffix(a, "0") || strings.HasSuffix(b, "0") {
+		return "", ErrNoPositionBetween
+	}
+	// adding todos at the start or the end steps by the last digit instead of halving, so positions stay short
+	if a != "" && b == "" {
+		if position, ok := step(a, 1); ok {
+			return position, nil
+		}
+	}
+	if a == "" && b != "" {
+		if position, ok := step(b, -1); ok {
+			return position, nil
+		}
+	}
+	return midpoint(a, b), nil
+}
+
+// Positions returns n new positions in ascending order that differ from all positions in taken. They renumber todos or
+// checklist items that have no room left between them, without colliding with the positions they had before.
+func Positions(n int, taken []string) []string {
+	positions := make([]string, 0, n)
+	last := ""
+	for range n {
+		position, _ := PositionBetween(last, "")
+		for slices.Contains(taken, position) {
+			position, _ = PositionBetween(position, "")
+		}
+		positions = append(positions, position)
+		last = position
+	}
+	return positions
+}
+
+// step adds delta, 1 or -1, to the last of at least minStepDigits digits of position. It returns false if the result
+// is not a position between 0 and 1.
+func step(position string, delta int) (string, bool) {
+	digits := []byte(position)
+	for len(digits) < minStepDigits {
+		digits = append(digits, positionDigits[0])
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := strings.IndexByte(positionDigits, digits[i]) + delta
+		if digit >= 0 && digit < len(positionDigits) {
+			digits[i] = positionDigits[digit]
+			position = strings.TrimRight(string(digits), positionDigits[:1])
+			return position, position != ""
+		}
+		// carry or borrow
+		digits[i] = positionDigits[(digit+len(positionDigits))%len(positionDigits)]
+	}
+	return "", false
+}
+
+// midpoint returns a position between the valid positions a < b, with an empty b after all positions.
+func midpoint(a string, b string) string {
+	if b != "" {
+		// keep the common prefix, the missing digits of a are zeros
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(a[min(n, len(a)):], b[n:])
+		}
+	}
+	low := strings.IndexByte(positionDigits, digitAt(a, 0))
+	high := len(positionDigits)
+	if b != "" {
+		high = strings.IndexByte(positionDigits, b[0])
+	}
+	if high-low > 1 {
+		return string(positionDigits[(low+high)/2])
+	}
+	// the first digits are adjacent: b without its other digits is between them unless it is just one digit
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 0 {
+		rest = a[1:]
+	}
+	return string(positionDigits[low]) + midpoint(rest, "")
+}
+
+// digitAt returns the digit of position at index i, 0 past its end.
+func digitAt(position string, i int) byte {
+	if i < len(position) {
+		return position[i]
+	}
+	return positionDigits[0]
+}
+
+// ErrMove is returned by [Move.Validate] unless a move names exactly one other todo or checklist item.
+var ErrMove = errors.New("a move needs either the id to move before or the id to move after")
+
+// Move moves a [Todo] or a [ChecklistItem] in its manual order to right before or right after another one.
+type Move struct {
+	Before *int `json:"before"`
+	After  *int `json:"after"`
+}
+
+// Validate checks that the move of the todo or checklist item with id id names exactly one other one.
+func (move Move) Validate(id int) error {
+	if (move.Before == nil) == (move.After == nil) || move.TargetId() == id {
+		return ErrMove
+	}
+	return nil
+}
+
+// TargetId returns the id of the todo or checklist item the move is relative to.
+func (move Move) TargetId() int {
+	if move.Before != nil {
+		return *move.Before
+	}
+	return *move.After
+}
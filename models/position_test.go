@@ -0,0 +1,84 @@
+package models
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPositionBetween(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{"", "", "i"},
+		{"i", "", "i00001"},
+		{"", "i", "hzzzzz"},
+		{"i00001", "i00002", "i00001i"},
+		{"0000000042i", "", "0000000042j"},
+		{"i0000z", "", "i0001"},
+		{"zzzzzz", "", "zzzzzzi"},
+		{"", "000001", "000000i"},
+		{"a", "b", "ai"},
+		{"a", "az", "ah"},
+		{"a1", "b", "ai"},
+	}
+	for _, test := range tests {
+		got, err := PositionBetween(test.a, test.b)
+		if err != nil || got != test.want {
+			t.Errorf("PositionBetween(%q, %q) = %q (%v), want %q", test.a, test.b, got, err, test.want)
+		}
+	}
+	for _, bounds := range [][2]string{{"b", "a"}, {"a", "a"}, {"a0", ""}, {"", "A"}} {
+		if _, err := PositionBetween(bounds[0], bounds[1]); err == nil {
+			t.Errorf("PositionBetween(%q, %q) succeeded", bounds[0], bounds[1])
+		}
+	}
+
+	// inserting at random places keeps the positions ordered and short
+	random := rand.New(rand.NewSource(1))
+	var positions []string
+	for range 2000 {
+		i := random.Intn(len(positions) + 1)
+		var a, b string
+		if i > 0 {
+			a = positions[i-1]
+		}
+		if i < len(positions) {
+			b = positions[i]
+		}
+		position, err := PositionBetween(a, b)
+		if err != nil || position <= a || (b != "" && position >= b) || strings.HasSuffix(position, "0") {
+			t.Fatalf("PositionBetween(%q, %q) = %q (%v)", a, b, position, err)
+		}
+		positions = slices.Insert(positions, i, position)
+	}
+	for range 2000 {
+		position, _ := PositionBetween(positions[len(positions)-1], "")
+		positions = append(positions, position)
+	}
+	if !slices.IsSorted(positions) {
+		t.Fatalf("The positions are not sorted")
+	}
+	for _, position := range positions {
+		if len(position) > 12 {
+			t.Fatalf("The position %q got long", position)
+		}
+	}
+}
+
+func TestPositions(t *testing.T) {
+	taken := []string{"i00001", "i00001", "0", "i00003"}
+	positions := Positions(4, taken)
+	if len(positions) != 4 {
+		t.Fatalf("Unexpected positions %q", positions)
+	}
+	for i, position := range positions {
+		if i > 0 && position <= positions[i-1] {
+			t.Fatalf("The positions are not ascending: %q", positions)
+		}
+		if slices.Contains(taken, position) {
+			t.Fatalf("The position %q was taken", position)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Priority is how important a [Todo] is. Priorities are ordered from [PriorityNone] to [PriorityUrgent] and are
+// written as their names in JSON.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+// ParsePriority returns the priority called name.
+func ParsePriority(name string) (Priority, error) {
+	for i, priorityName := range priorityNames {
+		if name == priorityName {
+			return Priority(i), nil
+		}
+	}
+	return PriorityNone, fmt.Errorf("unknown priority %q, it has to be none, low, medium, high or urgent", name)
+}
+
+func (p Priority) String() string {
+	if p < PriorityNone || p > PriorityUrgent {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+// MarshalJSON writes the name of the priority.
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON reads the name of a priority.
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	priority, err := ParsePriority(name)
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
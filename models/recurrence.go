@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+	"todo/recurrence"
+)
+
+// Occurrence is a future occurrence of a recurring [Todo].
+type Occurrence struct {
+	StartAt *time.Time `json:"startAt"`
+	DueAt   time.Time  `json:"dueAt"`
+}
+
+// Advance moves a recurring todo to its next occurrence after completing it: it is open again, due at the next
+// occurrence of its recurrence rule and starts the same time before it as before. A COUNT of the rule is decremented.
+// Timed todos repeat in the timezone loc, so that they keep their local time across changes of daylight saving time,
+// all-day todos repeat by their dates. Advance returns false and leaves the todo unchanged if it does not repeat or
+// its series has ended.
+func (todo *Todo) Advance(loc *time.Location) bool {
+	if todo.Recurrence == "" || todo.DueAt == nil {
+		return false
+	}
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		return false
+	}
+	if todo.AllDay {
+		loc = time.UTC
+	}
+	next, rest, ok := rule.Next(todo.DueAt.In(loc))
+	if !ok {
+		return false
+	}
+	next = next.UTC()
+	if todo.StartAt != nil {
+		start := next.Add(todo.StartAt.Sub(*todo.DueAt))
+		todo.StartAt = &start
+	}
+	todo.DueAt = &next
+	todo.SetStatus(StatusOpen, time.Time{})
+	todo.Recurrence = rest.String()
+	return true
+}
+
+// Occurrences returns up to n occurrences of a recurring todo after the current one, which are the dates the todo
+// takes when it is completed repeatedly with [Todo.Advance].
+func (todo *Todo) Occurrences(loc *time.Location, n int) []Occurrence {
+	next := *todo
+	occurrences := []Occurrence{}
+	for len(occurrences) < n && next.Advance(loc) {
+		occurrences = append(occurrences, Occurrence{StartAt: next.StartAt, DueAt: *next.DueAt})
+	}
+	return occurrences
+}
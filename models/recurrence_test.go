@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTodo_Advance(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 9:00 in Berlin on the Saturday before daylight saving time ends
+	start := time.Date(2024, 10, 26, 6, 0, 0, 0, time.UTC)
+	due := time.Date(2024, 10, 26, 7, 0, 0, 0, time.UTC)
+	todo := Todo{IsDone: true, StartAt: &start, DueAt: &due, Recurrence: "FREQ=WEEKLY;COUNT=2"}
+	if !todo.Advance(berlin) {
+		t.Fatalf("The todo was not advanced")
+	}
+	if todo.IsDone || !todo.DueAt.Equal(time.Date(2024, 11, 2, 8, 0, 0, 0, time.UTC)) ||
+		!todo.StartAt.Equal(time.Date(2024, 11, 2, 7, 0, 0, 0, time.UTC)) || todo.Recurrence != "FREQ=WEEKLY;COUNT=1" {
+		t.Fatalf("Unexpected advanced todo %+v", todo)
+	}
+	todo.IsDone = true
+	if todo.Advance(berlin) || !todo.IsDone {
+		t.Fatalf("The todo was advanced after its last occurrence")
+	}
+
+	allDay := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	todo = Todo{DueAt: &allDay, AllDay: true, Recurrence: "FREQ=MONTHLY;BYMONTHDAY=-1"}
+	occurrences := todo.Occurrences(berlin, 3)
+	if len(occurrences) != 3 || occurrences[0].DueAt != time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC) ||
+		occurrences[2].DueAt != time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC) || occurrences[0].StartAt != nil {
+		t.Fatalf("Unexpected occurrences %v", occurrences)
+	}
+	if *todo.DueAt != allDay {
+		t.Fatalf("Listing the occurrences changed the todo")
+	}
+	if occurrences := (&Todo{DueAt: &allDay}).Occurrences(berlin, 3); len(occurrences) != 0 {
+		t.Fatalf("A todo without recurrence has occurrences %v", occurrences)
+	}
+}
+
+func TestTodo_Validate_Recurrence(t *testing.T) {
+	due := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := (&Todo{Recurrence: "FREQ=DAILY"}).Validate(); !errors.Is(err, ErrRecurrenceWithoutDue) {
+		t.Fatalf("Expected %v but got %v", ErrRecurrenceWithoutDue, err)
+	}
+	if err := (&Todo{DueAt: &due, Recurrence: "FREQ=HOURLY"}).Validate(); err == nil {
+		t.Fatalf("An invalid recurrence was accepted")
+	}
+	if err := (&Todo{DueAt: &due, Recurrence: "FREQ=DAILY;INTERVAL=3"}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
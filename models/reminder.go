@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Reminder tells the owner of a todo about it at a time, either at a fixed time or some minutes before the todo is
+// due. A reminder is sent once, snoozing it schedules it again.
+type Reminder struct {
+	Id     int `json:"id"`
+	TodoId int `json:"todoId"`
+	// At is the time of a reminder at a fixed time, nil if the reminder is relative to the due date.
+	At *time.Time `json:"at"`
+	// BeforeMinutes is how many minutes before the due date of the todo a relative reminder is sent, nil if the
+	// reminder is at a fixed time.
+	BeforeMinutes *int `json:"beforeMinutes"`
+	// RemindAt is when the reminder is sent next, computed by [Reminder.Schedule] and moved by [Reminder.Snooze].
+	RemindAt time.Time `json:"remindAt"`
+	// SentAt is when the reminder was sent, nil if it is still pending.
+	SentAt *time.Time `json:"sentAt"`
+}
+
+// ErrReminderTime is returned by [Reminder.Schedule] unless a reminder has either a time or minutes before the due date.
+var ErrReminderTime = errors.New("a reminder needs either a time or a non-negative number of minutes before the due date")
+
+// ErrReminderWithoutDue is returned by [Reminder.Schedule] for reminders relative to the due date of a todo without one.
+var ErrReminderWithoutDue = errors.New("the todo has no due date to remind before")
+
+// Schedule computes when the reminder for todo is sent and makes it pending again. Relative reminders are sent before
+// the todo is due in the timezone loc, see [Todo.Due].
+func (r *Reminder) Schedule(todo Todo, loc *time.Location) error {
+	if (r.At == nil) == (r.BeforeMinutes == nil) || (r.BeforeMinutes != nil && *r.BeforeMinutes < 0) {
+		return ErrReminderTime
+	}
+	r.TodoId = todo.Id
+	r.SentAt = nil
+	if r.At != nil {
+		at := r.At.UTC().Truncate(time.Second)
+		r.At, r.RemindAt = &at, at
+		return nil
+	}
+	due, ok := todo.Due(loc)
+	if !ok {
+		return ErrReminderWithoutDue
+	}
+	r.RemindAt = due.Add(-time.Duration(*r.BeforeMinutes) * time.Minute).UTC()
+	return nil
+}
+
+// Snooze makes the reminder pending again to be sent at until.
+func (r *Reminder) Snooze(until time.Time) {
+	r.RemindAt = until.UTC().Truncate(time.Second)
+	r.SentAt = nil
+}
+
+// ReminderSnooze is the request to snooze a [Reminder], either for some minutes or until a time.
+type ReminderSnooze struct {
+	Minutes *int       `json:"minutes"`
+	Until   *time.Time `json:"until"`
+}
+
+// ErrSnoozeTime is returned by [ReminderSnooze.Time] unless a snooze has either positive minutes or a future time.
+var ErrSnoozeTime = errors.New("snoozing needs either a positive number of minutes or a time in the future")
+
+// Time returns when a reminder snoozed at now is sent again.
+func (s ReminderSnooze) Time(now time.Time) (time.Time, error) {
+	switch {
+	case s.Minutes != nil && s.Until == nil && *s.Minutes > 0:
+		return now.Add(time.Duration(*s.Minutes) * time.Minute), nil
+	case s.Until != nil && s.Minutes == nil && s.Until.After(now):
+		return *s.Until, nil
+	}
+	return time.Time{}, ErrSnoozeTime
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReminder_Schedule(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	minutes := 60
+	// an all-day todo is due at the end of its date in Tokyo, 15:00 UTC
+	reminder := Reminder{BeforeMinutes: &minutes}
+	if err := reminder.Schedule(Todo{Id: 3, DueAt: &date, AllDay: true}, tokyo); err != nil {
+		t.Fatal(err)
+	}
+	if reminder.TodoId != 3 || reminder.RemindAt != time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC) {
+		t.Fatalf("Unexpected reminder %+v", reminder)
+	}
+	if err := reminder.Schedule(Todo{}, tokyo); !errors.Is(err, ErrReminderWithoutDue) {
+		t.Fatalf("Expected %v but got %v", ErrReminderWithoutDue, err)
+	}
+	if err := (&Reminder{}).Schedule(Todo{DueAt: &date}, tokyo); !errors.Is(err, ErrReminderTime) {
+		t.Fatalf("Expected %v but got %v", ErrReminderTime, err)
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if until, err := (ReminderSnooze{Minutes: &minutes}).Time(now); err != nil || until != now.Add(time.Hour) {
+		t.Fatalf("Unexpected snooze until %v (%v)", until, err)
+	}
+	if _, err := (ReminderSnooze{Until: &date}).Time(now); !errors.Is(err, ErrSnoozeTime) {
+		t.Fatalf("Snoozing into the past was accepted: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Session represents a login of a user on one device. Only a hash of the session's token is persisted, the token
+// itself is only known to the client.
+type Session struct {
+	Id         int       `json:"id"`
+	UserId     int       `json:"userId"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	// ExpiresAt is the time the current access token of the session expires.
+	ExpiresAt time.Time `json:"expiresAt"`
+	// RefreshExpiresAt is the time the session ends unless it is refreshed before.
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+	UserAgent        string    `json:"userAgent"`
+	IP               string    `json:"ip"`
+	// Current is set if the session is the one used for the request that returned it.
+	Current bool `json:"current"`
+}
+
+// Client describes the device a session is created for.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionTokens are the credentials issued for a session. The short-lived access token authenticates requests, the
+// long-lived refresh token is exchanged for new tokens once the access token expired.
+type SessionTokens struct {
+	SessionId        int
+	User             User
+	AccessToken      string
+	ExpiresAt        time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+	// Challenge is set instead of the other tokens if the user has to complete the login with a second factor. It
+	// expires at ChallengeExpiresAt.
+	Challenge          string
+	ChallengeExpiresAt time.Time
+}
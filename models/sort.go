@@ -0,0 +1,91 @@
+package models
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TodoSort is a key todos are sorted by.
+type TodoSort struct {
+	// Key is "priority", "dueAt", "startAt", "position" or "id".
+	Key string
+	// Desc sorts in descending order.
+	Desc bool
+}
+
+var todoSortKeys = []string{"priority", "dueAt", "startAt", "position", "id"}
+
+// DefaultTodoSort is the manual order of todos, in which they are returned unless another order is asked for.
+var DefaultTodoSort = []TodoSort{{Key: "position"}}
+
+// ParseTodoSort parses a comma-separated list of sort keys like "priority,-dueAt,position", where a leading "-" sorts
+// in descending order.
+func ParseTodoSort(s string) ([]TodoSort, error) {
+	var sorts []TodoSort
+	for _, item := range strings.Split(s, ",") {
+		sort := TodoSort{Key: strings.TrimPrefix(item, "-"), Desc: strings.HasPrefix(item, "-")}
+		valid := false
+		for _, key := range todoSortKeys {
+			valid = valid || key == sort.Key
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown sort key %q, it has to be one of %s", item, strings.Join(todoSortKeys, ", "))
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
+
+// CompareTodos compares a and b by sorts and then by id, like [cmp.Compare]. Todos without the date a key sorts by come
+// last in both directions.
+func CompareTodos(a Todo, b Todo, sorts []TodoSort) int {
+	for _, sort := range sorts {
+		var c int
+		switch sort.Key {
+		case "priority":
+			c = cmp.Compare(a.Priority, b.Priority)
+		case "dueAt", "startAt":
+			at, bt := a.DueAt, b.DueAt
+			if sort.Key == "startAt" {
+				at, bt = a.StartAt, b.StartAt
+			}
+			if missing := compareMissing(at, bt); missing != 0 {
+				return missing
+			}
+			c = compareTimes(at, bt)
+		case "position":
+			c = strings.Compare(a.Position, b.Position)
+		case "id":
+			c = cmp.Compare(a.Id, b.Id)
+		}
+		if sort.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(a.Id, b.Id)
+}
+
+// compareMissing orders a missing time after a present one.
+func compareMissing(a *time.Time, b *time.Time) int {
+	return cmp.Compare(btoi(a == nil), btoi(b == nil))
+}
+
+// compareTimes compares two times that are both present or both missing.
+func compareTimes(a *time.Time, b *time.Time) int {
+	if a == nil {
+		return 0
+	}
+	return a.Compare(*b)
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseTodoSort(t *testing.T) {
+	sorts, err := ParseTodoSort("priority,-dueAt,position")
+	want := []TodoSort{{Key: "priority"}, {Key: "dueAt", Desc: true}, {Key: "position"}}
+	if err != nil || !slices.Equal(sorts, want) {
+		t.Fatalf("ParseTodoSort returned %+v (%v)", sorts, err)
+	}
+	for _, s := range []string{"", "title", "priority,", "--id"} {
+		if _, err := ParseTodoSort(s); err == nil {
+			t.Errorf("ParseTodoSort(%q) succeeded", s)
+		}
+	}
+}
+
+func TestCompareTodos(t *testing.T) {
+	due := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	later := due.Add(time.Hour)
+	todos := []Todo{
+		{Id: 1, Priority: PriorityHigh, Position: "b"},
+		{Id: 2, DueAt: &later, Position: "c"},
+		{Id: 3, Priority: PriorityHigh, DueAt: &due, Position: "a"},
+		{Id: 4, Position: "a"},
+	}
+	for sort, want := range map[string][]int{
+		"position":        {3, 4, 1, 2},
+		"-priority,dueAt": {3, 1, 2, 4},
+		"dueAt":           {3, 2, 1, 4},
+		"-dueAt":          {2, 3, 1, 4},
+		"-id":             {4, 3, 2, 1},
+	} {
+		sorts, _ := ParseTodoSort(sort)
+		slices.SortFunc(todos, func(a, b Todo) int { return CompareTodos(a, b, sorts) })
+		var got []int
+		for _, todo := range todos {
+			got = append(got, todo.Id)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Sorting by %q returned %v, want %v", sort, got, want)
+		}
+	}
+}
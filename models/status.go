@@ -0,0 +1,123 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Status is the state of the work on a [Todo]. [StatusDone] and [StatusCancelled] are terminal, a todo in one of them
+// counts as done.
+type Status string
+
+const (
+	StatusOpen       Status = "open"
+	StatusInProgress Status = "inProgress"
+	StatusBlocked    Status = "blocked"
+	StatusDone       Status = "done"
+	StatusCancelled  Status = "cancelled"
+)
+
+// Statuses are all statuses in the order work usually moves through them.
+var Statuses = []Status{StatusOpen, StatusInProgress, StatusBlocked, StatusDone, StatusCancelled}
+
+// ParseStatus returns the [Status] called name.
+func ParseStatus(name string) (Status, error) {
+	if status := Status(name); slices.Contains(Statuses, status) {
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown status %q, it has to be open, inProgress, blocked, done or cancelled", name)
+}
+
+// UnmarshalText reads the name of a status, also as the key of a [Workflow] in JSON.
+func (s *Status) UnmarshalText(text []byte) error {
+	status, err := ParseStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// IsTerminal reports whether the work on a todo with the status is finished.
+func (s Status) IsTerminal() bool {
+	return s == StatusDone || s == StatusCancelled
+}
+
+// ErrStatusTransition is returned by [Workflow.CheckTransition] if a todo cannot change from one status to another.
+var ErrStatusTransition = errors.New("illegal status transition")
+
+// Workflow maps each status to the statuses a todo in a [List] can change to from it. A status without an entry can
+// not be left. A nil workflow allows all transitions.
+type Workflow map[Status][]Status
+
+// Validate checks that the workflow has transitions and only uses known statuses.
+func (w Workflow) Validate() error {
+	if len(w) == 0 {
+		return errors.New("a workflow needs transitions, remove it with null to allow all of them")
+	}
+	for from, to := range w {
+		for _, status := range append([]Status{from}, to...) {
+			if _, err := ParseStatus(string(status)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CheckTransition returns [ErrStatusTransition] unless a todo can change from the status from to the status to.
+// Keeping the status is always allowed.
+func (w Workflow) CheckTransition(from Status, to Status) error {
+	if w == nil || from == to || slices.Contains(w[from], to) {
+		return nil
+	}
+	allowed := "no other status"
+	if len(w[from]) > 0 {
+		allowed = fmt.Sprint(w[from])
+	}
+	return fmt.Errorf("%w: a todo in the status %s cannot change to %s, only to %s", ErrStatusTransition, from, to, allowed)
+}
+
+// NullableWorkflow is an optional workflow of an update that can be removed by setting it to null.
+type NullableWorkflow struct {
+	// Set is true if the field was given, Workflow is nil if it was null.
+	Set      bool
+	Workflow Workflow
+}
+
+// UnmarshalJSON sets the workflow, which is only called if the field is present.
+func (w *NullableWorkflow) UnmarshalJSON(data []byte) error {
+	w.Set = true
+	return json.Unmarshal(data, &w.Workflow)
+}
+
+// NormalizeStatus derives the status of todo from IsDone if it has none or if IsDone does not match it, like for
+// clients that only know about IsDone. A done todo becomes [StatusDone] and one that is not done [StatusOpen], which
+// has no CompletedAt.
+func (todo *Todo) NormalizeStatus() {
+	if todo.Status != "" && todo.IsDone == todo.Status.IsTerminal() {
+		return
+	}
+	todo.Status = StatusOpen
+	if todo.IsDone {
+		todo.Status = StatusDone
+	} else {
+		todo.CompletedAt = nil
+	}
+}
+
+// SetStatus changes the status of todo and keeps IsDone and CompletedAt in line with it. CompletedAt is set to now
+// when the todo enters a terminal status and removed when it is no longer in one.
+func (todo *Todo) SetStatus(status Status, now time.Time) {
+	if !status.IsTerminal() {
+		todo.CompletedAt = nil
+	} else if status != todo.Status || todo.CompletedAt == nil {
+		completedAt := now.UTC().Truncate(time.Second)
+		todo.CompletedAt = &completedAt
+	}
+	todo.Status = status
+	todo.IsDone = status.IsTerminal()
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkflow_CheckTransition(t *testing.T) {
+	var workflow Workflow
+	if err := json.Unmarshal([]byte(`{"open": ["inProgress", "cancelled"], "inProgress": ["blocked", "done"], "blocked": ["inProgress"]}`), &workflow); err != nil {
+		t.Fatal(err)
+	}
+	if err := workflow.Validate(); err != nil {
+		t.Fatalf("The workflow is invalid: %v", err)
+	}
+	for _, transition := range [][2]Status{{StatusOpen, StatusInProgress}, {StatusBlocked, StatusInProgress}, {StatusDone, StatusDone}} {
+		if err := workflow.CheckTransition(transition[0], transition[1]); err != nil {
+			t.Errorf("The transition %v was rejected: %v", transition, err)
+		}
+	}
+	for _, transition := range [][2]Status{{StatusOpen, StatusDone}, {StatusDone, StatusOpen}} {
+		if err := workflow.CheckTransition(transition[0], transition[1]); !errors.Is(err, ErrStatusTransition) {
+			t.Errorf("The transition %v was not rejected: %v", transition, err)
+		}
+	}
+	if err := Workflow(nil).CheckTransition(StatusDone, StatusOpen); err != nil {
+		t.Fatalf("The default workflow rejected a transition: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"open": ["finished"]}`), &workflow); err == nil {
+		t.Fatalf("A workflow with an unknown status was accepted")
+	}
+	if err := (Workflow{}).Validate(); err == nil {
+		t.Fatalf("An empty workflow is valid")
+	}
+}
+
+func TestTodo_SetStatus(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	todo := Todo{IsDone: true}
+	todo.NormalizeStatus()
+	if todo.Status != StatusDone {
+		t.Fatalf("A done todo without status got the status %s", todo.Status)
+	}
+	todo.SetStatus(StatusInProgress, now)
+	if todo.IsDone || todo.CompletedAt != nil {
+		t.Fatalf("A todo in progress is done: %+v", todo)
+	}
+	todo.SetStatus(StatusCancelled, now)
+	if !todo.IsDone || todo.CompletedAt == nil || !todo.CompletedAt.Equal(now) {
+		t.Fatalf("The cancelled todo was not completed: %+v", todo)
+	}
+	todo.SetStatus(StatusCancelled, now.Add(time.Hour))
+	if !todo.CompletedAt.Equal(now) {
+		t.Fatalf("Keeping the status changed when the todo was completed: %v", todo.CompletedAt)
+	}
+	// a client that only knows isDone reopens the todo
+	todo.IsDone = false
+	todo.NormalizeStatus()
+	if todo.Status != StatusOpen || todo.CompletedAt != nil {
+		t.Fatalf("The reopened todo got the status %s and completion %v", todo.Status, todo.CompletedAt)
+	}
+}
@@ -0,0 +1,59 @@
+package models
+
+import "fmt"
+
+// SubtaskPolicy decides what happens to the subtasks of a todo that is completed or deleted.
+type SubtaskPolicy string
+
+const (
+	// SubtasksCascade completes the open subtasks of a completed todo and deletes the subtasks of a deleted todo,
+	// including their own subtasks.
+	SubtasksCascade SubtaskPolicy = "cascade"
+	// SubtasksOrphan turns the open subtasks of a completed todo and the subtasks of a deleted todo into top-level
+	// todos.
+	SubtasksOrphan SubtaskPolicy = "orphan"
+	// SubtasksBlock refuses to complete a todo with open subtasks and to delete a todo with subtasks.
+	SubtasksBlock SubtaskPolicy = "block"
+)
+
+// ParseSubtaskPolicy returns the [SubtaskPolicy] called name.
+func ParseSubtaskPolicy(name string) (SubtaskPolicy, error) {
+	switch policy := SubtaskPolicy(name); policy {
+	case SubtasksCascade, SubtasksOrphan, SubtasksBlock:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown subtask policy %q, use cascade, orphan or block", name)
+}
+
+// TodoTree is a todo together with its subtasks, which are trees themselves.
+type TodoTree struct {
+	Todo
+	Children []TodoTree `json:"children"`
+}
+
+// NewTodoTrees nests todos below their parents keeping their order. Todos whose parent is not among todos become
+// roots.
+func NewTodoTrees(todos []Todo) []TodoTree {
+	children := map[int][]Todo{}
+	ids := map[int]bool{}
+	for _, todo := range todos {
+		ids[todo.Id] = true
+	}
+	var roots []Todo
+	for _, todo := range todos {
+		if todo.ParentId != nil && ids[*todo.ParentId] && *todo.ParentId != todo.Id {
+			children[*todo.ParentId] = append(children[*todo.ParentId], todo)
+		} else {
+			roots = append(roots, todo)
+		}
+	}
+	var grow func(todos []Todo) []TodoTree
+	grow = func(todos []Todo) []TodoTree {
+		trees := make([]TodoTree, len(todos))
+		for i, todo := range todos {
+			trees[i] = TodoTree{Todo: todo, Children: grow(children[todo.Id])}
+		}
+		return trees
+	}
+	return grow(roots)
+}
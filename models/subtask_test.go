@@ -0,0 +1,48 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewTodoTrees(t *testing.T) {
+	one, two, five := 1, 2, 5
+	todos := []Todo{
+		{Id: 1},
+		{Id: 2, ParentId: &one},
+		{Id: 3, ParentId: &two},
+		{Id: 4, ParentId: &one},
+		// the parent of a subtask that was filtered out
+		{Id: 6, ParentId: &five},
+	}
+	trees := NewTodoTrees(todos)
+	var flatten func(trees []TodoTree) []int
+	flatten = func(trees []TodoTree) []int {
+		var ids []int
+		for _, tree := range trees {
+			ids = append(ids, tree.Id)
+			ids = append(ids, flatten(tree.Children)...)
+		}
+		return ids
+	}
+	if len(trees) != 2 || trees[0].Id != 1 || trees[1].Id != 6 {
+		t.Fatalf("Unexpected roots %+v", trees)
+	}
+	if got := flatten(trees[0].Children); !slices.Equal(got, []int{2, 3, 4}) {
+		t.Fatalf("Unexpected subtasks %v", got)
+	}
+	if trees[1].Children == nil {
+		t.Fatalf("A todo without subtasks has nil children, which is encoded as null")
+	}
+}
+
+func TestParseSubtaskPolicy(t *testing.T) {
+	for _, name := range []string{"cascade", "orphan", "block"} {
+		if policy, err := ParseSubtaskPolicy(name); err != nil || string(policy) != name {
+			t.Errorf("Parsing %q returned %q (%v)", name, policy, err)
+		}
+	}
+	if _, err := ParseSubtaskPolicy("delete"); err == nil {
+		t.Errorf("An unknown policy was accepted")
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Tag is a label a user attaches to its todos to categorize them. The names of the tags of a user are unique.
+type Tag struct {
+	Id     int    `json:"id"`
+	UserId int    `json:"userId"`
+	Name   string `json:"name"`
+	// Color is an optional hex color like "#ff8800".
+	Color string `json:"color"`
+}
+
+// TagUpdate represents the fields a user provides to create or change a [Tag]. Fields with nil values are not changed.
+type TagUpdate struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Update applies the non-nil fields of update to tag and validates the result. Names are trimmed and colors are
+// stored in lower case.
+func (tag *Tag) Update(update TagUpdate) error {
+	if update.Name != nil {
+		tag.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Color != nil {
+		tag.Color = strings.ToLower(*update.Color)
+	}
+	if tag.Name == "" {
+		return errors.New("The name of the tag is missing.")
+	}
+	if tag.Color != "" && !colorPattern.MatchString(tag.Color) {
+		return errors.New("The color of the tag has to be a hex color like #ff8800.")
+	}
+	return nil
+}
+
+// TagIds returns the ids of tags.
+func TagIds(tags []Tag) []int {
+	ids := make([]int, len(tags))
+	for i, tag := range tags {
+		ids[i] = tag.Id
+	}
+	return ids
+}
@@ -1,5 +1,15 @@
 package models
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"todo/recurrence"
+)
+
 // Todo represents a task or item in a todo list.
 // It contains fields such as ID, title, description, user ID, and completion status.
 type Todo struct {
@@ -7,23 +17,147 @@ type Todo struct {
 	Title  string `json:"title"`
 	Text   string `json:"text"`
 	UserId int    `json:"userId"`
+	// Status is the state of the work on the todo, IsDone is derived from it and reports whether it is terminal.
+	Status Status `json:"status"`
 	IsDone bool   `json:"isDone"`
+	// CompletedAt is when the todo last entered a terminal status, nil while it is not in one.
+	CompletedAt *time.Time `json:"completedAt"`
+	// StartAt and DueAt are optional. For AllDay todos only their dates matter: the todo starts at the beginning of
+	// the date of StartAt and is due at the end of the date of DueAt in the timezone of the user.
+	StartAt *time.Time `json:"startAt"`
+	DueAt   *time.Time `json:"dueAt"`
+	AllDay  bool       `json:"allDay"`
+	// Tags are the tags of the owner of the todo attached to it, ordered by name.
+	Tags []Tag `json:"tags"`
+	// ListId is the id of the list of the owner the todo belongs to, nil if it is not in a list.
+	ListId *int `json:"listId"`
+	// ParentId is the id of the todo of the same owner this todo is a subtask of, nil for top-level todos.
+	ParentId *int `json:"parentId"`
+	// Recurrence is a recurrence rule as accepted by [recurrence.Parse] that repeats the todo from its due date, empty
+	// if the todo does not repeat.
+	Recurrence string   `json:"recurrence"`
+	Priority   Priority `json:"priority"`
+	// Position is the place of the todo in the manual order of the todos of its owner, see [PositionBetween]. It is
+	// assigned by the store and changed by moving the todo.
+	Position string `json:"position"`
+	// Checklist counts the checked items of the checklist of the todo, it is set by the store.
+	Checklist ChecklistProgress `json:"checklist"`
+	// ChecklistAutoComplete completes the todo as soon as all items of its checklist are checked.
+	ChecklistAutoComplete bool `json:"checklistAutoComplete"`
+}
+
+// ErrStartAfterDue is returned by [Todo.Validate] if a todo starts after it is due.
+var ErrStartAfterDue = errors.New("the todo starts after it is due")
+
+// ErrRecurrenceWithoutDue is returned by [Todo.Validate] if a todo repeats but has no due date to repeat from.
+var ErrRecurrenceWithoutDue = errors.New("a recurring todo needs a due date")
+
+// NormalizeDates converts StartAt and DueAt to UTC without fractions of a second. The dates of AllDay todos become
+// midnight UTC of the date they were given for, whatever their timezone was.
+func (todo *Todo) NormalizeDates() {
+	for _, t := range []**time.Time{&todo.StartAt, &todo.DueAt} {
+		if *t == nil {
+			continue
+		}
+		normalized := (*t).UTC().Truncate(time.Second)
+		if todo.AllDay {
+			year, month, day := (*t).Date()
+			normalized = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		}
+		*t = &normalized
+	}
+}
+
+// Validate returns [ErrStartAfterDue] if the todo starts after it is due, [ErrRecurrenceWithoutDue] if it repeats
+// without a due date and an error if its recurrence rule is invalid.
+func (todo *Todo) Validate() error {
+	if todo.StartAt != nil && todo.DueAt != nil && todo.StartAt.After(*todo.DueAt) {
+		return ErrStartAfterDue
+	}
+	if todo.Recurrence == "" {
+		return nil
+	}
+	if todo.DueAt == nil {
+		return ErrRecurrenceWithoutDue
+	}
+	if _, err := recurrence.Parse(todo.Recurrence); err != nil {
+		return fmt.Errorf("invalid recurrence: %w", err)
+	}
+	return nil
+}
+
+// Due returns when the todo is due in the timezone loc. AllDay todos are due at the end of their date in loc, that is
+// at midnight of the next day. It returns false if the todo has no due date.
+func (todo *Todo) Due(loc *time.Location) (time.Time, bool) {
+	if todo.DueAt == nil {
+		return time.Time{}, false
+	}
+	if !todo.AllDay {
+		return *todo.DueAt, true
+	}
+	year, month, day := todo.DueAt.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, loc), true
 }
 
 // idea from https://eli.thegreenplace.net/2020/optional-json-fields-in-go/
 
 // TodoUpdate represents optional fields for updating a todo item.
-// It allows updating the title, description, and completion status of a todo item.
+// It allows updating the title, description, completion status, dates, tags, list and parent of a todo item.
 // Fields with nil values will not be updated.
 type TodoUpdate struct {
-	Title  *string `json:"title"`
-	Text   *string `json:"text"`
-	IsDone *bool   `json:"isDone"`
+	Title *string `json:"title"`
+	Text  *string `json:"text"`
+	// Status changes the status of the todo. IsDone is only used without a status, it completes an open todo or
+	// reopens a completed one.
+	Status  *Status      `json:"status"`
+	IsDone  *bool        `json:"isDone"`
+	StartAt NullableTime `json:"startAt"`
+	DueAt   NullableTime `json:"dueAt"`
+	AllDay  *bool        `json:"allDay"`
+	// AddTags and RemoveTags are the ids of tags to attach to and detach from the todo.
+	AddTags    []int `json:"addTags"`
+	RemoveTags []int `json:"removeTags"`
+	// ListId moves the todo to another list or out of its list if it is null.
+	ListId NullableInt `json:"listId"`
+	// ParentId makes the todo a subtask of another todo or a top-level todo if it is null.
+	ParentId NullableInt `json:"parentId"`
+	// Recurrence replaces the recurrence rule, an empty rule stops the todo from repeating.
+	Recurrence            *string   `json:"recurrence"`
+	Priority              *Priority `json:"priority"`
+	ChecklistAutoComplete *bool     `json:"checklistAutoComplete"`
+}
+
+// NullableTime is an optional field of an update that can be removed by setting it to null.
+type NullableTime struct {
+	// Set is true if the field was given, Time is nil if it was null.
+	Set  bool
+	Time *time.Time
+}
+
+// UnmarshalJSON sets the time, which is only called if the field is present.
+func (t *NullableTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	return json.Unmarshal(data, &t.Time)
+}
+
+// NullableInt is an optional id of an update that can be removed by setting it to null.
+type NullableInt struct {
+	// Set is true if the field was given, Int is nil if it was null.
+	Set bool
+	Int *int
+}
+
+// UnmarshalJSON sets the int, which is only called if the field is present.
+func (i *NullableInt) UnmarshalJSON(data []byte) error {
+	i.Set = true
+	return json.Unmarshal(data, &i.Int)
 }
 
 // Update updates the fields of a todo item based on the provided TodoUpdate values.
-// It updates the title, description, and completion status if non-nil values are provided in TodoUpdate.
-// Fields with nil values in TodoUpdate will not be updated.
+// It updates the title, description, status, dates, list, parent, recurrence, priority and checklist auto-completion if
+// non-nil values are provided in TodoUpdate. Fields with nil values in TodoUpdate will not be updated, dates, the list
+// and the parent are removed if they are set to null.
+// Added tags only carry their id until the todo is stored and fetched again.
 func (todo *Todo) Update(valuesFrom TodoUpdate) {
 	if valuesFrom.Title != nil {
 		todo.Title = *valuesFrom.Title
@@ -31,7 +165,107 @@ func (todo *Todo) Update(valuesFrom TodoUpdate) {
 	if valuesFrom.Text != nil {
 		todo.Text = *valuesFrom.Text
 	}
-	if valuesFrom.IsDone != nil {
-		todo.IsDone = *valuesFrom.IsDone
+	if valuesFrom.Status != nil {
+		todo.SetStatus(*valuesFrom.Status, time.Now())
+	} else if valuesFrom.IsDone != nil && *valuesFrom.IsDone != todo.IsDone {
+		status := StatusOpen
+		if *valuesFrom.IsDone {
+			status = StatusDone
+		}
+		todo.SetStatus(status, time.Now())
+	}
+	if valuesFrom.StartAt.Set {
+		todo.StartAt = valuesFrom.StartAt.Time
+	}
+	if valuesFrom.DueAt.Set {
+		todo.DueAt = valuesFrom.DueAt.Time
+	}
+	if valuesFrom.AllDay != nil {
+		todo.AllDay = *valuesFrom.AllDay
+	}
+	if valuesFrom.ListId.Set {
+		todo.ListId = valuesFrom.ListId.Int
+	}
+	if valuesFrom.ParentId.Set {
+		todo.ParentId = valuesFrom.ParentId.Int
+	}
+	if valuesFrom.Recurrence != nil {
+		todo.Recurrence = strings.TrimSpace(*valuesFrom.Recurrence)
+	}
+	if valuesFrom.Priority != nil {
+		todo.Priority = *valuesFrom.Priority
+	}
+	if valuesFrom.ChecklistAutoComplete != nil {
+		todo.ChecklistAutoComplete = *valuesFrom.ChecklistAutoComplete
+	}
+	for _, tagId := range valuesFrom.AddTags {
+		if !slices.Contains(TagIds(todo.Tags), tagId) {
+			todo.Tags = append(todo.Tags, Tag{Id: tagId})
+		}
+	}
+	todo.Tags = slices.DeleteFunc(todo.Tags, func(tag Tag) bool {
+		return slices.Contains(valuesFrom.RemoveTags, tag.Id)
+	})
+}
+
+// TodoFilter selects which todos of a user are returned.
+type TodoFilter struct {
+	// IncludeShared also returns the todos shared with the user, directly or through a shared list.
+	IncludeShared bool
+	// ListId only returns the todos in the list with this id if it is not nil.
+	ListId *int
+	// ParentId only returns the direct subtasks of the todo with this id if it is not nil.
+	ParentId *int
+	// IsDone only returns todos with the given completion status if it is not nil.
+	IsDone *bool
+	// DueAfter and DueBefore only return todos that have a due date and are due after DueAfter and at or before
+	// DueBefore. The due dates of all-day todos are compared at the end of their date in Location, UTC if it is nil.
+	DueAfter  *time.Time
+	DueBefore *time.Time
+	Location  *time.Location
+	// Tags only returns todos with any of the tag names, or with all of them if AllTags is set. Only the tags of the
+	// user the todos are fetched for are matched, not the ones other users gave to the todos shared with them.
+	Tags    []string
+	AllTags bool
+	// Actionable only returns todos whose blockers are all done, see [Dependency].
+	Actionable bool
+	// Sort is the order the todos are returned in, [DefaultTodoSort] if it is empty. Ties are ordered by id.
+	Sort []TodoSort
+}
+
+// Matches reports whether todo passes the filter for the user with id userId except for [TodoFilter.IncludeShared] and
+// [TodoFilter.Actionable], which depend on the shares and the dependencies.
+func (f TodoFilter) Matches(todo Todo, userId int) bool {
+	if f.IsDone != nil && todo.IsDone != *f.IsDone {
+		return false
+	}
+	if f.ListId != nil && (todo.ListId == nil || *todo.ListId != *f.ListId) {
+		return false
+	}
+	if f.ParentId != nil && (todo.ParentId == nil || *todo.ParentId != *f.ParentId) {
+		return false
+	}
+	if len(f.Tags) > 0 {
+		matching := 0
+		for _, name := range f.Tags {
+			if slices.ContainsFunc(todo.Tags, func(tag Tag) bool { return tag.Name == name && tag.UserId == userId }) {
+				matching++
+			}
+		}
+		if matching == 0 || (f.AllTags && matching < len(f.Tags)) {
+			return false
+		}
+	}
+	if f.DueAfter == nil && f.DueBefore == nil {
+		return true
+	}
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	due, ok := todo.Due(loc)
+	if !ok {
+		return false
 	}
+	return (f.DueAfter == nil || due.After(*f.DueAfter)) && (f.DueBefore == nil || !due.After(*f.DueBefore))
 }
@@ -1,6 +1,10 @@
 package models
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+	"time"
+)
 
 func TestTodo_Update(t *testing.T) {
 	todo := Todo{
@@ -15,6 +19,7 @@ func TestTodo_Update(t *testing.T) {
 		Title:  "TEST 2",
 		Text:   "test 2",
 		UserId: 3,
+		Status: StatusDone,
 		IsDone: true,
 	}
 	todoUpdate := TodoUpdate{
@@ -23,7 +28,11 @@ func TestTodo_Update(t *testing.T) {
 		IsDone: &updatedTodo.IsDone,
 	}
 	todo.Update(todoUpdate)
-	if todo != updatedTodo {
+	if todo.CompletedAt == nil {
+		t.Fatalf("Completing the todo did not set when it was completed.")
+	}
+	updatedTodo.CompletedAt = todo.CompletedAt
+	if !reflect.DeepEqual(todo, updatedTodo) {
 		t.Fatalf("Todo update went wrong.")
 	}
 	todo.Update(TodoUpdate{
@@ -31,7 +40,64 @@ func TestTodo_Update(t *testing.T) {
 		Text:   nil,
 		IsDone: nil,
 	})
-	if todo != updatedTodo {
+	if !reflect.DeepEqual(todo, updatedTodo) {
 		t.Fatalf("Fields were changed but they shouldn't have changed.")
 	}
 }
+
+func TestTodo_NormalizeDates(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 6, 1, 0, 30, 0, 500, berlin)
+	due := time.Date(2024, 6, 2, 23, 59, 59, 0, berlin)
+	todo := Todo{StartAt: &start, DueAt: &due}
+	todo.NormalizeDates()
+	if !todo.StartAt.Equal(start.Truncate(time.Second)) || todo.StartAt.Location() != time.UTC {
+		t.Fatalf("Unexpected normalized start %v", todo.StartAt)
+	}
+	todo = Todo{StartAt: &start, DueAt: &due, AllDay: true}
+	todo.NormalizeDates()
+	if *todo.StartAt != time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC) || *todo.DueAt != time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC) {
+		t.Fatalf("The dates of an all-day todo were not kept: %v, %v", todo.StartAt, todo.DueAt)
+	}
+	if err := (&Todo{StartAt: &due, DueAt: &start}).Validate(); err != ErrStartAfterDue {
+		t.Fatalf("A todo starting after it is due was accepted: %v", err)
+	}
+}
+
+func TestTodoFilter_Matches(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	allDay := Todo{DueAt: &date, AllDay: true}
+	// the date ends at 2024-06-01T15:00:00Z in Tokyo
+	before := time.Date(2024, 6, 1, 14, 59, 59, 0, time.UTC)
+	end := time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		filter TodoFilter
+		want   bool
+	}{
+		{TodoFilter{}, true},
+		{TodoFilter{DueBefore: &before, Location: tokyo}, false},
+		{TodoFilter{DueBefore: &end, Location: tokyo}, true},
+		{TodoFilter{DueAfter: &before, Location: tokyo}, true},
+		{TodoFilter{DueAfter: &end, Location: tokyo}, false},
+		{TodoFilter{DueBefore: &end}, false},
+	}
+	for _, test := range tests {
+		if got := test.filter.Matches(allDay, 0); got != test.want {
+			t.Errorf("%+v matched %t, want %t", test.filter, got, test.want)
+		}
+	}
+	done := true
+	if (TodoFilter{IsDone: &done}).Matches(Todo{}, 0) {
+		t.Errorf("A todo that is not done matched a filter for done todos")
+	}
+	if (TodoFilter{DueBefore: &end}).Matches(Todo{}, 0) {
+		t.Errorf("A todo without due date matched a due date filter")
+	}
+}
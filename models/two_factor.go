@@ -0,0 +1,9 @@
+package models
+
+// TOTPEnrollment is the secret of a new time-based one-time password and the recovery codes that can be used instead
+// of a one-time password if the authenticator is lost. Both are only returned once.
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
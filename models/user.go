@@ -2,37 +2,76 @@ package models
 
 import (
 	"errors"
-	"golang.org/x/crypto/bcrypt"
+	"time"
+	"todo/password"
 )
 
+// Role determines what a [User] is allowed to do.
+type Role string
+
+const (
+	// RoleMember can only access the user's own data and what was shared with the user.
+	RoleMember Role = "member"
+	// RoleAdmin can additionally manage all users and view all todos.
+	RoleAdmin Role = "admin"
+)
+
+// Roles contains all roles a [User] can have.
+var Roles = []Role{RoleMember, RoleAdmin}
+
 // User represents a user in the system.
 type User struct {
-	Id       int    `json:"id"`
-	Name     string `json:"name"`
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	// Email is where password reset tokens are sent to. It is optional and never included in responses.
+	Email string `json:"-"`
+	// Role is [RoleMember] unless an admin changed it.
+	Role Role `json:"role,omitempty"`
+	// Disabled users can't log in and their sessions and access tokens are rejected.
+	Disabled bool `json:"disabled,omitempty"`
+	// Timezone is the IANA name of the timezone dates of the user are interpreted in, for example "Europe/Berlin".
+	Timezone string `json:"timezone,omitempty"`
 	password string
 }
 
+// Location returns the location of [User.Timezone]. It falls back to UTC if the timezone is empty or unknown.
+func (user *User) Location() *time.Location {
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 // SetPassword sets the password for the user.
 func (user *User) SetPassword(password string) {
 	user.password = password
 }
 
-// GetPasswordHash generates and returns the bcrypt hash of the user's password.
-func (user *User) GetPasswordHash() ([]byte, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(user.password), 14)
-	return bytes, err
+// GetPasswordHash generates and returns the hash of the user's password created by hasher.
+func (user *User) GetPasswordHash(hasher password.Hasher) ([]byte, error) {
+	hash, err := hasher.Hash(user.password)
+	return []byte(hash), err
 }
 
 // CheckPassword compares the provided hashed password with the user's actual password.
 // It returns nil if the passwords match, otherwise, it returns an error indicating the mismatch.
-func (user *User) CheckPassword(hashedPassword []byte) error {
-	return bcrypt.CompareHashAndPassword(hashedPassword, []byte(user.password))
+// If they match, rehash reports whether the hash is outdated compared to the algorithm and parameters of hasher.
+func (user *User) CheckPassword(hasher password.Hasher, hashedPassword []byte) (rehash bool, err error) {
+	return hasher.Verify(string(hashedPassword), user.password)
+}
+
+// CheckPasswordPolicy returns an error if the user's password violates policy.
+func (user *User) CheckPasswordPolicy(policy password.Policy) error {
+	return policy.Check(user.Name, user.password)
 }
 
 // UserLogin represents the login credentials for a user.
 type UserLogin struct {
 	Name     *string `json:"name"`
 	Password *string `json:"password"`
+	// Email is only used when creating a user.
+	Email *string `json:"email"`
 }
 
 // UserFromLogin constructs a User object from the provided UserLogin credentials.
@@ -45,5 +84,8 @@ func UserFromLogin(login UserLogin) (User, error) {
 	}
 	user.Name = *login.Name
 	user.SetPassword(*login.Password)
+	if login.Email != nil {
+		user.Email = *login.Email
+	}
 	return user, nil
 }
@@ -1,6 +1,9 @@
 package models
 
-import "testing"
+import (
+	"testing"
+	"todo/password"
+)
 
 func TestUser_CheckPassword(t *testing.T) {
 	u := User{
@@ -8,15 +11,16 @@ func TestUser_CheckPassword(t *testing.T) {
 		Name:     "john doe",
 		password: "john1234!?",
 	}
-	pw, err := u.GetPasswordHash()
+	hasher := password.Hasher{Algorithm: password.Bcrypt, BcryptCost: 4}
+	pw, err := u.GetPasswordHash(hasher)
 	if err != nil {
 		t.Fatalf("Password generation failed when it should have worked! (Password: %s)", pw)
 	}
-	if err := u.CheckPassword(pw); err != nil {
+	if _, err := u.CheckPassword(hasher, pw); err != nil {
 		t.Fatalf("The password check failed while it should have passed (Password: %s)", pw)
 	}
 	u.SetPassword("john1234!")
-	if err := u.CheckPassword(pw); err == nil {
+	if _, err := u.CheckPassword(hasher, pw); err == nil {
 		t.Fatalf("The password check succeeded while it should have failed (Password: %s)", pw)
 	}
 }
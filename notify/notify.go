@@ -0,0 +1,122 @@
+// Package notify delivers messages like password reset tokens and reminders to users.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+	"todo/logger"
+)
+
+// Message is a plain text message to a single recipient.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	// Key identifies the message if it may be delivered more than once, so that the receiver can drop the copies. It
+	// is sent as the Message-ID of emails and as the Idempotency-Key header of webhooks.
+	Key string `json:"key,omitempty"`
+}
+
+// Notifier delivers [Message]s.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes messages to the log instead of delivering them. It is meant for development, as the log then
+// contains the secrets sent to users.
+type LogNotifier struct{}
+
+// Notify logs msg.
+func (LogNotifier) Notify(ctx context.Context, msg Message) error {
+	logger.Info(fmt.Sprintf("message to %s: %s\n%s", msg.To, msg.Subject, msg.Body))
+	return nil
+}
+
+// SMTPNotifier sends messages as emails through an SMTP server.
+type SMTPNotifier struct {
+	// Addr is the host and port of the server.
+	Addr string
+	// From is the sender address of the emails.
+	From string
+	// Auth authenticates at the server, it may be nil.
+	Auth smtp.Auth
+}
+
+// NewSMTPNotifier returns an [SMTPNotifier] sending from from through the server at addr. If username is not empty,
+// it authenticates with PLAIN, which net/smtp only allows over TLS or to localhost.
+func NewSMTPNotifier(addr string, from string, username string, password string) *SMTPNotifier {
+	notifier := &SMTPNotifier{Addr: addr, From: from}
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		notifier.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return notifier
+}
+
+// Notify sends msg as a plain text email. The key of msg becomes the Message-ID in the domain of the sender address.
+func (n *SMTPNotifier) Notify(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(n.From, "\r\n") {
+		return fmt.Errorf("invalid address %q", msg.To)
+	}
+	if strings.ContainsAny(msg.Key, "\r\n<>@ ") {
+		return fmt.Errorf("invalid key %q", msg.Key)
+	}
+	var email strings.Builder
+	fmt.Fprintf(&email, "From: %s\r\n", n.From)
+	fmt.Fprintf(&email, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&email, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&email, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if msg.Key != "" {
+		_, domain, _ := strings.Cut(n.From, "@")
+		fmt.Fprintf(&email, "Message-ID: <%s@%s>\r\n", msg.Key, domain)
+	}
+	email.WriteString("MIME-Version: 1.0\r\n")
+	email.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	email.WriteString("\r\n")
+	email.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return smtp.SendMail(n.Addr, n.Auth, n.From, []string{msg.To}, []byte(email.String()))
+}
+
+// WebhookNotifier posts messages as JSON objects with the fields "to", "subject" and "body" to a URL.
+type WebhookNotifier struct {
+	URL string
+	// Client sends the requests, [http.DefaultClient] if it is nil.
+	Client *http.Client
+}
+
+// Notify posts msg to the URL of the webhook with its key in the Idempotency-Key header. Responses with a status code
+// other than 2xx are errors.
+func (n *WebhookNotifier) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if msg.Key != "" {
+		req.Header.Set("Idempotency-Key", msg.Key)
+	}
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("the webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"todo/notify/smtptest"
+)
+
+func TestSMTPNotifier(t *testing.T) {
+	server := smtptest.NewServer()
+	defer server.Close()
+	notifier := NewSMTPNotifier(server.Addr, "todo@example.com", "user", "secret")
+	msg := Message{To: "john@example.com", Subject: "Reset your password", Body: "line 1\nline 2", Key: "reset-1"}
+	if err := notifier.Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Sending the email failed: %v", err)
+	}
+	emails := server.Emails()
+	if len(emails) != 1 {
+		t.Fatalf("Expected one email but got %d", len(emails))
+	}
+	email := emails[0]
+	if email.From != "todo@example.com" || !slices.Equal(email.To, []string{"john@example.com"}) {
+		t.Fatalf("Unexpected envelope %+v", email)
+	}
+	if !strings.Contains(email.Data, "Subject: Reset your password\r\n") || !strings.HasSuffix(email.Data, "line 1\r\nline 2\r\n") {
+		t.Fatalf("Unexpected email %q", email.Data)
+	}
+	if !strings.Contains(email.Data, "Message-ID: <reset-1@example.com>\r\n") {
+		t.Fatalf("The key is not the Message-ID of the email %q", email.Data)
+	}
+	if err := notifier.Notify(context.Background(), Message{To: "john@example.com\r\nBcc: eve@example.com"}); err == nil {
+		t.Fatalf("An address with a line break was accepted")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received []Message
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg Message
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&msg) != nil ||
+			r.Header.Get("Idempotency-Key") != msg.Key {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, msg)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	notifier := &WebhookNotifier{URL: server.URL}
+	msg := Message{To: "john@example.com", Subject: "Reminder", Body: "Pay the rent", Key: "reminder-1"}
+	if err := notifier.Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Posting the message failed: %v", err)
+	}
+	if len(received) != 1 || received[0] != msg {
+		t.Fatalf("Unexpected messages %+v", received)
+	}
+	status = http.StatusInternalServerError
+	if err := notifier.Notify(context.Background(), msg); err == nil {
+		t.Fatalf("A failed delivery was not reported")
+	}
+}
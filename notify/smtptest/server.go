@@ -0,0 +1,125 @@
+// Package smtptest provides an SMTP server for tests that accepts every email and keeps it in memory.
+package smtptest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Email is an email received by a [Server].
+type Email struct {
+	From string
+	To   []string
+	// Data is the email with its headers as sent after the DATA command.
+	Data string
+}
+
+// Server is an SMTP sink listening on a local port. It supports the commands net/smtp uses without TLS and accepts
+// any credentials.
+type Server struct {
+	// Addr is the host and port the server listens on.
+	Addr string
+
+	listener net.Listener
+	mu       sync.Mutex
+	emails   []Email
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a [Server] on a random local port.
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{Addr: listener.Addr().String(), listener: listener}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close stops the server and waits for open connections to finish.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Emails returns the emails received so far.
+func (s *Server) Emails() []Email {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Email(nil), s.emails...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost ESMTP smtptest")
+	var email Email
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "AUTH"):
+			reply("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			email = Email{From: strings.Trim(line[len("MAIL FROM:"):], " <>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			email.To = append(email.To, strings.Trim(line[len("RCPT TO:"):], " <>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			email.Data = data.String()
+			s.mu.Lock()
+			s.emails = append(s.emails, email)
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyCacheLifetime is how long the keys of a provider are used before they are fetched again.
+var keyCacheLifetime = time.Hour
+
+// keyRefetchInterval is the minimal time between two fetches caused by tokens signed with an unknown key, so that
+// tokens with made up key ids can't make the server hammer the provider.
+var keyRefetchInterval = time.Minute
+
+// jwk is a key of a JSON Web Key Set. Only the members of RSA and EC public keys are decoded.
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyId   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// publicKey decodes the RSA or P-256 key. Other keys are not supported and return an error.
+func (key jwk) publicKey() (crypto.PublicKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		bytes, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(bytes), nil
+	}
+	switch key.KeyType {
+	case "RSA":
+		n, err := decode(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(key.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("the RSA exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if key.Curve != "P-256" {
+			return nil, fmt.Errorf("the curve %s is not supported", key.Curve)
+		}
+		x, err := decode(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("the EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("the key type %s is not supported", key.KeyType)
+}
+
+// keyCache holds the signing keys of a provider fetched from its JWKS URI.
+type keyCache struct {
+	uri       string
+	client    *http.Client
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// key returns the key with the id kid. The keys are fetched again if they are older than keyCacheLifetime or, at most
+// every keyRefetchInterval, if kid is unknown because the provider rotated its keys.
+func (c *keyCache) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	age := time.Since(c.fetchedAt)
+	key, ok := c.keys[kid]
+	if ok && age < keyCacheLifetime {
+		return key, nil
+	}
+	if !ok && c.keys != nil && age < keyRefetchInterval {
+		return nil, fmt.Errorf("the ID token was signed with the unknown key %q", kid)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := getJSON(ctx, c.client, c.uri, &set); err != nil {
+		return nil, fmt.Errorf("fetching the keys of the provider failed: %w", err)
+	}
+	c.keys = map[string]crypto.PublicKey{}
+	c.fetchedAt = time.Now()
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if publicKey, err := k.publicKey(); err == nil {
+			c.keys[k.KeyId] = publicKey
+		}
+	}
+	key, ok = c.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("the ID token was signed with the unknown key %q", kid)
+	}
+	return key, nil
+}
+
+// verify checks the signature of the compact serialized token raw and returns its payload. RS256 and ES256 are
+// supported, the algorithm in the header has to match the type of the key.
+func (c *keyCache) verify(ctx context.Context, raw string) ([]byte, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("the ID token is malformed")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("the ID token header is malformed")
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyId     string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errors.New("the ID token header is malformed")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("the ID token signature is malformed")
+	}
+	key, err := c.key(ctx, header.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	valid := false
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		valid = header.Algorithm == "RS256" && rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil
+	case *ecdsa.PublicKey:
+		if header.Algorithm == "ES256" && len(signature) == 64 {
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			valid = ecdsa.Verify(key, hash[:], r, s)
+		}
+	}
+	if !valid {
+		return nil, errors.New("the signature of the ID token is invalid")
+	}
+	return base64.RawURLEncoding.DecodeString(parts[1])
+}
@@ -0,0 +1,268 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE against an external identity provider.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"todo/models"
+)
+
+// Config describes the client registered at the identity provider.
+type Config struct {
+	// Issuer is the URL of the provider, the discovery document is fetched from below it.
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	// RedirectURL is the callback URL registered for the client.
+	RedirectURL string
+	// Scopes are requested in addition to "openid". Defaults to "profile" and "email".
+	Scopes []string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an identity provider configured from its discovery document.
+type Provider struct {
+	config    Config
+	client    *http.Client
+	endpoints discovery
+	keys      *keyCache
+}
+
+// NewProvider fetches the discovery document of config.Issuer and returns the [Provider] described by it. If client
+// is nil, [http.DefaultClient] is used.
+func NewProvider(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"profile", "email"}
+	}
+	discoveryURL := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	var endpoints discovery
+	if err := getJSON(ctx, client, discoveryURL, &endpoints); err != nil {
+		return nil, fmt.Errorf("fetching the discovery document failed: %w", err)
+	}
+	if endpoints.Issuer != config.Issuer {
+		return nil, fmt.Errorf("the discovery document is for the issuer %q instead of %q", endpoints.Issuer, config.Issuer)
+	}
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" || endpoints.JWKSURI == "" {
+		return nil, errors.New("the discovery document lacks an endpoint")
+	}
+	return &Provider{
+		config:    config,
+		client:    client,
+		endpoints: endpoints,
+		keys:      &keyCache{uri: endpoints.JWKSURI, client: client},
+	}, nil
+}
+
+// Issuer returns the issuer identifier of the provider.
+func (p *Provider) Issuer() string {
+	return p.config.Issuer
+}
+
+// AuthRequest holds the values of one login attempt that have to be kept by the client until the callback.
+type AuthRequest struct {
+	// State is echoed in the callback to tie it to the login attempt.
+	State string
+	// Nonce is echoed in the ID token to prevent its replay.
+	Nonce string
+	// Verifier is the PKCE code verifier, only its hash is sent to the authorization endpoint.
+	Verifier string
+}
+
+// NewAuthRequest returns an [AuthRequest] with random values.
+func NewAuthRequest() (AuthRequest, error) {
+	var req AuthRequest
+	for _, value := range []*string{&req.State, &req.Nonce, &req.Verifier} {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return req, err
+		}
+		*value = base64.RawURLEncoding.EncodeToString(random)
+	}
+	return req, nil
+}
+
+// CodeChallenge returns the S256 PKCE code challenge of verifier.
+func CodeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// AuthCodeURL returns the URL of the authorization endpoint the user is redirected to for req.
+func (p *Provider) AuthCodeURL(req AuthRequest) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientId},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.config.Scopes...), " ")},
+		"state":                 {req.State},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {CodeChallenge(req.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(p.endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.endpoints.AuthorizationEndpoint + separator + query.Encode()
+}
+
+type tokenResponse struct {
+	IdToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems the authorization code returned to the callback of req at the token endpoint and returns the
+// verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code string, req AuthRequest) (IDToken, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {req.Verifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientId)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return IDToken{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		httpReq.SetBasicAuth(url.QueryEscape(p.config.ClientId), url.QueryEscape(p.config.ClientSecret))
+	}
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return IDToken{}, err
+	}
+	defer resp.Body.Close()
+	var token tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return IDToken{}, fmt.Errorf("decoding the token response failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return IDToken{}, fmt.Errorf("the token endpoint responded with %d: %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IdToken == "" {
+		return IDToken{}, errors.New("the token response contains no ID token")
+	}
+	return p.Verify(ctx, token.IdToken, req.Nonce, time.Now())
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s responded with %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// IDToken holds the verified claims of an ID token.
+type IDToken struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	PreferredUsername string   `json:"preferred_username"`
+	Email             string   `json:"email"`
+}
+
+// Identity returns the [models.ExternalIdentity] of the token. The suggested name is the preferred user name, the
+// email address or the subject, whichever is set first.
+func (token IDToken) Identity() models.ExternalIdentity {
+	name := token.PreferredUsername
+	if name == "" {
+		name = token.Email
+	}
+	if name == "" {
+		name = token.Subject
+	}
+	return models.ExternalIdentity{Issuer: token.Issuer, Subject: token.Subject, Name: name}
+}
+
+// audience is the "aud" claim, which is either a single string or an array of strings.
+type audience []string
+
+func (aud *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*aud = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*aud = multiple
+	return nil
+}
+
+func (aud audience) contains(clientId string) bool {
+	for _, a := range aud {
+		if a == clientId {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks the signature of the ID token raw with the keys of the provider and validates its issuer, audience,
+// expiry and nonce at now.
+func (p *Provider) Verify(ctx context.Context, raw string, nonce string, now time.Time) (IDToken, error) {
+	var token IDToken
+	payload, err := p.keys.verify(ctx, raw)
+	if err != nil {
+		return token, err
+	}
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return token, fmt.Errorf("decoding the ID token failed: %w", err)
+	}
+	switch {
+	case token.Issuer != p.config.Issuer:
+		return token, fmt.Errorf("the ID token was issued by %q", token.Issuer)
+	case !token.Audience.contains(p.config.ClientId):
+		return token, errors.New("the ID token was not issued for this client")
+	case len(token.Audience) > 1 && token.AuthorizedParty != p.config.ClientId:
+		return token, errors.New("the ID token was issued to another party")
+	case now.Unix() >= token.ExpiresAt:
+		return token, errors.New("the ID token expired")
+	case token.Nonce != nonce:
+		return token, errors.New("the nonce of the ID token does not match")
+	case token.Subject == "":
+		return token, errors.New("the ID token has no subject")
+	}
+	return token, nil
+}
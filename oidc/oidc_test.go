@@ -0,0 +1,126 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"todo/oidc/oidctest"
+)
+
+const testRedirectURL = "http://localhost:8080/oidc/callback"
+
+func newTestProvider(t *testing.T) (*oidctest.Provider, *Provider) {
+	mock := oidctest.NewProvider("todo", "secret")
+	t.Cleanup(mock.Close)
+	config := Config{Issuer: mock.Issuer(), ClientId: "todo", ClientSecret: "secret", RedirectURL: testRedirectURL}
+	provider, err := NewProvider(context.Background(), config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mock, provider
+}
+
+// authorize follows the authorization URL of req and returns the code the provider redirected back with.
+func authorize(t *testing.T, provider *Provider, req AuthRequest) string {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(provider.AuthCodeURL(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("Expected a redirect from the authorization endpoint but got %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(location.String(), testRedirectURL) || location.Query().Get("state") != req.State {
+		t.Fatalf("Unexpected redirect to %s", location)
+	}
+	return location.Query().Get("code")
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	mock.SetUser("1234", "bob")
+	req, err := NewAuthRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := authorize(t, provider, req)
+	wrongVerifier := req
+	wrongVerifier.Verifier = "wrong"
+	if _, err := provider.Exchange(context.Background(), code, wrongVerifier); err == nil {
+		t.Fatalf("The code was exchanged with a wrong PKCE verifier")
+	}
+	code = authorize(t, provider, req)
+	token, err := provider.Exchange(context.Background(), code, req)
+	if err != nil {
+		t.Fatalf("Exchanging the code failed: %v", err)
+	}
+	identity := token.Identity()
+	if identity.Issuer != mock.Issuer() || identity.Subject != "1234" || identity.Name != "bob" {
+		t.Fatalf("Unexpected identity %+v", identity)
+	}
+	if _, err := provider.Exchange(context.Background(), code, req); err == nil {
+		t.Fatalf("A code was exchanged twice")
+	}
+}
+
+func TestProvider_Verify(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	now := time.Now()
+	if _, err := provider.Verify(context.Background(), mock.Sign(mock.Claims("1", "bob", "n")), "n", now); err != nil {
+		t.Fatalf("A valid ID token was rejected: %v", err)
+	}
+	tests := []struct {
+		name   string
+		modify func(claims map[string]any)
+		nonce  string
+	}{
+		{"wrong nonce", func(map[string]any) {}, "other"},
+		{"wrong issuer", func(claims map[string]any) { claims["iss"] = "https://evil.example.com" }, "n"},
+		{"wrong audience", func(claims map[string]any) { claims["aud"] = "other" }, "n"},
+		{"other authorized party", func(claims map[string]any) { claims["aud"] = []string{"todo", "other"}; claims["azp"] = "other" }, "n"},
+		{"expired", func(claims map[string]any) { claims["exp"] = now.Add(-time.Second).Unix() }, "n"},
+		{"no subject", func(claims map[string]any) { claims["sub"] = "" }, "n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := mock.Claims("1", "bob", "n")
+			test.modify(claims)
+			if _, err := provider.Verify(context.Background(), mock.Sign(claims), test.nonce, now); err == nil {
+				t.Fatalf("The ID token was accepted")
+			}
+		})
+	}
+	token := mock.Sign(mock.Claims("1", "bob", "n"))
+	parts := strings.Split(token, ".")
+	forged := strings.Split(mock.Sign(mock.Claims("2", "mallory", "n")), ".")
+	if _, err := provider.Verify(context.Background(), parts[0]+"."+forged[1]+"."+parts[2], "n", now); err == nil {
+		t.Fatalf("A token with a tampered payload was accepted")
+	}
+}
+
+func TestProvider_keyRotation(t *testing.T) {
+	mock, provider := newTestProvider(t)
+	if _, err := provider.Verify(context.Background(), mock.Sign(mock.Claims("1", "bob", "n")), "n", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	mock.RotateKey()
+	rotated := mock.Sign(mock.Claims("1", "bob", "n"))
+	if _, err := provider.Verify(context.Background(), rotated, "n", time.Now()); err == nil {
+		t.Fatalf("The keys were fetched again right after the last fetch")
+	}
+	defer func(interval time.Duration) { keyRefetchInterval = interval }(keyRefetchInterval)
+	keyRefetchInterval = 0
+	if _, err := provider.Verify(context.Background(), rotated, "n", time.Now()); err != nil {
+		t.Fatalf("A token signed with a rotated key was rejected: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+// Package oidctest provides a mock OpenID Connect provider for tests.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type authorization struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	subject     string
+	name        string
+}
+
+// Provider is an identity provider running on a local [httptest.Server]. Its authorization endpoint logs in the
+// configured user without asking and redirects straight back to the client.
+type Provider struct {
+	Server       *httptest.Server
+	ClientId     string
+	ClientSecret string
+
+	mu      sync.Mutex
+	key     *rsa.PrivateKey
+	keyId   int
+	subject string
+	name    string
+	codes   map[string]authorization
+}
+
+// NewProvider starts a provider that accepts the client clientId authenticated with clientSecret. The user logged in
+// has the subject "alice" and the same preferred user name until [Provider.SetUser] is called.
+func NewProvider(clientId string, clientSecret string) *Provider {
+	p := &Provider{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		subject:      "alice",
+		name:         "alice",
+		codes:        map[string]authorization{},
+	}
+	p.RotateKey()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// Issuer returns the issuer identifier of the provider.
+func (p *Provider) Issuer() string {
+	return p.Server.URL
+}
+
+// Close shuts the provider down.
+func (p *Provider) Close() {
+	p.Server.Close()
+}
+
+// SetUser sets the user logged in by the following authorizations.
+func (p *Provider) SetUser(subject string, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subject = subject
+	p.name = name
+}
+
+// RotateKey replaces the signing key with a new key with a new key id. The old key is no longer published.
+func (p *Provider) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.key = key
+	p.keyId++
+}
+
+func (p *Provider) kid() string {
+	return fmt.Sprintf("key-%d", p.keyId)
+}
+
+// Sign returns an RS256 signed token with claims using the current key.
+func (p *Provider) Sign(claims map[string]any) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": p.kid()})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, hash[:])
+	if err != nil {
+		panic(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// Claims returns valid ID token claims for subject with nonce, which can be modified and passed to [Provider.Sign].
+func (p *Provider) Claims(subject string, name string, nonce string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":                p.Issuer(),
+		"sub":                subject,
+		"aud":                p.ClientId,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute).Unix(),
+		"nonce":              nonce,
+		"preferred_username": name,
+	}
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 p.Issuer(),
+		"authorization_endpoint": p.Issuer() + "/authorize",
+		"token_endpoint":         p.Issuer() + "/token",
+		"jwks_uri":               p.Issuer() + "/jwks",
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": p.kid(),
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+	}}})
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != p.ClientId ||
+		query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || query.Get("redirect_uri") == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	random := make([]byte, 16)
+	rand.Read(random)
+	code := base64.RawURLEncoding.EncodeToString(random)
+	p.mu.Lock()
+	p.codes[code] = authorization{
+		redirectURI: redirect.String(),
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		subject:     p.subject,
+		name:        p.name,
+	}
+	p.mu.Unlock()
+	callback := redirect.Query()
+	callback.Set("code", code)
+	callback.Set("state", query.Get("state"))
+	redirect.RawQuery = callback.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func tokenError(w http.ResponseWriter, code string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if id != p.ClientId || secret != p.ClientSecret {
+		tokenError(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", http.StatusBadRequest)
+		return
+	}
+	p.mu.Lock()
+	code := r.PostFormValue("code")
+	auth, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	verifierHash := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || auth.redirectURI != r.PostFormValue("redirect_uri") ||
+		auth.challenge != base64.RawURLEncoding.EncodeToString(verifierHash[:]) {
+		tokenError(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "mock",
+		"token_type":   "Bearer",
+		"id_token":     p.Sign(p.Claims(auth.subject, auth.name, auth.nonce)),
+	})
+}
@@ -0,0 +1,140 @@
+// Package password hashes passwords and checks new passwords against a policy.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithm is a function passwords are hashed with.
+type Algorithm string
+
+const (
+	Bcrypt   Algorithm = "bcrypt"
+	Argon2id Algorithm = "argon2id"
+)
+
+// ErrMismatch is returned by [Hasher.Verify] if the password does not match the hash.
+var ErrMismatch = errors.New("the password does not match the hash")
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Hasher hashes passwords with an algorithm and its parameters. It verifies hashes created with any supported
+// algorithm and parameters, so that they can be changed without invalidating stored passwords.
+type Hasher struct {
+	Algorithm Algorithm
+	// BcryptCost is the cost of [Bcrypt] hashes, between bcrypt.MinCost and bcrypt.MaxCost.
+	BcryptCost int
+	// Argon2Time is the number of passes over the memory of [Argon2id] hashes.
+	Argon2Time uint32
+	// Argon2Memory is the memory used for [Argon2id] hashes in KiB.
+	Argon2Memory uint32
+	// Argon2Threads is the number of threads used for [Argon2id] hashes.
+	Argon2Threads uint8
+}
+
+// DefaultHasher is the [Hasher] used unless it is configured otherwise. Its argon2id parameters are the ones
+// recommended by RFC 9106 for memory-constrained environments.
+var DefaultHasher = Hasher{
+	Algorithm:     Bcrypt,
+	BcryptCost:    14,
+	Argon2Time:    3,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 4,
+}
+
+// Validate returns an error if the algorithm is unknown or its parameters are out of range.
+func (h Hasher) Validate() error {
+	switch h.Algorithm {
+	case Bcrypt:
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("the bcrypt cost %d is not between %d and %d", h.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		if h.Argon2Time < 1 || h.Argon2Threads < 1 || h.Argon2Memory < 8*uint32(h.Argon2Threads) {
+			return errors.New("argon2id needs at least one pass, one thread and 8 KiB of memory per thread")
+		}
+	default:
+		return fmt.Errorf("unknown password hashing algorithm %q", h.Algorithm)
+	}
+	return nil
+}
+
+// Hash returns the hash of password. [Argon2id] hashes are encoded in the PHC string format, for example
+// "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>".
+func (h Hasher) Hash(password string) (string, error) {
+	if err := h.Validate(); err != nil {
+		return "", err
+	}
+	if h.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		return string(hash), err
+	}
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Argon2Time, h.Argon2Memory, h.Argon2Threads, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Argon2Memory, h.Argon2Time,
+		h.Argon2Threads, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks password against hash and returns [ErrMismatch] if it does not match. If it matches, rehash reports
+// whether hash was created with another algorithm or other parameters than h and should be replaced by a new hash.
+func (h Hasher) Verify(hash string, password string) (rehash bool, err error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return h.verifyArgon2id(hash, password)
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, ErrMismatch
+	}
+	if err != nil {
+		return false, err
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return h.Algorithm != Bcrypt || cost != h.BcryptCost, err
+}
+
+func (h Hasher) verifyArgon2id(hash string, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+	params := Hasher{Algorithm: Argon2id}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Argon2Memory, &params.Argon2Time, &params.Argon2Threads); err != nil {
+		return false, fmt.Errorf("malformed argon2id parameters %q: %w", parts[3], err)
+	}
+	if err := params.Validate(); err != nil {
+		return false, err
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, err
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Argon2Time, params.Argon2Memory, params.Argon2Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, ErrMismatch
+	}
+	rehash := h.Algorithm != Argon2id || params.Argon2Time != h.Argon2Time ||
+		params.Argon2Memory != h.Argon2Memory || params.Argon2Threads != h.Argon2Threads
+	return rehash, nil
+}
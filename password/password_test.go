@@ -0,0 +1,90 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHasher(t *testing.T) {
+	bcryptHasher := Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}
+	argon2Hasher := Hasher{Algorithm: Argon2id, Argon2Time: 1, Argon2Memory: 64, Argon2Threads: 1}
+	tests := []struct {
+		name   string
+		hasher Hasher
+		prefix string
+	}{
+		{"bcrypt", bcryptHasher, "$2a$04$"},
+		{"argon2id", argon2Hasher, "$argon2id$v=19$m=64,t=1,p=1$"},
+	}
+	for _, test := range tests {
+		hash, err := test.hasher.Hash("correct horse")
+		if err != nil || !strings.HasPrefix(hash, test.prefix) {
+			t.Fatalf("%s: unexpected hash %q (%v)", test.name, hash, err)
+		}
+		if rehash, err := test.hasher.Verify(hash, "correct horse"); err != nil || rehash {
+			t.Fatalf("%s: verifying the password failed: %t, %v", test.name, rehash, err)
+		}
+		if _, err := test.hasher.Verify(hash, "wrong horse"); !errors.Is(err, ErrMismatch) {
+			t.Fatalf("%s: a wrong password was not rejected: %v", test.name, err)
+		}
+	}
+
+	bcryptHash, _ := bcryptHasher.Hash("correct horse")
+	argon2Hash, _ := argon2Hasher.Hash("correct horse")
+	outdated := []struct {
+		name   string
+		hasher Hasher
+		hash   string
+	}{
+		{"other bcrypt cost", Hasher{Algorithm: Bcrypt, BcryptCost: 5}, bcryptHash},
+		{"bcrypt to argon2id", argon2Hasher, bcryptHash},
+		{"argon2id to bcrypt", bcryptHasher, argon2Hash},
+		{"other argon2id memory", Hasher{Algorithm: Argon2id, Argon2Time: 1, Argon2Memory: 128, Argon2Threads: 1}, argon2Hash},
+	}
+	for _, test := range outdated {
+		if rehash, err := test.hasher.Verify(test.hash, "correct horse"); err != nil || !rehash {
+			t.Fatalf("%s: the hash was not reported as outdated: %t, %v", test.name, rehash, err)
+		}
+	}
+
+	if _, err := argon2Hasher.Verify("$argon2id$v=19$m=64$salt$hash", "correct horse"); err == nil {
+		t.Fatalf("A malformed hash was accepted")
+	}
+	for _, invalid := range []Hasher{{Algorithm: "md5"}, {Algorithm: Bcrypt, BcryptCost: 32}, {Algorithm: Argon2id}} {
+		if _, err := invalid.Hash("correct horse"); err == nil {
+			t.Fatalf("The invalid hasher %+v hashed a password", invalid)
+		}
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	policy := Policy{MinLength: 8, RejectCommon: true}
+	tests := []struct {
+		name     string
+		password string
+		want     error
+	}{
+		{"john", "correct horse", nil},
+		{"john", "", ErrTooShort},
+		{"john", "äöüäöü!", ErrTooShort},
+		{"john", "äöüäöü!?", nil},
+		{"john", "Password1", ErrCommon},
+		{"john", "QWERTYUIOP", ErrCommon},
+		{"john", "John-1234-Doe", ErrContainsName},
+		{"", "John-1234-Doe", nil},
+	}
+	for _, test := range tests {
+		if err := policy.Check(test.name, test.password); !errors.Is(err, test.want) {
+			t.Errorf("The password %q of %q returned %v, want %v", test.password, test.name, err, test.want)
+		}
+	}
+	if err := (Policy{}).Check("john", "password"); err != nil {
+		t.Errorf("An empty policy rejected a password: %v", err)
+	}
+	if err := (Policy{}).Check("john", ""); !errors.Is(err, ErrTooShort) {
+		t.Errorf("An empty policy accepted an empty password: %v", err)
+	}
+}
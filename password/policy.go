@@ -0,0 +1,62 @@
+package password
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// commonPasswords is a list of frequently used and breached passwords, one per line in lower case.
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+var commonPasswordSet = sync.OnceValue(func() map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, line := range strings.Split(commonPasswords, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			set[line] = struct{}{}
+		}
+	}
+	return set
+})
+
+// The errors returned by [Policy.Check]. They are meant to be shown to the user.
+var (
+	ErrTooShort     = errors.New("The password is too short")
+	ErrCommon       = errors.New("The password is too common, it is on a list of breached passwords.")
+	ErrContainsName = errors.New("The password must not contain the user name.")
+)
+
+// Policy is what new passwords have to fulfil. Existing passwords are not checked again, so that a stricter policy
+// does not lock users out.
+type Policy struct {
+	// MinLength is the minimum number of characters.
+	MinLength int
+	// RejectCommon rejects passwords on the embedded list of common passwords, ignoring the case.
+	RejectCommon bool
+}
+
+// DefaultPolicy is the [Policy] applied unless it is configured otherwise.
+var DefaultPolicy = Policy{MinLength: 8, RejectCommon: true}
+
+// Check returns an error if the new password of the user called name violates the policy. The user name is always
+// rejected as part of the password, an empty name skips this rule.
+func (p Policy) Check(name string, password string) error {
+	if utf8.RuneCountInString(password) < max(p.MinLength, 1) {
+		return fmt.Errorf("%w, it needs at least %d characters.", ErrTooShort, max(p.MinLength, 1))
+	}
+	lower := strings.ToLower(password)
+	if p.RejectCommon {
+		if _, ok := commonPasswordSet()[lower]; ok {
+			return ErrCommon
+		}
+	}
+	if name != "" && strings.Contains(lower, strings.ToLower(name)) {
+		return ErrContainsName
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+// Package recurrence expands recurrence rules as specified in RFC 5545 section 3.3.10. Only a subset is supported: the
+// frequencies DAILY, WEEKLY, MONTHLY and YEARLY with the parts INTERVAL, BYDAY, BYMONTHDAY, COUNT and UNTIL. Weeks
+// start on Monday.
+package recurrence
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the unit a rule repeats in.
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods limits how many periods are searched for occurrences, so that rules like the 30th of February of every
+// year end.
+const maxPeriods = 5000
+
+// untilFormat and untilDateFormat are the formats of UTC timestamps and dates in UNTIL.
+const (
+	untilFormat     = "20060102T150405Z"
+	untilDateFormat = "20060102"
+)
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday,
+}
+
+// WeekdayNum is a day of BYDAY. A non-zero Ordinal selects only the nth weekday of the month or year, counted from
+// the end if it is negative, like 2TU or -1FR.
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+func (w WeekdayNum) String() string {
+	for name, weekday := range weekdays {
+		if weekday == w.Weekday {
+			if w.Ordinal == 0 {
+				return name
+			}
+			return strconv.Itoa(w.Ordinal) + name
+		}
+	}
+	return ""
+}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []WeekdayNum
+	// ByMonthDay are days of the month, counted from the end of the month if they are negative.
+	ByMonthDay []int
+	// Count limits the number of occurrences if it is not zero.
+	Count int
+	// Until is the last time an occurrence can happen if it is not zero. If UntilDate is set, it is a date and
+	// occurrences can happen anytime on it.
+	Until     time.Time
+	UntilDate bool
+}
+
+// Parse parses a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10". An "RRULE:" prefix is ignored.
+func Parse(s string) (Rule, error) {
+	rule := Rule{Interval: 1}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(name)
+		if !ok || value == "" {
+			return rule, fmt.Errorf("invalid rule part %q", part)
+		}
+		if seen[name] {
+			return rule, fmt.Errorf("%s is given twice", name)
+		}
+		seen[name] = true
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(value))
+			if !slices.Contains([]Frequency{Daily, Weekly, Monthly, Yearly}, rule.Freq) {
+				err = fmt.Errorf("unsupported frequency %q", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err == nil && rule.Interval < 1 {
+				err = fmt.Errorf("the interval has to be positive")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err == nil && rule.Count < 1 {
+				err = fmt.Errorf("the count has to be positive")
+			}
+		case "UNTIL":
+			rule.Until, rule.UntilDate, err = parseUntil(value)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseByMonthDay(value)
+		default:
+			err = fmt.Errorf("unsupported rule part %s", name)
+		}
+		if err != nil {
+			return rule, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if rule.Freq == "" {
+		return rule, fmt.Errorf("FREQ is missing")
+	}
+	if rule.Count != 0 && !rule.Until.IsZero() {
+		return rule, fmt.Errorf("COUNT and UNTIL can't be combined")
+	}
+	if rule.Freq == Daily || rule.Freq == Weekly {
+		for _, day := range rule.ByDay {
+			if day.Ordinal != 0 {
+				return rule, fmt.Errorf("BYDAY can't have an ordinal with the frequency %s", rule.Freq)
+			}
+		}
+	}
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(untilFormat, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(untilDateFormat, value)
+	if err != nil {
+		return t, false, fmt.Errorf("%q is neither a UTC timestamp like 20060102T150405Z nor a date like 20060102", value)
+	}
+	return t, true, nil
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(strings.ToUpper(value), ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		weekday, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		day := WeekdayNum{Weekday: weekday}
+		if ordinal := item[:len(item)-2]; ordinal != "" {
+			n, err := strconv.Atoi(ordinal)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid weekday %q", item)
+			}
+			day.Ordinal = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	var days []int
+	for _, item := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(item)
+		if err != nil || day == 0 || day < -31 || day > 31 {
+			return nil, fmt.Errorf("invalid day of the month %q", item)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// String formats the rule in the syntax [Parse] accepts.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.UntilDate {
+		parts = append(parts, "UNTIL="+r.Until.Format(untilDateFormat))
+	} else if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilFormat))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences returns up to n occurrences of the rule for a series starting at dtstart, in the location of dtstart.
+// Occurrences have the time of day of dtstart and dtstart is only an occurrence itself if it matches the rule.
+func (r Rule) Occurrences(dtstart time.Time, n int) []time.Time {
+	if r.Count > 0 && r.Count < n {
+		n = r.Count
+	}
+	var occurrences []time.Time
+	for period := 0; period < maxPeriods && len(occurrences) < n; period++ {
+		for _, t := range r.candidates(dtstart, period*r.interval()) {
+			if t.Before(dtstart) {
+				continue
+			}
+			if r.after(t) {
+				return occurrences
+			}
+			occurrences = append(occurrences, t)
+			if len(occurrences) == n {
+				break
+			}
+		}
+	}
+	return occurrences
+}
+
+// Next returns the first occurrence after dtstart, which is taken as an occurrence itself, together with the rule for
+// the rest of the series starting at the returned occurrence. It returns false if the series ends with dtstart.
+func (r Rule) Next(dtstart time.Time) (time.Time, Rule, bool) {
+	if r.Count == 1 {
+		return time.Time{}, r, false
+	}
+	rest := r
+	rest.Count = 0
+	for _, t := range rest.Occurrences(dtstart, 2) {
+		if t.After(dtstart) {
+			if r.Count > 0 {
+				rest.Count = r.Count - 1
+			}
+			return t, rest, true
+		}
+	}
+	return time.Time{}, r, false
+}
+
+func (r Rule) interval() int {
+	return max(r.Interval, 1)
+}
+
+// after reports whether t is after the end of the series given by UNTIL.
+func (r Rule) after(t time.Time) bool {
+	if r.Until.IsZero() {
+		return false
+	}
+	if r.UntilDate {
+		year, month, day := t.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).After(r.Until)
+	}
+	return t.After(r.Until)
+}
+
+// candidates returns the times in the period offset periods after the one of dtstart that match the rule, in order.
+func (r Rule) candidates(dtstart time.Time, offset int) []time.Time {
+	year, month, day := dtstart.Date()
+	var first time.Time
+	var days int
+	switch r.Freq {
+	case Daily:
+		first, days = time.Date(year, month, day+offset, 0, 0, 0, 0, time.UTC), 1
+	case Weekly:
+		sinceMonday := (int(dtstart.Weekday()) + 6) % 7
+		first, days = time.Date(year, month, day-sinceMonday+7*offset, 0, 0, 0, 0, time.UTC), 7
+	case Monthly:
+		first = time.Date(year, month+time.Month(offset), 1, 0, 0, 0, 0, time.UTC)
+		days = first.AddDate(0, 1, -1).Day()
+	case Yearly:
+		first = time.Date(year+offset, time.January, 1, 0, 0, 0, 0, time.UTC)
+		days = time.Date(year+offset, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	}
+	hour, minute, second := dtstart.Clock()
+	var candidates []time.Time
+	for i := 0; i < days; i++ {
+		date := first.AddDate(0, 0, i)
+		if r.matches(dtstart, date, i, days) {
+			candidates = append(candidates, time.Date(date.Year(), date.Month(), date.Day(), hour, minute, second, 0, dtstart.Location()))
+		}
+	}
+	return candidates
+}
+
+// matches reports whether date, the day with index i of a period of n days, is an occurrence. Without BYDAY and
+// BYMONTHDAY the day of dtstart in its week, month or year is taken.
+func (r Rule) matches(dtstart time.Time, date time.Time, i int, n int) bool {
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		switch r.Freq {
+		case Weekly:
+			return date.Weekday() == dtstart.Weekday()
+		case Monthly:
+			return date.Day() == dtstart.Day()
+		case Yearly:
+			return date.Month() == dtstart.Month() && date.Day() == dtstart.Day()
+		}
+		return true
+	}
+	if len(r.ByMonthDay) > 0 {
+		daysInMonth := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		if !slices.ContainsFunc(r.ByMonthDay, func(day int) bool {
+			return day == date.Day() || daysInMonth+1+day == date.Day()
+		}) {
+			return false
+		}
+	}
+	if len(r.ByDay) > 0 {
+		// the ordinals count the weekdays of the month or the year
+		fromStart, fromEnd := i/7+1, -((n-1-i)/7 + 1)
+		return slices.ContainsFunc(r.ByDay, func(day WeekdayNum) bool {
+			return day.Weekday == date.Weekday() && (day.Ordinal == 0 || day.Ordinal == fromStart || day.Ordinal == fromEnd)
+		})
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	valid := []string{
+		"FREQ=DAILY",
+		"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR",
+		"FREQ=MONTHLY;BYDAY=1FR,-1SU;COUNT=10",
+		"FREQ=MONTHLY;BYMONTHDAY=-3,15",
+		"FREQ=YEARLY;UNTIL=20301231T235959Z",
+		"freq=daily;until=20240630",
+	}
+	for _, s := range valid {
+		if _, err := Parse(s); err != nil {
+			t.Errorf("Parsing %q failed: %v", s, err)
+		}
+	}
+	invalid := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240630",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTH=2",
+		"FREQ=DAILY;COUNT",
+	}
+	for _, s := range invalid {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parsing %q succeeded", s)
+		}
+	}
+}
+
+func TestRule_String(t *testing.T) {
+	tests := map[string]string{
+		"RRULE:freq=monthly;byday=mo,-1fr;interval=1": "FREQ=MONTHLY;BYDAY=MO,-1FR",
+		"FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=1,-1":     "FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=1,-1",
+		"FREQ=DAILY;UNTIL=20240630":                   "FREQ=DAILY;UNTIL=20240630",
+		"FREQ=YEARLY;UNTIL=20240630T120000Z;":         "",
+		"FREQ=YEARLY;UNTIL=20240630T120000Z":          "FREQ=YEARLY;UNTIL=20240630T120000Z",
+		"FREQ=DAILY;COUNT=3":                          "FREQ=DAILY;COUNT=3",
+	}
+	for s, want := range tests {
+		rule, err := Parse(s)
+		if want == "" {
+			if err == nil {
+				t.Errorf("Parsing %q succeeded", s)
+			}
+			continue
+		}
+		if err != nil || rule.String() != want {
+			t.Errorf("%q was formatted as %q, want %q (%v)", s, rule.String(), want, err)
+		}
+	}
+}
+
+func TestRule_Occurrences(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 0, 0, 0, newYork)
+	}
+	// most cases are examples of RFC 5545 section 3.8.5.3 starting at 9:00 in New York
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		n       int
+		want    []time.Time
+	}{
+		{"daily for 3 occurrences", "FREQ=DAILY;COUNT=3", at(1997, 9, 2), 10,
+			[]time.Time{at(1997, 9, 2), at(1997, 9, 3), at(1997, 9, 4)}},
+		{"every other day", "FREQ=DAILY;INTERVAL=2", at(1997, 9, 29), 3,
+			[]time.Time{at(1997, 9, 29), at(1997, 10, 1), at(1997, 10, 3)}},
+		{"daily across the end of daylight saving time", "FREQ=DAILY", at(1997, 10, 25), 3,
+			[]time.Time{at(1997, 10, 25), at(1997, 10, 26), at(1997, 10, 27)}},
+		{"daily until a date", "FREQ=DAILY;UNTIL=19970904", at(1997, 9, 2), 10,
+			[]time.Time{at(1997, 9, 2), at(1997, 9, 3), at(1997, 9, 4)}},
+		{"daily until a timestamp", "FREQ=DAILY;UNTIL=19970904T125959Z", at(1997, 9, 2), 10,
+			[]time.Time{at(1997, 9, 2), at(1997, 9, 3)}},
+		{"weekdays", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", at(1997, 9, 5), 3,
+			[]time.Time{at(1997, 9, 5), at(1997, 9, 8), at(1997, 9, 9)}},
+		{"weekly", "FREQ=WEEKLY;COUNT=3", at(1997, 9, 2), 10,
+			[]time.Time{at(1997, 9, 2), at(1997, 9, 9), at(1997, 9, 16)}},
+		{"weekly on Tuesday and Thursday for five weeks", "FREQ=WEEKLY;UNTIL=19971007T000000Z;BYDAY=TU,TH", at(1997, 9, 2), 20,
+			[]time.Time{at(1997, 9, 2), at(1997, 9, 4), at(1997, 9, 9), at(1997, 9, 11), at(1997, 9, 16), at(1997, 9, 18),
+				at(1997, 9, 23), at(1997, 9, 25), at(1997, 9, 30), at(1997, 10, 2)}},
+		{"every other week on Monday, Wednesday and Friday", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR", at(1997, 9, 3), 6,
+			[]time.Time{at(1997, 9, 3), at(1997, 9, 5), at(1997, 9, 15), at(1997, 9, 17), at(1997, 9, 19), at(1997, 9, 29)}},
+		{"monthly on the first Friday", "FREQ=MONTHLY;COUNT=10;BYDAY=1FR", at(1997, 9, 5), 10,
+			[]time.Time{at(1997, 9, 5), at(1997, 10, 3), at(1997, 11, 7), at(1997, 12, 5), at(1998, 1, 2), at(1998, 2, 6),
+				at(1998, 3, 6), at(1998, 4, 3), at(1998, 5, 1), at(1998, 6, 5)}},
+		{"monthly on the first and last Sunday", "FREQ=MONTHLY;INTERVAL=2;COUNT=4;BYDAY=1SU,-1SU", at(1997, 9, 7), 10,
+			[]time.Time{at(1997, 9, 7), at(1997, 9, 28), at(1997, 11, 2), at(1997, 11, 30)}},
+		{"monthly on the second-to-last Monday", "FREQ=MONTHLY;COUNT=6;BYDAY=-2MO", at(1997, 9, 22), 10,
+			[]time.Time{at(1997, 9, 22), at(1997, 10, 20), at(1997, 11, 17), at(1997, 12, 22), at(1998, 1, 19), at(1998, 2, 16)}},
+		{"monthly on the third-to-the-last day", "FREQ=MONTHLY;BYMONTHDAY=-3", at(1997, 9, 28), 6,
+			[]time.Time{at(1997, 9, 28), at(1997, 10, 29), at(1997, 11, 28), at(1997, 12, 29), at(1998, 1, 29), at(1998, 2, 26)}},
+		{"monthly on the 2nd and 15th", "FREQ=MONTHLY;COUNT=4;BYMONTHDAY=2,15", at(1997, 9, 2), 10,
+			[]time.Time{at(1997, 9, 2), at(1997, 9, 15), at(1997, 10, 2), at(1997, 10, 15)}},
+		{"monthly on the first and last day", "FREQ=MONTHLY;COUNT=4;BYMONTHDAY=1,-1", at(1997, 9, 30), 10,
+			[]time.Time{at(1997, 9, 30), at(1997, 10, 1), at(1997, 10, 31), at(1997, 11, 1)}},
+		{"every Friday the 13th", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", at(1998, 2, 13), 5,
+			[]time.Time{at(1998, 2, 13), at(1998, 3, 13), at(1998, 11, 13), at(1999, 8, 13), at(2000, 10, 13)}},
+		{"monthly skips months without the day", "FREQ=MONTHLY", at(2024, 1, 31), 4,
+			[]time.Time{at(2024, 1, 31), at(2024, 3, 31), at(2024, 5, 31), at(2024, 7, 31)}},
+		{"yearly on the 29th of February", "FREQ=YEARLY", at(2024, 2, 29), 3,
+			[]time.Time{at(2024, 2, 29), at(2028, 2, 29), at(2032, 2, 29)}},
+		{"every other year", "FREQ=YEARLY;INTERVAL=2;COUNT=3", at(1997, 6, 10), 10,
+			[]time.Time{at(1997, 6, 10), at(1999, 6, 10), at(2001, 6, 10)}},
+		{"the 20th Monday of the year", "FREQ=YEARLY;BYDAY=20MO", at(1997, 5, 19), 3,
+			[]time.Time{at(1997, 5, 19), at(1998, 5, 18), at(1999, 5, 17)}},
+		{"yearly on the first of every month", "FREQ=YEARLY;BYMONTHDAY=1", at(1997, 11, 1), 3,
+			[]time.Time{at(1997, 11, 1), at(1997, 12, 1), at(1998, 1, 1)}},
+		{"a start that does not match the rule", "FREQ=WEEKLY;BYDAY=MO", at(1997, 9, 3), 2,
+			[]time.Time{at(1997, 9, 8), at(1997, 9, 15)}},
+		{"a Monday that is the 30th", "FREQ=YEARLY;BYMONTHDAY=30;BYDAY=MO;COUNT=1", at(1997, 2, 1), 1,
+			[]time.Time{at(1997, 6, 30)}},
+	}
+	for _, test := range tests {
+		rule, err := Parse(test.rule)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got := rule.Occurrences(test.dtstart, test.n)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: expected %v but got %v", test.name, test.want, got)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(test.want[i]) || got[i].Location() != newYork {
+				t.Errorf("%s: expected %v but got %v", test.name, test.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestRule_Occurrences_Ends(t *testing.T) {
+	rule, _ := Parse("FREQ=YEARLY;BYMONTHDAY=30")
+	// February never has a 30th, so the search has to give up
+	start := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	if got := (Rule{Freq: Monthly, Interval: 12, ByMonthDay: []int{30}}).Occurrences(start, 1); len(got) != 0 {
+		t.Fatalf("Expected no occurrences but got %v", got)
+	}
+	if got := rule.Occurrences(start, 0); len(got) != 0 {
+		t.Fatalf("Expected no occurrences but got %v", got)
+	}
+}
+
+func TestRule_Next(t *testing.T) {
+	start := time.Date(2024, 1, 29, 18, 0, 0, 0, time.UTC)
+	rule, _ := Parse("FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3")
+	next, rest, ok := rule.Next(start)
+	if !ok || !next.Equal(time.Date(2024, 2, 1, 18, 0, 0, 0, time.UTC)) || rest.String() != "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=2" {
+		t.Fatalf("Unexpected next occurrence %v with %q (%t)", next, rest, ok)
+	}
+	next, rest, ok = rest.Next(next)
+	if !ok || !next.Equal(time.Date(2024, 2, 5, 18, 0, 0, 0, time.UTC)) || rest.Count != 1 {
+		t.Fatalf("Unexpected next occurrence %v with %q (%t)", next, rest, ok)
+	}
+	if _, _, ok := rest.Next(next); ok {
+		t.Fatalf("The series continued after its last occurrence")
+	}
+
+	rule, _ = Parse("FREQ=DAILY;UNTIL=20240130")
+	if next, _, ok := rule.Next(start); !ok || next.Day() != 30 {
+		t.Fatalf("Unexpected next occurrence %v (%t)", next, ok)
+	}
+	if _, _, ok := rule.Next(start.AddDate(0, 0, 1)); ok {
+		t.Fatalf("The series continued after UNTIL")
+	}
+	// a due date that does not match the rule advances to the next matching day
+	rule, _ = Parse("FREQ=MONTHLY;BYMONTHDAY=15")
+	if next, _, ok := rule.Next(start); !ok || !next.Equal(time.Date(2024, 2, 15, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected next occurrence %v (%t)", next, ok)
+	}
+}
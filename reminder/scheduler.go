@@ -0,0 +1,133 @@
+// Package reminder sends the due reminders of todos in the background.
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"todo/db"
+	"todo/logger"
+	"todo/models"
+	"todo/notify"
+)
+
+// Scheduler sends [models.Reminder]s through a [notify.Notifier] once they are due.
+type Scheduler struct {
+	Reminders db.ReminderStore
+	Todos     db.TodoStore
+	Users     db.UserStore
+	Notifier  notify.Notifier
+	// Lease is how long a reminder is claimed for sending it. A reminder that is not sent by then, for example because
+	// the process stopped, is sent again by a later run.
+	Lease time.Duration
+	// Now returns the current time. It is [time.Now] unless it is replaced, for example by a fake clock in tests.
+	Now func() time.Time
+}
+
+// DefaultLease is the [Scheduler.Lease] of a new [Scheduler].
+const DefaultLease = 5 * time.Minute
+
+// NewScheduler returns a [Scheduler] sending the reminders in store through notifier.
+func NewScheduler(store db.Store, notifier notify.Notifier) *Scheduler {
+	return &Scheduler{Reminders: store, Todos: store, Users: store, Notifier: notifier, Lease: DefaultLease, Now: time.Now}
+}
+
+// Run sends the due reminders right away and then every interval until ctx is done. Reminders that became due while
+// the process was not running are sent by the first run.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.SendDue(ctx); err != nil {
+			logger.Error(err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends the reminders that are due now and returns how many were sent. Every reminder is claimed in the store
+// for the [Scheduler.Lease] before it is sent, so that only one process sends it even if several share the store. If
+// sending fails, the claim is released and the next run tries again; if the process stops while sending, the claim
+// expires and a later run sends the reminder. Reminders of completed todos and of users without an email address are
+// marked as sent without sending them.
+//
+// A reminder is marked as sent only after it was delivered, so a process that stops in between delivers it again.
+// Every delivery of a reminder carries the same [notify.Message.Key], which lets the receiver drop the copy, so that
+// each reminder reaches its user exactly once.
+func (s *Scheduler) SendDue(ctx context.Context) (int, error) {
+	now := s.Now()
+	reminders, err := s.Reminders.GetDueReminders(now)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	var errs []error
+	for _, reminder := range reminders {
+		ok, err := s.send(ctx, reminder)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reminder %d: %w", reminder.Id, err))
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+// send claims reminder and sends it unless its todo is done or its user has no email address. It returns whether a
+// message was sent.
+func (s *Scheduler) send(ctx context.Context, reminder models.Reminder) (bool, error) {
+	todo, err := s.Todos.GetTodo(reminder.TodoId)
+	if err != nil {
+		return false, err
+	}
+	user, err := s.Users.GetUser(todo.UserId)
+	if err != nil {
+		return false, err
+	}
+	now := s.Now()
+	claimed, err := s.Reminders.ClaimReminder(reminder, now, now.Add(s.Lease))
+	if err != nil || !claimed {
+		return false, err
+	}
+	if todo.IsDone {
+		return false, s.Reminders.CompleteReminder(reminder, now)
+	}
+	if user.Email == "" {
+		logger.Warning(fmt.Sprintf("reminder %d is not sent to user %d without email address", reminder.Id, user.Id))
+		return false, s.Reminders.CompleteReminder(reminder, now)
+	}
+	// the delivery is given up before the claim expires, so that it does not overlap with the one of a later run
+	ctx, cancel := context.WithTimeout(ctx, s.Lease)
+	defer cancel()
+	if err := s.Notifier.Notify(ctx, message(user, todo, reminder)); err != nil {
+		return false, errors.Join(err, s.Reminders.ReleaseReminder(reminder))
+	}
+	return true, s.Reminders.CompleteReminder(reminder, now)
+}
+
+// message returns reminder of todo for user. The due date is given in the timezone of the user. The key of the message
+// is the same for every delivery of the reminder at its RemindAt.
+func message(user models.User, todo models.Todo, reminder models.Reminder) notify.Message {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hello %s,\n\nthis is a reminder of your todo %q", user.Name, todo.Title)
+	if todo.DueAt != nil {
+		if todo.AllDay {
+			fmt.Fprintf(&body, ", due on %s", todo.DueAt.Format(time.DateOnly))
+		} else {
+			fmt.Fprintf(&body, ", due at %s", todo.DueAt.In(user.Location()).Format("2006-01-02 15:04 MST"))
+		}
+	}
+	body.WriteString(".\n")
+	if todo.Text != "" {
+		fmt.Fprintf(&body, "\n%s\n", todo.Text)
+	}
+	key := fmt.Sprintf("reminder-%d-%d", reminder.Id, reminder.RemindAt.Unix())
+	return notify.Message{To: user.Email, Subject: "Reminder: " + todo.Title, Body: body.String(), Key: key}
+}
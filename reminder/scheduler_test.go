@@ -0,0 +1,169 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"todo/db"
+	"todo/models"
+	"todo/notify"
+	"todo/password"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// recorder is a [notify.Notifier] that records the messages it is given and fails while err is set.
+type recorder struct {
+	messages []notify.Message
+	err      error
+}
+
+func (r *recorder) Notify(ctx context.Context, msg notify.Message) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+// newStore returns an empty [db.MemoryStore] hashing passwords with the lowest bcrypt cost to keep the tests fast.
+func newStore() *db.MemoryStore {
+	store := db.NewMemoryStore()
+	store.Hasher = password.Hasher{Algorithm: password.Bcrypt, BcryptCost: bcrypt.MinCost}
+	return store
+}
+
+// clock is a fake clock that only moves when it is told to.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestScheduler_SendDue(t *testing.T) {
+	store := newStore()
+	john, _ := store.CreateUser(models.User{Name: "john", Email: "john@example.com", Timezone: "Europe/Berlin"})
+	nobody, _ := store.CreateUser(models.User{Name: "nobody"})
+	due := time.Date(2024, 6, 1, 16, 0, 0, 0, time.UTC)
+	todo, _ := store.CreateTodo(models.Todo{Title: "Pay the rent", UserId: john.Id, DueAt: &due})
+	done, _ := store.CreateTodo(models.Todo{Title: "Done", UserId: john.Id, IsDone: true})
+	unreachable, _ := store.CreateTodo(models.Todo{Title: "Unreachable", UserId: nobody.Id})
+
+	c := &clock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	minutes := 60
+	relative := models.Reminder{BeforeMinutes: &minutes}
+	relative.Schedule(todo, time.UTC)
+	relative, _ = store.CreateReminder(relative)
+	at := c.now.Add(time.Minute)
+	for _, todo := range []models.Todo{todo, done, unreachable} {
+		reminder := models.Reminder{At: &at}
+		reminder.Schedule(todo, time.UTC)
+		store.CreateReminder(reminder)
+	}
+
+	notifier := &recorder{}
+	scheduler := NewScheduler(store, notifier)
+	scheduler.Now = c.Now
+	if sent, err := scheduler.SendDue(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("Reminders were sent before they were due: %d (%v)", sent, err)
+	}
+	c.now = c.now.Add(time.Minute)
+	if sent, err := scheduler.SendDue(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("Expected one reminder to be sent but got %d (%v)", sent, err)
+	}
+	msg := notifier.messages[0]
+	if msg.To != "john@example.com" || msg.Subject != "Reminder: Pay the rent" || !strings.Contains(msg.Body, "due at 2024-06-01 18:00 CEST") {
+		t.Fatalf("Unexpected message %+v", msg)
+	}
+	if sent, _ := scheduler.SendDue(context.Background()); sent != 0 {
+		t.Fatalf("A reminder was sent twice")
+	}
+
+	// the delivery fails at the time of the relative reminder and works again later
+	c.now = time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+	notifier.err = errors.New("unreachable")
+	if sent, err := scheduler.SendDue(context.Background()); err == nil || sent != 0 {
+		t.Fatalf("A failed delivery was not reported: %d (%v)", sent, err)
+	}
+	if due, _ := store.GetDueReminders(c.now); len(due) != 1 || due[0].Id != relative.Id {
+		t.Fatalf("The reminder stayed claimed after the delivery failed: %+v", due)
+	}
+	notifier.err = nil
+	// a new scheduler on the same store, like after a restart, sends what is left exactly once
+	restarted := NewScheduler(store, notifier)
+	restarted.Now = c.Now
+	c.now = c.now.Add(time.Hour)
+	if sent, err := restarted.SendDue(context.Background()); err != nil || sent != 1 || len(notifier.messages) != 2 {
+		t.Fatalf("Expected the relative reminder to be sent once but got %d (%v)", sent, err)
+	}
+	if due, _ := store.GetDueReminders(c.now); len(due) != 0 {
+		t.Fatalf("Reminders are still pending: %+v", due)
+	}
+}
+
+func TestScheduler_SendDueExpiredClaim(t *testing.T) {
+	store := newStore()
+	john, _ := store.CreateUser(models.User{Name: "john", Email: "john@example.com"})
+	todo, _ := store.CreateTodo(models.Todo{Title: "Pay the rent", UserId: john.Id})
+	c := &clock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	reminder := models.Reminder{At: &c.now}
+	reminder.Schedule(todo, time.UTC)
+	reminder, _ = store.CreateReminder(reminder)
+
+	// a process claimed the reminder and stopped before it was marked as sent
+	notifier := &recorder{}
+	scheduler := NewScheduler(store, notifier)
+	scheduler.Now = c.Now
+	if claimed, _ := store.ClaimReminder(reminder, c.now, c.now.Add(scheduler.Lease)); !claimed {
+		t.Fatal("Claiming the reminder failed")
+	}
+	if sent, err := scheduler.SendDue(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("A reminder claimed by another process was sent: %d (%v)", sent, err)
+	}
+	c.now = c.now.Add(scheduler.Lease)
+	if sent, err := scheduler.SendDue(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("The reminder was not sent after the claim expired: %d (%v)", sent, err)
+	}
+	if key := notifier.messages[0].Key; key != message(john, todo, reminder).Key || key == "" {
+		t.Fatalf("Unexpected key %q", key)
+	}
+	if sent, _ := scheduler.SendDue(context.Background()); sent != 0 {
+		t.Fatalf("A reminder was sent twice")
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	store := newStore()
+	john, _ := store.CreateUser(models.User{Name: "john", Email: "john@example.com"})
+	todo, _ := store.CreateTodo(models.Todo{Title: "overdue", UserId: john.Id})
+	at := time.Now().Add(-time.Hour)
+	reminder := models.Reminder{At: &at}
+	reminder.Schedule(todo, time.UTC)
+	store.CreateReminder(reminder)
+
+	notifier := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		NewScheduler(store, notifier).Run(ctx, time.Hour)
+		close(stopped)
+	}()
+	// the first run happens right away, so reminders missed while the process was down are not lost
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if due, _ := store.GetDueReminders(time.Now()); len(due) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The overdue reminder was not sent")
+		}
+	}
+	cancel()
+	<-stopped
+	if len(notifier.messages) != 1 {
+		t.Fatalf("Expected one message but got %+v", notifier.messages)
+	}
+}
@@ -1,3 +0,0 @@
-#!/usr/bin/env sh
-
-sqlite3 todo.db ".read todo.sql"
\ No newline at end of file
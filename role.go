@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"todo/db"
+	"todo/models"
+)
+
+// runRole executes the "role <name> admin|member" subcommand, which sets the role of a user. It is how the first admin
+// is appointed.
+func runRole(users db.UserStore, args []string) error {
+	if len(args) != 2 || !slices.Contains(models.Roles, models.Role(args[1])) {
+		return errors.New("usage: todo role <name> admin|member")
+	}
+	all, err := users.GetUsers()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(all, func(user models.User) bool { return user.Name == args[0] })
+	if i < 0 {
+		return fmt.Errorf("unknown user %q", args[0])
+	}
+	if err := users.SetUserRole(all[i].Id, models.Role(args[1])); err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", args[0], args[1])
+	return nil
+}
@@ -0,0 +1,58 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 secret of the test vectors in RFC 6238 appendix B.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// the last six digits of the eight digit codes in RFC 6238 appendix B
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, test := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(test.unix, 0)))
+		if err != nil || code != test.code {
+			t.Errorf("Expected %s at %d but got %s (%v)", test.code, test.unix, code, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := Step(now)
+	previous, _ := Code(rfcSecret, step-1)
+	if got, ok := Validate(rfcSecret, previous, now, 0); !ok || got != step-1 {
+		t.Fatalf("The code of the previous period was rejected")
+	}
+	if _, ok := Validate(rfcSecret, previous, now, step-1); ok {
+		t.Fatalf("A code was accepted twice")
+	}
+	old, _ := Code(rfcSecret, step-2)
+	if _, ok := Validate(rfcSecret, old, now, 0); ok {
+		t.Fatalf("A code of two periods ago was accepted")
+	}
+	if _, ok := Validate(rfcSecret, "000000", now, 0); ok {
+		t.Fatalf("A wrong code was accepted")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("todo", "john doe", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/todo:john%20doe?") || !strings.Contains(uri, "secret=ABC") {
+		t.Fatalf("Unexpected URI %s", uri)
+	}
+}